@@ -0,0 +1,217 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/handlers"
+)
+
+// apiClient calls the server's /admin API with an admin API key
+type apiClient struct {
+	baseURL    string
+	apiKey     string
+	totpCode   string
+	httpClient *http.Client
+}
+
+func newAPIClient(baseURL, apiKey, totpCode string) *apiClient {
+	return &apiClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		totpCode:   totpCode,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends a request and decodes a successful JSON response into out
+func (c *apiClient) do(method, path string, body, out any) error {
+	return c.send(c.httpClient, method, path, body, func(r io.Reader) error {
+		if out == nil {
+			return nil
+		}
+		if err := json.NewDecoder(r).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+		return nil
+	})
+}
+
+// send issues an authenticated request and passes a successful response
+// body to handle; error responses are turned into errors
+func (c *apiClient) send(httpClient *http.Client, method, path string, body any, handle func(io.Reader) error) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("User-Agent", "proxyctl")
+	if c.totpCode != "" {
+		req.Header.Set("X-TOTP-Code", c.totpCode)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Message != "" {
+			return fmt.Errorf("%s %s: %s: %s", method, path, apiErr.Error, apiErr.Message)
+		}
+		if apiErr.Error != "" {
+			return fmt.Errorf("%s %s: %s", method, path, apiErr.Error)
+		}
+		return fmt.Errorf("%s %s: HTTP %d", method, path, resp.StatusCode)
+	}
+
+	return handle(resp.Body)
+}
+
+func (c *apiClient) ListKeys() ([]handlers.KeyInfo, error) {
+	var resp struct {
+		Keys []handlers.KeyInfo `json:"keys"`
+	}
+	if err := c.do(http.MethodGet, "/admin/keys", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+func (c *apiClient) CreateKey(req handlers.CreateKeyRequest) (*handlers.IssuedKey, error) {
+	var issued handlers.IssuedKey
+	if err := c.do(http.MethodPost, "/admin/keys", req, &issued); err != nil {
+		return nil, err
+	}
+	return &issued, nil
+}
+
+func (c *apiClient) RevokeKey(keyID int64) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/admin/keys/%d", keyID), nil, nil)
+}
+
+func (c *apiClient) RotateKey(keyID int64) (*handlers.IssuedKey, error) {
+	var issued handlers.IssuedKey
+	if err := c.do(http.MethodPost, fmt.Sprintf("/admin/keys/%d/rotate", keyID), nil, &issued); err != nil {
+		return nil, err
+	}
+	return &issued, nil
+}
+
+func (c *apiClient) CreateSigV4(keyID int64) (*handlers.IssuedSigV4Credential, error) {
+	var issued handlers.IssuedSigV4Credential
+	if err := c.do(http.MethodPost, fmt.Sprintf("/admin/keys/%d/sigv4", keyID), nil, &issued); err != nil {
+		return nil, err
+	}
+	return &issued, nil
+}
+
+func (c *apiClient) ListSigV4(keyID int64) ([]handlers.SigV4CredentialInfo, error) {
+	var resp struct {
+		Credentials []handlers.SigV4CredentialInfo `json:"credentials"`
+	}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/admin/keys/%d/sigv4", keyID), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Credentials, nil
+}
+
+func (c *apiClient) RevokeSigV4(keyID int64, accessKeyID string) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/admin/keys/%d/sigv4/%s", keyID, url.PathEscape(accessKeyID)), nil, nil)
+}
+
+func (c *apiClient) EnrollTOTP(keyID int64, force bool) (*handlers.TOTPEnrollment, error) {
+	path := fmt.Sprintf("/admin/keys/%d/2fa", keyID)
+	if force {
+		path += "?force=true"
+	}
+
+	var enrollment handlers.TOTPEnrollment
+	if err := c.do(http.MethodPost, path, nil, &enrollment); err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+func (c *apiClient) ListSessions(keyID int64) ([]handlers.SessionInfo, error) {
+	var resp struct {
+		Sessions []handlers.SessionInfo `json:"sessions"`
+	}
+	if err := c.do(http.MethodGet, fmt.Sprintf("/admin/keys/%d/sessions", keyID), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Sessions, nil
+}
+
+func (c *apiClient) RevokeSession(keyID, sessionID int64) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("/admin/keys/%d/sessions/%d", keyID, sessionID), nil, nil)
+}
+
+func (c *apiClient) Audit(filter auth.AuditFilter) ([]auth.AuditEntry, error) {
+	var resp struct {
+		Entries []auth.AuditEntry `json:"entries"`
+	}
+	if err := c.do(http.MethodGet, "/admin/audit?"+filter.Values().Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+func (c *apiClient) ExportAudit(filter auth.AuditFilter, format string, w io.Writer) error {
+	query := filter.Values()
+	query.Set("format", format)
+
+	// Exports can be large; rely on the server to stream rather than a timeout
+	streaming := *c.httpClient
+	streaming.Timeout = 0
+	return c.send(&streaming, http.MethodGet, "/admin/audit/export?"+query.Encode(), nil, func(body io.Reader) error {
+		_, err := io.Copy(w, body)
+		return err
+	})
+}
+
+func (c *apiClient) VerifyAudit(keys []ed25519.PublicKey) (*auth.AuditVerifyReport, error) {
+	if len(keys) > 0 {
+		return nil, errors.New("--public-key needs direct database access; the server verifies with its own keys")
+	}
+
+	var report auth.AuditVerifyReport
+	if err := c.do(http.MethodGet, "/admin/audit/verify", nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (c *apiClient) Close() error {
+	return nil
+}
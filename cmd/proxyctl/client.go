@@ -0,0 +1,334 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/config"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/handlers"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+// client performs admin operations against the database or the admin API
+type client interface {
+	ListKeys() ([]handlers.KeyInfo, error)
+	CreateKey(req handlers.CreateKeyRequest) (*handlers.IssuedKey, error)
+	RevokeKey(keyID int64) error
+	RotateKey(keyID int64) (*handlers.IssuedKey, error)
+	CreateSigV4(keyID int64) (*handlers.IssuedSigV4Credential, error)
+	ListSigV4(keyID int64) ([]handlers.SigV4CredentialInfo, error)
+	RevokeSigV4(keyID int64, accessKeyID string) error
+	EnrollTOTP(keyID int64, force bool) (*handlers.TOTPEnrollment, error)
+	ListSessions(keyID int64) ([]handlers.SessionInfo, error)
+	RevokeSession(keyID, sessionID int64) error
+	Audit(filter auth.AuditFilter) ([]auth.AuditEntry, error)
+	ExportAudit(filter auth.AuditFilter, format string, w io.Writer) error
+	VerifyAudit(keys []ed25519.PublicKey) (*auth.AuditVerifyReport, error)
+	Close() error
+}
+
+// dbClient works directly on the auth database
+type dbClient struct {
+	apiKeyDB       *auth.APIKeyDB
+	sessionManager *auth.SessionManager
+	totpManager    *auth.TOTPManager
+}
+
+// openDBClient opens the auth database of the server config at configPath
+// (environment overrides included), or the SQLite file at dbPath. It never
+// migrates: pending migrations must be applied by the server first.
+func openDBClient(configPath, dbPath string) (*dbClient, error) {
+	serverConfig, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := storageConfig(serverConfig.Auth.Database, dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := storage.Open(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open auth database: %w", err)
+	}
+
+	migrator, err := auth.NewMigrator(store)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	pending, err := migrator.Pending()
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to read migration status: %w", err)
+	}
+	if len(pending) > 0 {
+		store.Close()
+		return nil, fmt.Errorf("auth database has %d pending migrations; run \"bedrock-proxy migrate up\"", len(pending))
+	}
+
+	apiKeyDB, err := auth.NewAPIKeyDBWithStore(store)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	if pepperFile := serverConfig.Auth.APIKeyPepperFile; pepperFile != "" {
+		if err := apiKeyDB.LoadPepperFile(pepperFile); err != nil {
+			apiKeyDB.Close()
+			return nil, err
+		}
+	}
+
+	totp := serverConfig.Auth.TOTP
+	totpOptions := auth.TOTPOptions{
+		MaxFailedAttempts: totp.MaxFailedAttempts,
+		LockoutDuration:   totp.LockoutDuration,
+	}
+	if totp.MasterKeyFile != "" {
+		keyring, err := auth.LoadKeyring(totp.MasterKeyFile, totp.PreviousMasterKeyFiles...)
+		if err != nil {
+			apiKeyDB.Close()
+			return nil, fmt.Errorf("failed to load TOTP master key: %w", err)
+		}
+		totpOptions.Keyring = keyring
+		apiKeyDB.SetKeyring(keyring)
+	}
+
+	return &dbClient{
+		apiKeyDB:       apiKeyDB,
+		sessionManager: auth.NewSessionManager(apiKeyDB.DB()),
+		totpManager:    auth.NewTOTPManagerWithOptions(apiKeyDB.DB(), totpOptions),
+	}, nil
+}
+
+// storageConfig selects the auth database like the server does; dbPath
+// overrides it with a SQLite file
+func storageConfig(db config.DatabaseConfig, dbPath string) (storage.Config, error) {
+	if dbPath != "" {
+		db.Backend, db.Path = storage.BackendSQLite, dbPath
+	}
+	cfg := storage.Config{Backend: db.Backend}
+
+	switch db.Backend {
+	case storage.BackendSQLite:
+		cfg.DSN = db.Path
+		if cfg.DSN == "" {
+			return cfg, errors.New("no auth database configured (use --config, --db, AUTH_DB_PATH or --server)")
+		}
+	case storage.BackendPostgres:
+		cfg.DSN = db.DSN
+		if db.DSNFile != "" {
+			data, err := os.ReadFile(db.DSNFile)
+			if err != nil {
+				return cfg, fmt.Errorf("failed to read auth database DSN file: %w", err)
+			}
+			cfg.DSN = strings.TrimSpace(string(data))
+		}
+		if cfg.DSN == "" {
+			return cfg, errors.New("the postgres auth database requires auth.database.dsn or dsn_file")
+		}
+	default:
+		return cfg, fmt.Errorf("unsupported auth database backend for proxyctl: %q (use sqlite or postgres)", db.Backend)
+	}
+
+	return cfg, nil
+}
+
+func (c *dbClient) ListKeys() ([]handlers.KeyInfo, error) {
+	keys, err := c.apiKeyDB.ListAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]handlers.KeyInfo, 0, len(keys))
+	for i := range keys {
+		result = append(result, handlers.NewKeyInfo(&keys[i]))
+	}
+	return result, nil
+}
+
+func (c *dbClient) CreateKey(req handlers.CreateKeyRequest) (*handlers.IssuedKey, error) {
+	var expiresIn *time.Duration
+	if req.ExpiresInDays > 0 {
+		d := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+		expiresIn = &d
+	}
+
+	apiKey, key, err := c.apiKeyDB.CreateAPIKeyWithSettings(req.Name, req.Email, req.Description, expiresIn, auth.KeySettings{
+		Permissions: req.Permissions,
+		Tags:        req.Tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.audit("admin_key_created", map[string]any{"api_key_id": key.ID, "email": req.Email})
+	return &handlers.IssuedKey{APIKey: apiKey, Key: handlers.NewKeyInfo(key)}, nil
+}
+
+func (c *dbClient) RevokeKey(keyID int64) error {
+	if _, err := c.apiKeyDB.GetAPIKeyByID(keyID); err != nil {
+		return err
+	}
+	if err := c.apiKeyDB.RevokeAPIKey(keyID); err != nil {
+		return err
+	}
+	if err := c.sessionManager.RevokeAllUserSessions(keyID); err != nil {
+		return err
+	}
+
+	c.audit("admin_key_revoked", map[string]any{"api_key_id": keyID})
+	return nil
+}
+
+func (c *dbClient) RotateKey(keyID int64) (*handlers.IssuedKey, error) {
+	apiKey, key, err := c.apiKeyDB.RotateAPIKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.audit("admin_key_rotated", map[string]any{"api_key_id": keyID, "new_api_key_id": key.ID})
+	return &handlers.IssuedKey{APIKey: apiKey, Key: handlers.NewKeyInfo(key)}, nil
+}
+
+func (c *dbClient) CreateSigV4(keyID int64) (*handlers.IssuedSigV4Credential, error) {
+	if _, err := c.apiKeyDB.GetAPIKeyByID(keyID); err != nil {
+		return nil, err
+	}
+
+	accessKeyID, secret, err := c.apiKeyDB.GenerateSigV4Credentials(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.audit("admin_sigv4_created", map[string]any{"api_key_id": keyID, "access_key_id": accessKeyID})
+	return &handlers.IssuedSigV4Credential{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secret,
+		APIKeyID:        keyID,
+	}, nil
+}
+
+func (c *dbClient) ListSigV4(keyID int64) ([]handlers.SigV4CredentialInfo, error) {
+	if _, err := c.apiKeyDB.GetAPIKeyByID(keyID); err != nil {
+		return nil, err
+	}
+	creds, err := c.apiKeyDB.ListSigV4Credentials(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]handlers.SigV4CredentialInfo, 0, len(creds))
+	for i := range creds {
+		result = append(result, handlers.NewSigV4CredentialInfo(&creds[i]))
+	}
+	return result, nil
+}
+
+func (c *dbClient) RevokeSigV4(keyID int64, accessKeyID string) error {
+	if err := c.apiKeyDB.RevokeSigV4Credential(keyID, accessKeyID); err != nil {
+		return err
+	}
+
+	c.audit("admin_sigv4_revoked", map[string]any{"api_key_id": keyID, "access_key_id": accessKeyID})
+	return nil
+}
+
+func (c *dbClient) EnrollTOTP(keyID int64, force bool) (*handlers.TOTPEnrollment, error) {
+	key, err := c.apiKeyDB.GetAPIKeyByID(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := c.totpManager.IsTOTPEnabled(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled && !force {
+		return nil, errors.New("2FA already enabled for this key (use --force to replace it)")
+	}
+
+	account := key.Email
+	if account == "" {
+		account = key.Name
+	}
+	otpKey, backupCodes, err := c.totpManager.GenerateTOTP(keyID, account, handlers.TOTPIssuer)
+	if err != nil {
+		return nil, err
+	}
+
+	c.audit("admin_2fa_enrolled", map[string]any{"api_key_id": keyID})
+	return &handlers.TOTPEnrollment{
+		APIKeyID:    keyID,
+		OTPAuthURI:  otpKey.URL(),
+		Secret:      otpKey.Secret(),
+		Issuer:      handlers.TOTPIssuer,
+		Account:     account,
+		BackupCodes: backupCodes,
+	}, nil
+}
+
+func (c *dbClient) ListSessions(keyID int64) ([]handlers.SessionInfo, error) {
+	sessions, err := c.sessionManager.ListUserSessions(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]handlers.SessionInfo, 0, len(sessions))
+	for i := range sessions {
+		result = append(result, handlers.NewSessionInfo(&sessions[i]))
+	}
+	return result, nil
+}
+
+func (c *dbClient) RevokeSession(keyID, sessionID int64) error {
+	if err := c.sessionManager.RevokeUserSession(keyID, sessionID); err != nil {
+		return err
+	}
+
+	c.audit("admin_session_revoked", map[string]any{"api_key_id": keyID, "session_id": sessionID})
+	return nil
+}
+
+func (c *dbClient) Audit(filter auth.AuditFilter) ([]auth.AuditEntry, error) {
+	return c.apiKeyDB.QueryAudit(filter)
+}
+
+func (c *dbClient) ExportAudit(filter auth.AuditFilter, format string, w io.Writer) error {
+	writer, err := auth.NewAuditWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	c.audit("admin_audit_exported", map[string]any{"format": format, "filter": filter.Values().Encode()})
+
+	if err := c.apiKeyDB.ExportAudit(filter, writer.Write); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func (c *dbClient) VerifyAudit(keys []ed25519.PublicKey) (*auth.AuditVerifyReport, error) {
+	return c.apiKeyDB.VerifyAudit(keys)
+}
+
+func (c *dbClient) Close() error {
+	return c.apiKeyDB.Close()
+}
+
+// audit records a proxyctl action; there is no caller key in direct mode
+func (c *dbClient) audit(action string, metadata map[string]any) {
+	metadata["via"] = "proxyctl"
+	meta, _ := json.Marshal(metadata)
+	c.apiKeyDB.LogAPIKeyUsage(0, action, "", "proxyctl", "", 0, string(meta))
+}
@@ -0,0 +1,716 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/handlers"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/router"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tags"
+)
+
+// runKeys implements "keys create|list|revoke|rotate|sigv4"
+func runKeys(opts *options, sub string, args []string) error {
+	if sub == "sigv4" {
+		if len(args) == 0 {
+			return errUsage
+		}
+		return runKeySigV4(opts, args[0], args[1:])
+	}
+	fs := newFlagSet(opts, "keys "+sub)
+
+	switch sub {
+	case "create":
+		var req handlers.CreateKeyRequest
+		var permissions, tagSpec string
+		fs.StringVar(&req.Name, "name", "", "key name (required)")
+		fs.StringVar(&req.Email, "email", "", "owner email")
+		fs.StringVar(&req.Description, "description", "", "description")
+		fs.IntVar(&req.ExpiresInDays, "expires-days", 0, "expire after this many days (0 never expires)")
+		fs.StringVar(&permissions, "permissions", "", "comma-separated permissions")
+		fs.StringVar(&tagSpec, "tags", "", "default cost-center tags (key=value,...)")
+		if err := fs.Parse(args); err != nil || req.Name == "" || fs.NArg() != 0 {
+			return errUsage
+		}
+		if permissions != "" {
+			req.Permissions = splitList(permissions)
+		}
+		if tagSpec != "" {
+			var err error
+			if req.Tags, err = tags.Parse(tagSpec); err != nil {
+				return err
+			}
+		}
+
+		return withClient(opts, func(c client) error {
+			issued, err := c.CreateKey(req)
+			if err != nil {
+				return err
+			}
+			return printIssuedKey(opts, issued)
+		})
+
+	case "list":
+		var all bool
+		fs.BoolVar(&all, "all", false, "include revoked keys")
+		if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+			return errUsage
+		}
+
+		return withClient(opts, func(c client) error {
+			keys, err := c.ListKeys()
+			if err != nil {
+				return err
+			}
+
+			result := make([]handlers.KeyInfo, 0, len(keys))
+			for _, key := range keys {
+				if all || key.IsActive {
+					result = append(result, key)
+				}
+			}
+
+			if opts.json {
+				return opts.printJSON(result)
+			}
+			w := opts.table()
+			fmt.Fprintln(w, "ID\tNAME\tEMAIL\tPREFIX\tSTATUS\tTEAM\tPERMISSIONS\tLAST USED\tEXPIRES")
+			for _, key := range result {
+				status := "active"
+				if !key.IsActive {
+					status = "revoked"
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					key.ID, key.Name, key.Email, key.KeyPrefix, status, teamColumn(key.TeamID),
+					strings.Join(key.Permissions, ","), formatTime(key.LastUsedAt), formatTime(key.ExpiresAt))
+			}
+			return w.Flush()
+		})
+
+	case "revoke":
+		keyID, err := parseIDs(fs, args, 1)
+		if err != nil {
+			return err
+		}
+
+		return withClient(opts, func(c client) error {
+			if err := c.RevokeKey(keyID[0]); err != nil {
+				return err
+			}
+			return printResult(opts, "revoked", fmt.Sprintf("Key %d revoked", keyID[0]))
+		})
+
+	case "rotate":
+		keyID, err := parseIDs(fs, args, 1)
+		if err != nil {
+			return err
+		}
+
+		return withClient(opts, func(c client) error {
+			issued, err := c.RotateKey(keyID[0])
+			if err != nil {
+				return err
+			}
+			return printIssuedKey(opts, issued)
+		})
+	}
+
+	return errUsage
+}
+
+// runKeySigV4 implements "keys sigv4 create|list|revoke"
+func runKeySigV4(opts *options, sub string, args []string) error {
+	fs := newFlagSet(opts, "keys sigv4 "+sub)
+
+	switch sub {
+	case "create":
+		keyID, err := parseIDs(fs, args, 1)
+		if err != nil {
+			return err
+		}
+
+		return withClient(opts, func(c client) error {
+			issued, err := c.CreateSigV4(keyID[0])
+			if err != nil {
+				return err
+			}
+
+			if opts.json {
+				return opts.printJSON(issued)
+			}
+			fmt.Fprintf(opts.stdout, "Key ID:            %d\n", issued.APIKeyID)
+			fmt.Fprintf(opts.stdout, "Access key ID:     %s\n", issued.AccessKeyID)
+			fmt.Fprintf(opts.stdout, "Secret access key: %s\n", issued.SecretAccessKey)
+			fmt.Fprintln(opts.stdout, "\nStore the secret securely; it will not be shown again.")
+			return nil
+		})
+
+	case "list":
+		keyID, err := parseIDs(fs, args, 1)
+		if err != nil {
+			return err
+		}
+
+		return withClient(opts, func(c client) error {
+			creds, err := c.ListSigV4(keyID[0])
+			if err != nil {
+				return err
+			}
+			if creds == nil {
+				creds = []handlers.SigV4CredentialInfo{}
+			}
+
+			if opts.json {
+				return opts.printJSON(creds)
+			}
+			w := opts.table()
+			fmt.Fprintln(w, "ACCESS KEY ID\tSTATUS\tCREATED")
+			for _, cred := range creds {
+				status := "active"
+				if !cred.IsActive {
+					status = "revoked"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", cred.AccessKeyID, status, formatTime(&cred.CreatedAt))
+			}
+			return w.Flush()
+		})
+
+	case "revoke":
+		positional, err := parseArgs(fs, args)
+		if err != nil || len(positional) != 2 {
+			return errUsage
+		}
+		keyID, err := strconv.ParseInt(positional[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid ID %q", positional[0])
+		}
+		accessKeyID := positional[1]
+
+		return withClient(opts, func(c client) error {
+			if err := c.RevokeSigV4(keyID, accessKeyID); err != nil {
+				return err
+			}
+			return printResult(opts, "revoked", fmt.Sprintf("Access key %s of key %d revoked", accessKeyID, keyID))
+		})
+	}
+
+	return errUsage
+}
+
+// runTOTP implements "totp enroll"
+func runTOTP(opts *options, sub string, args []string) error {
+	if sub != "enroll" {
+		return errUsage
+	}
+
+	fs := newFlagSet(opts, "totp enroll")
+	force := fs.Bool("force", false, "replace an existing 2FA secret")
+	keyID, err := parseIDs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	return withClient(opts, func(c client) error {
+		enrollment, err := c.EnrollTOTP(keyID[0], *force)
+		if err != nil {
+			return err
+		}
+
+		if opts.json {
+			return opts.printJSON(enrollment)
+		}
+
+		fmt.Fprintf(opts.stdout, "Scan with an authenticator app (%s / %s):\n\n", enrollment.Issuer, enrollment.Account)
+		if err := writeQR(opts.stdout, enrollment.OTPAuthURI); err != nil {
+			return err
+		}
+		fmt.Fprintf(opts.stdout, "\nSecret: %s\n\n", enrollment.Secret)
+		fmt.Fprintln(opts.stdout, "Backup codes (shown only once):")
+		for _, code := range enrollment.BackupCodes {
+			fmt.Fprintf(opts.stdout, "  %s\n", code)
+		}
+		return nil
+	})
+}
+
+// runSessions implements "sessions list|revoke"
+func runSessions(opts *options, sub string, args []string) error {
+	fs := newFlagSet(opts, "sessions "+sub)
+
+	switch sub {
+	case "list":
+		keyID, err := parseIDs(fs, args, 1)
+		if err != nil {
+			return err
+		}
+
+		return withClient(opts, func(c client) error {
+			sessions, err := c.ListSessions(keyID[0])
+			if err != nil {
+				return err
+			}
+			if sessions == nil {
+				sessions = []handlers.SessionInfo{}
+			}
+
+			if opts.json {
+				return opts.printJSON(sessions)
+			}
+			w := opts.table()
+			fmt.Fprintln(w, "ID\tFAMILY\tIP\tUSER AGENT\tCREATED\tLAST USED\tEXPIRES")
+			for _, s := range sessions {
+				fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+					s.ID, s.FamilyID, s.IPAddress, s.UserAgent,
+					formatTime(&s.CreatedAt), formatTime(s.LastUsedAt), formatTime(&s.ExpiresAt))
+			}
+			return w.Flush()
+		})
+
+	case "revoke":
+		ids, err := parseIDs(fs, args, 2)
+		if err != nil {
+			return err
+		}
+
+		return withClient(opts, func(c client) error {
+			if err := c.RevokeSession(ids[0], ids[1]); err != nil {
+				return err
+			}
+			return printResult(opts, "revoked", fmt.Sprintf("Session %d of key %d revoked", ids[1], ids[0]))
+		})
+	}
+
+	return errUsage
+}
+
+// runAudit implements "audit tail|filter|export|verify"
+func runAudit(opts *options, sub string, args []string) error {
+	if sub == "verify" {
+		return runAuditVerify(opts, args)
+	}
+	fs := newFlagSet(opts, "audit "+sub)
+
+	var filter auth.AuditFilter
+	var since, until, status string
+	fs.Int64Var(&filter.APIKeyID, "key-id", 0, "only records of this API key")
+	fs.Int64Var(&filter.TeamID, "team-id", 0, "only records of this team")
+	fs.StringVar(&filter.Action, "action", "", "action, or prefix ending in * (e.g. 2fa_*)")
+	fs.StringVar(&filter.Path, "path", "", "request path, or prefix ending in * (e.g. /admin/*)")
+	fs.StringVar(&filter.IPAddress, "ip", "", "client IP address")
+	fs.StringVar(&status, "status", "", "status code, class or range (404, 5xx, 400-499)")
+	fs.StringVar(&since, "since", "", "RFC 3339 time or duration ago (e.g. 24h)")
+	fs.StringVar(&until, "until", "", "RFC 3339 time or duration ago")
+
+	var follow bool
+	var interval time.Duration
+	var cursor, order, format, output string
+	switch sub {
+	case "tail":
+		fs.IntVar(&filter.Limit, "n", 20, "number of recent records")
+		fs.BoolVar(&follow, "f", false, "keep printing new records")
+		fs.DurationVar(&interval, "interval", 2*time.Second, "poll interval with -f")
+	case "filter":
+		fs.IntVar(&filter.Limit, "limit", 100, "records per page")
+		fs.StringVar(&order, "order", "desc", "asc (oldest first) or desc")
+		fs.StringVar(&cursor, "cursor", "", "next_cursor from the previous page")
+	case "export":
+		fs.StringVar(&format, "format", auth.AuditFormatJSONL, "jsonl or csv")
+		fs.StringVar(&output, "o", "", "write to this file instead of stdout")
+	default:
+		return errUsage
+	}
+
+	if positional, err := parseArgs(fs, args); err != nil || len(positional) != 0 {
+		return errUsage
+	}
+
+	var err error
+	if filter.Since, err = parseTimeFlag(since); err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	if filter.Until, err = parseTimeFlag(until); err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+	if status != "" {
+		if filter.StatusMin, filter.StatusMax, err = auth.ParseStatusRange(status); err != nil {
+			return err
+		}
+	}
+	switch order {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return fmt.Errorf("invalid --order %q (use asc or desc)", order)
+	}
+	if cursor != "" {
+		if err := filter.ApplyCursor(cursor); err != nil {
+			return err
+		}
+	}
+
+	switch sub {
+	case "export":
+		return withClient(opts, func(c client) error {
+			w := opts.stdout
+			if output != "" {
+				file, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				w = file
+			}
+			return c.ExportAudit(filter, format, w)
+		})
+
+	case "filter":
+		return withClient(opts, func(c client) error {
+			entries, err := c.Audit(filter)
+			if err != nil {
+				return err
+			}
+			next := filter.NextCursor(entries)
+
+			if opts.json {
+				return opts.printJSON(map[string]any{"entries": entries, "next_cursor": next})
+			}
+			w := opts.table()
+			fmt.Fprintln(w, "ID\tTIME\tKEY\tACTION\tSTATUS\tIP\tPATH\tMETADATA")
+			printAuditEntries(opts, w, entries)
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if next != "" {
+				fmt.Fprintf(opts.stderr, "More records: --cursor %s\n", next)
+			}
+			return nil
+		})
+	}
+
+	return withClient(opts, func(c client) error {
+		// The newest records, printed oldest first like tail(1)
+		entries, err := c.Audit(filter)
+		if err != nil {
+			return err
+		}
+		slices.Reverse(entries)
+
+		if !follow {
+			if opts.json {
+				return opts.printJSON(entries)
+			}
+			w := opts.table()
+			fmt.Fprintln(w, "ID\tTIME\tKEY\tACTION\tSTATUS\tIP\tPATH\tMETADATA")
+			printAuditEntries(opts, w, entries)
+			return w.Flush()
+		}
+
+		// Follow mode prints one JSON object per line with --json
+		printAuditEntries(opts, opts.stdout, entries)
+
+		lastID := int64(0)
+		if len(entries) > 0 {
+			lastID = entries[len(entries)-1].ID
+		} else {
+			// Nothing matched yet: only print records created from now on
+			latest, err := c.Audit(auth.AuditFilter{Limit: 1})
+			if err != nil {
+				return err
+			}
+			if len(latest) > 0 {
+				lastID = latest[0].ID
+			}
+		}
+
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		filter.Ascending = true
+		for {
+			select {
+			case <-stop:
+				return nil
+			case <-ticker.C:
+			}
+
+			filter.AfterID = lastID
+			if entries, err = c.Audit(filter); err != nil {
+				return err
+			}
+			printAuditEntries(opts, opts.stdout, entries)
+			if len(entries) > 0 {
+				lastID = entries[len(entries)-1].ID
+			}
+		}
+	})
+}
+
+// runAuditVerify implements "audit verify". It fails when the hash chain or
+// a checkpoint is broken, so it can run from cron or CI.
+func runAuditVerify(opts *options, args []string) error {
+	fs := newFlagSet(opts, "audit verify")
+	publicKeys := fs.String("public-key", "", "comma-separated PEM files verifying checkpoint signatures")
+	if positional, err := parseArgs(fs, args); err != nil || len(positional) != 0 {
+		return errUsage
+	}
+
+	var keyFiles []string
+	switch {
+	case *publicKeys != "":
+		keyFiles = splitList(*publicKeys)
+	case opts.server == "":
+		// Direct access: verify with the same keys as the server
+		keyFiles = splitList(os.Getenv("AUDIT_VERIFY_KEY_FILES"))
+		if f := os.Getenv("AUDIT_SIGNING_KEY_FILE"); f != "" {
+			keyFiles = append(keyFiles, f)
+		}
+	}
+	keys, err := auth.LoadAuditPublicKeys(keyFiles...)
+	if err != nil {
+		return err
+	}
+
+	return withClient(opts, func(c client) error {
+		report, err := c.VerifyAudit(keys)
+		if err != nil {
+			return err
+		}
+
+		if opts.json {
+			if err := opts.printJSON(report); err != nil {
+				return err
+			}
+		} else {
+			printAuditReport(opts.stdout, report)
+		}
+		if !report.OK {
+			return errors.New("audit log verification failed")
+		}
+		return nil
+	})
+}
+
+// printAuditReport summarizes a verification for humans
+func printAuditReport(w io.Writer, report *auth.AuditVerifyReport) {
+	if report.Entries > 0 {
+		fmt.Fprintf(w, "Chain:        %d entries (IDs %d-%d)\n", report.Entries, report.FirstID, report.LastID)
+	} else {
+		fmt.Fprintln(w, "Chain:        no chained entries")
+	}
+	if report.Unchained > 0 {
+		fmt.Fprintf(w, "Unchained:    %d entries written before hash chaining\n", report.Unchained)
+	}
+	fmt.Fprintf(w, "Checkpoints:  %d matched, %d archived\n", report.Checkpoints, report.ArchivedCheckpoints)
+	if !report.SignaturesChecked {
+		fmt.Fprintln(w, "Signatures:   not checked (no public key; use --public-key)")
+	}
+
+	if report.OK {
+		fmt.Fprintln(w, "Result:       OK")
+		return
+	}
+	fmt.Fprintf(w, "Result:       BROKEN (%s)\n", report.Break.Reason)
+	fmt.Fprintf(w, "First break:  %s\n", report.Break.Message)
+}
+
+// printAuditEntries writes audit records as table rows or JSON lines
+func printAuditEntries(opts *options, w io.Writer, entries []auth.AuditEntry) {
+	for _, e := range entries {
+		if opts.json {
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "%s\n", data)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%d\t%s\t%s\t%s\n",
+			e.ID, e.Timestamp.Local().Format(time.RFC3339), e.APIKeyID, e.Action,
+			e.StatusCode, e.IPAddress, e.RequestPath, string(e.Metadata))
+	}
+}
+
+// runConfig implements "config validate"
+func runConfig(opts *options, sub string, args []string) error {
+	if sub != "validate" {
+		return errUsage
+	}
+
+	fs := newFlagSet(opts, "config validate")
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) > 1 {
+		return errUsage
+	}
+
+	path := os.Getenv("MODEL_MAPPING_CONFIG")
+	if path == "" {
+		path = "configs/model-mapping.yaml"
+	}
+	if len(positional) == 1 {
+		path = positional[0]
+	}
+
+	result := struct {
+		Path      string   `json:"path"`
+		Valid     bool     `json:"valid"`
+		Error     string   `json:"error,omitempty"`
+		Models    []string `json:"models,omitempty"`
+		Providers []string `json:"enabled_providers,omitempty"`
+	}{Path: path}
+
+	config, err := router.LoadConfig(path)
+	if err == nil {
+		err = config.ValidateConfig()
+	}
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Valid = true
+		for model := range config.ModelMappings {
+			result.Models = append(result.Models, model)
+		}
+		sort.Strings(result.Models)
+		result.Providers = config.ListEnabledProviders()
+		sort.Strings(result.Providers)
+	}
+
+	if opts.json {
+		if err := opts.printJSON(result); err != nil {
+			return err
+		}
+	} else if result.Valid {
+		fmt.Fprintf(opts.stdout, "%s is valid: %d models, enabled providers: %s\n",
+			path, len(result.Models), strings.Join(result.Providers, ", "))
+	}
+
+	if !result.Valid {
+		return fmt.Errorf("%s: %s", path, result.Error)
+	}
+	return nil
+}
+
+// newFlagSet creates a flag set for a subcommand that reports errors to stderr
+func newFlagSet(opts *options, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(opts.stderr)
+	return fs
+}
+
+// withClient connects, runs fn and closes the connection
+func withClient(opts *options, fn func(client) error) error {
+	c, err := opts.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return fn(c)
+}
+
+// parseIDs parses flags followed by exactly n numeric positional arguments
+func parseIDs(fs *flag.FlagSet, args []string, n int) ([]int64, error) {
+	positional, err := parseArgs(fs, args)
+	if err != nil || len(positional) != n {
+		return nil, errUsage
+	}
+
+	ids := make([]int64, n)
+	for i, arg := range positional {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q", arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// parseArgs parses flags that may appear before or after positional
+// arguments (e.g. "totp enroll 3 --force") and returns the positionals
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// parseTimeFlag accepts an RFC 3339 time or a duration before now
+func parseTimeFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("use RFC 3339 (2025-01-02T15:04:05Z) or a duration (24h)")
+	}
+	return t, nil
+}
+
+// printIssuedKey prints a new or rotated key, which is only ever shown once
+func printIssuedKey(opts *options, issued *handlers.IssuedKey) error {
+	if opts.json {
+		return opts.printJSON(issued)
+	}
+
+	fmt.Fprintf(opts.stdout, "Key ID:  %d\n", issued.Key.ID)
+	fmt.Fprintf(opts.stdout, "Name:    %s\n", issued.Key.Name)
+	fmt.Fprintf(opts.stdout, "API key: %s\n", issued.APIKey)
+	fmt.Fprintln(opts.stdout, "\nStore this key securely; it will not be shown again.")
+	return nil
+}
+
+// printResult prints a confirmation for commands without other output
+func printResult(opts *options, status, message string) error {
+	if opts.json {
+		return opts.printJSON(map[string]string{"status": status, "message": message})
+	}
+	fmt.Fprintln(opts.stdout, message)
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func teamColumn(teamID int64) string {
+	if teamID == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(teamID, 10)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Command proxyctl administers API keys, SigV4 credentials, 2FA, sessions and
+// the audit log of a Bedrock Proxy deployment, either directly against the
+// auth database or through the server's /admin API.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+const usage = `Usage: proxyctl [global flags] <command> <subcommand> [flags]
+
+Commands:
+  keys create       Create an API key
+  keys list         List API keys
+  keys revoke ID    Revoke an API key and its sessions
+  keys rotate ID    Replace an API key, carrying over its settings
+  keys sigv4 create ID
+                    Issue a SigV4 access key for an API key
+  keys sigv4 list ID
+                    List the SigV4 access keys of an API key
+  keys sigv4 revoke ID ACCESS_KEY_ID
+                    Revoke a SigV4 access key
+  totp enroll ID    Enable 2FA for a key and print a QR code to scan
+  sessions list ID  List sessions of a key
+  sessions revoke ID SESSION_ID
+                    Revoke a session of a key
+  audit tail        Print recent audit records (-f to follow)
+  audit filter      Query the audit log, one page at a time
+  audit export      Stream matching audit records as JSONL or CSV
+  audit verify      Check the audit hash chain and signed checkpoints
+  config validate [PATH]
+                    Validate a model mapping file
+
+Global flags:
+  --json            Print JSON for scripting
+  --server URL      Use the admin API at URL (env PROXYCTL_SERVER)
+  --api-key KEY     Admin API key for --server (env PROXYCTL_API_KEY)
+  --totp-code CODE  2FA code when the admin key requires it
+  --config PATH     Server config file (env SERVER_CONFIG)
+  --db PATH         SQLite auth database, overriding the config
+
+Without --server the auth database is opened directly. The database, API key
+pepper and TOTP master keys come from the server config and its environment
+overrides (AUTH_DB_*, API_KEY_PEPPER_FILE, TOTP_MASTER_KEY_FILE), so run
+proxyctl with the server's config.
+`
+
+// errUsage reports invalid command-line usage (exit code 2)
+var errUsage = errors.New("invalid usage")
+
+// options are the global flags shared by all commands
+type options struct {
+	json     bool
+	server   string
+	apiKey   string
+	totpCode string
+	config   string
+	dbPath   string
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes proxyctl with args and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	opts := &options{stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("proxyctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+	fs.BoolVar(&opts.json, "json", false, "print JSON")
+	fs.StringVar(&opts.server, "server", os.Getenv("PROXYCTL_SERVER"), "admin API base URL")
+	fs.StringVar(&opts.apiKey, "api-key", os.Getenv("PROXYCTL_API_KEY"), "admin API key")
+	fs.StringVar(&opts.totpCode, "totp-code", "", "2FA code for the admin API key")
+	fs.StringVar(&opts.config, "config", os.Getenv("SERVER_CONFIG"), "server config file")
+	fs.StringVar(&opts.dbPath, "db", "", "SQLite auth database path")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	rest := fs.Args()
+	if len(rest) < 2 {
+		fs.Usage()
+		return 2
+	}
+
+	var err error
+	switch rest[0] {
+	case "keys":
+		err = runKeys(opts, rest[1], rest[2:])
+	case "totp":
+		err = runTOTP(opts, rest[1], rest[2:])
+	case "sessions":
+		err = runSessions(opts, rest[1], rest[2:])
+	case "audit":
+		err = runAudit(opts, rest[1], rest[2:])
+	case "config":
+		err = runConfig(opts, rest[1], rest[2:])
+	default:
+		err = errUsage
+	}
+
+	switch {
+	case errors.Is(err, errUsage):
+		fs.Usage()
+		return 2
+	case err != nil:
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// connect opens the admin API client or the auth database
+func (o *options) connect() (client, error) {
+	if o.server != "" {
+		if o.apiKey == "" {
+			return nil, errors.New("--server requires --api-key or PROXYCTL_API_KEY")
+		}
+		return newAPIClient(o.server, o.apiKey, o.totpCode), nil
+	}
+	return openDBClient(o.config, o.dbPath)
+}
+
+// printJSON writes v as indented JSON
+func (o *options) printJSON(v any) error {
+	enc := json.NewEncoder(o.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// table returns a writer for aligned columns; call Flush when done
+func (o *options) table() *tabwriter.Writer {
+	return tabwriter.NewWriter(o.stdout, 0, 4, 2, ' ', 0)
+}
@@ -0,0 +1,424 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestDB creates a migrated SQLite auth database with one key and clears
+// the environment proxyctl reads, returning the database path and key ID
+func newTestDB(t *testing.T) (string, int64) {
+	t.Helper()
+
+	for _, name := range []string{
+		"SERVER_CONFIG", "AUTH_DB_BACKEND", "AUTH_DB_PATH", "AUTH_DB_DSN", "AUTH_DB_DSN_FILE",
+		"API_KEY_PEPPER_FILE", "TOTP_MASTER_KEY_FILE", "TOTP_PREVIOUS_MASTER_KEY_FILES",
+		"PROXYCTL_SERVER", "PROXYCTL_API_KEY", "AUDIT_VERIFY_KEY_FILES", "AUDIT_SIGNING_KEY_FILE",
+	} {
+		t.Setenv(name, "")
+	}
+
+	path := filepath.Join(t.TempDir(), "auth.db")
+	db, err := auth.NewAPIKeyDB(path)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	_, key, err := db.CreateAPIKey("Existing", "existing@example.com", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	return path, key.ID
+}
+
+// runCLI runs proxyctl with args and returns its exit code and output
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// runJSON runs proxyctl with --json, expecting success, and decodes stdout into v
+func runJSON(t *testing.T, v any, args ...string) {
+	t.Helper()
+
+	code, stdout, stderr := runCLI(t, append([]string{"--json"}, args...)...)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0 for %v, got %d: %s", args, code, stderr)
+	}
+	if err := json.Unmarshal([]byte(stdout), v); err != nil {
+		t.Fatalf("Expected JSON output for %v, got %q: %v", args, stdout, err)
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	path, keyID := newTestDB(t)
+	id := strconv.FormatInt(keyID, 10)
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    int
+		wantErr string
+	}{
+		{name: "success", args: []string{"--db", path, "keys", "list"}, want: 0},
+		{name: "no command", args: []string{"--db", path}, want: 2},
+		{name: "unknown command", args: []string{"--db", path, "users", "list"}, want: 2},
+		{name: "unknown subcommand", args: []string{"--db", path, "keys", "delete", id}, want: 2},
+		{name: "missing required flag", args: []string{"--db", path, "keys", "create"}, want: 2},
+		{name: "unknown flag", args: []string{"--db", path, "keys", "list", "--color"}, want: 2},
+		{name: "missing argument", args: []string{"--db", path, "sessions", "revoke", id}, want: 2},
+		{name: "invalid ID", args: []string{"--db", path, "keys", "revoke", "abc"}, want: 1, wantErr: `invalid ID "abc"`},
+		{name: "unknown key", args: []string{"--db", path, "keys", "revoke", "999"}, want: 1, wantErr: "API key not found"},
+		{name: "invalid tags", args: []string{"--db", path, "keys", "create", "--name", "x", "--tags", "team"}, want: 1},
+		{name: "invalid audit order", args: []string{"--db", path, "audit", "filter", "--order", "up"}, want: 1, wantErr: "invalid --order"},
+		{name: "no database", args: []string{"keys", "list"}, want: 1, wantErr: "no auth database configured"},
+		{name: "server without key", args: []string{"--server", "http://127.0.0.1:1", "keys", "list"}, want: 1, wantErr: "--server requires --api-key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runCLI(t, tt.args...)
+			if code != tt.want {
+				t.Errorf("Expected exit code %d, got %d: %s", tt.want, code, stderr)
+			}
+			if tt.want == 2 && !strings.Contains(stderr, "Usage: proxyctl") {
+				t.Errorf("Expected usage on stderr, got %q", stderr)
+			}
+			if tt.wantErr != "" && !strings.Contains(stderr, tt.wantErr) {
+				t.Errorf("Expected error %q on stderr, got %q", tt.wantErr, stderr)
+			}
+		})
+	}
+}
+
+func TestRunKeys(t *testing.T) {
+	path, existingID := newTestDB(t)
+
+	var issued handlers.IssuedKey
+	runJSON(t, &issued, "--db", path, "keys", "create", "--name", "CI", "--email", "ci@example.com",
+		"--permissions", "invoke, admin", "--tags", "team=ml,env=prod")
+	if !strings.HasPrefix(issued.APIKey, "bdrk_live_") {
+		t.Errorf("Expected a live API key, got %q", issued.APIKey)
+	}
+	if issued.Key.Name != "CI" || !issued.Key.IsActive {
+		t.Errorf("Unexpected key: %+v", issued.Key)
+	}
+	if strings.Join(issued.Key.Permissions, ",") != "invoke,admin" {
+		t.Errorf("Expected permissions invoke,admin, got %v", issued.Key.Permissions)
+	}
+	if issued.Key.Tags["team"] != "ml" || issued.Key.Tags["env"] != "prod" {
+		t.Errorf("Expected tags team=ml env=prod, got %v", issued.Key.Tags)
+	}
+
+	code, stdout, _ := runCLI(t, "--db", path, "keys", "create", "--name", "Plain")
+	if code != 0 || !strings.Contains(stdout, "API key: bdrk_live_") || !strings.Contains(stdout, "will not be shown again") {
+		t.Errorf("Expected the new key printed once, got %d %q", code, stdout)
+	}
+
+	var keys []handlers.KeyInfo
+	runJSON(t, &keys, "--db", path, "keys", "list")
+	if len(keys) != 3 {
+		t.Fatalf("Expected 3 keys, got %d", len(keys))
+	}
+
+	var result map[string]string
+	runJSON(t, &result, "--db", path, "keys", "revoke", strconv.FormatInt(existingID, 10))
+	if result["status"] != "revoked" {
+		t.Errorf("Expected status revoked, got %v", result)
+	}
+
+	runJSON(t, &keys, "--db", path, "keys", "list")
+	for _, key := range keys {
+		if key.ID == existingID {
+			t.Error("Expected the revoked key hidden without --all")
+		}
+	}
+	runJSON(t, &keys, "--db", path, "keys", "list", "--all")
+	if len(keys) != 3 {
+		t.Errorf("Expected 3 keys with --all, got %d", len(keys))
+	}
+
+	code, stdout, _ = runCLI(t, "--db", path, "keys", "list", "--all")
+	if code != 0 || !strings.Contains(stdout, "revoked") || !strings.Contains(stdout, "invoke,admin") {
+		t.Errorf("Expected a table with statuses and permissions, got %d %q", code, stdout)
+	}
+
+	var rotated handlers.IssuedKey
+	runJSON(t, &rotated, "--db", path, "keys", "rotate", strconv.FormatInt(issued.Key.ID, 10))
+	if rotated.Key.ID == issued.Key.ID || rotated.APIKey == issued.APIKey {
+		t.Errorf("Expected a new key, got %+v", rotated.Key)
+	}
+	if strings.Join(rotated.Key.Permissions, ",") != "invoke,admin" || rotated.Key.Tags["team"] != "ml" {
+		t.Errorf("Expected settings carried over, got %+v", rotated.Key)
+	}
+}
+
+func TestRunSessions(t *testing.T) {
+	path, keyID := newTestDB(t)
+	id := strconv.FormatInt(keyID, 10)
+
+	db, err := auth.NewAPIKeyDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	sessions := auth.NewSessionManager(db.DB())
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if _, err := sessions.GenerateSessionToken(keyID, time.Hour, ip, "test"); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+	db.Close()
+
+	var list []handlers.SessionInfo
+	runJSON(t, &list, "--db", path, "sessions", "list", id)
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 sessions, got %+v", list)
+	}
+
+	code, stdout, _ := runCLI(t, "--db", path, "sessions", "revoke", id, strconv.FormatInt(list[0].ID, 10))
+	if code != 0 || !strings.Contains(stdout, "revoked") {
+		t.Errorf("Expected the session revoked, got %d %q", code, stdout)
+	}
+
+	runJSON(t, &list, "--db", path, "sessions", "list", id)
+	if len(list) != 1 {
+		t.Errorf("Expected 1 session left, got %+v", list)
+	}
+
+	// Revoking the key revokes its remaining sessions
+	runCLI(t, "--db", path, "keys", "revoke", id)
+	runJSON(t, &list, "--db", path, "sessions", "list", id)
+	if len(list) != 0 {
+		t.Errorf("Expected no sessions after revoking the key, got %+v", list)
+	}
+}
+
+func TestRunAuditFilter(t *testing.T) {
+	path, keyID := newTestDB(t)
+
+	for _, name := range []string{"a", "b", "c"} {
+		if code, _, stderr := runCLI(t, "--db", path, "keys", "create", "--name", name); code != 0 {
+			t.Fatalf("Failed to create key: %s", stderr)
+		}
+	}
+	runCLI(t, "--db", path, "keys", "revoke", strconv.FormatInt(keyID, 10))
+
+	type page struct {
+		Entries    []auth.AuditEntry `json:"entries"`
+		NextCursor string            `json:"next_cursor"`
+	}
+
+	// Page through the created keys, oldest first
+	var ids []int64
+	args := []string{"--db", path, "audit", "filter", "--action", "admin_key_created", "--order", "asc", "--limit", "2"}
+	var p page
+	runJSON(t, &p, args...)
+	for _, e := range p.Entries {
+		ids = append(ids, e.ID)
+	}
+	if p.NextCursor == "" {
+		t.Fatal("Expected a cursor to the next page")
+	}
+	runJSON(t, &p, append(args, "--cursor", p.NextCursor)...)
+	for _, e := range p.Entries {
+		ids = append(ids, e.ID)
+	}
+	if len(ids) != 3 || ids[0] >= ids[1] || ids[1] >= ids[2] {
+		t.Errorf("Expected 3 created keys in ascending order, got IDs %v", ids)
+	}
+
+	// Prefix matching selects both actions
+	runJSON(t, &p, "--db", path, "audit", "filter", "--action", "admin_key_*")
+	if len(p.Entries) != 4 || p.Entries[0].Action != "admin_key_revoked" || p.NextCursor != "" {
+		t.Errorf("Expected the revocation first of 4 key records, got %+v", p)
+	}
+
+	code, stdout, _ := runCLI(t, "--db", path, "audit", "filter", "--action", "admin_key_revoked")
+	if code != 0 || !strings.HasPrefix(stdout, "ID ") || strings.Count(stdout, "admin_key_revoked") != 1 {
+		t.Errorf("Expected a table with one revocation, got %d %q", code, stdout)
+	}
+
+	runJSON(t, &p, "--db", path, "audit", "filter", "--action", "admin_key_*", "--since", "2000-01-01T00:00:00Z", "--until", "2001-01-01T00:00:00Z")
+	if len(p.Entries) != 0 {
+		t.Errorf("Expected no records in 2000, got %d", len(p.Entries))
+	}
+
+	code, stdout, _ = runCLI(t, "--db", path, "audit", "export", "--action", "admin_key_created", "--format", "csv")
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); code != 0 || len(lines) != 4 || !strings.HasPrefix(lines[0], "id,timestamp") {
+		t.Errorf("Expected a CSV header and 3 rows, got %d %q", code, stdout)
+	}
+
+	var report auth.AuditVerifyReport
+	runJSON(t, &report, "--db", path, "audit", "verify")
+	if !report.OK || report.Entries == 0 {
+		t.Errorf("Expected an intact audit chain, got %+v", report)
+	}
+}
+
+func TestRunKeySigV4(t *testing.T) {
+	path, keyID := newTestDB(t)
+	id := strconv.FormatInt(keyID, 10)
+
+	// The same commands work directly and through the admin API
+	db, err := auth.NewAPIKeyDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	admin := handlers.NewAdminHandler(db, auth.NewSessionManager(db.DB()), nil, nil)
+	router.POST("/admin/keys/:id/sigv4", admin.CreateKeySigV4)
+	router.GET("/admin/keys/:id/sigv4", admin.ListKeySigV4)
+	router.DELETE("/admin/keys/:id/sigv4/:accessKeyID", admin.RevokeKeySigV4)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	modes := map[string][]string{
+		"Direct": {"--db", path},
+		"Server": {"--server", server.URL, "--api-key", "admin"},
+	}
+
+	for name, global := range modes {
+		t.Run(name, func(t *testing.T) {
+			cli := func(args ...string) []string { return append(append([]string{}, global...), args...) }
+
+			var issued handlers.IssuedSigV4Credential
+			runJSON(t, &issued, cli("keys", "sigv4", "create", id)...)
+			if !strings.HasPrefix(issued.AccessKeyID, "BDRK") || issued.SecretAccessKey == "" || issued.APIKeyID != keyID {
+				t.Fatalf("Unexpected credential: %+v", issued)
+			}
+
+			code, stdout, _ := runCLI(t, cli("keys", "sigv4", "create", id)...)
+			if code != 0 || !strings.Contains(stdout, "Secret access key: ") || !strings.Contains(stdout, "will not be shown again") {
+				t.Errorf("Expected the secret printed once, got %d %q", code, stdout)
+			}
+
+			var creds []handlers.SigV4CredentialInfo
+			runJSON(t, &creds, cli("keys", "sigv4", "list", id)...)
+			listed := false
+			for _, cred := range creds {
+				listed = listed || strings.Contains(stdout, cred.AccessKeyID)
+			}
+			if len(creds) < 2 || !listed {
+				t.Errorf("Expected both new credentials listed, got %+v", creds)
+			}
+
+			var result map[string]string
+			runJSON(t, &result, cli("keys", "sigv4", "revoke", id, issued.AccessKeyID)...)
+			if result["status"] != "revoked" {
+				t.Errorf("Expected status revoked, got %v", result)
+			}
+			if _, err := db.GetSigV4Credential(issued.AccessKeyID); err == nil {
+				t.Error("Expected the revoked credential rejected")
+			}
+
+			code, _, stderr := runCLI(t, cli("keys", "sigv4", "revoke", "999", issued.AccessKeyID)...)
+			if code != 1 {
+				t.Errorf("Expected exit code 1 revoking through another key, got %d: %s", code, stderr)
+			}
+
+			code, stdout, _ = runCLI(t, cli("keys", "sigv4", "list", id)...)
+			if code != 0 || !strings.Contains(stdout, issued.AccessKeyID) || !strings.Contains(stdout, "revoked") {
+				t.Errorf("Expected a table with the revoked credential, got %d %q", code, stdout)
+			}
+		})
+	}
+}
+
+func TestRunServerConfig(t *testing.T) {
+	path, keyID := newTestDB(t)
+	id := strconv.FormatInt(keyID, 10)
+
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return file
+	}
+	pepperFile := writeFile("pepper", strings.Repeat("p", 32))
+	masterKeyFile := writeFile("master.key", strings.Repeat("ab", 32))
+	configFile := writeFile("server.yaml", fmt.Sprintf(`
+auth:
+  api_key_pepper_file: %s
+  database:
+    path: %s
+  totp:
+    master_key_file: %s
+`, pepperFile, path, masterKeyFile))
+
+	// SERVER_CONFIG selects the database, pepper and master key
+	t.Setenv("SERVER_CONFIG", configFile)
+
+	var issued handlers.IssuedKey
+	runJSON(t, &issued, "keys", "create", "--name", "Peppered")
+
+	var enrollment handlers.TOTPEnrollment
+	runJSON(t, &enrollment, "totp", "enroll", id)
+
+	var sigv4 handlers.IssuedSigV4Credential
+	runJSON(t, &sigv4, "keys", "sigv4", "create", id)
+
+	db, err := auth.NewAPIKeyDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var scheme string
+	var totpKeyID, sigv4KeyID sql.NullString
+	if err := db.DB().QueryRow("SELECT hash_scheme FROM api_keys WHERE id = ?", issued.Key.ID).Scan(&scheme); err != nil {
+		t.Fatalf("Failed to read key: %v", err)
+	}
+	if scheme != auth.HashSchemeHMACSHA256 {
+		t.Errorf("Expected the key hashed with the configured pepper, got %q", scheme)
+	}
+	db.DB().QueryRow("SELECT secret_key_id FROM api_key_2fa WHERE api_key_id = ?", keyID).Scan(&totpKeyID)
+	db.DB().QueryRow("SELECT secret_key_id FROM api_key_sigv4_credentials WHERE access_key_id = ?", sigv4.AccessKeyID).Scan(&sigv4KeyID)
+	if !totpKeyID.Valid || !sigv4KeyID.Valid {
+		t.Errorf("Expected secrets encrypted with the configured master key, got %v %v", totpKeyID, sigv4KeyID)
+	}
+
+	// Without the config, proxyctl has no master key and must not write
+	// plaintext secrets next to encrypted ones
+	t.Setenv("SERVER_CONFIG", "")
+	for _, args := range [][]string{
+		{"--db", path, "totp", "enroll", "--force", id},
+		{"--db", path, "keys", "sigv4", "create", id},
+	} {
+		code, _, stderr := runCLI(t, args...)
+		if code != 1 || !strings.Contains(stderr, "configure the master key") {
+			t.Errorf("Expected %v refused without the master key, got %d %q", args, code, stderr)
+		}
+	}
+
+	// --db overrides the database of the config
+	code, _, stderr := runCLI(t, "--config", configFile, "--db", filepath.Join(dir, "new.db"), "keys", "list")
+	if code != 1 || !strings.Contains(stderr, "pending migrations") {
+		t.Errorf("Expected the empty --db database opened, got %d %q", code, stderr)
+	}
+}
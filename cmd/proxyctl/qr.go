@@ -0,0 +1,59 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+// qrQuietZone is the blank border in modules that scanners need around a code
+const qrQuietZone = 2
+
+// writeQR renders content as a QR code with Unicode half blocks, two
+// modules per character row, dark modules drawn as spaces on light text
+func writeQR(w io.Writer, content string) error {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	bounds := code.Bounds()
+	size := bounds.Dx()
+	dark := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= size || y >= size {
+			return false
+		}
+		return isDark(code, bounds.Min.X+x, bounds.Min.Y+y)
+	}
+
+	out := bufio.NewWriter(w)
+	for y := -qrQuietZone; y < size+qrQuietZone; y += 2 {
+		for x := -qrQuietZone; x < size+qrQuietZone; x++ {
+			top, bottom := dark(x, y), dark(x, y+1)
+			switch {
+			case top && bottom:
+				out.WriteString(" ")
+			case top:
+				out.WriteString("▄")
+			case bottom:
+				out.WriteString("▀")
+			default:
+				out.WriteString("█")
+			}
+		}
+		out.WriteString("\n")
+	}
+	return out.Flush()
+}
+
+// isDark reports whether the module at x, y is dark
+func isDark(img image.Image, x, y int) bool {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return r+g+b < 3*0x8000
+}
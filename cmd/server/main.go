@@ -4,185 +4,250 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/config"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/dlp"
 	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/handlers"
 	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/health"
 	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/middleware"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/notify"
 	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers"
-	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/anthropic"
-	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/azure"
-	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/bedrock"
-	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/ibm"
-	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/openai"
-	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/oracle"
-	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/vertex"
 	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/router"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tags"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tracing"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/transcript"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	// Configuration from environment
-	port := getEnv("PORT", "8080")
-	tlsPort := getEnv("TLS_PORT", "8443")
-	region := getEnv("AWS_REGION", "us-east-1")
-	ginMode := getEnv("GIN_MODE", "release")
-	authEnabled := getEnv("AUTH_ENABLED", "false") == "true"
-	authMode := getEnv("AUTH_MODE", "api_key")
-	tlsCertFile := getEnv("TLS_CERT_FILE", "/etc/tls/tls.crt")
-	tlsKeyFile := getEnv("TLS_KEY_FILE", "/etc/tls/tls.key")
-	tlsEnabled := getEnv("TLS_ENABLED", "false") == "true"
-	modelMappingConfig := getEnv("MODEL_MAPPING_CONFIG", "configs/model-mapping.yaml")
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	configPath := flag.String("config", os.Getenv("SERVER_CONFIG"), "server configuration file (YAML)")
+	validateOnly := flag.Bool("validate-config", false, "check the configuration and the files it references, then exit")
+	flag.Parse()
+
+	// Configuration from the config file, overridden by the environment
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load server config: %v", err)
+	}
+	if *validateOnly {
+		os.Exit(runValidateConfig(*configPath, cfg))
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid server config: %v", err)
+	}
+	port := cfg.Server.Port
+	tlsPort := cfg.Server.TLSPort
+	authEnabled := cfg.Auth.Enabled
+	authMode := cfg.Auth.Mode
+	tlsEnabled := cfg.TLS.Enabled
 
 	// Set Gin mode
-	gin.SetMode(ginMode)
+	gin.SetMode(cfg.Server.GinMode)
+
+	// Distributed tracing (optional)
+	shutdownTracing := setupTracing(cfg.Features.Tracing)
+
+	// Background work runs until the servers have drained on shutdown
+	background, stopBackground := context.WithCancel(context.Background())
+	var backgroundWorkers sync.WaitGroup
+
+	// Webhook notifications for budget, outage and security events (optional)
+	notifier := loadNotifier(cfg.Features.Notifications)
 
 	// Initialize components
 	healthChecker := health.NewChecker()
 
-	// Initialize providers
+	// Load router configuration
+	log.Printf("Loading model mapping configuration from: %s", cfg.Features.ModelMapping)
+	routerConfig, err := router.LoadConfig(cfg.Features.ModelMapping)
+	if err != nil {
+		log.Fatalf("Failed to load router config: %v", err)
+	}
+	log.Println("✓ Model mapping configuration loaded")
+
+	// Initialize the provider instances declared in the model mapping
 	log.Println("Initializing providers...")
-	providerRegistry := make(map[string]providers.Provider)
+	providerRegistry := buildProviders(newProviderRegistry(), routerConfig, cfg.Providers)
+	if len(providerRegistry) == 0 {
+		log.Fatal("No providers initialized. Please configure at least one provider.")
+	}
+	log.Printf("Total providers initialized: %d", len(providerRegistry))
 
-	// Bedrock provider (always initialize if AWS region is set)
-	if region != "" {
-		bedrockProvider, err := bedrock.NewBedrockProvider(region)
-		if err != nil {
-			log.Printf("Warning: Failed to create Bedrock provider: %v", err)
-		} else {
-			providerRegistry["bedrock"] = bedrockProvider
-			log.Printf("✓ Bedrock provider initialized (region: %s)", region)
-		}
+	// Trace provider calls and count upstream responses
+	for name, provider := range providerRegistry {
+		providerRegistry[name] = providers.Instrument(provider)
 	}
 
-	// Azure OpenAI provider
-	if azureEndpoint := os.Getenv("AZURE_OPENAI_ENDPOINT"); azureEndpoint != "" {
-		azureAPIKey := os.Getenv("AZURE_OPENAI_API_KEY")
-		if azureAPIKey != "" {
-			azureProvider, err := azure.NewAzureProvider(azure.AzureConfig{
-				Endpoint:   azureEndpoint,
-				APIKey:     azureAPIKey,
-				APIVersion: getEnv("AZURE_API_VERSION", "2024-02-15-preview"),
-			})
-			if err != nil {
-				log.Printf("Warning: Failed to create Azure provider: %v", err)
-			} else {
-				providerRegistry["azure"] = azureProvider
-				log.Println("✓ Azure OpenAI provider initialized")
-			}
-		}
+	// Initialize router
+	aiRouter, err := router.NewRouter(routerConfig, providerRegistry)
+	if err != nil {
+		log.Fatalf("Failed to create router: %v", err)
 	}
+	log.Println("✓ Router initialized")
 
-	// OpenAI provider
-	if openaiAPIKey := os.Getenv("OPENAI_API_KEY"); openaiAPIKey != "" {
-		openaiProvider, err := openai.NewOpenAIProvider(openai.OpenAIConfig{
-			APIKey:  openaiAPIKey,
-			BaseURL: getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1"),
-		})
-		if err != nil {
-			log.Printf("Warning: Failed to create OpenAI provider: %v", err)
-		} else {
-			providerRegistry["openai"] = openaiProvider
-			log.Println("✓ OpenAI provider initialized")
-		}
+	// Validate configuration
+	enabledProviders := routerConfig.ListEnabledProviders()
+	log.Printf("Enabled providers: %s", strings.Join(enabledProviders, ", "))
+
+	// Probe provider health in the background for /ready
+	prober := newProviderProber(routerConfig, providerRegistry, notifier)
+	prober.Start(background)
+	if required := routerConfig.ListRequiredProviders(); len(required) > 0 {
+		log.Printf("✓ Provider health probing started (required for readiness: %s)", strings.Join(required, ", "))
+	} else {
+		log.Println("✓ Provider health probing started (no providers required for readiness)")
 	}
 
-	// Anthropic provider
-	if anthropicAPIKey := os.Getenv("ANTHROPIC_API_KEY"); anthropicAPIKey != "" {
-		anthropicProvider, err := anthropic.NewAnthropicProvider(anthropic.AnthropicConfig{
-			APIKey:  anthropicAPIKey,
-			BaseURL: getEnv("ANTHROPIC_BASE_URL", "https://api.anthropic.com/v1"),
-		})
+	// Send synthetic requests to mapped models so broken ones stop being offered
+	modelChecker := newModelChecker(routerConfig, providerRegistry)
+	if modelChecker != nil {
+		aiRouter.SetModelHealth(modelChecker)
+		modelChecker.Start(background)
+		log.Printf("✓ Synthetic model checks started (%d model/provider pairs)", len(modelChecker.Statuses()))
+	}
+
+	// Initialize handlers
+	openaiHandler := handlers.NewOpenAIHandler(aiRouter)
+	openaiHandler.SetProviderHealth(prober)
+	transcripts := loadTranscriptLogger(cfg.Features.Transcripts)
+	if transcripts != nil {
+		openaiHandler.SetTranscriptLogger(transcripts)
+	}
+	if scanner := loadDLPScanner(cfg.Features.DLP); scanner != nil {
+		openaiHandler.SetDLPScanner(scanner)
+	}
+	openaiHandler.SetMetricsTenant(cfg.Features.MetricsTenantLabel)
+
+	// Mutual TLS client certificate mapping
+	var certMapper *auth.CertMapper
+	if authEnabled && authMode == "mtls" {
+		certMapper, err = auth.NewCertMapper(cfg.TLS.ClientCAFile, cfg.TLS.IdentityMapping)
 		if err != nil {
-			log.Printf("Warning: Failed to create Anthropic provider: %v", err)
-		} else {
-			providerRegistry["anthropic"] = anthropicProvider
-			log.Println("✓ Anthropic provider initialized")
+			log.Fatalf("Failed to load mTLS configuration: %v", err)
 		}
+		log.Println("✓ mTLS client certificate mapping loaded")
 	}
 
-	// Google Vertex AI provider
-	if gcpProjectID := os.Getenv("GCP_PROJECT_ID"); gcpProjectID != "" {
-		vertexProvider, err := vertex.NewVertexProvider(vertex.VertexConfig{
-			ProjectID:   gcpProjectID,
-			Location:    getEnv("GCP_LOCATION", "us-central1"),
-			AccessToken: os.Getenv("GCP_ACCESS_TOKEN"), // Or use Application Default Credentials
-		})
+	// API key database (optional; used by mtls second factor, SigV4 and audit)
+	var apiKeyDB *auth.APIKeyDB
+	if storageConfig, ok := loadStorageConfig(cfg.Auth.Database); ok {
+		store, err := storage.Open(storageConfig)
 		if err != nil {
-			log.Printf("Warning: Failed to create Vertex AI provider: %v", err)
-		} else {
-			providerRegistry["vertex"] = vertexProvider
-			log.Println("✓ Google Vertex AI provider initialized")
+			log.Fatalf("Failed to open API key database: %v", err)
 		}
-	}
+		migrateOnStartup(store, cfg.Auth.Database.AutoMigrate)
+		apiKeyDB, err = auth.NewAPIKeyDBWithStore(store)
+		if err != nil {
+			log.Fatalf("Failed to open API key database: %v", err)
+		}
+		log.Printf("✓ API key database opened: backend=%s", storageConfig.Backend)
 
-	// IBM Watson provider
-	if ibmAPIKey := os.Getenv("IBM_API_KEY"); ibmAPIKey != "" {
-		ibmProjectID := os.Getenv("IBM_PROJECT_ID")
-		if ibmProjectID != "" {
-			ibmProvider, err := ibm.NewIBMProvider(ibm.IBMConfig{
-				APIKey:    ibmAPIKey,
-				ProjectID: ibmProjectID,
-				BaseURL:   getEnv("IBM_BASE_URL", "https://us-south.ml.cloud.ibm.com"),
-			})
-			if err != nil {
-				log.Printf("Warning: Failed to create IBM Watson provider: %v", err)
-			} else {
-				providerRegistry["ibm"] = ibmProvider
-				log.Println("✓ IBM Watson provider initialized")
+		if pepperFile := cfg.Auth.APIKeyPepperFile; pepperFile != "" {
+			if err := apiKeyDB.LoadPepperFile(pepperFile); err != nil {
+				log.Fatalf("Failed to load API key pepper: %v", err)
 			}
+			log.Println("✓ API key pepper loaded (HMAC-SHA256 key hashing)")
 		}
 	}
 
-	// Oracle Cloud AI provider
-	if oracleEndpoint := os.Getenv("ORACLE_ENDPOINT"); oracleEndpoint != "" {
-		oracleAuthToken := os.Getenv("ORACLE_AUTH_TOKEN")
-		oracleCompartmentID := os.Getenv("ORACLE_COMPARTMENT_ID")
-		if oracleAuthToken != "" && oracleCompartmentID != "" {
-			oracleProvider, err := oracle.NewOracleProvider(oracle.OracleConfig{
-				Endpoint:      oracleEndpoint,
-				AuthToken:     oracleAuthToken,
-				CompartmentID: oracleCompartmentID,
-			})
+	// Session and TOTP managers share the API key database
+	var sessionManager *auth.SessionManager
+	var totpManager *auth.TOTPManager
+	if apiKeyDB != nil {
+		sessionManager = auth.NewSessionManagerWithPolicy(apiKeyDB.DB(), sessionPolicy(cfg.Auth.Session))
+		totpOptions := loadTOTPOptions(cfg.Auth.TOTP)
+		totpManager = auth.NewTOTPManagerWithOptions(apiKeyDB.DB(), totpOptions)
+
+		// Re-seal secrets written in plaintext or under a rotated-out master key
+		if totpOptions.Keyring != nil {
+			n, err := totpManager.ReencryptSecrets()
 			if err != nil {
-				log.Printf("Warning: Failed to create Oracle Cloud AI provider: %v", err)
-			} else {
-				providerRegistry["oracle"] = oracleProvider
-				log.Println("✓ Oracle Cloud AI provider initialized")
+				log.Fatalf("Failed to re-encrypt TOTP secrets: %v", err)
+			}
+			if n > 0 {
+				log.Printf("✓ Re-encrypted %d TOTP secrets under the current master key", n)
+			}
+
+			// SigV4 secrets share the TOTP master keys
+			apiKeyDB.SetKeyring(totpOptions.Keyring)
+			n, err = apiKeyDB.ReencryptSigV4Secrets()
+			if err != nil {
+				log.Fatalf("Failed to re-encrypt SigV4 secrets: %v", err)
+			}
+			if n > 0 {
+				log.Printf("✓ Re-encrypted %d SigV4 secrets under the current master key", n)
 			}
 		}
 	}
 
-	if len(providerRegistry) == 0 {
-		log.Fatal("No providers initialized. Please configure at least one provider.")
+	// Archive and prune old audit records, and sign the audit chain head
+	var auditKeys []ed25519.PublicKey
+	if apiKeyDB != nil {
+		startAuditRetention(background, &backgroundWorkers, apiKeyDB, cfg.Auth.Audit)
+		auditKeys = startAuditCheckpoints(background, &backgroundWorkers, apiKeyDB, cfg.Auth.Audit)
 	}
-	log.Printf("Total providers initialized: %d", len(providerRegistry))
 
-	// Load router configuration
-	log.Printf("Loading model mapping configuration from: %s", modelMappingConfig)
-	routerConfig, err := router.LoadConfig(modelMappingConfig)
-	if err != nil {
-		log.Fatalf("Failed to load router config: %v", err)
+	// Usage records for GET /v1/usage and GET /admin/usage
+	if apiKeyDB != nil {
+		openaiHandler.SetUsageStore(apiKeyDB)
 	}
-	log.Println("✓ Model mapping configuration loaded")
 
-	// Initialize router
-	aiRouter, err := router.NewRouter(routerConfig, providerRegistry)
-	if err != nil {
-		log.Fatalf("Failed to create router: %v", err)
+	// Brute-force protection for database-backed authentication
+	var guard *auth.BruteForceGuard
+	if apiKeyDB != nil && cfg.Auth.Lockout.Enabled {
+		guard = newBruteForceGuard(apiKeyDB, cfg.Auth.Lockout)
+		if notifier != nil {
+			guard.OnLockout(notifyLockout(notifier))
+		}
 	}
-	log.Println("✓ Router initialized")
 
-	// Validate configuration
-	enabledProviders := routerConfig.ListEnabledProviders()
-	log.Printf("Enabled providers: %s", strings.Join(enabledProviders, ", "))
+	// Build auth middleware once so every route group shares it
+	var authMiddleware gin.HandlerFunc
+	if authEnabled {
+		authMiddleware = getAuthMiddleware(cfg.Auth, certMapper, apiKeyDB, sessionManager, totpManager, guard)
+	}
 
-	// Initialize handlers
-	openaiHandler := handlers.NewOpenAIHandler(aiRouter)
+	// Inbound SigV4 verification for AWS SDK clients on legacy Bedrock routes
+	legacyAuthMiddleware := authMiddleware
+	if cfg.Auth.SigV4.Enabled {
+		legacyAuthMiddleware = newSigV4Auth(cfg.Auth.SigV4, apiKeyDB, guard, authMiddleware)
+		log.Println("✓ SigV4 authentication enabled for legacy Bedrock endpoints")
+	}
+
+	// Team permissions, rate limits and budgets for database API keys
+	var teamPolicy gin.HandlerFunc
+	if apiKeyDB != nil && authMiddleware != nil {
+		teamPolicy = middleware.TeamPolicy(apiKeyDB, middleware.NewRequestLimiter(), middleware.NewBudgetAlerts(notifier))
+	}
+
+	// Cost-center tags for usage records and provider metadata
+	var requestTags gin.HandlerFunc
+	if spec := cfg.Features.RequestTags; spec != "" {
+		allow, err := tags.ParseAllowlist(spec)
+		if err != nil {
+			log.Fatalf("Invalid request tags allowlist: %v", err)
+		}
+		requestTags = middleware.RequestTags(allow)
+		log.Printf("✓ Request tags enabled: %d allowed keys", len(allow))
+	}
 
 	// Initialize Gin router
 	ginRouter := gin.New()
@@ -190,103 +255,200 @@ func main() {
 	// Global middleware
 	ginRouter.Use(middleware.Recovery())
 	ginRouter.Use(middleware.RequestID())
+	ginRouter.Use(tracing.Middleware())
 	ginRouter.Use(middleware.Logger())
 	ginRouter.Use(middleware.Security())
 	ginRouter.Use(middleware.Metrics())
 
 	// Health endpoints (no auth required)
 	ginRouter.GET("/health", healthHandler(healthChecker))
-	ginRouter.GET("/ready", readyHandler(healthChecker, aiRouter))
+	ginRouter.GET("/ready", readyHandler(healthChecker, prober, modelChecker))
 	ginRouter.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	// Session authentication endpoints
+	if sessionManager != nil {
+		authHandler := handlers.NewAuthHandler(apiKeyDB, totpManager, sessionManager, cfg.Auth.Session.Duration, guard)
+
+		authGroup := ginRouter.Group("/auth")
+		{
+			authGroup.POST("/login", authHandler.Login)
+			authGroup.POST("/refresh", authHandler.Refresh)
+			authGroup.POST("/logout", authHandler.Logout)
+			authGroup.GET("/sessions", authHandler.ListSessions)
+			authGroup.DELETE("/sessions/:id", authHandler.RevokeSession)
+
+			// 2FA self-enrollment: session token or API key (plus a code once 2FA is on)
+			twoFAGroup := authGroup.Group("/2fa")
+			twoFAGroup.Use(middleware.HybridAuth(sessionManager, apiKeyDB, totpManager, false, guard))
+			{
+				twoFAGroup.POST("/enroll", authHandler.Enroll2FA)
+				twoFAGroup.POST("/confirm", authHandler.Confirm2FA)
+				twoFAGroup.POST("/backup-codes/regenerate", authHandler.RegenerateBackupCodes)
+				twoFAGroup.DELETE("", authHandler.Disable2FA)
+			}
+		}
+	}
+
+	// Admin endpoints (require the "admin" permission on the caller's API key)
+	if apiKeyDB != nil && authMiddleware != nil {
+		adminHandler := handlers.NewAdminHandler(apiKeyDB, sessionManager, totpManager, guard)
+		adminHandler.SetAuditKeys(auditKeys)
+		teamHandler := handlers.NewTeamHandler(apiKeyDB)
+
+		adminGroup := ginRouter.Group("/admin")
+		adminGroup.Use(authMiddleware, teamPolicy, middleware.RequirePermission("admin"))
+		{
+			adminGroup.GET("/lockouts", adminHandler.ListLockouts)
+			adminGroup.POST("/lockouts/unlock", adminHandler.Unlock)
+
+			adminGroup.GET("/keys", adminHandler.ListKeys)
+			adminGroup.POST("/keys", adminHandler.CreateKey)
+			adminGroup.DELETE("/keys/:id", adminHandler.RevokeKey)
+			adminGroup.POST("/keys/:id/rotate", adminHandler.RotateKey)
+			adminGroup.PUT("/keys/:id/tags", adminHandler.SetKeyTags)
+			adminGroup.POST("/keys/:id/2fa", adminHandler.EnrollKeyTOTP)
+			adminGroup.GET("/keys/:id/sessions", adminHandler.ListKeySessions)
+			adminGroup.DELETE("/keys/:id/sessions/:sessionID", adminHandler.RevokeKeySession)
+			adminGroup.POST("/keys/:id/sigv4", adminHandler.CreateKeySigV4)
+			adminGroup.GET("/keys/:id/sigv4", adminHandler.ListKeySigV4)
+			adminGroup.DELETE("/keys/:id/sigv4/:accessKeyID", adminHandler.RevokeKeySigV4)
+			adminGroup.GET("/audit", adminHandler.ListAudit)
+			adminGroup.GET("/audit/export", adminHandler.ExportAudit)
+			adminGroup.GET("/audit/verify", adminHandler.VerifyAudit)
+			adminGroup.GET("/usage", adminHandler.GetUsage)
+
+			adminGroup.GET("/teams", teamHandler.ListTeams)
+			adminGroup.POST("/teams", teamHandler.CreateTeam)
+			adminGroup.PUT("/teams/:id", teamHandler.UpdateTeam)
+			adminGroup.POST("/teams/:id/members", teamHandler.AddMember)
+			adminGroup.DELETE("/teams/:id/members/:email", teamHandler.RemoveMember)
+			adminGroup.POST("/teams/:id/keys", teamHandler.AssignKey)
+		}
+
+		// Team self-service: team admins manage their own team's keys
+		teamGroup := ginRouter.Group("/teams")
+		teamGroup.Use(authMiddleware, teamPolicy)
+		{
+			teamGroup.GET("/:id", teamHandler.GetTeam)
+			teamGroup.GET("/:id/members", teamHandler.ListMembers)
+			teamGroup.GET("/:id/keys", teamHandler.ListKeys)
+			teamGroup.POST("/:id/keys", teamHandler.CreateKey)
+			teamGroup.PATCH("/:id/keys/:keyID", teamHandler.UpdateKeyLimits)
+			teamGroup.DELETE("/:id/keys/:keyID", teamHandler.RevokeKey)
+		}
+	}
+
 	// OpenAI-compatible API endpoints
 	openaiGroup := ginRouter.Group("/v1")
 	if authEnabled {
 		log.Printf("Authentication enabled for OpenAI API: mode=%s", authMode)
-		openaiGroup.Use(getAuthMiddleware(authMode))
+		openaiGroup.Use(authMiddleware)
+	}
+	if teamPolicy != nil {
+		openaiGroup.Use(teamPolicy)
+	}
+	if requestTags != nil {
+		openaiGroup.Use(requestTags)
 	}
 	{
 		openaiGroup.POST("/chat/completions", openaiHandler.ChatCompletions)
 		openaiGroup.GET("/models", openaiHandler.ListModels)
 		openaiGroup.GET("/models/:model", openaiHandler.GetModel)
+		if apiKeyDB != nil {
+			openaiGroup.GET("/usage", handlers.NewUsageHandler(apiKeyDB).GetUsage)
+		}
 	}
 
 	// Native provider API endpoints
 	providersGroup := ginRouter.Group("/providers")
 	if authEnabled {
 		log.Printf("Authentication enabled for provider APIs: mode=%s", authMode)
-		providersGroup.Use(getAuthMiddleware(authMode))
+		providersGroup.Use(authMiddleware)
+	}
+	if teamPolicy != nil {
+		providersGroup.Use(teamPolicy)
+	}
+	if requestTags != nil {
+		providersGroup.Use(requestTags)
 	}
 	{
-		// Register native API endpoints for each provider
-		if bedrockProvider, ok := providerRegistry["bedrock"]; ok {
-			providersGroup.Any("/bedrock/*path", createProviderHandler(bedrockProvider, healthChecker))
-		}
-		if azureProvider, ok := providerRegistry["azure"]; ok {
-			providersGroup.Any("/azure/*path", createProviderHandler(azureProvider, healthChecker))
-		}
-		if openaiProvider, ok := providerRegistry["openai"]; ok {
-			providersGroup.Any("/openai/*path", createProviderHandler(openaiProvider, healthChecker))
-		}
-		if anthropicProvider, ok := providerRegistry["anthropic"]; ok {
-			providersGroup.Any("/anthropic/*path", createProviderHandler(anthropicProvider, healthChecker))
-		}
-		if vertexProvider, ok := providerRegistry["vertex"]; ok {
-			providersGroup.Any("/vertex/*path", createProviderHandler(vertexProvider, healthChecker))
-		}
-		if ibmProvider, ok := providerRegistry["ibm"]; ok {
-			providersGroup.Any("/ibm/*path", createProviderHandler(ibmProvider, healthChecker))
-		}
-		if oracleProvider, ok := providerRegistry["oracle"]; ok {
-			providersGroup.Any("/oracle/*path", createProviderHandler(oracleProvider, healthChecker))
+		// Register native API endpoints for each provider instance
+		for name, provider := range providerRegistry {
+			providersGroup.Any("/"+name+"/*path", createProviderHandler(provider, healthChecker, prober, apiKeyDB))
 		}
 	}
 
 	// Legacy endpoints (backward compatibility - Bedrock only)
-	if bedrockProvider, ok := providerRegistry["bedrock"]; ok {
+	if bedrockProvider := legacyBedrockProvider(providerRegistry); bedrockProvider != nil {
 		legacyGroup := ginRouter.Group("/")
-		if authEnabled {
-			legacyGroup.Use(getAuthMiddleware(authMode))
+		if legacyAuthMiddleware != nil {
+			legacyGroup.Use(legacyAuthMiddleware)
+		}
+		if teamPolicy != nil {
+			legacyGroup.Use(teamPolicy)
+		}
+		if requestTags != nil {
+			legacyGroup.Use(requestTags)
 		}
 		{
-			legacyGroup.Any("/v1/bedrock/*path", createProviderHandler(bedrockProvider, healthChecker))
-			legacyGroup.Any("/bedrock/*path", createProviderHandler(bedrockProvider, healthChecker))
-			legacyGroup.Any("/model/*path", createProviderHandler(bedrockProvider, healthChecker))
+			legacyGroup.Any("/v1/bedrock/*path", createProviderHandler(bedrockProvider, healthChecker, prober, apiKeyDB))
+			legacyGroup.Any("/bedrock/*path", createProviderHandler(bedrockProvider, healthChecker, prober, apiKeyDB))
+			legacyGroup.Any("/model/*path", createProviderHandler(bedrockProvider, healthChecker, prober, apiKeyDB))
 		}
 	}
 
 	// Print startup banner
 	printStartupBanner(port, tlsPort, tlsEnabled, authEnabled, enabledProviders)
 
-	// Start server(s)
+	// Start server(s) and serve until SIGTERM
+	serverConfig := cfg.Server
+	httpServer := newHTTPServer(fmt.Sprintf(":%s", port), ginRouter, serverConfig)
+	listeners := []listener{{name: "HTTP", server: httpServer, serve: httpServer.ListenAndServe}}
 	if tlsEnabled {
-		// Start HTTP server in goroutine
-		go func() {
-			addr := fmt.Sprintf(":%s", port)
-			log.Printf("Starting HTTP server on %s", addr)
-			if err := ginRouter.Run(addr); err != nil {
-				log.Fatalf("Failed to start HTTP server: %v", err)
+		tlsServer := newHTTPServer(fmt.Sprintf(":%s", tlsPort), ginRouter, serverConfig)
+		if certMapper != nil {
+			// Request and verify client certificates against the client CA bundle
+			tlsServer.TLSConfig = &tls.Config{
+				ClientCAs:  certMapper.ClientCAs(),
+				ClientAuth: tls.RequireAndVerifyClientCert,
+				MinVersion: tls.VersionTLS12,
 			}
-		}()
-
-		// Start HTTPS/TLS server (blocking)
-		addrTLS := fmt.Sprintf(":%s", tlsPort)
-		log.Printf("Starting HTTPS/TLS server on %s", addrTLS)
-		if err := ginRouter.RunTLS(addrTLS, tlsCertFile, tlsKeyFile); err != nil {
-			log.Fatalf("Failed to start HTTPS/TLS server: %v", err)
+			log.Println("Client certificates required on TLS listener")
 		}
-	} else {
-		// Start HTTP server only
-		addr := fmt.Sprintf(":%s", port)
-		log.Printf("Starting HTTP server on %s", addr)
-		if err := ginRouter.Run(addr); err != nil {
-			log.Fatalf("Failed to start HTTP server: %v", err)
+		listeners = append(listeners, listener{
+			name:   "HTTPS/TLS",
+			server: tlsServer,
+			serve:  func() error { return tlsServer.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile) },
+		})
+	}
+	serveUntilSignal(listeners, healthChecker, serverConfig)
+
+	// Stop background work, flush queued notifications, transcripts and
+	// spans, then close the databases
+	stopBackground()
+	backgroundWorkers.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
+	defer cancel()
+	if err := notifier.Close(ctx); err != nil {
+		log.Printf("Pending notifications dead-lettered: %v", err)
+	}
+	if err := transcripts.Close(); err != nil {
+		log.Printf("Failed to close transcript logger: %v", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+	if apiKeyDB != nil {
+		if err := apiKeyDB.Close(); err != nil {
+			log.Printf("Failed to close API key database: %v", err)
 		}
 	}
+	log.Println("✓ Shutdown complete")
 }
 
 // createProviderHandler creates a handler for native provider API
-func createProviderHandler(provider providers.Provider, healthChecker *health.Checker) gin.HandlerFunc {
+func createProviderHandler(provider providers.Provider, healthChecker *health.Checker, prober *health.Prober, usage *auth.APIKeyDB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Extract path after the prefix
 		path := c.Param("path")
@@ -314,6 +476,9 @@ func createProviderHandler(provider providers.Provider, healthChecker *health.Ch
 
 		// Invoke provider
 		resp, err := provider.Invoke(c.Request.Context(), providerReq)
+		if c.Request.Context().Err() == nil {
+			prober.Record(provider.Name(), providers.ProviderFault(err))
+		}
 		if err != nil {
 			healthChecker.RecordError()
 			if providerErr, ok := err.(*providers.ProviderError); ok {
@@ -325,6 +490,7 @@ func createProviderHandler(provider providers.Provider, healthChecker *health.Ch
 		}
 
 		healthChecker.RecordSuccess()
+		recordNativeUsage(c, usage, provider.Name(), resp.Metadata)
 
 		// Return response
 		for key, value := range resp.Headers {
@@ -334,9 +500,46 @@ func createProviderHandler(provider providers.Provider, healthChecker *health.Ch
 	}
 }
 
+// recordNativeUsage adds a native API call to the usage records when the
+// provider reported its token usage or cost
+func recordNativeUsage(c *gin.Context, usage *auth.APIKeyDB, providerName string, meta providers.ResponseMetadata) {
+	if usage == nil || (meta.InputTokens == 0 && meta.OutputTokens == 0 && meta.TotalCost == 0) {
+		return
+	}
+
+	record := &auth.UsageRecord{
+		RequestID:     c.GetString("request_id"),
+		APIKeyID:      c.GetInt64("api_key_id"),
+		Provider:      providerName,
+		Model:         meta.ModelUsed,
+		ProviderModel: meta.ModelUsed,
+		InputTokens:   meta.InputTokens,
+		OutputTokens:  meta.OutputTokens,
+		CostUSD:       meta.TotalCost,
+		Tags:          c.GetStringMapString("request_tags"),
+	}
+	if policy, ok := c.Get("key_policy"); ok {
+		if p, ok := policy.(*auth.KeyPolicy); ok {
+			record.TeamID = p.TeamID
+		}
+	}
+	if err := usage.RecordUsage(record); err != nil {
+		log.Printf("Failed to record usage for %s: %v", record.RequestID, err)
+	}
+}
+
 // getAuthMiddleware returns the appropriate auth middleware
-func getAuthMiddleware(authMode string) gin.HandlerFunc {
-	switch authMode {
+func getAuthMiddleware(
+	authConfig config.AuthConfig,
+	certMapper *auth.CertMapper,
+	apiKeyDB *auth.APIKeyDB,
+	sessionManager *auth.SessionManager,
+	totpManager *auth.TOTPManager,
+	guard *auth.BruteForceGuard,
+) gin.HandlerFunc {
+	require2FA := authConfig.Require2FA
+
+	switch authConfig.Mode {
 	case "api_key":
 		apiKeys := middleware.LoadAPIKeysFromEnv()
 		if len(apiKeys) == 0 {
@@ -346,25 +549,56 @@ func getAuthMiddleware(authMode string) gin.HandlerFunc {
 		return middleware.APIKeyAuth(apiKeys)
 
 	case "basic":
-		credentials := loadBasicAuthCredentials()
-		if len(credentials) == 0 {
-			log.Fatal("Basic auth enabled but no credentials found")
-		}
-		return middleware.BasicAuth(credentials)
+		return middleware.BasicAuth(authConfig.BasicCredentials)
 
 	case "service_account":
-		allowedSAs := loadAllowedServiceAccounts()
-		if len(allowedSAs) == 0 {
-			log.Fatal("Service account auth enabled but no allowed accounts found")
+		return middleware.ServiceAccountAuth(authConfig.ServiceAccounts)
+
+	case "api_key_db":
+		if apiKeyDB == nil {
+			log.Fatal("Database API key auth enabled but no auth database is configured")
+		}
+		return middleware.EnhancedAPIKeyAuth(apiKeyDB, totpManager, require2FA, guard)
+
+	case "session":
+		if apiKeyDB == nil {
+			log.Fatal("Session auth enabled but no auth database is configured")
+		}
+		return middleware.SessionTokenAuth(sessionManager, apiKeyDB, guard)
+
+	case "hybrid":
+		if apiKeyDB == nil {
+			log.Fatal("Hybrid auth enabled but no auth database is configured")
 		}
-		return middleware.ServiceAccountAuth(allowedSAs)
+		return middleware.HybridAuth(sessionManager, apiKeyDB, totpManager, require2FA, guard)
+
+	case "mtls":
+		// API key database is optional; it enables the second factor and audit log
+		return middleware.MTLSAuth(certMapper, apiKeyDB, guard)
+
+	case "sigv4":
+		return newSigV4Auth(authConfig.SigV4, apiKeyDB, guard, nil)
 
 	default:
-		log.Printf("Unknown auth mode: %s, running without auth", authMode)
+		log.Printf("Unknown auth mode: %s, running without auth", authConfig.Mode)
 		return func(c *gin.Context) { c.Next() }
 	}
 }
 
+// newSigV4Auth verifies SigV4 signed requests with the sigv4 settings,
+// passing unsigned requests to fallback or rejecting them when it is nil
+func newSigV4Auth(sigV4 config.SigV4Config, apiKeyDB *auth.APIKeyDB, guard *auth.BruteForceGuard, fallback gin.HandlerFunc) gin.HandlerFunc {
+	if apiKeyDB == nil {
+		log.Fatal("SigV4 auth requires an auth database (auth.database)")
+	}
+	verifier := auth.NewSigV4Verifier(apiKeyDB, auth.SigV4VerifierConfig{
+		Service:              sigV4.Service,
+		Regions:              sigV4.Regions,
+		AllowUnsignedPayload: sigV4.AllowUnsignedPayload,
+	})
+	return middleware.SigV4Auth(verifier, apiKeyDB, guard, fallback)
+}
+
 func healthHandler(checker *health.Checker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if checker.IsHealthy() {
@@ -381,67 +615,340 @@ func healthHandler(checker *health.Checker) gin.HandlerFunc {
 	}
 }
 
-func readyHandler(checker *health.Checker, aiRouter *router.Router) gin.HandlerFunc {
+// readyHandler reports ready when the service is and every provider marked
+// required in the router config is healthy, listing each provider's health
+// and, when synthetic checks run, each model's
+func readyHandler(checker *health.Checker, prober *health.Prober, models *health.ModelChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Check if providers are healthy
-		healthResults := aiRouter.HealthCheck(c.Request.Context())
-		allHealthy := true
-		for _, err := range healthResults {
-			if err != nil {
-				allHealthy = false
-				break
-			}
+		providersReady, statuses := prober.Ready()
+		body := gin.H{
+			"status":    "ready",
+			"providers": statuses,
 		}
-
-		if checker.IsHealthy() && allHealthy {
-			c.JSON(200, gin.H{
-				"status": "ready",
-			})
+		if models != nil {
+			body["models"] = models.Statuses()
+		}
+		if checker.IsReady() && providersReady {
+			c.JSON(200, body)
 		} else {
-			c.JSON(503, gin.H{
-				"status": "not ready",
-			})
+			body["status"] = "not ready"
+			c.JSON(503, body)
 		}
 	}
 }
 
-func loadBasicAuthCredentials() map[string]string {
-	creds := make(map[string]string)
+// newProviderProber probes the enabled providers in the background using
+// the router config's health_checks settings, notifying outages and
+// recoveries
+func newProviderProber(cfg *router.Config, registry map[string]providers.Provider, notifier *notify.Notifier) *health.Prober {
+	probeConfig := health.ProberConfig{
+		Interval:         cfg.HealthChecks.Interval,
+		Timeout:          cfg.HealthChecks.Timeout,
+		Window:           cfg.HealthChecks.Window,
+		ErrorThreshold:   cfg.HealthChecks.ErrorThreshold,
+		MinRequests:      cfg.HealthChecks.MinRequests,
+		FailureThreshold: cfg.HealthChecks.FailureThreshold,
+		Required:         cfg.ListRequiredProviders(),
+	}
+	if notifier != nil {
+		probeConfig.OnChange = notifyProviderChange(notifier)
+	}
+	prober := health.NewProber(probeConfig)
+	for name, provider := range registry {
+		if cfg.IsProviderEnabled(name) {
+			prober.Add(name, provider.HealthCheck)
+		}
+	}
+	return prober
+}
 
-	// Load from BASIC_AUTH_CREDENTIALS env var (format: user1:pass1,user2:pass2)
-	if credsEnv := os.Getenv("BASIC_AUTH_CREDENTIALS"); credsEnv != "" {
-		for _, pair := range strings.Split(credsEnv, ",") {
-			parts := strings.SplitN(pair, ":", 2)
-			if len(parts) == 2 {
-				creds[parts[0]] = parts[1]
+// newModelChecker checks every mapped model on each of its enabled
+// providers, or returns nil when synthetic checks are disabled
+func newModelChecker(cfg *router.Config, registry map[string]providers.Provider) *health.ModelChecker {
+	if !cfg.HealthChecks.Models.Enabled {
+		return nil
+	}
+	checker := health.NewModelChecker(health.ModelCheckerConfig{
+		Interval:         cfg.HealthChecks.Models.Interval,
+		Timeout:          cfg.HealthChecks.Models.Timeout,
+		FailureThreshold: cfg.HealthChecks.Models.FailureThreshold,
+		Concurrency:      cfg.HealthChecks.Models.Concurrency,
+	})
+	for model, mapping := range cfg.ModelMappings {
+		for name := range mapping.Providers {
+			provider, ok := registry[name]
+			if !ok || !cfg.IsProviderEnabled(name) {
+				continue
 			}
+			checker.Add(model, name, handlers.ModelCheck(provider, model))
 		}
 	}
+	return checker
+}
+
+// newBruteForceGuard builds the lockout guard from the lockout settings
+func newBruteForceGuard(apiKeyDB *auth.APIKeyDB, lockout config.LockoutConfig) *auth.BruteForceGuard {
+	policy := auth.LockoutPolicy{
+		Threshold:   lockout.Threshold,
+		BaseLockout: lockout.BaseDuration,
+		MaxLockout:  lockout.MaxDuration,
+		ResetAfter:  lockout.ResetAfter,
+	}
 
-	return creds
+	var store auth.LockoutStore
+	switch lockout.Store {
+	case "memory":
+		store = auth.NewMemoryLockoutStore()
+	case "sqlite", "database":
+		// Shares the auth database, so PostgreSQL-backed replicas share lockouts
+		dbStore, err := auth.NewDBLockoutStore(apiKeyDB.DB())
+		if err != nil {
+			log.Fatalf("Failed to create lockout store: %v", err)
+		}
+		store = dbStore
+	default:
+		log.Fatalf("Unknown lockout store: %s (expected memory or database)", lockout.Store)
+	}
+
+	log.Printf("✓ Brute-force protection enabled (threshold %d, base lockout %s)", policy.Threshold, policy.BaseLockout)
+	return auth.NewBruteForceGuard(store, policy)
 }
 
-func loadAllowedServiceAccounts() []string {
-	var accounts []string
+// loadTOTPOptions loads the TOTP master keys and lockout settings
+func loadTOTPOptions(totp config.TOTPConfig) auth.TOTPOptions {
+	options := auth.TOTPOptions{
+		MaxFailedAttempts: totp.MaxFailedAttempts,
+		LockoutDuration:   totp.LockoutDuration,
+	}
 
-	// Load from ALLOWED_SERVICE_ACCOUNTS env var (format: ns1/sa1,ns2/sa2)
-	if sasEnv := os.Getenv("ALLOWED_SERVICE_ACCOUNTS"); sasEnv != "" {
-		for _, sa := range strings.Split(sasEnv, ",") {
-			sa = strings.TrimSpace(sa)
-			if sa != "" {
-				accounts = append(accounts, sa)
-			}
+	if totp.MasterKeyFile == "" {
+		log.Println("⚠️  TOTP master key not set - TOTP secrets are stored unencrypted")
+		return options
+	}
+	keyring, err := auth.LoadKeyring(totp.MasterKeyFile, totp.PreviousMasterKeyFiles...)
+	if err != nil {
+		log.Fatalf("Failed to load TOTP master key: %v", err)
+	}
+	options.Keyring = keyring
+	log.Printf("✓ TOTP secrets encrypted at rest (master key %s)", keyring.PrimaryID())
+	return options
+}
+
+// loadTranscriptLogger starts request/response transcript logging when path
+// names a config file with logging enabled
+func loadTranscriptLogger(path string) *transcript.Logger {
+	if path == "" {
+		return nil
+	}
+
+	cfg, err := transcript.LoadConfig(path)
+	if err != nil {
+		log.Fatalf("Failed to load transcript config: %v", err)
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	transcripts, err := transcript.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to start transcript logging: %v", err)
+	}
+	log.Printf("✓ Transcript logging enabled: %s (%d redaction rules)", cfg.Dir, len(cfg.Redact))
+	return transcripts
+}
+
+// loadNotifier starts webhook notifications when path names a config file
+// with notifications enabled
+func loadNotifier(path string) *notify.Notifier {
+	if path == "" {
+		return nil
+	}
+
+	cfg, err := notify.LoadConfig(path)
+	if err != nil {
+		log.Fatalf("Failed to load notification config: %v", err)
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	notifier, err := notify.New(cfg)
+	if err != nil {
+		log.Fatalf("Invalid notification config: %v", err)
+	}
+	log.Printf("✓ Webhook notifications enabled (%d destinations)", len(cfg.Destinations))
+	return notifier
+}
+
+// notifyProviderChange reports providers becoming unhealthy or recovering
+func notifyProviderChange(notifier *notify.Notifier) func(health.ProviderStatus) {
+	return func(status health.ProviderStatus) {
+		data := map[string]any{
+			"provider":   status.Name,
+			"status":     status.Status,
+			"required":   status.Required,
+			"error_rate": status.ErrorRate,
+		}
+		if status.Status != health.StatusUnhealthy {
+			notifier.Notify(notify.EventProviderRecovered, notify.SeverityInfo,
+				fmt.Sprintf("Provider %s recovered", status.Name), data)
+			return
 		}
+
+		severity := notify.SeverityWarning
+		if status.Required {
+			severity = notify.SeverityCritical
+		}
+		data["last_error"] = status.LastError
+		notifier.Notify(notify.EventProviderUnhealthy, severity,
+			fmt.Sprintf("Provider %s is unhealthy", status.Name), data)
+	}
+}
+
+// notifyLockout reports clients and keys locked out after repeated failed
+// logins
+func notifyLockout(notifier *notify.Notifier) func(auth.LockoutState) {
+	return func(state auth.LockoutState) {
+		notifier.Notify(notify.EventAuthLockout, notify.SeverityWarning,
+			fmt.Sprintf("%s locked out after %d failed logins", state.Subject, state.Failures),
+			map[string]any{
+				"subject":      state.Subject,
+				"failures":     state.Failures,
+				"locked_until": state.LockedUntil.UTC().Format(time.RFC3339),
+			})
+	}
+}
+
+// loadDLPScanner enables PII detection on chat prompts when path names a
+// config file with detection enabled
+func loadDLPScanner(path string) *dlp.Scanner {
+	if path == "" {
+		return nil
 	}
 
-	return accounts
+	cfg, err := dlp.LoadConfig(path)
+	if err != nil {
+		log.Fatalf("Failed to load DLP config: %v", err)
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+
+	scanner, err := dlp.New(cfg)
+	if err != nil {
+		log.Fatalf("Invalid DLP config: %v", err)
+	}
+	log.Printf("✓ PII detection enabled (default policy: %s)", cfg.Policy.Default)
+	return scanner
+}
+
+// setupTracing exports spans over OTLP when tracing is enabled. The
+// collector endpoint and sampler come from the standard OTEL_* variables.
+func setupTracing(tracingConfig config.TracingConfig) func(context.Context) error {
+	if !tracingConfig.Enabled {
+		return func(context.Context) error { return nil }
+	}
+
+	ctx := context.Background()
+	exporter, err := tracing.NewOTLPExporter(ctx)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	serviceName := tracingConfig.ServiceName
+	shutdown, err := tracing.Setup(ctx, tracing.Config{ServiceName: serviceName, Exporter: exporter})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	log.Printf("✓ Tracing enabled (service: %s)", serviceName)
+	return shutdown
+}
+
+// startAuditRetention archives audit records older than the retention
+// period to the archive directory in the background; retention is off by
+// default
+func startAuditRetention(ctx context.Context, workers *sync.WaitGroup, apiKeyDB *auth.APIKeyDB, audit config.AuditConfig) {
+	if audit.RetentionDays == 0 {
+		return
+	}
+
+	archiver := auth.NewAuditArchiver(apiKeyDB, audit.ArchiveDir, time.Duration(audit.RetentionDays)*24*time.Hour)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		archiver.Run(ctx, audit.RetentionInterval)
+	}()
+	log.Printf("✓ Audit retention enabled: records older than %d days archived to %s", audit.RetentionDays, audit.ArchiveDir)
+}
+
+// startAuditCheckpoints signs the audit chain head every checkpoint
+// interval with the signing key. It returns the keys that verify
+// checkpoints: the signing key plus the verify keys of retired signing keys.
+func startAuditCheckpoints(ctx context.Context, workers *sync.WaitGroup, apiKeyDB *auth.APIKeyDB, audit config.AuditConfig) []ed25519.PublicKey {
+	var keys []ed25519.PublicKey
+	if len(audit.VerifyKeyFiles) > 0 {
+		var err error
+		if keys, err = auth.LoadAuditPublicKeys(audit.VerifyKeyFiles...); err != nil {
+			log.Fatalf("Failed to load audit verify keys: %v", err)
+		}
+	}
+
+	if audit.SigningKeyFile == "" {
+		log.Println("⚠️  Audit signing key not set - audit hash chain is not checkpointed")
+		return keys
+	}
+
+	signer, err := auth.LoadAuditSigner(audit.SigningKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load audit signing key: %v", err)
+	}
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		apiKeyDB.RunAuditCheckpoints(ctx, signer, audit.CheckpointInterval)
+	}()
+	log.Printf("✓ Audit checkpoints enabled: signed every %s with key %s", audit.CheckpointInterval, signer.KeyID())
+	return append(keys, signer.PublicKey())
+}
+
+// loadStorageConfig selects the auth database backend; ok is false when no
+// database is configured
+func loadStorageConfig(dbConfig config.DatabaseConfig) (storage.Config, bool) {
+	if !dbConfig.Configured() {
+		return storage.Config{}, false
+	}
+
+	cfg := storage.Config{
+		Backend:         dbConfig.Backend,
+		MaxOpenConns:    dbConfig.MaxOpenConns,
+		ConnMaxLifetime: dbConfig.ConnMaxLifetime,
+	}
+	switch cfg.Backend {
+	case storage.BackendSQLite:
+		cfg.DSN = dbConfig.Path
+	case storage.BackendPostgres:
+		cfg.DSN = dbConfig.DSN
+		if dbConfig.DSNFile != "" {
+			data, err := os.ReadFile(dbConfig.DSNFile)
+			if err != nil {
+				log.Fatalf("Failed to read auth database DSN file: %v", err)
+			}
+			cfg.DSN = strings.TrimSpace(string(data))
+		}
+	}
+	return cfg, true
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// sessionPolicy converts the session settings to the session manager's policy
+func sessionPolicy(session config.SessionConfig) auth.SessionPolicy {
+	return auth.SessionPolicy{
+		BindIP:            session.BindIP,
+		BindSubnetV4:      session.BindSubnetV4,
+		BindSubnetV6:      session.BindSubnetV6,
+		BindUserAgent:     session.BindUserAgent,
+		IdleTimeout:       session.IdleTimeout,
+		MaxSessionsPerKey: session.MaxPerKey,
 	}
-	return defaultValue
 }
 
 func printStartupBanner(port, tlsPort string, tlsEnabled, authEnabled bool, enabledProviders []string) {
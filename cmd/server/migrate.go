@@ -0,0 +1,122 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/config"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+const migrateUsage = `Usage: bedrock-proxy migrate <command>
+
+Commands:
+  status   List auth database migrations and whether they are applied
+  up       Apply pending migrations
+
+The database is selected by auth.database in the SERVER_CONFIG file, or with
+AUTH_DB_BACKEND, AUTH_DB_PATH and AUTH_DB_DSN.
+`
+
+// runMigrate implements the migrate subcommand and returns the exit code
+func runMigrate(args []string) int {
+	if len(args) != 1 || (args[0] != "status" && args[0] != "up") {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	serverConfig, err := config.Load(os.Getenv("SERVER_CONFIG"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load server config: %v\n", err)
+		return 1
+	}
+	cfg, ok := loadStorageConfig(serverConfig.Auth.Database)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "No auth database configured (set AUTH_DB_PATH or AUTH_DB_BACKEND)")
+		return 1
+	}
+
+	store, err := storage.Open(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open auth database: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	migrator, err := auth.NewMigrator(store)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load migrations: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+
+	case "status":
+		status, err := migrator.Status()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range status {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state = "applied"
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		w.Flush()
+	}
+
+	return 0
+}
+
+// migrateOnStartup applies pending migrations, or without autoMigrate
+// refuses to start until they have been applied with "migrate up"
+func migrateOnStartup(store storage.Store, autoMigrate bool) {
+	migrator, err := auth.NewMigrator(store)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	if !autoMigrate {
+		pending, err := migrator.Pending()
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		if len(pending) > 0 {
+			log.Fatalf("Auth database has %d pending migrations; run \"bedrock-proxy migrate up\"", len(pending))
+		}
+		return
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		log.Fatalf("Failed to migrate auth database: %v", err)
+	}
+	for _, m := range applied {
+		log.Printf("✓ Applied auth database migration %04d_%s", m.Version, m.Name)
+	}
+}
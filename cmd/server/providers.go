@@ -0,0 +1,175 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/config"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/anthropic"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/azure"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/bedrock"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/ibm"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/openai"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/oracle"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers/vertex"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/router"
+)
+
+// newProviderRegistry registers the factory for each built-in provider type
+func newProviderRegistry() *providers.Registry {
+	registry := providers.NewRegistry()
+	registry.Register("bedrock", func(s providers.Settings) (providers.Provider, error) {
+		if s.Region == "" {
+			return nil, fmt.Errorf("Bedrock region is required")
+		}
+		return build(bedrock.NewBedrockProvider(s.Region))
+	})
+	registry.Register("azure", func(s providers.Settings) (providers.Provider, error) {
+		return build(azure.NewAzureProvider(azure.AzureConfig{
+			Endpoint:   s.Endpoint,
+			APIKey:     s.APIKey,
+			APIVersion: s.APIVersion,
+		}))
+	})
+	registry.Register("openai", func(s providers.Settings) (providers.Provider, error) {
+		return build(openai.NewOpenAIProvider(openai.OpenAIConfig{APIKey: s.APIKey, BaseURL: s.BaseURL}))
+	})
+	registry.Register("anthropic", func(s providers.Settings) (providers.Provider, error) {
+		return build(anthropic.NewAnthropicProvider(anthropic.AnthropicConfig{APIKey: s.APIKey, BaseURL: s.BaseURL}))
+	})
+	registry.Register("vertex", func(s providers.Settings) (providers.Provider, error) {
+		return build(vertex.NewVertexProvider(vertex.VertexConfig{
+			ProjectID:   s.ProjectID,
+			Location:    s.Location,
+			AccessToken: s.AccessToken, // Or use Application Default Credentials
+		}))
+	})
+	registry.Register("ibm", func(s providers.Settings) (providers.Provider, error) {
+		return build(ibm.NewIBMProvider(ibm.IBMConfig{APIKey: s.APIKey, ProjectID: s.ProjectID, BaseURL: s.BaseURL}))
+	})
+	registry.Register("oracle", func(s providers.Settings) (providers.Provider, error) {
+		return build(oracle.NewOracleProvider(oracle.OracleConfig{
+			Endpoint:      s.Endpoint,
+			AuthToken:     s.AuthToken,
+			CompartmentID: s.CompartmentID,
+		}))
+	})
+	return registry
+}
+
+// build returns a constructed provider as a Provider, keeping a failed
+// construction a nil interface
+func build[P providers.Provider](provider P, err error) (providers.Provider, error) {
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// buildProviders creates every enabled provider instance declared in the
+// router config. An instance named after its type, such as "openai", fills
+// the settings its declaration leaves empty from the server config (and its
+// environment variables), so existing deployments keep their credentials;
+// other instances are configured only by their declaration. Instances that
+// fail to build, usually for lack of credentials, are logged and skipped; an
+// unknown type is fatal.
+func buildProviders(registry *providers.Registry, routerConfig *router.Config, server config.ProvidersConfig) map[string]providers.Provider {
+	names := make([]string, 0, len(routerConfig.Providers))
+	for name := range routerConfig.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	instances := make(map[string]providers.Provider)
+	for _, name := range names {
+		declared := routerConfig.Providers[name]
+		if !declared.Enabled {
+			continue
+		}
+		providerType := routerConfig.ProviderType(name)
+		if !registry.Has(providerType) {
+			log.Fatalf("Provider %s has unknown type %q (expected one of %v)", name, providerType, registry.Types())
+		}
+
+		settings := declared.Settings
+		if name == providerType {
+			settings = overlaySettings(settings, serverSettings(server, providerType))
+		}
+		provider, err := registry.Build(name, providerType, settings)
+		if err != nil {
+			log.Printf("Skipping provider %s: %v", name, err)
+			continue
+		}
+		instances[name] = provider
+		log.Printf("✓ Provider %s initialized (type: %s)", name, providerType)
+	}
+	return instances
+}
+
+// serverSettings returns the server config's settings for a provider type
+func serverSettings(server config.ProvidersConfig, providerType string) providers.Settings {
+	switch providerType {
+	case "bedrock":
+		return providers.Settings{Region: server.Bedrock.Region}
+	case "azure":
+		return providers.Settings{Endpoint: server.Azure.Endpoint, APIKey: server.Azure.APIKey, APIVersion: server.Azure.APIVersion}
+	case "openai":
+		return providers.Settings{APIKey: server.OpenAI.APIKey, BaseURL: server.OpenAI.BaseURL}
+	case "anthropic":
+		return providers.Settings{APIKey: server.Anthropic.APIKey, BaseURL: server.Anthropic.BaseURL}
+	case "vertex":
+		return providers.Settings{ProjectID: server.Vertex.ProjectID, Location: server.Vertex.Location, AccessToken: server.Vertex.AccessToken}
+	case "ibm":
+		return providers.Settings{APIKey: server.IBM.APIKey, ProjectID: server.IBM.ProjectID, BaseURL: server.IBM.BaseURL}
+	case "oracle":
+		return providers.Settings{Endpoint: server.Oracle.Endpoint, AuthToken: server.Oracle.AuthToken, CompartmentID: server.Oracle.CompartmentID}
+	}
+	return providers.Settings{}
+}
+
+// overlaySettings returns base with its empty fields taken from fallback
+func overlaySettings(base, fallback providers.Settings) providers.Settings {
+	first := func(a, b string) string {
+		if a != "" {
+			return a
+		}
+		return b
+	}
+	return providers.Settings{
+		Region:        first(base.Region, fallback.Region),
+		Location:      first(base.Location, fallback.Location),
+		ProjectID:     first(base.ProjectID, fallback.ProjectID),
+		Endpoint:      first(base.Endpoint, fallback.Endpoint),
+		BaseURL:       first(base.BaseURL, fallback.BaseURL),
+		APIVersion:    first(base.APIVersion, fallback.APIVersion),
+		APIKey:        first(base.APIKey, fallback.APIKey),
+		AuthToken:     first(base.AuthToken, fallback.AuthToken),
+		AccessToken:   first(base.AccessToken, fallback.AccessToken),
+		CompartmentID: first(base.CompartmentID, fallback.CompartmentID),
+	}
+}
+
+// legacyBedrockProvider returns the provider behind the legacy Bedrock
+// routes: the instance named "bedrock", else the first bedrock instance by
+// name, or nil when there is none
+func legacyBedrockProvider(instances map[string]providers.Provider) providers.Provider {
+	if provider, ok := instances["bedrock"]; ok {
+		return provider
+	}
+	names := make([]string, 0, len(instances))
+	for name, provider := range instances {
+		if providers.TypeOf(provider) == "bedrock" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return instances[names[0]]
+}
@@ -0,0 +1,114 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/config"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/router"
+)
+
+// stubProvider is a provider of a given type that remembers its settings
+type stubProvider struct {
+	providerType string
+	settings     providers.Settings
+}
+
+func (s *stubProvider) Name() string                          { return s.providerType }
+func (s *stubProvider) HealthCheck(ctx context.Context) error { return nil }
+func (s *stubProvider) Invoke(ctx context.Context, request *providers.ProviderRequest) (*providers.ProviderResponse, error) {
+	return &providers.ProviderResponse{StatusCode: 200}, nil
+}
+func (s *stubProvider) InvokeStreaming(ctx context.Context, request *providers.ProviderRequest) (io.ReadCloser, error) {
+	return nil, errors.New("not supported")
+}
+func (s *stubProvider) ListModels(ctx context.Context) ([]providers.Model, error) { return nil, nil }
+func (s *stubProvider) GetModelInfo(ctx context.Context, modelID string) (*providers.Model, error) {
+	return nil, errors.New("not found")
+}
+
+// stubRegistry registers bedrock and openai factories that require a
+// region and an API key respectively
+func stubRegistry() *providers.Registry {
+	registry := providers.NewRegistry()
+	registry.Register("bedrock", func(s providers.Settings) (providers.Provider, error) {
+		if s.Region == "" {
+			return nil, errors.New("region is required")
+		}
+		return &stubProvider{providerType: "bedrock", settings: s}, nil
+	})
+	registry.Register("openai", func(s providers.Settings) (providers.Provider, error) {
+		if s.APIKey == "" {
+			return nil, errors.New("API key is required")
+		}
+		return &stubProvider{providerType: "openai", settings: s}, nil
+	})
+	return registry
+}
+
+// settingsOf returns the settings an instance named after its type was
+// built with
+func settingsOf(t *testing.T, p providers.Provider) providers.Settings {
+	t.Helper()
+	stub, ok := p.(*stubProvider)
+	if !ok {
+		t.Fatalf("Unexpected provider %T", p)
+	}
+	return stub.settings
+}
+
+func TestBuildProviders(t *testing.T) {
+	server := config.Default().Providers
+	server.OpenAI.APIKey = "sk-server"
+
+	routerConfig := &router.Config{Providers: map[string]router.ProviderConfig{
+		"bedrock": {Enabled: true, Settings: providers.Settings{Region: "eu-west-1"}},
+		"bedrock-ap": {Type: "bedrock", Enabled: true, Settings: providers.Settings{
+			Region: "ap-southeast-2",
+		}},
+		"bedrock-unset": {Type: "bedrock", Enabled: true},
+		"openai":        {Enabled: true},
+		"openai-off":    {Type: "openai", Enabled: false, Settings: providers.Settings{APIKey: "sk-off"}},
+	}}
+
+	instances := buildProviders(stubRegistry(), routerConfig, server)
+
+	if len(instances) != 3 {
+		t.Fatalf("Expected bedrock, bedrock-ap and openai, got %v", instances)
+	}
+	if _, ok := instances["bedrock-unset"]; ok {
+		t.Error("Expected an instance without settings to be skipped, not to take the server config")
+	}
+	if _, ok := instances["openai-off"]; ok {
+		t.Error("Expected a disabled instance to be skipped")
+	}
+
+	if got := settingsOf(t, instances["bedrock"]).Region; got != "eu-west-1" {
+		t.Errorf("Expected the declared region to win over the server default, got %q", got)
+	}
+	if providers.TypeOf(instances["bedrock-ap"]) != "bedrock" || instances["bedrock-ap"].Name() != "bedrock-ap" {
+		t.Error("Expected a named bedrock instance")
+	}
+
+	openai := settingsOf(t, instances["openai"])
+	if openai.APIKey != "sk-server" || openai.BaseURL != "https://api.openai.com/v1" {
+		t.Errorf("Expected empty declared settings filled from the server config, got %+v", openai)
+	}
+}
+
+func TestOverlaySettings(t *testing.T) {
+	got := overlaySettings(
+		providers.Settings{Region: "eu-west-1", APIKey: "declared"},
+		providers.Settings{Region: "us-east-1", APIKey: "server", BaseURL: "https://example.com"},
+	)
+	want := providers.Settings{Region: "eu-west-1", APIKey: "declared", BaseURL: "https://example.com"}
+	if got != want {
+		t.Errorf("overlaySettings() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/config"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/health"
+)
+
+// newHTTPServer creates a server for handler on addr with the configured
+// limits
+func newHTTPServer(addr string, handler http.Handler, cfg config.ServerConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// listener is a server and the call that runs it, e.g. ListenAndServe
+type listener struct {
+	name   string
+	server *http.Server
+	serve  func() error
+}
+
+// handlerExitTimeout bounds the wait for handlers to return once their
+// connections were closed at the drain deadline
+const handlerExitTimeout = 5 * time.Second
+
+// serveUntilSignal runs the listeners until SIGTERM or SIGINT, then marks
+// the service not ready, waits ShutdownDelay and drains in-flight requests
+// for up to ShutdownTimeout before closing what remains. It returns once
+// every handler has returned, so callers can close what handlers use. A
+// listener failing to start is fatal.
+func serveUntilSignal(listeners []listener, checker *health.Checker, cfg config.ServerConfig) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	serveUntil(listeners, checker, cfg, signals)
+}
+
+// serveUntil runs the listeners until a signal arrives on stop and then
+// shuts them down as serveUntilSignal describes
+func serveUntil(listeners []listener, checker *health.Checker, cfg config.ServerConfig, stop <-chan os.Signal) {
+	var handlers handlerTracker
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		l.server.Handler = handlers.wrap(l.server.Handler)
+		go func(l listener) {
+			log.Printf("Starting %s server on %s", l.name, l.server.Addr)
+			if err := l.serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}(l)
+	}
+
+	select {
+	case err := <-errs:
+		log.Fatalf("Failed to start server: %v", err)
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down", sig)
+	}
+
+	checker.SetReady(false)
+	if cfg.ShutdownDelay > 0 {
+		log.Printf("Not ready; serving for %s before closing listeners", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(l listener) {
+			defer wg.Done()
+			if err := l.server.Shutdown(ctx); err != nil {
+				log.Printf("%s server did not drain within %s, closing remaining connections: %v", l.name, cfg.ShutdownTimeout, err)
+				l.server.Close()
+			}
+		}(l)
+	}
+	wg.Wait()
+
+	// Close does not wait for handlers; their request contexts are now
+	// cancelled, so give them a moment to notice and return
+	if !handlers.wait(handlerExitTimeout) {
+		log.Printf("Handlers still running %s after connections were closed", handlerExitTimeout)
+	}
+	log.Println("✓ Servers stopped")
+}
+
+// handlerTracker counts running handlers
+type handlerTracker struct {
+	mu     sync.Mutex
+	active int
+	// idle is closed when the last running handler returns
+	idle chan struct{}
+}
+
+// wrap counts next's requests while they run
+func (t *handlerTracker) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.add(1)
+		defer t.add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (t *handlerTracker) add(delta int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.active == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.active += delta
+	if t.active == 0 {
+		close(t.idle)
+	}
+}
+
+// wait reports whether all handlers returned within timeout
+func (t *handlerTracker) wait(timeout time.Duration) bool {
+	t.mu.Lock()
+	idle := t.idle
+	t.mu.Unlock()
+	if idle == nil {
+		return true
+	}
+
+	select {
+	case <-idle:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
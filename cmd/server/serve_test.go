@@ -0,0 +1,143 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/config"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/health"
+)
+
+// streamingServer returns a listener whose handler streams one event and
+// then holds the response open until release is closed or the request is
+// cancelled. The handler sleeps briefly before returning, like a handler
+// recording usage, and sets finished as its last step.
+func streamingServer(t *testing.T, release <-chan struct{}, finished *atomic.Bool) (listener, string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-release:
+			w.Write([]byte("data: [DONE]\n\n"))
+		case <-r.Context().Done():
+		}
+		time.Sleep(50 * time.Millisecond)
+		finished.Store(true)
+	})
+
+	server := newHTTPServer(ln.Addr().String(), handler, config.Default().Server)
+	return listener{
+		name:   "test",
+		server: server,
+		serve:  func() error { return server.Serve(ln) },
+	}, "http://" + ln.Addr().String()
+}
+
+// startStream opens a streaming request and waits for its first event
+func startStream(t *testing.T, url string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Failed to start stream: %v", err)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "data: first\n" {
+		t.Fatalf("Expected the first event, got %q, %v", line, err)
+	}
+	return resp
+}
+
+func TestServeUntilSignal(t *testing.T) {
+	t.Run("in-flight stream drains", func(t *testing.T) {
+		release := make(chan struct{})
+		var finished atomic.Bool
+		l, url := streamingServer(t, release, &finished)
+
+		cfg := config.Default().Server
+		cfg.ShutdownDelay = 100 * time.Millisecond
+		cfg.ShutdownTimeout = 5 * time.Second
+		checker := health.NewChecker()
+
+		stop := make(chan os.Signal, 1)
+		done := make(chan struct{})
+		go func() {
+			serveUntil([]listener{l}, checker, cfg, stop)
+			close(done)
+		}()
+
+		resp := startStream(t, url)
+		defer resp.Body.Close()
+
+		stop <- syscall.SIGTERM
+		time.Sleep(50 * time.Millisecond)
+		if checker.IsReady() {
+			t.Error("Expected not ready during the shutdown delay")
+		}
+		select {
+		case <-done:
+			t.Fatal("Expected the server to wait for the in-flight stream")
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		close(release)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Server did not stop after the stream finished")
+		}
+		if !finished.Load() {
+			t.Error("Expected the handler to finish before serving returned")
+		}
+	})
+
+	t.Run("stream outliving the drain deadline", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		var finished atomic.Bool
+		l, url := streamingServer(t, release, &finished)
+
+		cfg := config.Default().Server
+		cfg.ShutdownDelay = 0
+		cfg.ShutdownTimeout = 100 * time.Millisecond
+
+		stop := make(chan os.Signal, 1)
+		done := make(chan struct{})
+		go func() {
+			serveUntil([]listener{l}, health.NewChecker(), cfg, stop)
+			close(done)
+		}()
+
+		resp := startStream(t, url)
+		defer resp.Body.Close()
+
+		stop <- syscall.SIGTERM
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Server did not stop at the drain deadline")
+		}
+		// Resources such as the API key database are closed right after
+		// serving returns, so the handler must be done by then
+		if !finished.Load() {
+			t.Error("Expected the cancelled handler to return before serving returned")
+		}
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/config"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/dlp"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/middleware"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/notify"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/router"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tags"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/transcript"
+)
+
+// runValidateConfig implements --validate-config: it checks the server
+// config and loads every file it references, prints each problem found and
+// returns the exit code
+func runValidateConfig(path string, cfg *config.Config) int {
+	problems := cfg.Problems()
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Model mapping
+	if cfg.Features.ModelMapping != "" {
+		routerConfig, err := router.LoadConfig(cfg.Features.ModelMapping)
+		if err == nil {
+			err = routerConfig.ValidateConfig()
+		}
+		if err != nil {
+			add("features.model_mapping: %v", err)
+		}
+		if routerConfig != nil {
+			registry := newProviderRegistry()
+			for name := range routerConfig.Providers {
+				if providerType := routerConfig.ProviderType(name); !registry.Has(providerType) {
+					add("features.model_mapping: provider %s has unknown type %q (expected one of %v)", name, providerType, registry.Types())
+				}
+			}
+		}
+	}
+
+	// Optional feature configs
+	if cfg.Features.DLP != "" {
+		dlpConfig, err := dlp.LoadConfig(cfg.Features.DLP)
+		if err == nil {
+			_, err = dlp.New(dlpConfig)
+		}
+		if err != nil {
+			add("features.dlp: %v", err)
+		}
+	}
+	if cfg.Features.Transcripts != "" {
+		if _, err := transcript.LoadConfig(cfg.Features.Transcripts); err != nil {
+			add("features.transcripts: %v", err)
+		}
+	}
+	if cfg.Features.Notifications != "" {
+		if _, err := notify.LoadConfig(cfg.Features.Notifications); err != nil {
+			add("features.notifications: %v", err)
+		}
+	}
+	if cfg.Features.RequestTags != "" {
+		if _, err := tags.ParseAllowlist(cfg.Features.RequestTags); err != nil {
+			add("features.request_tags: %v", err)
+		}
+	}
+
+	// Certificates and key files
+	if cfg.TLS.Enabled && cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != "" {
+		if _, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile); err != nil {
+			add("tls: failed to load certificate: %v", err)
+		}
+	}
+	if cfg.Auth.Enabled && cfg.Auth.Mode == "mtls" {
+		if _, err := auth.NewCertMapper(cfg.TLS.ClientCAFile, cfg.TLS.IdentityMapping); err != nil {
+			add("tls: failed to load mTLS configuration: %v", err)
+		}
+	}
+	if cfg.Auth.Enabled && cfg.Auth.Mode == "api_key" && len(middleware.LoadAPIKeysFromEnv()) == 0 {
+		add("the api_key auth mode requires BEDROCK_API_KEY_<NAME> environment variables")
+	}
+	if file := cfg.Auth.Database.DSNFile; file != "" {
+		if _, err := os.Stat(file); err != nil {
+			add("auth.database.dsn_file: %v", err)
+		}
+	}
+	if file := cfg.Auth.APIKeyPepperFile; file != "" {
+		if _, err := auth.ReadPepperFile(file); err != nil {
+			add("auth.api_key_pepper_file: %v", err)
+		}
+	}
+	if totp := cfg.Auth.TOTP; totp.MasterKeyFile != "" {
+		if _, err := auth.LoadKeyring(totp.MasterKeyFile, totp.PreviousMasterKeyFiles...); err != nil {
+			add("auth.totp: %v", err)
+		}
+	}
+	if audit := cfg.Auth.Audit; audit.SigningKeyFile != "" {
+		if _, err := auth.LoadAuditSigner(audit.SigningKeyFile); err != nil {
+			add("auth.audit.signing_key_file: %v", err)
+		}
+	}
+	if files := cfg.Auth.Audit.VerifyKeyFiles; len(files) > 0 {
+		if _, err := auth.LoadAuditPublicKeys(files...); err != nil {
+			add("auth.audit.verify_key_files: %v", err)
+		}
+	}
+
+	source := path
+	if source == "" {
+		source = "environment configuration"
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%s has %d problems:\n", source, len(problems))
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+		}
+		return 1
+	}
+	fmt.Printf("✓ %s is valid\n", source)
+	return 0
+}
@@ -7,11 +7,12 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
 )
 
 // APIKey represents an API key in the database
@@ -27,70 +28,72 @@ type APIKey struct {
 	ExpiresAt   *time.Time
 	Permissions string // JSON array of permissions
 	Metadata    string // JSON metadata
+	KeyPrefix   string // Public lookup prefix (e.g. bdrk_live_ab12cd34)
+	HashScheme  string // bcrypt or hmac-sha256
+	TeamID      int64  // Owning team, 0 if none
 }
 
-// APIKeyDB manages API keys in SQLite
+// APIKeyDB manages API keys in a storage backend
 type APIKeyDB struct {
-	db *sql.DB
+	db     storage.Store
+	pepper []byte
+
+	// keyring encrypts SigV4 secrets at rest; nil stores them in plaintext
+	keyring *Keyring
+
+	// auditMu serializes appends to the audit hash chain
+	auditMu sync.Mutex
+}
+
+// apiKeyColumns is the column list scanned by scanAPIKey
+const apiKeyColumns = `id, key_hash, name, email, description, is_active, created_at, last_used_at, expires_at, permissions, metadata,
+		COALESCE(key_prefix, ''), COALESCE(hash_scheme, 'bcrypt'), COALESCE(team_id, 0)`
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanAPIKey reads one api_keys row selected with apiKeyColumns
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+	var lastUsed, expires sql.NullTime
+
+	err := row.Scan(
+		&key.ID, &key.KeyHash, &key.Name, &key.Email, &key.Description,
+		&key.IsActive, &key.CreatedAt, &lastUsed, &expires,
+		&key.Permissions, &key.Metadata,
+		&key.KeyPrefix, &key.HashScheme, &key.TeamID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if expires.Valid {
+		key.ExpiresAt = &expires.Time
+	}
+
+	return &key, nil
 }
 
 // NewAPIKeyDB creates a new API key database
 func NewAPIKeyDB(dbPath string) (*APIKeyDB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := storage.Open(storage.Config{Backend: storage.BackendSQLite, DSN: dbPath})
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
-
-	// Create schema
-	schema := `
-	CREATE TABLE IF NOT EXISTS api_keys (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		key_hash TEXT NOT NULL UNIQUE,
-		name TEXT NOT NULL,
-		email TEXT,
-		description TEXT,
-		is_active BOOLEAN DEFAULT 1,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		last_used_at TIMESTAMP,
-		expires_at TIMESTAMP,
-		permissions TEXT DEFAULT '[]',
-		metadata TEXT DEFAULT '{}'
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_key_hash ON api_keys(key_hash);
-	CREATE INDEX IF NOT EXISTS idx_email ON api_keys(email);
-	CREATE INDEX IF NOT EXISTS idx_is_active ON api_keys(is_active);
-
-	-- Audit log table
-	CREATE TABLE IF NOT EXISTS api_key_audit (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		api_key_id INTEGER,
-		action TEXT NOT NULL,
-		ip_address TEXT,
-		user_agent TEXT,
-		request_path TEXT,
-		status_code INTEGER,
-		timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		metadata TEXT DEFAULT '{}'
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_audit_key_id ON api_key_audit(api_key_id);
-	CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON api_key_audit(timestamp);
-
-	-- 2FA table
-	CREATE TABLE IF NOT EXISTS api_key_2fa (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		api_key_id INTEGER NOT NULL UNIQUE,
-		totp_secret TEXT NOT NULL,
-		backup_codes TEXT,
-		is_enabled BOOLEAN DEFAULT 0,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE
-	);
-	`
-
-	if _, err := db.Exec(schema); err != nil {
-		return nil, fmt.Errorf("failed to create schema: %w", err)
+		return nil, err
+	}
+
+	return NewAPIKeyDBWithStore(db)
+}
+
+// NewAPIKeyDBWithStore creates an API key database on an opened storage
+// backend, applying any pending schema migrations first
+func NewAPIKeyDBWithStore(db storage.Store) (*APIKeyDB, error) {
+	if _, err := Migrate(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	return &APIKeyDB{db: db}, nil
@@ -98,103 +101,266 @@ func NewAPIKeyDB(dbPath string) (*APIKeyDB, error) {
 
 // GenerateAPIKey creates a new secure API key
 func (db *APIKeyDB) GenerateAPIKey(name, email, description string, expiresIn *time.Duration) (string, error) {
-	// Generate secure random key
-	keyBytes := make([]byte, 32)
-	if _, err := rand.Read(keyBytes); err != nil {
-		return "", fmt.Errorf("failed to generate random key: %w", err)
+	apiKey, _, err := db.insertAPIKey(db.db, name, email, description, expiryFrom(expiresIn))
+	return apiKey, err
+}
+
+// CreateAPIKey creates a new secure API key and returns it with its stored info
+func (db *APIKeyDB) CreateAPIKey(name, email, description string, expiresIn *time.Duration) (string, *APIKey, error) {
+	apiKey, id, err := db.insertAPIKey(db.db, name, email, description, expiryFrom(expiresIn))
+	if err != nil {
+		return "", nil, err
+	}
+
+	key, err := db.GetAPIKeyByID(id)
+	if err != nil {
+		return "", nil, err
+	}
+	return apiKey, key, nil
+}
+
+// KeySettings are optional settings stored with a new API key
+type KeySettings struct {
+	// Permissions replace the default permissions when not nil
+	Permissions []string
+	// Tags are the key's default cost-center tags
+	Tags map[string]string
+}
+
+// CreateAPIKeyWithSettings creates a new API key together with its
+// permissions and default tags, so a failure leaves no key behind
+func (db *APIKeyDB) CreateAPIKeyWithSettings(name, email, description string, expiresIn *time.Duration, settings KeySettings) (string, *APIKey, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin key creation: %w", err)
+	}
+	defer tx.Rollback()
+
+	apiKey, id, err := db.insertAPIKey(tx, name, email, description, expiryFrom(expiresIn))
+	if err != nil {
+		return "", nil, err
+	}
+	if settings.Permissions != nil {
+		if err := setPermissions(tx, id, settings.Permissions); err != nil {
+			return "", nil, err
+		}
+	}
+	if len(settings.Tags) > 0 {
+		if err := setDefaultTags(tx, id, settings.Tags); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", nil, fmt.Errorf("failed to commit key creation: %w", err)
 	}
-	apiKey := "bdrk_" + hex.EncodeToString(keyBytes)
 
-	// Hash the key for storage (bcrypt)
-	hash, err := bcrypt.GenerateFromPassword([]byte(apiKey), bcrypt.DefaultCost)
+	key, err := db.GetAPIKeyByID(id)
 	if err != nil {
-		return "", fmt.Errorf("failed to hash key: %w", err)
+		return "", nil, err
 	}
+	return apiKey, key, nil
+}
 
-	// Calculate expiration
-	var expiresAt *time.Time
-	if expiresIn != nil {
-		exp := time.Now().Add(*expiresIn)
-		expiresAt = &exp
+// insertAPIKey generates a key and stores its hash, returning the key and row ID
+func (db *APIKeyDB) insertAPIKey(q storage.Querier, name, email, description string, expiresAt *time.Time) (string, int64, error) {
+	// Generate public identifier and secure random secret
+	idBytes := make([]byte, keyIDBytes)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", 0, fmt.Errorf("failed to generate random key: %w", err)
+	}
+	keyBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return "", 0, fmt.Errorf("failed to generate random key: %w", err)
+	}
+	prefix := liveKeyPrefix + hex.EncodeToString(idBytes)
+	apiKey := prefix + "_" + hex.EncodeToString(keyBytes)
+
+	// Hash the key for storage (HMAC with pepper, bcrypt without)
+	hash, scheme, err := db.hashKey(apiKey)
+	if err != nil {
+		return "", 0, err
 	}
 
 	// Insert into database
-	_, err = db.db.Exec(`
-		INSERT INTO api_keys (key_hash, name, email, description, expires_at)
-		VALUES (?, ?, ?, ?, ?)
-	`, string(hash), name, email, description, expiresAt)
+	var id int64
+	err = q.QueryRow(`
+		INSERT INTO api_keys (key_hash, key_prefix, hash_scheme, name, email, description, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, hash, prefix, scheme, name, email, description, expiresAt).Scan(&id)
 
 	if err != nil {
-		return "", fmt.Errorf("failed to insert API key: %w", err)
+		return "", 0, fmt.Errorf("failed to insert API key: %w", err)
 	}
 
-	return apiKey, nil
+	return apiKey, id, nil
+}
+
+// expiryFrom converts a relative expiry into an absolute one
+func expiryFrom(expiresIn *time.Duration) *time.Time {
+	if expiresIn == nil {
+		return nil
+	}
+	exp := time.Now().Add(*expiresIn)
+	return &exp
 }
 
 // ValidateAPIKey checks if an API key is valid and returns the key info
 func (db *APIKeyDB) ValidateAPIKey(apiKey string) (*APIKey, error) {
-	// Get all active keys
-	rows, err := db.db.Query(`
-		SELECT id, key_hash, name, email, description, is_active, created_at, last_used_at, expires_at, permissions, metadata
-		FROM api_keys
-		WHERE is_active = 1
-	`)
+	prefix := keyPrefixOf(apiKey)
+
+	// Fast path: candidates sharing the public prefix
+	key, err := db.findKey(apiKey, `WHERE is_active = TRUE AND key_prefix = ?`, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Slow path: legacy rows created before prefixes existed
+	if key == nil {
+		key, err = db.findKey(apiKey, `WHERE is_active = TRUE AND (key_prefix IS NULL OR key_prefix = '')`)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("invalid API key")
+	}
+
+	// Check expiration
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, fmt.Errorf("API key expired")
+	}
+
+	// Transparently move old rows to the current prefix and hash scheme
+	db.upgradeKey(key, apiKey, prefix)
+
+	// Update last used timestamp
+	db.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", time.Now(), key.ID)
+
+	return key, nil
+}
+
+// findKey returns the row matching apiKey among the rows selected by where
+func (db *APIKeyDB) findKey(apiKey, where string, args ...any) (*APIKey, error) {
+	rows, err := db.db.Query(`SELECT `+apiKeyColumns+` FROM api_keys `+where, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query keys: %w", err)
 	}
 	defer rows.Close()
 
-	// Check each key with constant-time comparison
+	// Check each candidate with constant-time comparison
 	for rows.Next() {
-		var key APIKey
-		var lastUsed, expires sql.NullTime
-
-		err := rows.Scan(
-			&key.ID, &key.KeyHash, &key.Name, &key.Email, &key.Description,
-			&key.IsActive, &key.CreatedAt, &lastUsed, &expires,
-			&key.Permissions, &key.Metadata,
-		)
+		key, err := scanAPIKey(rows)
 		if err != nil {
 			continue
 		}
-
-		if lastUsed.Valid {
-			key.LastUsedAt = &lastUsed.Time
-		}
-		if expires.Valid {
-			key.ExpiresAt = &expires.Time
+		if db.verifyKey(key, apiKey) {
+			return key, nil
 		}
+	}
 
-		// Check if key matches (constant-time comparison via bcrypt)
-		if err := bcrypt.CompareHashAndPassword([]byte(key.KeyHash), []byte(apiKey)); err == nil {
-			// Check expiration
-			if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
-				return nil, fmt.Errorf("API key expired")
-			}
+	return nil, nil
+}
 
-			// Update last used timestamp
-			db.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", time.Now(), key.ID)
+// upgradeKey rewrites the prefix and hash of a row after successful authentication
+func (db *APIKeyDB) upgradeKey(key *APIKey, apiKey, prefix string) {
+	hash, scheme := key.KeyHash, key.HashScheme
+	if db.pepper != nil && scheme != HashSchemeHMACSHA256 {
+		hash, scheme = db.hmacKey(apiKey), HashSchemeHMACSHA256
+	}
 
-			return &key, nil
-		}
+	if key.KeyPrefix == prefix && key.HashScheme == scheme {
+		return
+	}
+
+	if _, err := db.db.Exec(
+		"UPDATE api_keys SET key_hash = ?, key_prefix = ?, hash_scheme = ? WHERE id = ?",
+		hash, prefix, scheme, key.ID,
+	); err != nil {
+		return
 	}
 
-	return nil, fmt.Errorf("invalid API key")
+	key.KeyHash, key.KeyPrefix, key.HashScheme = hash, prefix, scheme
 }
 
 // RevokeAPIKey deactivates an API key
 func (db *APIKeyDB) RevokeAPIKey(keyID int64) error {
-	_, err := db.db.Exec("UPDATE api_keys SET is_active = 0 WHERE id = ?", keyID)
+	_, err := db.db.Exec("UPDATE api_keys SET is_active = FALSE WHERE id = ?", keyID)
 	if err != nil {
 		return fmt.Errorf("failed to revoke key: %w", err)
 	}
 	return nil
 }
 
+// RotateAPIKey replaces an active key with a new secret and revokes the old
+// one. Name, expiry, permissions, limits, team, 2FA and SigV4 credentials
+// carry over to the new key; sessions of the old key are revoked.
+func (db *APIKeyDB) RotateAPIKey(keyID int64) (string, *APIKey, error) {
+	old, err := db.GetAPIKeyByID(keyID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin rotation: %w", err)
+	}
+	defer tx.Rollback()
+
+	apiKey, newID, err := db.insertAPIKey(tx, old.Name, old.Email, old.Description, old.ExpiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var teamID, rpm sql.NullInt64
+	var models sql.NullString
+	var budget sql.NullFloat64
+	err = tx.QueryRow(`
+		SELECT team_id, allowed_models, rate_limit_rpm, monthly_budget_usd
+		FROM api_keys WHERE id = ?
+	`, keyID).Scan(&teamID, &models, &rpm, &budget)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read key limits: %w", err)
+	}
+
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{`UPDATE api_keys
+			SET permissions = ?, metadata = ?, team_id = ?, allowed_models = ?, rate_limit_rpm = ?, monthly_budget_usd = ?
+			WHERE id = ?`,
+			[]any{old.Permissions, old.Metadata, teamID, models, rpm, budget, newID}},
+		{"UPDATE api_key_2fa SET api_key_id = ? WHERE api_key_id = ?", []any{newID, keyID}},
+		{"UPDATE api_key_2fa_backup_codes SET api_key_id = ? WHERE api_key_id = ?", []any{newID, keyID}},
+		{"UPDATE api_key_sigv4_credentials SET api_key_id = ? WHERE api_key_id = ?", []any{newID, keyID}},
+		{"UPDATE api_keys SET is_active = FALSE WHERE id = ?", []any{keyID}},
+		{`UPDATE session_tokens SET is_active = FALSE, revoked_reason = 'key_rotated'
+			WHERE api_key_id = ? AND is_active = TRUE`, []any{keyID}},
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			return "", nil, fmt.Errorf("failed to rotate key: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", nil, fmt.Errorf("failed to commit rotation: %w", err)
+	}
+
+	key, err := db.GetAPIKeyByID(newID)
+	if err != nil {
+		return "", nil, err
+	}
+	return apiKey, key, nil
+}
+
 // ListAPIKeys returns all API keys (for admin)
 func (db *APIKeyDB) ListAPIKeys() ([]APIKey, error) {
 	rows, err := db.db.Query(`
-		SELECT id, key_hash, name, email, description, is_active, created_at, last_used_at, expires_at, permissions, metadata
+		SELECT ` + apiKeyColumns + `
 		FROM api_keys
 		ORDER BY created_at DESC
 	`)
@@ -205,56 +371,38 @@ func (db *APIKeyDB) ListAPIKeys() ([]APIKey, error) {
 
 	var keys []APIKey
 	for rows.Next() {
-		var key APIKey
-		var lastUsed, expires sql.NullTime
-
-		err := rows.Scan(
-			&key.ID, &key.KeyHash, &key.Name, &key.Email, &key.Description,
-			&key.IsActive, &key.CreatedAt, &lastUsed, &expires,
-			&key.Permissions, &key.Metadata,
-		)
+		key, err := scanAPIKey(rows)
 		if err != nil {
 			continue
 		}
-
-		if lastUsed.Valid {
-			key.LastUsedAt = &lastUsed.Time
-		}
-		if expires.Valid {
-			key.ExpiresAt = &expires.Time
-		}
-
-		keys = append(keys, key)
+		keys = append(keys, *key)
 	}
 
 	return keys, nil
 }
 
-// LogAPIKeyUsage records API key usage for audit
+// LogAPIKeyUsage records API key usage for audit, attributed to the key's
+// team and linked into the audit hash chain
 func (db *APIKeyDB) LogAPIKeyUsage(keyID int64, action, ip, userAgent, path string, statusCode int, metadata string) error {
-	_, err := db.db.Exec(`
-		INSERT INTO api_key_audit (api_key_id, action, ip_address, user_agent, request_path, status_code, metadata)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, keyID, action, ip, userAgent, path, statusCode, metadata)
-
-	return err
+	return db.appendAudit(AuditEntry{
+		APIKeyID:    keyID,
+		Action:      action,
+		IPAddress:   ip,
+		UserAgent:   userAgent,
+		RequestPath: path,
+		StatusCode:  statusCode,
+		Timestamp:   time.Now().UTC().Truncate(time.Second),
+	}, metadata)
 }
 
 // GetAPIKeyByEmail returns API key info by email
 func (db *APIKeyDB) GetAPIKeyByEmail(email string) (*APIKey, error) {
-	var key APIKey
-	var lastUsed, expires sql.NullTime
-
-	err := db.db.QueryRow(`
-		SELECT id, key_hash, name, email, description, is_active, created_at, last_used_at, expires_at, permissions, metadata
+	key, err := scanAPIKey(db.db.QueryRow(`
+		SELECT `+apiKeyColumns+`
 		FROM api_keys
-		WHERE email = ? AND is_active = 1
+		WHERE email = ? AND is_active = TRUE
 		LIMIT 1
-	`, email).Scan(
-		&key.ID, &key.KeyHash, &key.Name, &key.Email, &key.Description,
-		&key.IsActive, &key.CreatedAt, &lastUsed, &expires,
-		&key.Permissions, &key.Metadata,
-	)
+	`, email))
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("no active API key found for email: %s", email)
@@ -263,31 +411,17 @@ func (db *APIKeyDB) GetAPIKeyByEmail(email string) (*APIKey, error) {
 		return nil, fmt.Errorf("failed to get API key: %w", err)
 	}
 
-	if lastUsed.Valid {
-		key.LastUsedAt = &lastUsed.Time
-	}
-	if expires.Valid {
-		key.ExpiresAt = &expires.Time
-	}
-
-	return &key, nil
+	return key, nil
 }
 
 // GetAPIKeyByID returns API key info by ID
 func (db *APIKeyDB) GetAPIKeyByID(id int64) (*APIKey, error) {
-	var key APIKey
-	var lastUsed, expires sql.NullTime
-
-	err := db.db.QueryRow(`
-		SELECT id, key_hash, name, email, description, is_active, created_at, last_used_at, expires_at, permissions, metadata
+	key, err := scanAPIKey(db.db.QueryRow(`
+		SELECT `+apiKeyColumns+`
 		FROM api_keys
-		WHERE id = ? AND is_active = 1
+		WHERE id = ? AND is_active = TRUE
 		LIMIT 1
-	`, id).Scan(
-		&key.ID, &key.KeyHash, &key.Name, &key.Email, &key.Description,
-		&key.IsActive, &key.CreatedAt, &lastUsed, &expires,
-		&key.Permissions, &key.Metadata,
-	)
+	`, id))
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("API key not found: %d", id)
@@ -296,14 +430,97 @@ func (db *APIKeyDB) GetAPIKeyByID(id int64) (*APIKey, error) {
 		return nil, fmt.Errorf("failed to get API key: %w", err)
 	}
 
-	if lastUsed.Valid {
-		key.LastUsedAt = &lastUsed.Time
+	return key, nil
+}
+
+// PermissionList returns the key's permissions as a slice
+func (k *APIKey) PermissionList() []string {
+	var permissions []string
+	if err := json.Unmarshal([]byte(k.Permissions), &permissions); err != nil {
+		return nil
 	}
-	if expires.Valid {
-		key.ExpiresAt = &expires.Time
+	return permissions
+}
+
+// SetPermissions replaces the permissions of an API key
+func (db *APIKeyDB) SetPermissions(keyID int64, permissions []string) error {
+	return setPermissions(db.db, keyID, permissions)
+}
+
+func setPermissions(q storage.Querier, keyID int64, permissions []string) error {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	data, err := json.Marshal(permissions)
+	if err != nil {
+		return fmt.Errorf("failed to encode permissions: %w", err)
 	}
 
-	return &key, nil
+	if _, err := q.Exec("UPDATE api_keys SET permissions = ? WHERE id = ?", string(data), keyID); err != nil {
+		return fmt.Errorf("failed to update permissions: %w", err)
+	}
+	return nil
+}
+
+// keyMetadata is the JSON stored in an API key's metadata column
+type keyMetadata struct {
+	// Tags are the default cost-center tags of the key's requests
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// DefaultTags returns the key's default cost-center tags, or nil
+func (k *APIKey) DefaultTags() map[string]string {
+	return decodeKeyMetadata(k.Metadata).Tags
+}
+
+// decodeKeyMetadata reads key metadata, ignoring malformed JSON
+func decodeKeyMetadata(metadata string) keyMetadata {
+	var meta keyMetadata
+	json.Unmarshal([]byte(metadata), &meta)
+	return meta
+}
+
+// SetDefaultTags replaces the default cost-center tags of an API key,
+// keeping the rest of its metadata
+func (db *APIKeyDB) SetDefaultTags(keyID int64, tags map[string]string) error {
+	return setDefaultTags(db.db, keyID, tags)
+}
+
+func setDefaultTags(q storage.Querier, keyID int64, tags map[string]string) error {
+	var metadata sql.NullString
+	err := q.QueryRow("SELECT metadata FROM api_keys WHERE id = ?", keyID).Scan(&metadata)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("API key not found: %d", keyID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get key metadata: %w", err)
+	}
+
+	fields := make(map[string]json.RawMessage)
+	json.Unmarshal([]byte(metadata.String), &fields)
+	if len(tags) == 0 {
+		delete(fields, "tags")
+	} else {
+		data, err := json.Marshal(tags)
+		if err != nil {
+			return fmt.Errorf("failed to encode tags: %w", err)
+		}
+		fields["tags"] = data
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("failed to encode key metadata: %w", err)
+	}
+
+	if _, err := q.Exec("UPDATE api_keys SET metadata = ? WHERE id = ?", string(data), keyID); err != nil {
+		return fmt.Errorf("failed to update key metadata: %w", err)
+	}
+	return nil
+}
+
+// DB returns the underlying database handle (shared by session and TOTP managers)
+func (db *APIKeyDB) DB() storage.Store {
+	return db.db
 }
 
 // Close closes the database connection
@@ -4,11 +4,15 @@
 package auth
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestAPIKeyDB(t *testing.T) {
@@ -28,12 +32,12 @@ func TestAPIKeyDB(t *testing.T) {
 			t.Fatalf("Failed to generate API key: %v", err)
 		}
 
-		if apiKey[:5] != "bdrk_" {
-			t.Errorf("API key should start with 'bdrk_', got: %s", apiKey[:5])
+		if apiKey[:10] != "bdrk_live_" {
+			t.Errorf("API key should start with 'bdrk_live_', got: %s", apiKey[:10])
 		}
 
-		if len(apiKey) != 69 { // bdrk_ (5) + 64 hex chars
-			t.Errorf("API key should be 69 chars, got: %d", len(apiKey))
+		if len(apiKey) != 83 { // bdrk_live_ (10) + 8 hex id + _ + 64 hex chars
+			t.Errorf("API key should be 83 chars, got: %d", len(apiKey))
 		}
 	})
 
@@ -109,6 +113,12 @@ func TestAPIKeyDB(t *testing.T) {
 		if len(keys) < 1 {
 			t.Error("Expected at least 1 API key in the list")
 		}
+
+		for _, key := range keys {
+			if !strings.HasPrefix(key.KeyPrefix, "bdrk_live_") {
+				t.Errorf("Expected listed key to show its prefix, got: %q", key.KeyPrefix)
+			}
+		}
 	})
 
 	t.Run("GetAPIKeyByEmail", func(t *testing.T) {
@@ -155,6 +165,71 @@ func TestAPIKeyDB(t *testing.T) {
 	})
 }
 
+func TestAPIKeyHashSchemeUpgrade(t *testing.T) {
+	db, err := NewAPIKeyDB(filepath.Join(t.TempDir(), "upgrade.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Insert a row the way keys were stored before prefixes existed
+	legacyKey := "bdrk_" + strings.Repeat("ab", 32)
+	hash, err := bcrypt.GenerateFromPassword([]byte(legacyKey), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("Failed to hash key: %v", err)
+	}
+	if _, err := db.db.Exec(`INSERT INTO api_keys (key_hash, name, email, description) VALUES (?, ?, ?, ?)`,
+		string(hash), "Legacy User", "legacy@example.com", "Pre-prefix key"); err != nil {
+		t.Fatalf("Failed to insert legacy key: %v", err)
+	}
+
+	if err := db.SetPepper([]byte("short")); err == nil {
+		t.Error("Expected error for short pepper, got nil")
+	}
+	if err := db.SetPepper([]byte(strings.Repeat("p", 32))); err != nil {
+		t.Fatalf("Failed to set pepper: %v", err)
+	}
+
+	keyInfo, err := db.ValidateAPIKey(legacyKey)
+	if err != nil {
+		t.Fatalf("Failed to validate legacy key: %v", err)
+	}
+	if keyInfo.HashScheme != HashSchemeHMACSHA256 {
+		t.Errorf("Expected scheme %s after upgrade, got: %s", HashSchemeHMACSHA256, keyInfo.HashScheme)
+	}
+	if keyInfo.KeyPrefix != "bdrk_abababab" {
+		t.Errorf("Expected prefix 'bdrk_abababab', got: %s", keyInfo.KeyPrefix)
+	}
+
+	// Upgraded row is found through the prefix and HMAC on the next request
+	stored, err := db.GetAPIKeyByEmail("legacy@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get API key: %v", err)
+	}
+	if stored.HashScheme != HashSchemeHMACSHA256 || stored.KeyHash == string(hash) {
+		t.Error("Expected stored hash to be rewritten")
+	}
+	if _, err := db.ValidateAPIKey(legacyKey); err != nil {
+		t.Fatalf("Failed to validate upgraded key: %v", err)
+	}
+
+	// New keys are HMAC'd directly
+	apiKey, err := db.GenerateAPIKey("New User", "new@example.com", "Peppered key", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+	newInfo, err := db.ValidateAPIKey(apiKey)
+	if err != nil {
+		t.Fatalf("Failed to validate API key: %v", err)
+	}
+	if newInfo.HashScheme != HashSchemeHMACSHA256 {
+		t.Errorf("Expected scheme %s, got: %s", HashSchemeHMACSHA256, newInfo.HashScheme)
+	}
+	if _, err := db.ValidateAPIKey(newInfo.KeyPrefix + "_" + strings.Repeat("0", 64)); err == nil {
+		t.Error("Expected error for wrong secret with valid prefix, got nil")
+	}
+}
+
 func TestTOTP(t *testing.T) {
 	// Create temporary database
 	dbPath := "/tmp/test_totp.db"
@@ -251,3 +326,252 @@ func TestTOTP(t *testing.T) {
 		}
 	})
 }
+
+func TestTOTPEncryptionAndLockout(t *testing.T) {
+	apiKeyDB, err := NewAPIKeyDB(filepath.Join(t.TempDir(), "totp.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer apiKeyDB.Close()
+
+	if _, err := apiKeyDB.GenerateAPIKey("Secure User", "secure@example.com", "Encrypted TOTP", nil); err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+	keyInfo, err := apiKeyDB.GetAPIKeyByEmail("secure@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get API key: %v", err)
+	}
+
+	oldRing := NewKeyring([]byte(strings.Repeat("a", 32)))
+	totpManager := NewTOTPManagerWithOptions(apiKeyDB.db, TOTPOptions{
+		Keyring:           oldRing,
+		MaxFailedAttempts: 3,
+		LockoutDuration:   time.Minute,
+	})
+
+	key, backupCodes, err := totpManager.GenerateTOTP(keyInfo.ID, "secure@example.com", "Bedrock Proxy")
+	if err != nil {
+		t.Fatalf("Failed to generate TOTP: %v", err)
+	}
+
+	t.Run("SecretEncryptedAtRest", func(t *testing.T) {
+		var stored, keyID, legacyCodes string
+		err := apiKeyDB.db.QueryRow(
+			"SELECT totp_secret, secret_key_id, backup_codes FROM api_key_2fa WHERE api_key_id = ?", keyInfo.ID,
+		).Scan(&stored, &keyID, &legacyCodes)
+		if err != nil {
+			t.Fatalf("Failed to read TOTP row: %v", err)
+		}
+		if strings.Contains(stored, key.Secret()) {
+			t.Error("TOTP secret should not be stored in plaintext")
+		}
+		if keyID != oldRing.PrimaryID() {
+			t.Errorf("Expected key ID %s, got: %s", oldRing.PrimaryID(), keyID)
+		}
+		if legacyCodes != "" {
+			t.Error("Backup codes should not be stored in plaintext")
+		}
+	})
+
+	t.Run("BackupCodeSingleUse", func(t *testing.T) {
+		if valid, err := totpManager.ValidateTOTP(keyInfo.ID, backupCodes[0]); err != nil || !valid {
+			t.Fatalf("Backup code should be valid, got: %v", err)
+		}
+		if valid, _ := totpManager.ValidateTOTP(keyInfo.ID, backupCodes[0]); valid {
+			t.Error("Backup code should be consumed after use")
+		}
+		if err := totpManager.UnlockTOTP(keyInfo.ID); err != nil {
+			t.Fatalf("Failed to unlock: %v", err)
+		}
+	})
+
+	t.Run("ReencryptOnRotation", func(t *testing.T) {
+		newRing := NewKeyring([]byte(strings.Repeat("b", 32)))
+		newRing.keys[oldRing.PrimaryID()] = oldRing.keys[oldRing.PrimaryID()]
+		rotated := NewTOTPManagerWithOptions(apiKeyDB.db, TOTPOptions{Keyring: newRing})
+
+		n, err := rotated.ReencryptSecrets()
+		if err != nil {
+			t.Fatalf("Failed to re-encrypt secrets: %v", err)
+		}
+		if n != 1 {
+			t.Errorf("Expected 1 re-encrypted secret, got: %d", n)
+		}
+
+		code, _ := totp.GenerateCode(key.Secret(), time.Now())
+		if valid, err := rotated.ValidateTOTP(keyInfo.ID, code); err != nil || !valid {
+			t.Fatalf("TOTP should validate after rotation, got: %v", err)
+		}
+
+		// The old master key alone can no longer open the secret
+		if _, err := totpManager.ValidateTOTP(keyInfo.ID, code); err == nil {
+			t.Error("Expected decryption error with rotated-out key, got nil")
+		}
+	})
+
+	t.Run("Lockout", func(t *testing.T) {
+		rotated := NewTOTPManagerWithOptions(apiKeyDB.db, TOTPOptions{
+			Keyring:           NewKeyring([]byte(strings.Repeat("b", 32))),
+			MaxFailedAttempts: 3,
+			LockoutDuration:   time.Minute,
+		})
+
+		for i := 0; i < 3; i++ {
+			rotated.ValidateTOTP(keyInfo.ID, "000000")
+		}
+
+		code, _ := totp.GenerateCode(key.Secret(), time.Now())
+		if _, err := rotated.ValidateTOTP(keyInfo.ID, code); !errors.Is(err, ErrTOTPLocked) {
+			t.Fatalf("Expected ErrTOTPLocked, got: %v", err)
+		}
+
+		if err := rotated.UnlockTOTP(keyInfo.ID); err != nil {
+			t.Fatalf("Failed to unlock: %v", err)
+		}
+		if valid, err := rotated.ValidateTOTP(keyInfo.ID, code); err != nil || !valid {
+			t.Errorf("TOTP should validate after unlock, got: %v", err)
+		}
+	})
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	apiKeyDB, err := NewAPIKeyDB(filepath.Join(t.TempDir(), "enroll.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer apiKeyDB.Close()
+
+	if _, err := apiKeyDB.GenerateAPIKey("Enroll User", "enroll@example.com", "Self-enrollment", nil); err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+	keyInfo, err := apiKeyDB.GetAPIKeyByEmail("enroll@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get API key: %v", err)
+	}
+
+	totpManager := NewTOTPManager(apiKeyDB.db)
+
+	if _, err := totpManager.ConfirmEnrollment(keyInfo.ID, "123456"); !errors.Is(err, ErrTOTPNoEnrollment) {
+		t.Errorf("Expected ErrTOTPNoEnrollment, got: %v", err)
+	}
+
+	key, err := totpManager.BeginEnrollment(keyInfo.ID, "enroll@example.com", "Bedrock Proxy")
+	if err != nil {
+		t.Fatalf("Failed to begin enrollment: %v", err)
+	}
+
+	if enabled, _ := totpManager.IsTOTPEnabled(keyInfo.ID); enabled {
+		t.Error("2FA should not be enabled before confirmation")
+	}
+
+	if _, err := totpManager.ConfirmEnrollment(keyInfo.ID, "000000"); err == nil {
+		t.Error("Expected error for wrong confirmation code, got nil")
+	}
+
+	code, _ := totp.GenerateCode(key.Secret(), time.Now())
+	backupCodes, err := totpManager.ConfirmEnrollment(keyInfo.ID, code)
+	if err != nil {
+		t.Fatalf("Failed to confirm enrollment: %v", err)
+	}
+	if len(backupCodes) != 10 {
+		t.Errorf("Expected 10 backup codes, got: %d", len(backupCodes))
+	}
+
+	if enabled, _ := totpManager.IsTOTPEnabled(keyInfo.ID); !enabled {
+		t.Error("2FA should be enabled after confirmation")
+	}
+
+	if _, err := totpManager.BeginEnrollment(keyInfo.ID, "enroll@example.com", "Bedrock Proxy"); !errors.Is(err, ErrTOTPAlreadyEnabled) {
+		t.Errorf("Expected ErrTOTPAlreadyEnabled, got: %v", err)
+	}
+
+	if err := totpManager.DisableTOTP(keyInfo.ID); err != nil {
+		t.Fatalf("Failed to disable TOTP: %v", err)
+	}
+	if _, err := totpManager.BeginEnrollment(keyInfo.ID, "enroll@example.com", "Bedrock Proxy"); err != nil {
+		t.Errorf("Re-enrollment after disable should succeed, got: %v", err)
+	}
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	db, sessions, keyID := newSessionTestDB(t, SessionPolicy{})
+
+	if err := db.SetPermissions(keyID, []string{"invoke"}); err != nil {
+		t.Fatalf("Failed to set permissions: %v", err)
+	}
+	totpManager := NewTOTPManager(db.DB())
+	if _, _, err := totpManager.GenerateTOTP(keyID, "session@example.com", "Bedrock Proxy"); err != nil {
+		t.Fatalf("Failed to generate TOTP: %v", err)
+	}
+	token, err := sessions.GenerateSessionToken(keyID, time.Hour, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	apiKey, key, err := db.RotateAPIKey(keyID)
+	if err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	if key.ID == keyID || key.Name != "Session User" {
+		t.Errorf("Unexpected rotated key: id=%d name=%q", key.ID, key.Name)
+	}
+
+	if _, err := db.GetAPIKeyByID(keyID); err == nil {
+		t.Error("Old key should be revoked")
+	}
+	validated, err := db.ValidateAPIKey(apiKey)
+	if err != nil {
+		t.Fatalf("New key invalid: %v", err)
+	}
+	if perms := validated.PermissionList(); len(perms) != 1 || perms[0] != "invoke" {
+		t.Errorf("Permissions not carried over: %v", perms)
+	}
+	if enabled, _ := totpManager.IsTOTPEnabled(key.ID); !enabled {
+		t.Error("2FA not carried over to rotated key")
+	}
+	if _, _, err := sessions.ValidateSessionToken(token, "127.0.0.1", "test"); err == nil {
+		t.Error("Sessions of the old key should be revoked")
+	}
+
+	if _, _, err := db.RotateAPIKey(keyID); err == nil {
+		t.Error("Expected error rotating a revoked key")
+	}
+}
+
+func TestCreateAPIKeyWithSettings(t *testing.T) {
+	db := newAuditTestDB(t)
+
+	apiKey, key, err := db.CreateAPIKeyWithSettings("CI", "ci@example.com", "", nil, KeySettings{
+		Permissions: []string{"invoke"},
+		Tags:        map[string]string{"team": "ml"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if perms := key.PermissionList(); len(perms) != 1 || perms[0] != "invoke" {
+		t.Errorf("Expected permissions [invoke], got %v", perms)
+	}
+	if tags := key.DefaultTags(); tags["team"] != "ml" {
+		t.Errorf("Expected default tag team=ml, got %v", tags)
+	}
+	if _, err := db.ValidateAPIKey(apiKey); err != nil {
+		t.Errorf("Expected the new key to be valid: %v", err)
+	}
+
+	// A failure storing the settings must not leave an active key behind
+	mustExec(t, db, `CREATE TRIGGER reject_tags BEFORE UPDATE OF metadata ON api_keys
+		BEGIN SELECT RAISE(FAIL, 'rejected'); END`)
+	if _, _, err := db.CreateAPIKeyWithSettings("Tagged", "", "", nil, KeySettings{
+		Permissions: []string{"invoke"},
+		Tags:        map[string]string{"team": "ml"},
+	}); err == nil {
+		t.Fatal("Expected an error when the tags cannot be stored")
+	}
+	keys, err := db.ListAPIKeys()
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("Expected only the first key, got %d keys", len(keys))
+	}
+}
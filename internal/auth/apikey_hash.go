@@ -0,0 +1,147 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// HashSchemeBcrypt is the original per-key bcrypt hash
+	HashSchemeBcrypt = "bcrypt"
+
+	// HashSchemeHMACSHA256 is HMAC-SHA256 keyed with the server pepper
+	HashSchemeHMACSHA256 = "hmac-sha256"
+
+	// liveKeyPrefix starts every key issued with a public identifier
+	liveKeyPrefix = "bdrk_live_"
+
+	// legacyKeyPrefix starts keys issued before public identifiers
+	legacyKeyPrefix = "bdrk_"
+
+	// keyIDBytes is the size of the random public identifier
+	keyIDBytes = 4
+
+	// minPepperBytes is the shortest pepper accepted from a secret file
+	minPepperBytes = 32
+)
+
+// LoadPepperFile loads the server-side pepper used to HMAC API keys.
+// Existing bcrypt rows are upgraded on their next successful authentication.
+func (db *APIKeyDB) LoadPepperFile(path string) error {
+	pepper, err := ReadPepperFile(path)
+	if err != nil {
+		return err
+	}
+
+	db.pepper = pepper
+	return nil
+}
+
+// ReadPepperFile reads and checks a pepper secret file
+func ReadPepperFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pepper file: %w", err)
+	}
+
+	pepper := []byte(strings.TrimSpace(string(data)))
+	if err := checkPepper(pepper); err != nil {
+		return nil, err
+	}
+	return pepper, nil
+}
+
+// SetPepper sets the server-side pepper used to HMAC API keys
+func (db *APIKeyDB) SetPepper(pepper []byte) error {
+	if err := checkPepper(pepper); err != nil {
+		return err
+	}
+
+	db.pepper = pepper
+	return nil
+}
+
+func checkPepper(pepper []byte) error {
+	if len(pepper) < minPepperBytes {
+		return fmt.Errorf("pepper must be at least %d bytes, got %d", minPepperBytes, len(pepper))
+	}
+	return nil
+}
+
+// hashKey hashes a new key with the strongest scheme available
+func (db *APIKeyDB) hashKey(apiKey string) (string, string, error) {
+	if db.pepper != nil {
+		return db.hmacKey(apiKey), HashSchemeHMACSHA256, nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(keySecretOf(apiKey)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to hash key: %w", err)
+	}
+	return string(hash), HashSchemeBcrypt, nil
+}
+
+// hmacKey returns the hex HMAC-SHA256 of apiKey under the pepper
+func (db *APIKeyDB) hmacKey(apiKey string) string {
+	mac := hmac.New(sha256.New, db.pepper)
+	mac.Write([]byte(apiKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyKey checks apiKey against a stored row using the row's hash scheme
+func (db *APIKeyDB) verifyKey(key *APIKey, apiKey string) bool {
+	switch key.HashScheme {
+	case HashSchemeHMACSHA256:
+		if db.pepper == nil {
+			return false
+		}
+		expected, err := hex.DecodeString(key.KeyHash)
+		if err != nil {
+			return false
+		}
+		actual, _ := hex.DecodeString(db.hmacKey(apiKey))
+		return hmac.Equal(expected, actual)
+
+	default:
+		return bcrypt.CompareHashAndPassword([]byte(key.KeyHash), []byte(keySecretOf(apiKey))) == nil
+	}
+}
+
+// keyPrefixOf returns the public lookup prefix of a presented key. Legacy
+// keys use their first eight characters after bdrk_ as identifier.
+func keyPrefixOf(apiKey string) string {
+	if rest, ok := strings.CutPrefix(apiKey, liveKeyPrefix); ok {
+		id, _, found := strings.Cut(rest, "_")
+		if !found || id == "" {
+			return ""
+		}
+		return liveKeyPrefix + id
+	}
+
+	if strings.HasPrefix(apiKey, legacyKeyPrefix) && len(apiKey) > len(legacyKeyPrefix)+keyIDBytes*2 {
+		return apiKey[:len(legacyKeyPrefix)+keyIDBytes*2]
+	}
+
+	return ""
+}
+
+// keySecretOf returns the part of a key covered by bcrypt, which only reads
+// 72 bytes. Prefixed keys hash the secret after the public identifier;
+// legacy keys hash the whole key as they always did.
+func keySecretOf(apiKey string) string {
+	if rest, ok := strings.CutPrefix(apiKey, liveKeyPrefix); ok {
+		if _, secret, found := strings.Cut(rest, "_"); found {
+			return secret
+		}
+	}
+	return apiKey
+}
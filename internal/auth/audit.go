@@ -0,0 +1,425 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultAuditLimit is the page size when AuditFilter.Limit is unset
+	defaultAuditLimit = 100
+
+	// maxAuditLimit caps a single audit query
+	maxAuditLimit = 1000
+)
+
+// AuditEntry is one record of the API key audit log
+type AuditEntry struct {
+	ID          int64           `json:"id"`
+	APIKeyID    int64           `json:"api_key_id"`
+	TeamID      int64           `json:"team_id,omitempty"`
+	Action      string          `json:"action"`
+	IPAddress   string          `json:"ip_address"`
+	UserAgent   string          `json:"user_agent"`
+	RequestPath string          `json:"request_path"`
+	StatusCode  int             `json:"status_code"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Metadata    json.RawMessage `json:"metadata"`
+
+	// PrevHash and EntryHash link the record into the audit hash chain;
+	// they are empty for records written before chaining was enabled
+	PrevHash  string `json:"prev_hash,omitempty"`
+	EntryHash string `json:"entry_hash,omitempty"`
+
+	// rawMetadata is the stored metadata the entry hash covers
+	rawMetadata string
+}
+
+// AuditFilter selects audit records. Zero values match everything.
+type AuditFilter struct {
+	APIKeyID int64
+	TeamID   int64
+
+	// Action and Path match exactly, or as a prefix when they end in "*"
+	// (e.g. "2fa_*" or "/admin/*")
+	Action string
+	Path   string
+
+	IPAddress string
+
+	// StatusMin and StatusMax bound the response status (inclusive)
+	StatusMin int
+	StatusMax int
+
+	Since time.Time
+	Until time.Time
+
+	// Ascending returns records oldest first, after AfterID. Otherwise
+	// records are returned newest first, before BeforeID when set.
+	Ascending bool
+	AfterID   int64
+	BeforeID  int64
+
+	Limit int
+}
+
+// QueryAudit returns one page of audit records matching the filter, newest
+// first unless the filter is ascending
+func (db *APIKeyDB) QueryAudit(filter AuditFilter) ([]AuditEntry, error) {
+	where, args := filter.conditions()
+
+	ascending := filter.Ascending || filter.AfterID > 0
+	order := "DESC"
+	if ascending {
+		order = "ASC"
+		if filter.AfterID > 0 {
+			where = append(where, "id > ?")
+			args = append(args, filter.AfterID)
+		}
+	} else if filter.BeforeID > 0 {
+		where = append(where, "id < ?")
+		args = append(args, filter.BeforeID)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+
+	query := `
+		SELECT id, COALESCE(api_key_id, 0), COALESCE(team_id, 0), action,
+			COALESCE(ip_address, ''), COALESCE(user_agent, ''), COALESCE(request_path, ''),
+			COALESCE(status_code, 0), timestamp, metadata,
+			COALESCE(prev_hash, ''), COALESCE(entry_hash, '')
+		FROM api_key_audit`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY id %s LIMIT %d", order, limit)
+
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var timestamp sql.NullTime
+		var metadata sql.NullString
+		if err := rows.Scan(
+			&entry.ID, &entry.APIKeyID, &entry.TeamID, &entry.Action,
+			&entry.IPAddress, &entry.UserAgent, &entry.RequestPath,
+			&entry.StatusCode, &timestamp, &metadata,
+			&entry.PrevHash, &entry.EntryHash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		entry.Timestamp = timestamp.Time
+		entry.rawMetadata = metadata.String
+		if !metadata.Valid {
+			metadata.String = "{}"
+		}
+		entry.Metadata = auditMetadata(metadata.String)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// ExportAudit calls fn for every record matching the filter, oldest first.
+// Records are read in pages so no database cursor is held while fn writes.
+func (db *APIKeyDB) ExportAudit(filter AuditFilter, fn func(AuditEntry) error) error {
+	filter.Ascending = true
+	filter.BeforeID = 0
+	filter.Limit = maxAuditLimit
+
+	for {
+		entries, err := db.QueryAudit(filter)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		if len(entries) < filter.Limit {
+			return nil
+		}
+		filter.AfterID = entries[len(entries)-1].ID
+	}
+}
+
+// conditions returns the SQL conditions for the non-paging filters
+func (f AuditFilter) conditions() ([]string, []any) {
+	var where []string
+	var args []any
+
+	if f.APIKeyID != 0 {
+		where = append(where, "api_key_id = ?")
+		args = append(args, f.APIKeyID)
+	}
+	if f.TeamID != 0 {
+		where = append(where, "team_id = ?")
+		args = append(args, f.TeamID)
+	}
+	for _, match := range []struct{ column, value string }{
+		{"action", f.Action},
+		{"request_path", f.Path},
+	} {
+		if prefix, ok := strings.CutSuffix(match.value, "*"); ok {
+			where = append(where, match.column+` LIKE ? ESCAPE '\'`)
+			args = append(args, escapeLike(prefix)+"%")
+		} else if match.value != "" {
+			where = append(where, match.column+" = ?")
+			args = append(args, match.value)
+		}
+	}
+	if f.IPAddress != "" {
+		where = append(where, "ip_address = ?")
+		args = append(args, f.IPAddress)
+	}
+	if f.StatusMin > 0 {
+		where = append(where, "status_code >= ?")
+		args = append(args, f.StatusMin)
+	}
+	if f.StatusMax > 0 {
+		where = append(where, "status_code <= ?")
+		args = append(args, f.StatusMax)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "timestamp < ?")
+		args = append(args, f.Until.UTC())
+	}
+
+	return where, args
+}
+
+// escapeLike makes LIKE wildcards in a prefix match literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// NextCursor returns the cursor for the page after entries, or "" when
+// entries is the last page
+func (f AuditFilter) NextCursor(entries []AuditEntry) string {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+	if len(entries) < limit {
+		return ""
+	}
+
+	direction := "b"
+	if f.Ascending || f.AfterID > 0 {
+		direction = "a"
+	}
+	last := entries[len(entries)-1].ID
+	return base64.RawURLEncoding.EncodeToString([]byte(direction + strconv.FormatInt(last, 10)))
+}
+
+// ApplyCursor positions the filter after a cursor from NextCursor
+func (f *AuditFilter) ApplyCursor(cursor string) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(data) < 2 {
+		return errors.New("invalid cursor")
+	}
+	id, err := strconv.ParseInt(string(data[1:]), 10, 64)
+	if err != nil {
+		return errors.New("invalid cursor")
+	}
+
+	switch data[0] {
+	case 'a':
+		f.Ascending, f.AfterID, f.BeforeID = true, id, 0
+	case 'b':
+		f.Ascending, f.AfterID, f.BeforeID = false, 0, id
+	default:
+		return errors.New("invalid cursor")
+	}
+	return nil
+}
+
+// ParseAuditFilter reads a filter from query parameters: api_key_id,
+// team_id, action, path, ip, status (e.g. 404 or 5xx), since and until
+// (RFC 3339), order (asc or desc), after_id, before_id, cursor and limit
+func ParseAuditFilter(query url.Values) (AuditFilter, error) {
+	var filter AuditFilter
+	var err error
+
+	for _, p := range []struct {
+		param string
+		dest  *int64
+	}{
+		{"api_key_id", &filter.APIKeyID},
+		{"team_id", &filter.TeamID},
+		{"after_id", &filter.AfterID},
+		{"before_id", &filter.BeforeID},
+	} {
+		if v := query.Get(p.param); v != "" {
+			if *p.dest, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return filter, fmt.Errorf("invalid %s", p.param)
+			}
+		}
+	}
+
+	for _, p := range []struct {
+		param string
+		dest  *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	} {
+		if v := query.Get(p.param); v != "" {
+			if *p.dest, err = time.Parse(time.RFC3339, v); err != nil {
+				return filter, fmt.Errorf("invalid %s (use RFC 3339)", p.param)
+			}
+		}
+	}
+
+	if v := query.Get("status"); v != "" {
+		if filter.StatusMin, filter.StatusMax, err = ParseStatusRange(v); err != nil {
+			return filter, err
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil {
+			return filter, errors.New("invalid limit")
+		}
+	}
+
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Ascending = true
+	default:
+		return filter, errors.New("invalid order (use asc or desc)")
+	}
+
+	filter.Action = query.Get("action")
+	filter.Path = query.Get("path")
+	filter.IPAddress = query.Get("ip")
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		if err := filter.ApplyCursor(cursor); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+// Values encodes the filter as query parameters for ParseAuditFilter
+func (f AuditFilter) Values() url.Values {
+	query := url.Values{}
+	for param, v := range map[string]int64{
+		"api_key_id": f.APIKeyID,
+		"team_id":    f.TeamID,
+		"after_id":   f.AfterID,
+		"before_id":  f.BeforeID,
+		"limit":      int64(f.Limit),
+	} {
+		if v != 0 {
+			query.Set(param, strconv.FormatInt(v, 10))
+		}
+	}
+	for param, v := range map[string]string{
+		"action": f.Action,
+		"path":   f.Path,
+		"ip":     f.IPAddress,
+	} {
+		if v != "" {
+			query.Set(param, v)
+		}
+	}
+	if f.StatusMin > 0 || f.StatusMax > 0 {
+		query.Set("status", FormatStatusRange(f.StatusMin, f.StatusMax))
+	}
+	if !f.Since.IsZero() {
+		query.Set("since", f.Since.UTC().Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		query.Set("until", f.Until.UTC().Format(time.RFC3339))
+	}
+	if f.Ascending {
+		query.Set("order", "asc")
+	}
+	return query
+}
+
+// ParseStatusRange parses a status filter: an exact code (404), a class
+// (4xx) or an inclusive range (400-499)
+func ParseStatusRange(status string) (int, int, error) {
+	invalid := fmt.Errorf("invalid status %q (use 404, 4xx or 400-499)", status)
+
+	if class, ok := strings.CutSuffix(strings.ToLower(status), "xx"); ok {
+		n, err := strconv.Atoi(class)
+		if err != nil || n < 1 || n > 5 {
+			return 0, 0, invalid
+		}
+		return n * 100, n*100 + 99, nil
+	}
+
+	if from, to, ok := strings.Cut(status, "-"); ok {
+		min, err1 := strconv.Atoi(from)
+		max, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || min > max {
+			return 0, 0, invalid
+		}
+		return min, max, nil
+	}
+
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	return code, code, nil
+}
+
+// FormatStatusRange is the inverse of ParseStatusRange
+func FormatStatusRange(min, max int) string {
+	switch {
+	case min == max:
+		return strconv.Itoa(min)
+	case min%100 == 0 && max == min+99:
+		return strconv.Itoa(min/100) + "xx"
+	default:
+		return strconv.Itoa(min) + "-" + strconv.Itoa(max)
+	}
+}
+
+// auditMetadata returns stored metadata as JSON, quoting legacy non-JSON values
+func auditMetadata(metadata string) json.RawMessage {
+	if json.Valid([]byte(metadata)) {
+		return json.RawMessage(metadata)
+	}
+	quoted, _ := json.Marshal(metadata)
+	return quoted
+}
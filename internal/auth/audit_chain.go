@@ -0,0 +1,473 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+// auditChainLockID is the PostgreSQL advisory lock held while appending to
+// the audit hash chain, so replicas sharing a database never fork it
+const auditChainLockID = 4_217_530_982
+
+// Reasons reported by VerifyAudit for a broken chain
+const (
+	AuditBreakModified      = "modified"
+	AuditBreakGap           = "gap"
+	AuditBreakUnhashed      = "unhashed"
+	AuditBreakCheckpoint    = "checkpoint_mismatch"
+	AuditBreakTruncated     = "truncated"
+	AuditBreakBadSignature  = "bad_signature"
+	AuditBreakUnknownSigner = "unknown_signer"
+)
+
+// appendAudit inserts an audit record linked to the hash of the record
+// before it. Appends are serialized in-process and, on PostgreSQL, across
+// processes; on SQLite the insert itself takes the database write lock.
+func (db *APIKeyDB) appendAudit(entry AuditEntry, metadata string) error {
+	db.auditMu.Lock()
+	defer db.auditMu.Unlock()
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin audit write: %w", err)
+	}
+	defer tx.Rollback()
+
+	if db.db.Dialect() == storage.DialectPostgres {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLockID); err != nil {
+			return fmt.Errorf("failed to lock audit chain: %w", err)
+		}
+	}
+
+	var teamID sql.NullInt64
+	if err := tx.QueryRow("SELECT team_id FROM api_keys WHERE id = ?", entry.APIKeyID).Scan(&teamID); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to look up key team: %w", err)
+	}
+	entry.TeamID = teamID.Int64
+
+	var team any
+	if teamID.Valid {
+		team = teamID.Int64
+	}
+	if err := tx.QueryRow(`
+		INSERT INTO api_key_audit (api_key_id, team_id, action, ip_address, user_agent, request_path, status_code, timestamp, metadata)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, entry.APIKeyID, team, entry.Action, entry.IPAddress, entry.UserAgent, entry.RequestPath,
+		entry.StatusCode, entry.Timestamp, metadata,
+	).Scan(&entry.ID); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+
+	var prevHash string
+	err = tx.QueryRow(`
+		SELECT COALESCE(entry_hash, '') FROM api_key_audit WHERE id < ? ORDER BY id DESC LIMIT 1
+	`, entry.ID).Scan(&prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read audit chain: %w", err)
+	}
+
+	entryHash := auditEntryHash(prevHash, entry, metadata)
+	if _, err := tx.Exec(
+		"UPDATE api_key_audit SET prev_hash = ?, entry_hash = ? WHERE id = ?", prevHash, entryHash, entry.ID,
+	); err != nil {
+		return fmt.Errorf("failed to write audit chain: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit audit record: %w", err)
+	}
+	return nil
+}
+
+// auditEntryHash returns the chain hash of a record: SHA-256 over the
+// previous hash and the record's canonical content. Timestamps are hashed at
+// second precision, which every backend stores exactly.
+func auditEntryHash(prevHash string, entry AuditEntry, metadata string) string {
+	canonical, _ := json.Marshal([]any{
+		entry.ID, entry.APIKeyID, entry.TeamID, entry.Action,
+		entry.IPAddress, entry.UserAgent, entry.RequestPath, entry.StatusCode,
+		entry.Timestamp.UTC().Format(time.RFC3339), metadata,
+	})
+
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write([]byte{'\n'})
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditCheckpoint is a signed statement of the audit chain head
+type AuditCheckpoint struct {
+	ID        int64     `json:"id"`
+	AuditID   int64     `json:"audit_id"`
+	EntryHash string    `json:"entry_hash"`
+	KeyID     string    `json:"key_id"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// signedMessage is the byte string covered by the checkpoint signature
+func (c AuditCheckpoint) signedMessage() []byte {
+	return fmt.Appendf(nil, "bedrock-proxy audit checkpoint v1\n%d\n%s\n%s\n",
+		c.AuditID, c.EntryHash, c.CreatedAt.UTC().Format(time.RFC3339))
+}
+
+// AuditSigner signs audit checkpoints with a local Ed25519 key
+type AuditSigner struct {
+	key ed25519.PrivateKey
+	id  string
+}
+
+// NewAuditSigner creates a signer for an Ed25519 private key
+func NewAuditSigner(key ed25519.PrivateKey) *AuditSigner {
+	return &AuditSigner{key: key, id: AuditKeyID(key.Public().(ed25519.PublicKey))}
+}
+
+// LoadAuditSigner reads a PEM-encoded PKCS #8 Ed25519 private key, as
+// written by "openssl genpkey -algorithm ed25519"
+func LoadAuditSigner(path string) (*AuditSigner, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse audit signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("audit signing key is not an Ed25519 key")
+	}
+	return NewAuditSigner(key), nil
+}
+
+// KeyID identifies the signer's public key in checkpoints
+func (s *AuditSigner) KeyID() string {
+	return s.id
+}
+
+// PublicKey returns the key that verifies the signer's checkpoints
+func (s *AuditSigner) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// AuditKeyID returns a short fingerprint of a checkpoint verification key
+func AuditKeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// LoadAuditPublicKeys reads checkpoint verification keys from PEM files
+// holding either a public key or the signing key itself
+func LoadAuditPublicKeys(paths ...string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for _, path := range paths {
+		block, err := readPEM(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var parsed any
+		if block.Type == "PUBLIC KEY" {
+			parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+		} else {
+			parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		switch key := parsed.(type) {
+		case ed25519.PublicKey:
+			keys = append(keys, key)
+		case ed25519.PrivateKey:
+			keys = append(keys, key.Public().(ed25519.PublicKey))
+		default:
+			return nil, fmt.Errorf("%s is not an Ed25519 key", path)
+		}
+	}
+	return keys, nil
+}
+
+// readPEM reads the first PEM block of a file
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM encoded", path)
+	}
+	return block, nil
+}
+
+// WriteAuditCheckpoint signs the current chain head at now. It returns nil
+// when the chain is empty or has not advanced since the last checkpoint.
+func (db *APIKeyDB) WriteAuditCheckpoint(signer *AuditSigner, now time.Time) (*AuditCheckpoint, error) {
+	checkpoint := AuditCheckpoint{KeyID: signer.KeyID(), CreatedAt: now.UTC().Truncate(time.Second)}
+
+	err := db.db.QueryRow(`
+		SELECT id, entry_hash FROM api_key_audit
+		WHERE entry_hash IS NOT NULL ORDER BY id DESC LIMIT 1
+	`).Scan(&checkpoint.AuditID, &checkpoint.EntryHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit chain head: %w", err)
+	}
+
+	var lastAuditID int64
+	if err := db.db.QueryRow(
+		"SELECT COALESCE(MAX(audit_id), 0) FROM api_key_audit_checkpoints",
+	).Scan(&lastAuditID); err != nil {
+		return nil, fmt.Errorf("failed to read audit checkpoints: %w", err)
+	}
+	if lastAuditID >= checkpoint.AuditID {
+		return nil, nil
+	}
+
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(signer.key, checkpoint.signedMessage()))
+	if err := db.db.QueryRow(`
+		INSERT INTO api_key_audit_checkpoints (audit_id, entry_hash, key_id, signature, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id
+	`, checkpoint.AuditID, checkpoint.EntryHash, checkpoint.KeyID, checkpoint.Signature, checkpoint.CreatedAt,
+	).Scan(&checkpoint.ID); err != nil {
+		return nil, fmt.Errorf("failed to write audit checkpoint: %w", err)
+	}
+
+	return &checkpoint, nil
+}
+
+// RunAuditCheckpoints signs the chain head on every interval until ctx is
+// cancelled
+func (db *APIKeyDB) RunAuditCheckpoints(ctx context.Context, signer *AuditSigner, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := db.WriteAuditCheckpoint(signer, time.Now()); err != nil {
+			log.Printf("Audit checkpoint failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// AuditVerifyReport is the result of VerifyAudit
+type AuditVerifyReport struct {
+	OK bool `json:"ok"`
+
+	// Entries is the number of chained records checked, from FirstID to LastID
+	Entries int   `json:"entries"`
+	FirstID int64 `json:"first_id,omitempty"`
+	LastID  int64 `json:"last_id,omitempty"`
+
+	// Unchained counts records written before hash chaining was enabled
+	Unchained int `json:"unchained"`
+
+	// ArchivedThrough is the last record moved to archives by retention
+	ArchivedThrough int64 `json:"archived_through,omitempty"`
+
+	// Checkpoints counts signed checkpoints matched against the chain;
+	// ArchivedCheckpoints refer to records already moved to archives
+	Checkpoints         int  `json:"checkpoints"`
+	ArchivedCheckpoints int  `json:"archived_checkpoints"`
+	SignaturesChecked   bool `json:"signatures_checked"`
+
+	// Break describes the first broken link when OK is false
+	Break *AuditBreak `json:"break,omitempty"`
+}
+
+// AuditBreak locates the first broken link of the audit chain
+type AuditBreak struct {
+	Reason       string `json:"reason"`
+	EntryID      int64  `json:"entry_id,omitempty"`
+	CheckpointID int64  `json:"checkpoint_id,omitempty"`
+	Message      string `json:"message"`
+}
+
+// VerifyAudit recomputes the audit hash chain and checks it against the
+// signed checkpoints. Checkpoint signatures are verified when keys are
+// given. The chain must start where the last archive ended, or at the
+// beginning when nothing was archived, so records deleted outside
+// retention are reported.
+func (db *APIKeyDB) VerifyAudit(keys []ed25519.PublicKey) (*AuditVerifyReport, error) {
+	report := &AuditVerifyReport{SignaturesChecked: len(keys) > 0}
+	fail := func(b AuditBreak) (*AuditVerifyReport, error) {
+		report.Break = &b
+		return report, nil
+	}
+
+	checkpoints, err := db.auditCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	var anchorHash string
+	err = db.db.QueryRow(`
+		SELECT last_audit_id, last_entry_hash FROM api_key_audit_archives
+		ORDER BY last_audit_id DESC LIMIT 1
+	`).Scan(&report.ArchivedThrough, &anchorHash)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to read audit archives: %w", err)
+	}
+
+	if len(keys) > 0 {
+		byID := make(map[string]ed25519.PublicKey, len(keys))
+		for _, key := range keys {
+			byID[AuditKeyID(key)] = key
+		}
+		for _, c := range checkpoints {
+			key, ok := byID[c.KeyID]
+			if !ok {
+				return fail(AuditBreak{
+					Reason: AuditBreakUnknownSigner, CheckpointID: c.ID, EntryID: c.AuditID,
+					Message: fmt.Sprintf("checkpoint %d is signed by unknown key %s", c.ID, c.KeyID),
+				})
+			}
+			signature, err := base64.StdEncoding.DecodeString(c.Signature)
+			if err != nil || !ed25519.Verify(key, c.signedMessage(), signature) {
+				return fail(AuditBreak{
+					Reason: AuditBreakBadSignature, CheckpointID: c.ID, EntryID: c.AuditID,
+					Message: fmt.Sprintf("checkpoint %d has an invalid signature", c.ID),
+				})
+			}
+		}
+	}
+
+	byAuditID := make(map[int64][]AuditCheckpoint, len(checkpoints))
+	for _, c := range checkpoints {
+		byAuditID[c.AuditID] = append(byAuditID[c.AuditID], c)
+	}
+
+	matched := make(map[int64]bool, len(checkpoints))
+	var prevHash string
+	var broken *AuditBreak
+	errStop := errors.New("stop")
+	err = db.ExportAudit(AuditFilter{}, func(entry AuditEntry) error {
+		if entry.EntryHash == "" {
+			if report.Entries == 0 {
+				report.Unchained++
+				return nil
+			}
+			broken = &AuditBreak{
+				Reason: AuditBreakUnhashed, EntryID: entry.ID,
+				Message: fmt.Sprintf("entry %d has no hash but follows chained entry %d", entry.ID, report.LastID),
+			}
+			return errStop
+		}
+
+		if report.Entries == 0 {
+			report.FirstID = entry.ID
+			if entry.PrevHash != anchorHash {
+				message := fmt.Sprintf("entry %d does not start the chain: earlier entries were deleted without being archived", entry.ID)
+				if report.ArchivedThrough != 0 {
+					message = fmt.Sprintf("entry %d does not link to archived entry %d: entries were deleted after the archive", entry.ID, report.ArchivedThrough)
+				}
+				broken = &AuditBreak{Reason: AuditBreakTruncated, EntryID: entry.ID, Message: message}
+				return errStop
+			}
+		} else if entry.PrevHash != prevHash {
+			broken = &AuditBreak{
+				Reason: AuditBreakGap, EntryID: entry.ID,
+				Message: fmt.Sprintf("entry %d does not link to entry %d: entries were deleted, inserted or re-hashed between them", entry.ID, report.LastID),
+			}
+			return errStop
+		}
+
+		if auditEntryHash(entry.PrevHash, entry, entry.rawMetadata) != entry.EntryHash {
+			broken = &AuditBreak{
+				Reason: AuditBreakModified, EntryID: entry.ID,
+				Message: fmt.Sprintf("entry %d does not match its hash: it was modified", entry.ID),
+			}
+			return errStop
+		}
+
+		for _, c := range byAuditID[entry.ID] {
+			if c.EntryHash != entry.EntryHash {
+				broken = &AuditBreak{
+					Reason: AuditBreakCheckpoint, EntryID: entry.ID, CheckpointID: c.ID,
+					Message: fmt.Sprintf("entry %d differs from signed checkpoint %d: the chain was rewritten", entry.ID, c.ID),
+				}
+				return errStop
+			}
+			matched[c.ID] = true
+			report.Checkpoints++
+		}
+
+		prevHash = entry.EntryHash
+		report.LastID = entry.ID
+		report.Entries++
+		return nil
+	})
+	if broken != nil {
+		return fail(*broken)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Every checkpoint must have matched a record, unless retention archived it
+	for _, c := range checkpoints {
+		switch {
+		case matched[c.ID]:
+		case c.AuditID <= report.ArchivedThrough:
+			report.ArchivedCheckpoints++
+		default:
+			return fail(AuditBreak{
+				Reason: AuditBreakTruncated, EntryID: c.AuditID, CheckpointID: c.ID,
+				Message: fmt.Sprintf("checkpoint %d covers entry %d, which no longer exists: entries were deleted", c.ID, c.AuditID),
+			})
+		}
+	}
+
+	report.OK = true
+	return report, nil
+}
+
+// auditCheckpoints returns all checkpoints in order
+func (db *APIKeyDB) auditCheckpoints() ([]AuditCheckpoint, error) {
+	rows, err := db.db.Query(`
+		SELECT id, audit_id, entry_hash, key_id, signature, created_at
+		FROM api_key_audit_checkpoints ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var checkpoints []AuditCheckpoint
+	for rows.Next() {
+		var c AuditCheckpoint
+		if err := rows.Scan(&c.ID, &c.AuditID, &c.EntryHash, &c.KeyID, &c.Signature, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read audit checkpoints: %w", err)
+		}
+		checkpoints = append(checkpoints, c)
+	}
+	return checkpoints, rows.Err()
+}
@@ -0,0 +1,312 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newChainTestDB returns a database with five chained audit records and a
+// signed checkpoint after the fourth
+func newChainTestDB(t *testing.T) (*APIKeyDB, *AuditSigner, []AuditEntry) {
+	t.Helper()
+
+	db := newAuditTestDB(t)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer := NewAuditSigner(key)
+
+	for i, action := range []string{"login", "2fa_enabled", "login", "session_revoked", "logout"} {
+		if err := db.LogAPIKeyUsage(1, action, "10.0.0.1", "test", "/auth", 200, `{"n":1}`); err != nil {
+			t.Fatalf("Failed to log usage: %v", err)
+		}
+		if i == 3 {
+			if _, err := db.WriteAuditCheckpoint(signer, time.Now()); err != nil {
+				t.Fatalf("Failed to write checkpoint: %v", err)
+			}
+		}
+	}
+
+	entries, err := db.QueryAudit(AuditFilter{Ascending: true})
+	if err != nil {
+		t.Fatalf("Failed to query audit: %v", err)
+	}
+	return db, signer, entries
+}
+
+func TestAuditChainIntact(t *testing.T) {
+	db, signer, entries := newChainTestDB(t)
+
+	for i, entry := range entries {
+		if entry.EntryHash == "" {
+			t.Fatalf("Entry %d has no hash", entry.ID)
+		}
+		if i > 0 && entry.PrevHash != entries[i-1].EntryHash {
+			t.Errorf("Entry %d does not link to its predecessor", entry.ID)
+		}
+	}
+
+	// The head advanced past the first checkpoint; a second one is written once
+	if cp, err := db.WriteAuditCheckpoint(signer, time.Now()); err != nil || cp == nil || cp.AuditID != entries[4].ID {
+		t.Fatalf("Expected checkpoint of the head, got %+v, %v", cp, err)
+	}
+	if cp, err := db.WriteAuditCheckpoint(signer, time.Now()); err != nil || cp != nil {
+		t.Errorf("Expected no checkpoint for an unchanged head, got %+v, %v", cp, err)
+	}
+
+	report, err := db.VerifyAudit([]ed25519.PublicKey{signer.PublicKey()})
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if !report.OK || report.Entries != 5 || report.Checkpoints != 2 || !report.SignaturesChecked {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestAuditChainTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, db *APIKeyDB, entries []AuditEntry)
+		reason string
+		entry  int
+	}{
+		{
+			name: "modified field",
+			tamper: func(t *testing.T, db *APIKeyDB, entries []AuditEntry) {
+				mustExec(t, db, "UPDATE api_key_audit SET status_code = 500 WHERE id = ?", entries[1].ID)
+			},
+			reason: AuditBreakModified,
+			entry:  1,
+		},
+		{
+			name: "modified metadata",
+			tamper: func(t *testing.T, db *APIKeyDB, entries []AuditEntry) {
+				mustExec(t, db, "UPDATE api_key_audit SET metadata = '{}' WHERE id = ?", entries[4].ID)
+			},
+			reason: AuditBreakModified,
+			entry:  4,
+		},
+		{
+			name: "deleted entry",
+			tamper: func(t *testing.T, db *APIKeyDB, entries []AuditEntry) {
+				mustExec(t, db, "DELETE FROM api_key_audit WHERE id = ?", entries[2].ID)
+			},
+			reason: AuditBreakGap,
+			entry:  3,
+		},
+		{
+			name: "modified and re-hashed",
+			tamper: func(t *testing.T, db *APIKeyDB, entries []AuditEntry) {
+				mustExec(t, db, "UPDATE api_key_audit SET action = 'login' WHERE id = ?", entries[1].ID)
+				rehash(t, db)
+			},
+			reason: AuditBreakCheckpoint,
+			entry:  3,
+		},
+		{
+			name: "oldest entries deleted without archiving",
+			tamper: func(t *testing.T, db *APIKeyDB, entries []AuditEntry) {
+				mustExec(t, db, "DELETE FROM api_key_audit WHERE id <= ?", entries[1].ID)
+			},
+			reason: AuditBreakTruncated,
+			entry:  2,
+		},
+		{
+			name: "truncated after checkpoint",
+			tamper: func(t *testing.T, db *APIKeyDB, entries []AuditEntry) {
+				mustExec(t, db, "DELETE FROM api_key_audit WHERE id >= ?", entries[3].ID)
+			},
+			reason: AuditBreakTruncated,
+			entry:  3,
+		},
+		{
+			name: "forged checkpoint",
+			tamper: func(t *testing.T, db *APIKeyDB, entries []AuditEntry) {
+				mustExec(t, db, "UPDATE api_key_audit_checkpoints SET entry_hash = ?", entries[2].EntryHash)
+			},
+			reason: AuditBreakBadSignature,
+			entry:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, signer, entries := newChainTestDB(t)
+			tt.tamper(t, db, entries)
+
+			report, err := db.VerifyAudit([]ed25519.PublicKey{signer.PublicKey()})
+			if err != nil {
+				t.Fatalf("Failed to verify: %v", err)
+			}
+			if report.OK || report.Break == nil {
+				t.Fatalf("Expected a broken chain, got %+v", report)
+			}
+			if report.Break.Reason != tt.reason || report.Break.EntryID != entries[tt.entry].ID {
+				t.Errorf("Expected %s at entry %d, got %+v", tt.reason, entries[tt.entry].ID, report.Break)
+			}
+		})
+	}
+}
+
+func TestAuditChainUnknownSigner(t *testing.T) {
+	db, _, _ := newChainTestDB(t)
+
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	report, err := db.VerifyAudit([]ed25519.PublicKey{other})
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if report.OK || report.Break.Reason != AuditBreakUnknownSigner {
+		t.Errorf("Expected unknown signer, got %+v", report.Break)
+	}
+
+	// Without keys only the chain is checked
+	report, err = db.VerifyAudit(nil)
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	if !report.OK || report.SignaturesChecked {
+		t.Errorf("Expected unsigned verification to pass, got %+v", report)
+	}
+}
+
+func TestAuditChainAfterRetention(t *testing.T) {
+	// newArchivedChain returns a legacy record, four chained records older
+	// than the 30-day retention and two recent ones, with the five old
+	// records archived
+	newArchivedChain := func(t *testing.T) (*APIKeyDB, *AuditSigner, []AuditEntry) {
+		t.Helper()
+
+		db := newAuditTestDB(t)
+		_, key, _ := ed25519.GenerateKey(rand.Reader)
+		signer := NewAuditSigner(key)
+
+		now := time.Now().UTC().Truncate(time.Second)
+		mustExec(t, db, "INSERT INTO api_key_audit (api_key_id, action, timestamp) VALUES (1, 'legacy', ?)", now.AddDate(0, 0, -41))
+		for i, age := range []time.Duration{40, 39, 38, 37, 0, 0} {
+			if err := db.appendAudit(AuditEntry{
+				APIKeyID: 1, Action: "login", StatusCode: 200,
+				Timestamp: now.Add(-age*24*time.Hour - time.Hour),
+			}, "{}"); err != nil {
+				t.Fatalf("Failed to append audit record: %v", err)
+			}
+			if i == 2 || i == 5 {
+				if _, err := db.WriteAuditCheckpoint(signer, now); err != nil {
+					t.Fatalf("Failed to write checkpoint: %v", err)
+				}
+			}
+		}
+		entries, err := db.QueryAudit(AuditFilter{Ascending: true})
+		if err != nil {
+			t.Fatalf("Failed to query audit: %v", err)
+		}
+
+		count, _, err := NewAuditArchiver(db, t.TempDir(), 30*24*time.Hour).ArchiveOnce(now)
+		if err != nil || count != 5 {
+			t.Fatalf("Expected 5 archived records, got %d, %v", count, err)
+		}
+		return db, signer, entries
+	}
+
+	t.Run("archived chain verifies", func(t *testing.T) {
+		db, signer, entries := newArchivedChain(t)
+
+		report, err := db.VerifyAudit([]ed25519.PublicKey{signer.PublicKey()})
+		if err != nil {
+			t.Fatalf("Failed to verify: %v", err)
+		}
+		if !report.OK || report.Entries != 2 || report.FirstID != entries[5].ID || report.ArchivedThrough != entries[4].ID ||
+			report.ArchivedCheckpoints != 1 || report.Checkpoints != 1 {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("deleted after the archive", func(t *testing.T) {
+		db, signer, entries := newArchivedChain(t)
+		mustExec(t, db, "DELETE FROM api_key_audit WHERE id = ?", entries[5].ID)
+
+		report, err := db.VerifyAudit([]ed25519.PublicKey{signer.PublicKey()})
+		if err != nil {
+			t.Fatalf("Failed to verify: %v", err)
+		}
+		if report.OK || report.Break.Reason != AuditBreakTruncated || report.Break.EntryID != entries[6].ID {
+			t.Errorf("Expected truncation at entry %d, got %+v", entries[6].ID, report.Break)
+		}
+	})
+
+	t.Run("everything after the archive deleted", func(t *testing.T) {
+		db, signer, entries := newArchivedChain(t)
+		mustExec(t, db, "DELETE FROM api_key_audit WHERE id > ?", entries[4].ID)
+
+		report, err := db.VerifyAudit([]ed25519.PublicKey{signer.PublicKey()})
+		if err != nil {
+			t.Fatalf("Failed to verify: %v", err)
+		}
+		if report.OK || report.Break.Reason != AuditBreakTruncated || report.Break.EntryID != entries[6].ID {
+			t.Errorf("Expected the recent checkpoint to be reported, got %+v", report.Break)
+		}
+	})
+}
+
+func TestLoadAuditKeys(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	dir := t.TempDir()
+
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	privateFile := filepath.Join(dir, "audit.pem")
+	os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	der, _ = x509.MarshalPKIXPublicKey(key.Public())
+	publicFile := filepath.Join(dir, "audit.pub")
+	os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644)
+
+	signer, err := LoadAuditSigner(privateFile)
+	if err != nil {
+		t.Fatalf("Failed to load signer: %v", err)
+	}
+	keys, err := LoadAuditPublicKeys(publicFile, privateFile)
+	if err != nil {
+		t.Fatalf("Failed to load public keys: %v", err)
+	}
+	for _, k := range keys {
+		if AuditKeyID(k) != signer.KeyID() {
+			t.Errorf("Expected key ID %s, got %s", signer.KeyID(), AuditKeyID(k))
+		}
+	}
+
+	if _, err := LoadAuditSigner(publicFile); err == nil {
+		t.Error("Expected error loading a public key as signer")
+	}
+}
+
+func mustExec(t *testing.T, db *APIKeyDB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.db.Exec(query, args...); err != nil {
+		t.Fatalf("Failed to run %q: %v", query, err)
+	}
+}
+
+// rehash recomputes the whole chain as an attacker with database access could
+func rehash(t *testing.T, db *APIKeyDB) {
+	t.Helper()
+
+	entries, err := db.QueryAudit(AuditFilter{Ascending: true})
+	if err != nil {
+		t.Fatalf("Failed to query audit: %v", err)
+	}
+	prev := ""
+	for _, entry := range entries {
+		hash := auditEntryHash(prev, entry, entry.rawMetadata)
+		mustExec(t, db, "UPDATE api_key_audit SET prev_hash = ?, entry_hash = ? WHERE id = ?", prev, hash, entry.ID)
+		prev = hash
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audit export formats
+const (
+	AuditFormatJSONL = "jsonl"
+	AuditFormatCSV   = "csv"
+)
+
+// auditCSVHeader is the column order of CSV exports
+var auditCSVHeader = []string{
+	"id", "timestamp", "api_key_id", "team_id", "action", "status_code",
+	"ip_address", "user_agent", "request_path", "metadata", "prev_hash", "entry_hash",
+}
+
+// AuditWriter encodes audit records for export
+type AuditWriter interface {
+	Write(entry AuditEntry) error
+	Flush() error
+}
+
+// NewAuditWriter returns a writer for the jsonl or csv format
+func NewAuditWriter(w io.Writer, format string) (AuditWriter, error) {
+	switch format {
+	case AuditFormatJSONL:
+		return &jsonlAuditWriter{enc: json.NewEncoder(w)}, nil
+	case AuditFormatCSV:
+		return &csvAuditWriter{w: csv.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported audit format %q (use jsonl or csv)", format)
+	}
+}
+
+type jsonlAuditWriter struct {
+	enc *json.Encoder
+}
+
+func (w *jsonlAuditWriter) Write(entry AuditEntry) error {
+	return w.enc.Encode(entry)
+}
+
+func (w *jsonlAuditWriter) Flush() error {
+	return nil
+}
+
+type csvAuditWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (w *csvAuditWriter) Write(entry AuditEntry) error {
+	if !w.wroteHeader {
+		if err := w.w.Write(auditCSVHeader); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+
+	return w.w.Write([]string{
+		strconv.FormatInt(entry.ID, 10),
+		entry.Timestamp.UTC().Format(time.RFC3339),
+		strconv.FormatInt(entry.APIKeyID, 10),
+		strconv.FormatInt(entry.TeamID, 10),
+		csvSafe(entry.Action),
+		strconv.Itoa(entry.StatusCode),
+		csvSafe(entry.IPAddress),
+		csvSafe(entry.UserAgent),
+		csvSafe(entry.RequestPath),
+		csvSafe(string(entry.Metadata)),
+		entry.PrevHash,
+		entry.EntryHash,
+	})
+}
+
+func (w *csvAuditWriter) Flush() error {
+	if !w.wroteHeader {
+		if err := w.w.Write(auditCSVHeader); err != nil {
+			return err
+		}
+		w.wroteHeader = true
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// csvSafe stops client-controlled values such as user agents from being
+// evaluated as formulas when an export is opened in a spreadsheet
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// AuditArchiver moves audit records older than the retention period into
+// gzip-compressed JSONL files and deletes them from the database
+type AuditArchiver struct {
+	db        *APIKeyDB
+	dir       string
+	retention time.Duration
+}
+
+// NewAuditArchiver creates an archiver writing to dir
+func NewAuditArchiver(db *APIKeyDB, dir string, retention time.Duration) *AuditArchiver {
+	return &AuditArchiver{db: db, dir: dir, retention: retention}
+}
+
+// ArchiveOnce archives records older than the retention period at now. It
+// returns the number of records archived and the archive file, if any.
+// Only the oldest records up to the first one inside the retention period
+// are archived, so the remaining chain still starts where the archive ends.
+// Records are deleted only after the archive has been written and synced,
+// together with a record of the last archived entry.
+func (a *AuditArchiver) ArchiveOnce(now time.Time) (int, string, error) {
+	cutoff := now.Add(-a.retention)
+
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return 0, "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	name := filepath.Join(a.dir, "audit-"+now.UTC().Format("20060102T150405Z")+".jsonl.gz")
+	tmp := name + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	writer, _ := NewAuditWriter(gz, AuditFormatJSONL)
+
+	var count int
+	var last AuditEntry
+	errDone := errors.New("done")
+	err = a.db.ExportAudit(AuditFilter{}, func(entry AuditEntry) error {
+		if !entry.Timestamp.Before(cutoff) {
+			return errDone
+		}
+		count++
+		last = entry
+		return writer.Write(entry)
+	})
+	if err != nil && err != errDone {
+		return 0, "", err
+	}
+	if count == 0 {
+		return 0, "", nil
+	}
+
+	if err := gz.Close(); err != nil {
+		return 0, "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return 0, "", fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	// Everything up to the last entry was written above
+	tx, err := a.db.db.Begin()
+	if err != nil {
+		return count, name, fmt.Errorf("failed to delete archived records: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO api_key_audit_archives (last_audit_id, last_entry_hash, records, file, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, last.ID, last.EntryHash, count, filepath.Base(name), now.UTC()); err != nil {
+		return count, name, fmt.Errorf("failed to record audit archive: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM api_key_audit WHERE id <= ?", last.ID); err != nil {
+		return count, name, fmt.Errorf("failed to delete archived records: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return count, name, fmt.Errorf("failed to delete archived records: %w", err)
+	}
+
+	return count, name, nil
+}
+
+// Run archives on every interval until ctx is cancelled
+func (a *AuditArchiver) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count, name, err := a.ArchiveOnce(time.Now())
+		if err != nil {
+			log.Printf("Audit retention failed: %v", err)
+		} else if count > 0 {
+			log.Printf("Archived %d audit records to %s", count, name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+func newAuditTestDB(t *testing.T) *APIKeyDB {
+	t.Helper()
+
+	store, err := storage.NewMemory()
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	db, err := NewAPIKeyDBWithStore(store)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestQueryAudit(t *testing.T) {
+	db := newAuditTestDB(t)
+
+	for _, entry := range []struct {
+		keyID  int64
+		action string
+		ip     string
+		path   string
+		status int
+	}{
+		{1, "login", "10.0.0.1", "/auth/login", 200},
+		{1, "2fa_enabled", "10.0.0.1", "/auth/2fa/enable", 200},
+		{2, "login", "10.0.0.2", "/auth/login", 401},
+		{1, "2fa_verify_failed", "10.0.0.1", "/auth/2fa/verify", 403},
+		{2, "session_revoked", "10.0.0.2", "/admin/keys/2/sessions/1", 200},
+	} {
+		if err := db.LogAPIKeyUsage(entry.keyID, entry.action, entry.ip, "test", entry.path, entry.status, "{}"); err != nil {
+			t.Fatalf("Failed to log usage: %v", err)
+		}
+	}
+
+	actions := func(entries []AuditEntry) []string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Action)
+		}
+		return out
+	}
+
+	t.Run("newest first by default", func(t *testing.T) {
+		entries, err := db.QueryAudit(AuditFilter{Limit: 2})
+		if err != nil {
+			t.Fatalf("Failed to query audit: %v", err)
+		}
+		if got := actions(entries); len(got) != 2 || got[0] != "session_revoked" || got[1] != "2fa_verify_failed" {
+			t.Errorf("Unexpected entries: %v", got)
+		}
+	})
+
+	t.Run("filters combine", func(t *testing.T) {
+		entries, err := db.QueryAudit(AuditFilter{APIKeyID: 1, Action: "2fa_*"})
+		if err != nil {
+			t.Fatalf("Failed to query audit: %v", err)
+		}
+		if got := actions(entries); len(got) != 2 || got[1] != "2fa_enabled" {
+			t.Errorf("Unexpected entries: %v", got)
+		}
+	})
+
+	t.Run("status path and ip", func(t *testing.T) {
+		for _, tt := range []struct {
+			name   string
+			filter AuditFilter
+			want   int
+		}{
+			{"client errors", AuditFilter{StatusMin: 400, StatusMax: 499}, 2},
+			{"exact status", AuditFilter{StatusMin: 403, StatusMax: 403}, 1},
+			{"path prefix", AuditFilter{Path: "/auth/*"}, 4},
+			{"exact path", AuditFilter{Path: "/auth/login"}, 2},
+			{"wildcards are literal", AuditFilter{Path: "/auth_%*"}, 0},
+			{"ip", AuditFilter{IPAddress: "10.0.0.2"}, 2},
+		} {
+			entries, err := db.QueryAudit(tt.filter)
+			if err != nil {
+				t.Fatalf("%s: failed to query audit: %v", tt.name, err)
+			}
+			if len(entries) != tt.want {
+				t.Errorf("%s: expected %d entries, got %v", tt.name, tt.want, actions(entries))
+			}
+		}
+	})
+
+	t.Run("after id returns newer entries", func(t *testing.T) {
+		all, _ := db.QueryAudit(AuditFilter{Ascending: true})
+		entries, err := db.QueryAudit(AuditFilter{AfterID: all[2].ID})
+		if err != nil {
+			t.Fatalf("Failed to query audit: %v", err)
+		}
+		if len(entries) != 2 || entries[0].ID != all[3].ID {
+			t.Errorf("Expected the 2 entries after %d, got %v", all[2].ID, actions(entries))
+		}
+	})
+
+	t.Run("cursor pages through everything", func(t *testing.T) {
+		for _, order := range []string{"desc", "asc"} {
+			seen := map[int64]bool{}
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > 5 {
+					t.Fatalf("%s: cursor did not terminate", order)
+				}
+				filter, err := ParseAuditFilter(map[string][]string{
+					"limit": {"2"}, "order": {order}, "cursor": {cursor},
+				})
+				if err != nil {
+					t.Fatalf("%s: failed to parse filter: %v", order, err)
+				}
+				entries, err := db.QueryAudit(filter)
+				if err != nil {
+					t.Fatalf("%s: failed to query audit: %v", order, err)
+				}
+				for _, e := range entries {
+					if seen[e.ID] {
+						t.Errorf("%s: entry %d returned twice", order, e.ID)
+					}
+					seen[e.ID] = true
+				}
+				if cursor = filter.NextCursor(entries); cursor == "" {
+					break
+				}
+			}
+			if len(seen) != 5 {
+				t.Errorf("%s: expected 5 entries across pages, got %d", order, len(seen))
+			}
+		}
+	})
+
+	t.Run("time range", func(t *testing.T) {
+		entries, err := db.QueryAudit(AuditFilter{Since: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("Failed to query audit: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Expected no future entries, got %d", len(entries))
+		}
+	})
+
+	t.Run("filter survives query encoding", func(t *testing.T) {
+		filter := AuditFilter{
+			APIKeyID: 1, Action: "2fa_*", Path: "/auth/*", IPAddress: "10.0.0.1",
+			StatusMin: 400, StatusMax: 499, Ascending: true, Limit: 10,
+			Since: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		parsed, err := ParseAuditFilter(filter.Values())
+		if err != nil {
+			t.Fatalf("Failed to parse filter: %v", err)
+		}
+		if parsed != filter {
+			t.Errorf("Expected %+v, got %+v", filter, parsed)
+		}
+	})
+}
+
+func TestParseStatusRange(t *testing.T) {
+	for _, tt := range []struct {
+		in       string
+		min, max int
+		wantErr  bool
+	}{
+		{"404", 404, 404, false},
+		{"5xx", 500, 599, false},
+		{"4XX", 400, 499, false},
+		{"400-403", 400, 403, false},
+		{"403-400", 0, 0, true},
+		{"9xx", 0, 0, true},
+		{"abc", 0, 0, true},
+	} {
+		min, max, err := ParseStatusRange(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: unexpected error: %v", tt.in, err)
+			continue
+		}
+		if min != tt.min || max != tt.max {
+			t.Errorf("%q: expected %d-%d, got %d-%d", tt.in, tt.min, tt.max, min, max)
+		}
+		if err == nil && FormatStatusRange(min, max) != strings.ToLower(tt.in) {
+			t.Errorf("%q: formatted as %q", tt.in, FormatStatusRange(min, max))
+		}
+	}
+}
+
+func TestAuditWriterCSV(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewAuditWriter(&buf, AuditFormatCSV)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if err := w.Write(AuditEntry{
+		ID: 1, APIKeyID: 2, Action: "login", UserAgent: "=HYPERLINK(\"x\")",
+		Timestamp: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Metadata: json.RawMessage(`{}`),
+	}); err != nil {
+		t.Fatalf("Failed to write entry: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "id,timestamp,") {
+		t.Fatalf("Unexpected CSV output: %q", buf.String())
+	}
+	if !strings.Contains(lines[1], `"'=HYPERLINK(""x"")"`) {
+		t.Errorf("Expected formula to be neutralized, got %q", lines[1])
+	}
+
+	buf.Reset()
+	w, _ = NewAuditWriter(&buf, AuditFormatCSV)
+	w.Flush()
+	if !strings.HasPrefix(buf.String(), "id,") {
+		t.Errorf("Expected header for empty export, got %q", buf.String())
+	}
+
+	if _, err := NewAuditWriter(&buf, "xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestAuditArchiver(t *testing.T) {
+	db := newAuditTestDB(t)
+
+	now := time.Now().UTC()
+	for _, age := range []time.Duration{40 * 24 * time.Hour, 35 * 24 * time.Hour, time.Hour} {
+		if _, err := db.db.Exec(
+			"INSERT INTO api_key_audit (api_key_id, action, timestamp) VALUES (?, ?, ?)",
+			1, "login", now.Add(-age),
+		); err != nil {
+			t.Fatalf("Failed to insert audit record: %v", err)
+		}
+	}
+
+	archiver := NewAuditArchiver(db, t.TempDir(), 30*24*time.Hour)
+	count, name, err := archiver.ArchiveOnce(now)
+	if err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 archived records, got %d", count)
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	var archived []AuditEntry
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid archive line %q: %v", scanner.Text(), err)
+		}
+		archived = append(archived, entry)
+	}
+	if len(archived) != 2 || archived[0].ID >= archived[1].ID {
+		t.Errorf("Expected 2 records oldest first, got %+v", archived)
+	}
+
+	remaining, err := db.QueryAudit(AuditFilter{})
+	if err != nil {
+		t.Fatalf("Failed to query audit: %v", err)
+	}
+	if len(remaining) != 1 {
+		t.Errorf("Expected 1 record to remain, got %d", len(remaining))
+	}
+
+	var lastID, records int64
+	if err := db.db.QueryRow(
+		"SELECT last_audit_id, records FROM api_key_audit_archives",
+	).Scan(&lastID, &records); err != nil {
+		t.Fatalf("Failed to read archive record: %v", err)
+	}
+	if lastID != archived[1].ID || records != 2 {
+		t.Errorf("Expected the archive to end at %d with 2 records, got %d, %d", archived[1].ID, lastID, records)
+	}
+
+	// Nothing left to archive: no empty files are written
+	if count, name, err := archiver.ArchiveOnce(now); err != nil || count != 0 || name != "" {
+		t.Errorf("Expected no-op, got %d %q %v", count, name, err)
+	}
+}
@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -11,6 +10,10 @@ import (
 
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // AWSSigner handles AWS Signature V4 signing for Bedrock requests
@@ -29,16 +32,24 @@ func NewAWSSigner(region, service string) (*AWSSigner, error) {
 
 // SignRequest signs an HTTP request using AWS Signature V4
 func (s *AWSSigner) SignRequest(req *http.Request, body []byte) error {
+	ctx, span := tracing.Tracer().Start(req.Context(), "AWSSigner.SignRequest", trace.WithAttributes(
+		semconv.CloudRegion(s.region),
+		attribute.String("aws.signing.service", s.service),
+	))
+	defer span.End()
+
 	// Load AWS config with default credential chain (supports IRSA, EC2 instance profile, env vars)
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		log.Printf("Unable to load AWS config: %v", err)
+		tracing.RecordError(span, err, "config_error")
 		return fmt.Errorf("unable to load AWS config: %w", err)
 	}
 
-	credentials, err := cfg.Credentials.Retrieve(context.TODO())
+	credentials, err := cfg.Credentials.Retrieve(ctx)
 	if err != nil {
 		log.Printf("Unable to retrieve AWS credentials: %v", err)
+		tracing.RecordError(span, err, "credentials_error")
 		return fmt.Errorf("unable to retrieve AWS credentials: %w", err)
 	}
 
@@ -55,9 +66,10 @@ func (s *AWSSigner) SignRequest(req *http.Request, body []byte) error {
 
 	// Use AWS SDK v4 signer
 	signer := v4.NewSigner()
-	err = signer.SignHTTP(context.TODO(), credentials, req, hash, s.service, s.region, time.Now().UTC())
+	err = signer.SignHTTP(ctx, credentials, req, hash, s.service, s.region, time.Now().UTC())
 	if err != nil {
 		log.Printf("Unable to sign request: %v", err)
+		tracing.RecordError(span, err, "signing_error")
 		return fmt.Errorf("unable to sign request: %w", err)
 	}
 
@@ -0,0 +1,178 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// masterKeySize is the AES-256 master key length in bytes
+const masterKeySize = 32
+
+// Keyring holds the master keys used to envelope-encrypt secrets at rest.
+// New secrets are sealed under the primary key; older keys are kept only
+// to open secrets written before a rotation.
+type Keyring struct {
+	primaryID string
+	keys      map[string][]byte
+}
+
+// LoadKeyring loads the primary master key file and any previous key files
+// still needed to decrypt existing secrets. Key files hold 32 bytes, either
+// raw or hex encoded.
+func LoadKeyring(primaryFile string, previousFiles ...string) (*Keyring, error) {
+	primary, err := readMasterKey(primaryFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ring := NewKeyring(primary)
+	for _, path := range previousFiles {
+		key, err := readMasterKey(path)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[masterKeyID(key)] = key
+	}
+
+	return ring, nil
+}
+
+// NewKeyring creates a keyring with a single primary master key
+func NewKeyring(primary []byte) *Keyring {
+	id := masterKeyID(primary)
+	return &Keyring{
+		primaryID: id,
+		keys:      map[string][]byte{id: primary},
+	}
+}
+
+// PrimaryID returns the ID of the key used for new secrets
+func (k *Keyring) PrimaryID() string {
+	return k.primaryID
+}
+
+// Seal encrypts plaintext under a fresh data key wrapped by the primary key.
+// It returns the envelope and the ID of the master key that wrapped it.
+func (k *Keyring) Seal(plaintext []byte) (string, string, error) {
+	dataKey := make([]byte, masterKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	wrappedKey, err := gcmSeal(k.keys[k.primaryID], dataKey)
+	if err != nil {
+		return "", "", err
+	}
+	ciphertext, err := gcmSeal(dataKey, plaintext)
+	if err != nil {
+		return "", "", err
+	}
+
+	envelope := base64.RawStdEncoding.EncodeToString(wrappedKey) + "." +
+		base64.RawStdEncoding.EncodeToString(ciphertext)
+	return envelope, k.primaryID, nil
+}
+
+// Open decrypts an envelope produced by Seal under the given master key ID
+func (k *Keyring) Open(envelope, keyID string) ([]byte, error) {
+	masterKey, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key: %s", keyID)
+	}
+
+	wrappedPart, cipherPart, found := strings.Cut(envelope, ".")
+	if !found {
+		return nil, fmt.Errorf("malformed envelope")
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(wrappedPart)
+	if err != nil {
+		return nil, fmt.Errorf("malformed envelope: %w", err)
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(cipherPart)
+	if err != nil {
+		return nil, fmt.Errorf("malformed envelope: %w", err)
+	}
+
+	dataKey, err := gcmOpen(masterKey, wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	plaintext, err := gcmOpen(dataKey, ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// gcmSeal encrypts with AES-GCM and prepends the random nonce
+func gcmSeal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// gcmOpen decrypts a nonce-prefixed AES-GCM ciphertext
+func gcmOpen(key, sealed []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// readMasterKey reads a raw or hex-encoded 32 byte key file
+func readMasterKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+
+	if len(data) == masterKeySize {
+		return data, nil
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != masterKeySize {
+		return nil, fmt.Errorf("master key file %s must hold %d raw or hex-encoded bytes", path, masterKeySize)
+	}
+	return key, nil
+}
+
+// masterKeyID derives a stable, non-secret identifier for a master key
+func masterKeyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte("bedrock-proxy-keyring:"), key...))
+	return hex.EncodeToString(sum[:8])
+}
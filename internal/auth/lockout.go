@@ -0,0 +1,394 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+// LockoutState is the failure history of one subject (an IP or a key)
+type LockoutState struct {
+	Subject     string    `json:"subject"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until,omitempty"`
+}
+
+// LockoutStore persists failure counters for the brute-force guard
+type LockoutStore interface {
+	Get(subject string) (*LockoutState, error)
+	Put(state *LockoutState) error
+	Delete(subject string) error
+	List() ([]LockoutState, error)
+}
+
+// LockoutPolicy controls when and for how long subjects are locked out
+type LockoutPolicy struct {
+	// Threshold is the number of failures allowed before the first lockout
+	Threshold int
+
+	// BaseLockout is the first lockout; each further failure doubles it
+	BaseLockout time.Duration
+
+	// MaxLockout caps the exponential backoff
+	MaxLockout time.Duration
+
+	// ResetAfter forgets failures after this long without a new one
+	ResetAfter time.Duration
+}
+
+// DefaultLockoutPolicy returns the lockout policy used when none is configured
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		Threshold:   5,
+		BaseLockout: 30 * time.Second,
+		MaxLockout:  time.Hour,
+		ResetAfter:  time.Hour,
+	}
+}
+
+// BruteForceGuard counts authentication failures per subject and imposes
+// exponentially growing lockouts once a subject crosses the threshold
+type BruteForceGuard struct {
+	store     LockoutStore
+	policy    LockoutPolicy
+	mu        sync.Mutex
+	now       func() time.Time
+	onLockout func(state LockoutState)
+}
+
+// NewBruteForceGuard creates a guard backed by store
+func NewBruteForceGuard(store LockoutStore, policy LockoutPolicy) *BruteForceGuard {
+	return &BruteForceGuard{store: store, policy: policy, now: time.Now}
+}
+
+// OnLockout sets a function called, outside the guard's lock, each time a
+// failure locks a subject out
+func (g *BruteForceGuard) OnLockout(fn func(state LockoutState)) {
+	g.onLockout = fn
+}
+
+// IPSubject returns the lockout subject for a client IP
+func IPSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// KeySubject returns the lockout subject for an API key presented from a
+// client IP. Key prefixes are public, so failures are only counted per IP:
+// a client that fails with someone else's prefix cannot lock the owner out.
+// Empty if the key has no prefix.
+func KeySubject(ip, apiKey string) string {
+	prefix := keyPrefixOf(apiKey)
+	if prefix == "" {
+		return ""
+	}
+	return "key:" + prefix + "@" + ip
+}
+
+// AccessKeySubject returns the lockout subject for a SigV4 access key ID
+// presented from a client IP, or empty if there is none
+func AccessKeySubject(ip, accessKeyID string) string {
+	if accessKeyID == "" {
+		return ""
+	}
+	return "akid:" + accessKeyID + "@" + ip
+}
+
+// Check returns how long the caller must wait if any subject is locked out.
+// A nil guard never locks anyone out.
+func (g *BruteForceGuard) Check(subjects ...string) (time.Duration, bool) {
+	if g == nil {
+		return 0, false
+	}
+	now := g.now()
+
+	var wait time.Duration
+	for _, subject := range subjects {
+		if subject == "" {
+			continue
+		}
+		state, err := g.store.Get(subject)
+		if err != nil || state == nil {
+			continue
+		}
+		if remaining := state.LockedUntil.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+
+	return wait, wait > 0
+}
+
+// RecordFailure counts a failure against every subject and returns the
+// longest lockout that is now in effect (zero if none)
+func (g *BruteForceGuard) RecordFailure(subjects ...string) time.Duration {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+
+	now := g.now()
+
+	var wait time.Duration
+	var lockouts []LockoutState
+	for _, subject := range subjects {
+		if subject == "" {
+			continue
+		}
+
+		state, err := g.store.Get(subject)
+		if err != nil {
+			continue
+		}
+		if state == nil || now.Sub(state.LastFailure) > g.policy.ResetAfter {
+			state = &LockoutState{Subject: subject}
+		}
+
+		state.Failures++
+		state.LastFailure = now
+		if lockout := g.lockoutFor(state.Failures); lockout > 0 {
+			state.LockedUntil = now.Add(lockout)
+			if lockout > wait {
+				wait = lockout
+			}
+			lockouts = append(lockouts, *state)
+		}
+
+		g.store.Put(state)
+	}
+	g.mu.Unlock()
+
+	if g.onLockout != nil {
+		for _, state := range lockouts {
+			g.onLockout(state)
+		}
+	}
+	return wait
+}
+
+// RecordSuccess clears the failure history of the given subjects
+func (g *BruteForceGuard) RecordSuccess(subjects ...string) {
+	if g == nil {
+		return
+	}
+	for _, subject := range subjects {
+		if subject != "" {
+			g.store.Delete(subject)
+		}
+	}
+}
+
+// Unlock removes a lockout and failure history for a subject
+func (g *BruteForceGuard) Unlock(subject string) error {
+	return g.store.Delete(subject)
+}
+
+// UnlockKey removes the lockouts and failure history of a key prefix from
+// every client IP and returns the subjects it cleared
+func (g *BruteForceGuard) UnlockKey(prefix string) ([]string, error) {
+	states, err := g.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var cleared []string
+	for _, state := range states {
+		if !strings.HasPrefix(state.Subject, "key:"+prefix+"@") {
+			continue
+		}
+		if err := g.store.Delete(state.Subject); err != nil {
+			return cleared, err
+		}
+		cleared = append(cleared, state.Subject)
+	}
+	return cleared, nil
+}
+
+// Lockouts returns subjects that are currently locked out
+func (g *BruteForceGuard) Lockouts() ([]LockoutState, error) {
+	states, err := g.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	now := g.now()
+	locked := make([]LockoutState, 0, len(states))
+	for _, state := range states {
+		if state.LockedUntil.After(now) {
+			locked = append(locked, state)
+		}
+	}
+
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].LockedUntil.After(locked[j].LockedUntil)
+	})
+
+	return locked, nil
+}
+
+// lockoutFor returns the lockout after the given number of failures
+func (g *BruteForceGuard) lockoutFor(failures int) time.Duration {
+	if failures < g.policy.Threshold {
+		return 0
+	}
+
+	lockout := g.policy.BaseLockout
+	for i := g.policy.Threshold; i < failures; i++ {
+		lockout *= 2
+		if lockout >= g.policy.MaxLockout {
+			return g.policy.MaxLockout
+		}
+	}
+
+	return lockout
+}
+
+// MemoryLockoutStore keeps lockout state in process memory
+type MemoryLockoutStore struct {
+	mu     sync.RWMutex
+	states map[string]LockoutState
+}
+
+// NewMemoryLockoutStore creates an in-memory lockout store
+func NewMemoryLockoutStore() *MemoryLockoutStore {
+	return &MemoryLockoutStore{states: make(map[string]LockoutState)}
+}
+
+// Get returns the state for subject, or nil if it has none
+func (s *MemoryLockoutStore) Get(subject string) (*LockoutState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.states[subject]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// Put stores state
+func (s *MemoryLockoutStore) Put(state *LockoutState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[state.Subject] = *state
+	return nil
+}
+
+// Delete removes the state for subject
+func (s *MemoryLockoutStore) Delete(subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, subject)
+	return nil
+}
+
+// List returns all stored states
+func (s *MemoryLockoutStore) List() ([]LockoutState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	states := make([]LockoutState, 0, len(s.states))
+	for _, state := range s.states {
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// DBLockoutStore keeps lockout state in the auth database (SQLite or
+// PostgreSQL) so it survives restarts and is shared by replicas
+type DBLockoutStore struct {
+	db storage.Store
+}
+
+// NewDBLockoutStore creates a lockout store in the given auth database;
+// its auth_lockouts table is created by the auth migrations
+func NewDBLockoutStore(db storage.Store) (*DBLockoutStore, error) {
+	return &DBLockoutStore{db: db}, nil
+}
+
+// Get returns the state for subject, or nil if it has none
+func (s *DBLockoutStore) Get(subject string) (*LockoutState, error) {
+	state := LockoutState{Subject: subject}
+	var lockedUntil sql.NullTime
+
+	err := s.db.QueryRow(`
+		SELECT failures, last_failure, locked_until
+		FROM auth_lockouts
+		WHERE subject = ?
+	`, subject).Scan(&state.Failures, &state.LastFailure, &lockedUntil)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lockout: %w", err)
+	}
+
+	if lockedUntil.Valid {
+		state.LockedUntil = lockedUntil.Time
+	}
+	return &state, nil
+}
+
+// Put stores state
+func (s *DBLockoutStore) Put(state *LockoutState) error {
+	var lockedUntil any
+	if !state.LockedUntil.IsZero() {
+		lockedUntil = state.LockedUntil
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO auth_lockouts (subject, failures, last_failure, locked_until)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(subject) DO UPDATE SET
+			failures = excluded.failures,
+			last_failure = excluded.last_failure,
+			locked_until = excluded.locked_until
+	`, state.Subject, state.Failures, state.LastFailure, lockedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to store lockout: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the state for subject
+func (s *DBLockoutStore) Delete(subject string) error {
+	if _, err := s.db.Exec("DELETE FROM auth_lockouts WHERE subject = ?", subject); err != nil {
+		return fmt.Errorf("failed to delete lockout: %w", err)
+	}
+	return nil
+}
+
+// List returns all stored states
+func (s *DBLockoutStore) List() ([]LockoutState, error) {
+	rows, err := s.db.Query("SELECT subject, failures, last_failure, locked_until FROM auth_lockouts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lockouts: %w", err)
+	}
+	defer rows.Close()
+
+	var states []LockoutState
+	for rows.Next() {
+		var state LockoutState
+		var lockedUntil sql.NullTime
+		if err := rows.Scan(&state.Subject, &state.Failures, &state.LastFailure, &lockedUntil); err != nil {
+			return nil, fmt.Errorf("failed to list lockouts: %w", err)
+		}
+		if lockedUntil.Valid {
+			state.LockedUntil = lockedUntil.Time
+		}
+		states = append(states, state)
+	}
+
+	return states, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBruteForceGuard(t *testing.T) {
+	db, err := NewAPIKeyDB(filepath.Join(t.TempDir(), "lockout.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	dbStore, err := NewDBLockoutStore(db.DB())
+	if err != nil {
+		t.Fatalf("Failed to create SQLite store: %v", err)
+	}
+
+	stores := map[string]LockoutStore{
+		"Memory": NewMemoryLockoutStore(),
+		"SQLite": dbStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			guard := NewBruteForceGuard(store, LockoutPolicy{
+				Threshold:   3,
+				BaseLockout: 10 * time.Second,
+				MaxLockout:  30 * time.Second,
+				ResetAfter:  time.Hour,
+			})
+			guard.now = func() time.Time { return now }
+			var lockedOut []LockoutState
+			guard.OnLockout(func(state LockoutState) { lockedOut = append(lockedOut, state) })
+
+			ip := IPSubject("203.0.113.7")
+			key := KeySubject("203.0.113.7", "bdrk_live_ab12cd34_"+"00")
+
+			for i := 0; i < 2; i++ {
+				if wait := guard.RecordFailure(ip, key); wait != 0 {
+					t.Fatalf("Failure %d should not lock, got wait %s", i+1, wait)
+				}
+			}
+			if _, locked := guard.Check(ip, key); locked {
+				t.Fatal("Should not be locked below threshold")
+			}
+			if len(lockedOut) != 0 {
+				t.Fatalf("Expected no lockout notifications below threshold, got %d", len(lockedOut))
+			}
+
+			// Threshold reached, then exponential backoff up to the cap
+			for i, expected := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+				if wait := guard.RecordFailure(ip, key); wait != expected {
+					t.Errorf("Failure %d: expected lockout %s, got %s", i+3, expected, wait)
+				}
+			}
+
+			if len(lockedOut) != 8 || lockedOut[0].Subject != ip || lockedOut[0].Failures != 3 {
+				t.Errorf("Expected a lockout notification per subject and failure, got %+v", lockedOut)
+			}
+
+			wait, locked := guard.Check(ip)
+			if !locked || wait != 30*time.Second {
+				t.Errorf("Expected 30s lockout, got locked=%v wait=%s", locked, wait)
+			}
+
+			lockouts, err := guard.Lockouts()
+			if err != nil {
+				t.Fatalf("Failed to list lockouts: %v", err)
+			}
+			if len(lockouts) != 2 {
+				t.Errorf("Expected 2 lockouts, got: %d", len(lockouts))
+			}
+
+			// Success clears the key but not the IP
+			guard.RecordSuccess(key)
+			if _, locked := guard.Check(key); locked {
+				t.Error("Key should be cleared after success")
+			}
+
+			if err := guard.Unlock(ip); err != nil {
+				t.Fatalf("Failed to unlock: %v", err)
+			}
+			if _, locked := guard.Check(ip); locked {
+				t.Error("IP should be unlocked")
+			}
+
+			// Lockout expires on its own
+			guard.RecordFailure(ip, ip, ip)
+			now = now.Add(11 * time.Second)
+			if _, locked := guard.Check(ip); locked {
+				t.Error("Lockout should have expired")
+			}
+		})
+	}
+
+	t.Run("NilGuard", func(t *testing.T) {
+		var guard *BruteForceGuard
+		if _, locked := guard.Check("ip:1.2.3.4"); locked {
+			t.Error("Nil guard should never lock")
+		}
+		if wait := guard.RecordFailure("ip:1.2.3.4"); wait != 0 {
+			t.Error("Nil guard should not record failures")
+		}
+	})
+}
+
+func TestKeySubjectPerIP(t *testing.T) {
+	guard := NewBruteForceGuard(NewMemoryLockoutStore(), LockoutPolicy{
+		Threshold:   3,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		ResetAfter:  time.Hour,
+	})
+
+	// An attacker who knows the public prefix fails from their own IP
+	apiKey := "bdrk_live_ab12cd34_" + "00"
+	attacker := KeySubject("198.51.100.9", apiKey)
+	for i := 0; i < 5; i++ {
+		guard.RecordFailure(IPSubject("198.51.100.9"), attacker)
+	}
+	if _, locked := guard.Check(attacker); !locked {
+		t.Fatal("Expected the key locked from the attacker's IP")
+	}
+
+	owner := KeySubject("203.0.113.7", apiKey)
+	if owner == attacker {
+		t.Fatalf("Expected distinct subjects per IP, got %q", owner)
+	}
+	if _, locked := guard.Check(IPSubject("203.0.113.7"), owner); locked {
+		t.Error("Failures from one IP should not lock the key from another IP")
+	}
+
+	if got := AccessKeySubject("203.0.113.7", "BDRKEXAMPLE"); got == AccessKeySubject("198.51.100.9", "BDRKEXAMPLE") {
+		t.Errorf("Expected access key subjects per IP, got %q", got)
+	}
+
+	// Unlocking a prefix clears it from every IP
+	guard.RecordFailure(owner)
+	cleared, err := guard.UnlockKey("bdrk_live_ab12cd34")
+	if err != nil {
+		t.Fatalf("Failed to unlock key: %v", err)
+	}
+	if len(cleared) != 2 {
+		t.Errorf("Expected 2 subjects cleared, got %v", cleared)
+	}
+	if _, locked := guard.Check(attacker); locked {
+		t.Error("Expected the key unlocked")
+	}
+	if _, locked := guard.Check(IPSubject("198.51.100.9")); !locked {
+		t.Error("Expected the attacker's IP still locked")
+	}
+}
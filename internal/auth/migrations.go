@@ -0,0 +1,39 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"embed"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+// migrationFiles holds the auth database schema as numbered up-migrations.
+// Never edit a released migration; add a new file instead.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the auth database migrations in version order
+func Migrations() ([]storage.Migration, error) {
+	return storage.LoadMigrations(migrationFiles, "migrations")
+}
+
+// NewMigrator returns a migrator for the auth database schema
+func NewMigrator(db storage.Store) (*storage.Migrator, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewMigrator(db, migrations), nil
+}
+
+// Migrate applies pending auth database migrations
+func Migrate(db storage.Store) ([]storage.Migration, error) {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	return migrator.Up()
+}
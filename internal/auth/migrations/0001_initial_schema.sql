@@ -0,0 +1,55 @@
+-- API keys, audit log, 2FA secrets and SigV4 credentials as first released
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	key_hash TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	email TEXT,
+	description TEXT,
+	is_active BOOLEAN DEFAULT TRUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	last_used_at TIMESTAMP,
+	expires_at TIMESTAMP,
+	permissions TEXT DEFAULT '[]',
+	metadata TEXT DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS idx_key_hash ON api_keys(key_hash);
+CREATE INDEX IF NOT EXISTS idx_email ON api_keys(email);
+CREATE INDEX IF NOT EXISTS idx_is_active ON api_keys(is_active);
+
+CREATE TABLE IF NOT EXISTS api_key_audit (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	api_key_id INTEGER,
+	action TEXT NOT NULL,
+	ip_address TEXT,
+	user_agent TEXT,
+	request_path TEXT,
+	status_code INTEGER,
+	timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	metadata TEXT DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_key_id ON api_key_audit(api_key_id);
+CREATE INDEX IF NOT EXISTS idx_audit_timestamp ON api_key_audit(timestamp);
+
+CREATE TABLE IF NOT EXISTS api_key_2fa (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	api_key_id INTEGER NOT NULL UNIQUE,
+	totp_secret TEXT NOT NULL,
+	backup_codes TEXT,
+	is_enabled BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS api_key_sigv4_credentials (
+	access_key_id TEXT PRIMARY KEY,
+	api_key_id INTEGER NOT NULL,
+	secret_access_key TEXT NOT NULL,
+	is_active BOOLEAN DEFAULT TRUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sigv4_api_key_id ON api_key_sigv4_credentials(api_key_id);
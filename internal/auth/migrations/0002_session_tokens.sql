@@ -0,0 +1,17 @@
+-- Short-lived session tokens issued by POST /auth/login
+
+CREATE TABLE IF NOT EXISTS session_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	token TEXT NOT NULL UNIQUE,
+	api_key_id INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP NOT NULL,
+	last_used_at TIMESTAMP,
+	ip_address TEXT,
+	user_agent TEXT,
+	is_active BOOLEAN DEFAULT TRUE,
+	FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_token ON session_tokens(token);
+CREATE INDEX IF NOT EXISTS idx_session_active ON session_tokens(is_active, expires_at);
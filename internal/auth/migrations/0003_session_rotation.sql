@@ -0,0 +1,8 @@
+-- Refresh token rotation lineage and reuse detection
+
+ALTER TABLE session_tokens ADD COLUMN parent_id INTEGER;
+ALTER TABLE session_tokens ADD COLUMN family_id INTEGER;
+ALTER TABLE session_tokens ADD COLUMN rotated_at TIMESTAMP;
+ALTER TABLE session_tokens ADD COLUMN revoked_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_session_family ON session_tokens(family_id);
@@ -0,0 +1,6 @@
+-- Public key prefixes for indexed lookup and the hash scheme per key
+
+ALTER TABLE api_keys ADD COLUMN key_prefix TEXT;
+ALTER TABLE api_keys ADD COLUMN hash_scheme TEXT DEFAULT 'bcrypt';
+
+CREATE INDEX IF NOT EXISTS idx_key_prefix ON api_keys(key_prefix);
@@ -0,0 +1,16 @@
+-- Encrypted TOTP secrets, 2FA lockout state and hashed single-use backup codes
+
+ALTER TABLE api_key_2fa ADD COLUMN secret_key_id TEXT;
+ALTER TABLE api_key_2fa ADD COLUMN failed_attempts INTEGER DEFAULT 0;
+ALTER TABLE api_key_2fa ADD COLUMN locked_until TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS api_key_2fa_backup_codes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	api_key_id INTEGER NOT NULL,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_backup_codes_key ON api_key_2fa_backup_codes(api_key_id);
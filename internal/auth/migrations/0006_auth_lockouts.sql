@@ -0,0 +1,10 @@
+-- Brute-force failure counters per IP and key prefix
+
+CREATE TABLE IF NOT EXISTS auth_lockouts (
+	subject TEXT PRIMARY KEY,
+	failures INTEGER NOT NULL DEFAULT 0,
+	last_failure TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_lockouts_locked_until ON auth_lockouts(locked_until);
@@ -0,0 +1,43 @@
+-- Teams with shared permissions, rate limits and budgets
+
+CREATE TABLE IF NOT EXISTS teams (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE,
+	description TEXT DEFAULT '',
+	permissions TEXT DEFAULT '[]',
+	allowed_models TEXT DEFAULT '[]',
+	rate_limit_rpm INTEGER DEFAULT 0,
+	monthly_budget_usd REAL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+	team_id INTEGER NOT NULL,
+	email TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'member',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (team_id, email),
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_team_members_email ON team_members(email);
+
+-- Monthly spend per key, rolled up per team for budgets
+CREATE TABLE IF NOT EXISTS team_spend (
+	period TEXT NOT NULL,
+	api_key_id INTEGER NOT NULL,
+	team_id INTEGER,
+	amount_usd REAL NOT NULL DEFAULT 0,
+	PRIMARY KEY (period, api_key_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_team_spend_team ON team_spend(team_id, period);
+
+ALTER TABLE api_keys ADD COLUMN team_id INTEGER;
+ALTER TABLE api_keys ADD COLUMN allowed_models TEXT DEFAULT '[]';
+ALTER TABLE api_keys ADD COLUMN rate_limit_rpm INTEGER DEFAULT 0;
+ALTER TABLE api_keys ADD COLUMN monthly_budget_usd REAL DEFAULT 0;
+ALTER TABLE api_key_audit ADD COLUMN team_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_team ON api_keys(team_id);
+CREATE INDEX IF NOT EXISTS idx_audit_team_id ON api_key_audit(team_id);
@@ -0,0 +1,15 @@
+-- Hash chain over audit records and signed checkpoints of its head
+
+ALTER TABLE api_key_audit ADD COLUMN prev_hash TEXT;
+ALTER TABLE api_key_audit ADD COLUMN entry_hash TEXT;
+
+CREATE TABLE IF NOT EXISTS api_key_audit_checkpoints (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	audit_id INTEGER NOT NULL,
+	entry_hash TEXT NOT NULL,
+	key_id TEXT NOT NULL,
+	signature TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_checkpoints_audit_id ON api_key_audit_checkpoints(audit_id);
@@ -0,0 +1,35 @@
+-- Per-request usage records and daily rollups for usage reporting
+
+CREATE TABLE IF NOT EXISTS usage_records (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	request_id TEXT NOT NULL,
+	api_key_id INTEGER,
+	team_id INTEGER,
+	provider TEXT NOT NULL,
+	model TEXT NOT NULL,
+	provider_model TEXT DEFAULT '',
+	input_tokens INTEGER NOT NULL DEFAULT 0,
+	output_tokens INTEGER NOT NULL DEFAULT 0,
+	cost_usd REAL NOT NULL DEFAULT 0,
+	tags TEXT DEFAULT '{}',
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_records_key ON usage_records(api_key_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_records_team ON usage_records(team_id, created_at);
+
+-- Daily totals per key, provider and model; key 0 is unauthenticated traffic
+CREATE TABLE IF NOT EXISTS usage_daily (
+	day TEXT NOT NULL,
+	api_key_id INTEGER NOT NULL DEFAULT 0,
+	team_id INTEGER NOT NULL DEFAULT 0,
+	provider TEXT NOT NULL,
+	model TEXT NOT NULL,
+	requests INTEGER NOT NULL DEFAULT 0,
+	input_tokens INTEGER NOT NULL DEFAULT 0,
+	output_tokens INTEGER NOT NULL DEFAULT 0,
+	cost_usd REAL NOT NULL DEFAULT 0,
+	PRIMARY KEY (day, api_key_id, team_id, provider, model)
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_daily_team ON usage_daily(team_id, day);
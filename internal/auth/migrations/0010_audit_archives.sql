@@ -0,0 +1,19 @@
+-- Last record of each audit archive, so verification can tell archived
+-- records from deleted ones
+
+CREATE TABLE IF NOT EXISTS api_key_audit_archives (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	last_audit_id INTEGER NOT NULL,
+	last_entry_hash TEXT NOT NULL DEFAULT '',
+	records INTEGER NOT NULL DEFAULT 0,
+	file TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_archives_last_audit_id ON api_key_audit_archives(last_audit_id);
+
+-- Records archived before this table existed are anchored at the oldest
+-- remaining chained record, as verification did until now
+INSERT INTO api_key_audit_archives (last_audit_id, last_entry_hash, created_at)
+SELECT id - 1, prev_hash, CURRENT_TIMESTAMP FROM api_key_audit
+WHERE id = (SELECT MIN(id) FROM api_key_audit WHERE entry_hash IS NOT NULL) AND prev_hash <> '';
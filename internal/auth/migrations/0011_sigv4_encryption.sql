@@ -0,0 +1,3 @@
+-- Master key ID of encrypted SigV4 secrets; NULL means plaintext
+
+ALTER TABLE api_key_sigv4_credentials ADD COLUMN secret_key_id TEXT;
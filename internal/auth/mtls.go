@@ -0,0 +1,209 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CertIdentity is the identity resolved from a verified client certificate
+type CertIdentity struct {
+	Name          string
+	Email         string
+	Subject       string
+	SPIFFEID      string
+	Fingerprint   string
+	Permissions   []string
+	RequireAPIKey bool
+}
+
+// CertMappingConfig is the YAML mapping of client certificates to identities
+type CertMappingConfig struct {
+	// RequireAPIKey makes an API key mandatory for every certificate identity
+	RequireAPIKey bool               `yaml:"require_api_key"`
+	Identities    []CertIdentityRule `yaml:"identities"`
+}
+
+// CertIdentityRule maps a certificate subject or SAN to an identity
+type CertIdentityRule struct {
+	Name          string    `yaml:"name"`
+	Email         string    `yaml:"email,omitempty"`
+	Match         CertMatch `yaml:"match"`
+	Permissions   []string  `yaml:"permissions"`
+	RequireAPIKey bool      `yaml:"require_api_key"`
+}
+
+// CertMatch selects certificates by subject or subject alternative name.
+// All non-empty fields must match.
+type CertMatch struct {
+	SubjectCN string `yaml:"subject_cn,omitempty"`
+	Subject   string `yaml:"subject,omitempty"`
+	SANURI    string `yaml:"san_uri,omitempty"` // e.g. spiffe://cluster.local/ns/payments/sa/api
+	SANDNS    string `yaml:"san_dns,omitempty"`
+	SANEmail  string `yaml:"san_email,omitempty"`
+}
+
+// CertMapper verifies client certificates and maps them to identities
+type CertMapper struct {
+	roots  *x509.CertPool
+	config CertMappingConfig
+}
+
+// NewCertMapper creates a certificate mapper from a CA bundle and mapping file
+func NewCertMapper(caFile, mappingFile string) (*CertMapper, error) {
+	roots, err := LoadClientCAPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(mappingFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate mapping: %w", err)
+	}
+
+	var config CertMappingConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse certificate mapping: %w", err)
+	}
+
+	for i, rule := range config.Identities {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("certificate mapping entry %d has no name", i)
+		}
+		if rule.Match == (CertMatch{}) {
+			return nil, fmt.Errorf("certificate mapping %q has no match criteria", rule.Name)
+		}
+		if (rule.RequireAPIKey || config.RequireAPIKey) && rule.Email == "" {
+			return nil, fmt.Errorf("certificate mapping %q requires an API key but has no email to match key owners", rule.Name)
+		}
+	}
+
+	return &CertMapper{roots: roots, config: config}, nil
+}
+
+// LoadClientCAPool reads a PEM bundle of CAs trusted to issue client certificates
+func LoadClientCAPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA bundle %s", caFile)
+	}
+
+	return pool, nil
+}
+
+// ClientCAs returns the trusted client CA pool (for tls.Config.ClientCAs)
+func (m *CertMapper) ClientCAs() *x509.CertPool {
+	return m.roots
+}
+
+// Identify verifies the certificate chain and resolves the mapped identity
+func (m *CertMapper) Identify(chain []*x509.Certificate) (*CertIdentity, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no client certificate presented")
+	}
+
+	leaf := chain[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         m.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return nil, fmt.Errorf("client certificate verification failed: %w", err)
+	}
+
+	for _, rule := range m.config.Identities {
+		if !rule.Match.matches(leaf) {
+			continue
+		}
+
+		return &CertIdentity{
+			Name:          rule.Name,
+			Email:         rule.Email,
+			Subject:       leaf.Subject.String(),
+			SPIFFEID:      spiffeID(leaf),
+			Fingerprint:   CertFingerprint(leaf),
+			Permissions:   rule.Permissions,
+			RequireAPIKey: rule.RequireAPIKey || m.config.RequireAPIKey,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("client certificate %q is not mapped to an identity", leaf.Subject.String())
+}
+
+// OwnsKey reports whether an API key was issued to this identity as its
+// second factor, i.e. the key's email is the identity's
+func (id *CertIdentity) OwnsKey(key *APIKey) bool {
+	return id.Email != "" && strings.EqualFold(key.Email, id.Email)
+}
+
+// CertFingerprint returns the SHA-256 fingerprint of a certificate as hex
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// matches checks all configured criteria against the certificate
+func (cm CertMatch) matches(cert *x509.Certificate) bool {
+	if cm.SubjectCN != "" && cert.Subject.CommonName != cm.SubjectCN {
+		return false
+	}
+	if cm.Subject != "" && cert.Subject.String() != cm.Subject {
+		return false
+	}
+	if cm.SANURI != "" {
+		found := false
+		for _, uri := range cert.URIs {
+			if uri.String() == cm.SANURI {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if cm.SANDNS != "" && !containsFold(cert.DNSNames, cm.SANDNS) {
+		return false
+	}
+	if cm.SANEmail != "" && !containsFold(cert.EmailAddresses, cm.SANEmail) {
+		return false
+	}
+	return true
+}
+
+// spiffeID returns the first spiffe:// URI SAN, if any
+func spiffeID(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	return ""
+}
+
+func containsFold(values []string, target string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Client CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func newTestClientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, cn, spiffe string) *x509.Certificate {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if spiffe != "" {
+		u, _ := url.Parse(spiffe)
+		tmpl.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create client certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+func TestCertMapper(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newTestCA(t)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+
+	mappingFile := filepath.Join(dir, "mapping.yaml")
+	os.WriteFile(mappingFile, []byte(`
+identities:
+  - name: payments
+    email: payments@example.com
+    match:
+      san_uri: spiffe://cluster.local/ns/payments/sa/api
+    permissions: ["chat:completions"]
+    require_api_key: true
+  - name: reports
+    match:
+      subject_cn: reports.internal
+`), 0600)
+
+	mapper, err := NewCertMapper(caFile, mappingFile)
+	if err != nil {
+		t.Fatalf("Failed to create cert mapper: %v", err)
+	}
+
+	t.Run("SPIFFEIdentity", func(t *testing.T) {
+		cert := newTestClientCert(t, ca, caKey, "payments", "spiffe://cluster.local/ns/payments/sa/api")
+		identity, err := mapper.Identify([]*x509.Certificate{cert})
+		if err != nil {
+			t.Fatalf("Failed to identify certificate: %v", err)
+		}
+		if identity.Name != "payments" {
+			t.Errorf("Expected identity 'payments', got: %s", identity.Name)
+		}
+		if !identity.RequireAPIKey {
+			t.Error("Expected API key to be required")
+		}
+		if identity.SPIFFEID != "spiffe://cluster.local/ns/payments/sa/api" {
+			t.Errorf("Unexpected SPIFFE ID: %s", identity.SPIFFEID)
+		}
+		if identity.Fingerprint != CertFingerprint(cert) || len(identity.Fingerprint) != 64 {
+			t.Errorf("Unexpected fingerprint: %s", identity.Fingerprint)
+		}
+	})
+
+	t.Run("SubjectIdentity", func(t *testing.T) {
+		cert := newTestClientCert(t, ca, caKey, "reports.internal", "")
+		identity, err := mapper.Identify([]*x509.Certificate{cert})
+		if err != nil {
+			t.Fatalf("Failed to identify certificate: %v", err)
+		}
+		if identity.Name != "reports" || identity.RequireAPIKey {
+			t.Errorf("Unexpected identity: %+v", identity)
+		}
+	})
+
+	t.Run("UnmappedCertificate", func(t *testing.T) {
+		cert := newTestClientCert(t, ca, caKey, "unknown.internal", "")
+		if _, err := mapper.Identify([]*x509.Certificate{cert}); err == nil {
+			t.Error("Expected error for unmapped certificate, got nil")
+		}
+	})
+
+	t.Run("UntrustedIssuer", func(t *testing.T) {
+		otherCA, otherKey := newTestCA(t)
+		cert := newTestClientCert(t, otherCA, otherKey, "reports.internal", "")
+		if _, err := mapper.Identify([]*x509.Certificate{cert}); err == nil {
+			t.Error("Expected error for certificate from untrusted CA, got nil")
+		}
+	})
+}
+
+func TestCertIdentityOwnsKey(t *testing.T) {
+	identity := &CertIdentity{Name: "payments", Email: "payments@example.com"}
+
+	if !identity.OwnsKey(&APIKey{Email: "Payments@example.com"}) {
+		t.Error("Expected a key with the identity's email to be owned")
+	}
+	if identity.OwnsKey(&APIKey{Email: "mallory@example.com"}) {
+		t.Error("Expected a key of another owner to be rejected")
+	}
+	if (&CertIdentity{Name: "reports"}).OwnsKey(&APIKey{}) {
+		t.Error("Expected an identity without an email to own no keys")
+	}
+}
+
+func TestCertMapperRequiresEmailForAPIKey(t *testing.T) {
+	dir := t.TempDir()
+	ca, _ := newTestCA(t)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+
+	mappingFile := filepath.Join(dir, "mapping.yaml")
+	os.WriteFile(mappingFile, []byte(`
+identities:
+  - name: payments
+    match:
+      subject_cn: payments.internal
+    require_api_key: true
+`), 0600)
+
+	if _, err := NewCertMapper(caFile, mappingFile); err == nil {
+		t.Error("Expected an error for an API key identity without an email")
+	}
+}
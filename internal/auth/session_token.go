@@ -7,8 +7,25 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"net"
 	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+var (
+	// ErrSessionReused is returned when a rotated session token is presented
+	// again. The whole session family is revoked as a theft precaution.
+	ErrSessionReused = errors.New("session token reuse detected")
+
+	// ErrSessionBindingMismatch is returned when a bound session is used from
+	// a different client IP, subnet or user agent
+	ErrSessionBindingMismatch = errors.New("session token used from a different client")
+
+	// ErrSessionIdle is returned when a session exceeded its idle timeout
+	ErrSessionIdle = errors.New("session token idle timeout exceeded")
 )
 
 // SessionToken represents a temporary session token
@@ -22,37 +39,51 @@ type SessionToken struct {
 	IPAddress  string
 	UserAgent  string
 	IsActive   bool
+	ParentID   *int64
+	FamilyID   int64
+	RotatedAt  *time.Time
+}
+
+// SessionPolicy controls session binding, idle timeouts and concurrency
+type SessionPolicy struct {
+	// BindIP requires every request to come from the login IP address
+	BindIP bool
+
+	// BindSubnetV4 / BindSubnetV6 require requests to come from the same
+	// subnet (prefix length in bits) as the login; 0 disables the check
+	BindSubnetV4 int
+	BindSubnetV6 int
+
+	// BindUserAgent requires the login user agent on every request
+	BindUserAgent bool
+
+	// IdleTimeout expires sessions not used for this long (0 disables)
+	IdleTimeout time.Duration
+
+	// MaxSessionsPerKey caps concurrent active sessions per API key; the
+	// least recently used sessions are revoked beyond the cap (0 disables)
+	MaxSessionsPerKey int
 }
 
 // SessionManager manages session tokens
 type SessionManager struct {
-	db *sql.DB
+	db     storage.Store
+	policy SessionPolicy
 }
 
 // NewSessionManager creates a new session manager
-func NewSessionManager(db *sql.DB) *SessionManager {
-	// Create session tokens table
-	schema := `
-	CREATE TABLE IF NOT EXISTS session_tokens (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		token TEXT NOT NULL UNIQUE,
-		api_key_id INTEGER NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		expires_at TIMESTAMP NOT NULL,
-		last_used_at TIMESTAMP,
-		ip_address TEXT,
-		user_agent TEXT,
-		is_active BOOLEAN DEFAULT 1,
-		FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_session_token ON session_tokens(token);
-	CREATE INDEX IF NOT EXISTS idx_session_active ON session_tokens(is_active, expires_at);
-	`
-
-	db.Exec(schema)
-
-	return &SessionManager{db: db}
+func NewSessionManager(db storage.Store) *SessionManager {
+	return NewSessionManagerWithPolicy(db, SessionPolicy{})
+}
+
+// NewSessionManagerWithPolicy creates a session manager enforcing a policy
+func NewSessionManagerWithPolicy(db storage.Store, policy SessionPolicy) *SessionManager {
+	return &SessionManager{db: db, policy: policy}
+}
+
+// Policy returns the session policy in effect
+func (m *SessionManager) Policy() SessionPolicy {
+	return m.policy
 }
 
 // GenerateSessionToken creates a new session token after successful auth
@@ -61,10 +92,76 @@ func (m *SessionManager) GenerateSessionToken(
 	duration time.Duration,
 	ipAddress, userAgent string,
 ) (string, error) {
+	token, _, err := m.createSession(m.db, apiKeyID, duration, ipAddress, userAgent, nil, 0)
+	if err != nil {
+		return "", err
+	}
+
+	if err := m.enforceSessionCap(apiKeyID); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RotateSessionToken replaces a valid session token with a new one. The old
+// token is marked as rotated; presenting it again revokes the whole family.
+// The returned session is the one that was rotated out.
+func (m *SessionManager) RotateSessionToken(
+	oldToken string,
+	duration time.Duration,
+	ipAddress, userAgent string,
+) (string, *SessionToken, error) {
+	session, _, err := m.ValidateSessionToken(oldToken, ipAddress, userAgent)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin rotation: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Only one refresh of a given token may win
+	result, err := tx.Exec(`
+		UPDATE session_tokens
+		SET is_active = FALSE, rotated_at = ?, revoked_reason = 'rotated'
+		WHERE id = ? AND rotated_at IS NULL AND is_active = TRUE
+	`, time.Now(), session.ID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to rotate session: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return "", nil, ErrSessionReused
+	}
+
+	parentID := session.ID
+	newToken, _, err := m.createSession(tx, session.APIKeyID, duration, ipAddress, userAgent, &parentID, session.FamilyID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", nil, fmt.Errorf("failed to commit rotation: %w", err)
+	}
+
+	return newToken, session, nil
+}
+
+// createSession inserts a session row; familyID 0 starts a new family
+func (m *SessionManager) createSession(
+	exec storage.Querier,
+	apiKeyID int64,
+	duration time.Duration,
+	ipAddress, userAgent string,
+	parentID *int64,
+	familyID int64,
+) (string, int64, error) {
 	// Generate secure random token (32 bytes = 44 chars base64)
 	tokenBytes := make([]byte, 32)
 	if _, err := rand.Read(tokenBytes); err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+		return "", 0, fmt.Errorf("failed to generate token: %w", err)
 	}
 
 	// Create alphanumeric token with prefix
@@ -74,36 +171,47 @@ func (m *SessionManager) GenerateSessionToken(
 	expiresAt := time.Now().Add(duration)
 
 	// Insert into database
-	_, err := m.db.Exec(`
-		INSERT INTO session_tokens (token, api_key_id, expires_at, ip_address, user_agent)
-		VALUES (?, ?, ?, ?, ?)
-	`, token, apiKeyID, expiresAt, ipAddress, userAgent)
-
+	var id int64
+	err := exec.QueryRow(`
+		INSERT INTO session_tokens (token, api_key_id, expires_at, ip_address, user_agent, parent_id, family_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, token, apiKeyID, expiresAt, ipAddress, userAgent, parentID, nullableID(familyID)).Scan(&id)
 	if err != nil {
-		return "", fmt.Errorf("failed to store session token: %w", err)
+		return "", 0, fmt.Errorf("failed to store session token: %w", err)
 	}
 
-	return token, nil
+	// A fresh login is the root of its own family
+	if familyID == 0 {
+		if _, err := exec.Exec("UPDATE session_tokens SET family_id = ? WHERE id = ?", id, id); err != nil {
+			return "", 0, fmt.Errorf("failed to store session token: %w", err)
+		}
+	}
+
+	return token, id, nil
 }
 
-// ValidateSessionToken checks if a session token is valid
-func (m *SessionManager) ValidateSessionToken(token string) (*SessionToken, int64, error) {
+// ValidateSessionToken checks if a session token is valid for the calling client
+func (m *SessionManager) ValidateSessionToken(token, ipAddress, userAgent string) (*SessionToken, int64, error) {
 	var session SessionToken
 	var apiKeyID int64
-	var lastUsed sql.NullTime
+	var lastUsed, rotatedAt sql.NullTime
+	var parentID sql.NullInt64
 
 	err := m.db.QueryRow(`
 		SELECT
 			st.id, st.token, st.api_key_id, st.created_at, st.expires_at,
 			st.last_used_at, st.ip_address, st.user_agent, st.is_active,
+			st.parent_id, COALESCE(st.family_id, st.id), st.rotated_at,
 			ak.id as api_key_id
 		FROM session_tokens st
 		JOIN api_keys ak ON st.api_key_id = ak.id
-		WHERE st.token = ? AND st.is_active = 1
+		WHERE st.token = ?
 	`, token).Scan(
 		&session.ID, &session.Token, &session.APIKeyID,
 		&session.CreatedAt, &session.ExpiresAt, &lastUsed,
 		&session.IPAddress, &session.UserAgent, &session.IsActive,
+		&parentID, &session.FamilyID, &rotatedAt,
 		&apiKeyID,
 	)
 
@@ -117,27 +225,173 @@ func (m *SessionManager) ValidateSessionToken(token string) (*SessionToken, int6
 	if lastUsed.Valid {
 		session.LastUsedAt = &lastUsed.Time
 	}
+	if parentID.Valid {
+		session.ParentID = &parentID.Int64
+	}
+
+	// A rotated token coming back means someone else holds a copy
+	if rotatedAt.Valid {
+		session.RotatedAt = &rotatedAt.Time
+		m.RevokeSessionFamily(session.FamilyID, "reuse_detected")
+		return &session, apiKeyID, ErrSessionReused
+	}
+
+	if !session.IsActive {
+		return nil, 0, fmt.Errorf("invalid session token")
+	}
+
+	now := time.Now()
 
 	// Check expiration
-	if time.Now().After(session.ExpiresAt) {
+	if now.After(session.ExpiresAt) {
 		return nil, 0, fmt.Errorf("session token expired")
 	}
 
+	// Check idle timeout
+	if m.policy.IdleTimeout > 0 {
+		lastActivity := session.CreatedAt
+		if session.LastUsedAt != nil {
+			lastActivity = *session.LastUsedAt
+		}
+		if now.Sub(lastActivity) > m.policy.IdleTimeout {
+			m.revokeSession(session.ID, "idle_timeout")
+			return nil, 0, ErrSessionIdle
+		}
+	}
+
+	// Check client binding
+	if !m.policy.bindingMatches(&session, ipAddress, userAgent) {
+		return nil, 0, ErrSessionBindingMismatch
+	}
+
 	// Update last used timestamp
-	m.db.Exec("UPDATE session_tokens SET last_used_at = ? WHERE id = ?", time.Now(), session.ID)
+	m.db.Exec("UPDATE session_tokens SET last_used_at = ? WHERE id = ?", now, session.ID)
 
 	return &session, apiKeyID, nil
 }
 
+// bindingMatches checks the client against the session's login IP and user agent
+func (p SessionPolicy) bindingMatches(session *SessionToken, ipAddress, userAgent string) bool {
+	if p.BindUserAgent && session.UserAgent != userAgent {
+		return false
+	}
+
+	if p.BindIP && session.IPAddress != ipAddress {
+		return false
+	}
+
+	if p.BindSubnetV4 > 0 || p.BindSubnetV6 > 0 {
+		return sameSubnet(session.IPAddress, ipAddress, p.BindSubnetV4, p.BindSubnetV6)
+	}
+
+	return true
+}
+
+// sameSubnet reports whether two addresses share a prefix of the given length
+func sameSubnet(a, b string, bitsV4, bitsV6 int) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return false
+	}
+
+	if v4A, v4B := ipA.To4(), ipB.To4(); v4A != nil && v4B != nil {
+		if bitsV4 <= 0 {
+			return true
+		}
+		mask := net.CIDRMask(bitsV4, 32)
+		return v4A.Mask(mask).Equal(v4B.Mask(mask))
+	}
+
+	if ipA.To4() != nil || ipB.To4() != nil {
+		return false // address family changed
+	}
+
+	if bitsV6 <= 0 {
+		return true
+	}
+	mask := net.CIDRMask(bitsV6, 128)
+	return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
+
+// enforceSessionCap revokes the least recently used sessions beyond the cap
+func (m *SessionManager) enforceSessionCap(apiKeyID int64) error {
+	if m.policy.MaxSessionsPerKey <= 0 {
+		return nil
+	}
+
+	rows, err := m.db.Query(`
+		SELECT id FROM session_tokens
+		WHERE api_key_id = ? AND is_active = TRUE AND expires_at > ?
+		ORDER BY COALESCE(last_used_at, created_at) DESC, id DESC
+	`, apiKeyID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to enforce session limit: %w", err)
+	}
+
+	var excess []int64
+	for kept := 0; rows.Next(); kept++ {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to enforce session limit: %w", err)
+		}
+		if kept >= m.policy.MaxSessionsPerKey {
+			excess = append(excess, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range excess {
+		if _, err := m.db.Exec(
+			"UPDATE session_tokens SET is_active = FALSE, revoked_reason = 'session_limit' WHERE id = ?", id,
+		); err != nil {
+			return fmt.Errorf("failed to enforce session limit: %w", err)
+		}
+	}
+
+	return nil
+}
+
 // RevokeSessionToken invalidates a session token
 func (m *SessionManager) RevokeSessionToken(token string) error {
-	_, err := m.db.Exec("UPDATE session_tokens SET is_active = 0 WHERE token = ?", token)
+	_, err := m.db.Exec("UPDATE session_tokens SET is_active = FALSE, revoked_reason = 'revoked' WHERE token = ?", token)
+	return err
+}
+
+// RevokeSessionFamily invalidates every session derived from the same login
+func (m *SessionManager) RevokeSessionFamily(familyID int64, reason string) error {
+	_, err := m.db.Exec(`
+		UPDATE session_tokens
+		SET is_active = FALSE, revoked_reason = COALESCE(revoked_reason, ?)
+		WHERE COALESCE(family_id, id) = ? AND is_active = TRUE
+	`, reason, familyID)
 	return err
 }
 
+// RevokeUserSession revokes a session by ID if it belongs to the API key
+func (m *SessionManager) RevokeUserSession(apiKeyID, sessionID int64) error {
+	result, err := m.db.Exec(`
+		UPDATE session_tokens
+		SET is_active = FALSE, revoked_reason = 'revoked'
+		WHERE id = ? AND api_key_id = ?
+	`, sessionID, apiKeyID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("session not found: %d", sessionID)
+	}
+	return nil
+}
+
+// revokeSession deactivates a single session by ID
+func (m *SessionManager) revokeSession(sessionID int64, reason string) {
+	m.db.Exec("UPDATE session_tokens SET is_active = FALSE, revoked_reason = ? WHERE id = ?", reason, sessionID)
+}
+
 // RevokeAllUserSessions revokes all sessions for a specific API key
 func (m *SessionManager) RevokeAllUserSessions(apiKeyID int64) error {
-	_, err := m.db.Exec("UPDATE session_tokens SET is_active = 0 WHERE api_key_id = ?", apiKeyID)
+	_, err := m.db.Exec("UPDATE session_tokens SET is_active = FALSE, revoked_reason = 'revoked' WHERE api_key_id = ?", apiKeyID)
 	return err
 }
 
@@ -150,9 +404,10 @@ func (m *SessionManager) CleanupExpiredSessions() error {
 // ListUserSessions returns active sessions for an API key
 func (m *SessionManager) ListUserSessions(apiKeyID int64) ([]SessionToken, error) {
 	rows, err := m.db.Query(`
-		SELECT id, token, api_key_id, created_at, expires_at, last_used_at, ip_address, user_agent, is_active
+		SELECT id, token, api_key_id, created_at, expires_at, last_used_at, ip_address, user_agent, is_active,
+			parent_id, COALESCE(family_id, id)
 		FROM session_tokens
-		WHERE api_key_id = ? AND is_active = 1 AND expires_at > ?
+		WHERE api_key_id = ? AND is_active = TRUE AND expires_at > ?
 		ORDER BY created_at DESC
 	`, apiKeyID, time.Now())
 
@@ -165,10 +420,12 @@ func (m *SessionManager) ListUserSessions(apiKeyID int64) ([]SessionToken, error
 	for rows.Next() {
 		var s SessionToken
 		var lastUsed sql.NullTime
+		var parentID sql.NullInt64
 
 		err := rows.Scan(
 			&s.ID, &s.Token, &s.APIKeyID, &s.CreatedAt, &s.ExpiresAt,
 			&lastUsed, &s.IPAddress, &s.UserAgent, &s.IsActive,
+			&parentID, &s.FamilyID,
 		)
 		if err != nil {
 			continue
@@ -177,9 +434,19 @@ func (m *SessionManager) ListUserSessions(apiKeyID int64) ([]SessionToken, error
 		if lastUsed.Valid {
 			s.LastUsedAt = &lastUsed.Time
 		}
+		if parentID.Valid {
+			s.ParentID = &parentID.Int64
+		}
 
 		sessions = append(sessions, s)
 	}
 
 	return sessions, nil
 }
+
+func nullableID(id int64) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
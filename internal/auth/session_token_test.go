@@ -0,0 +1,139 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+func newSessionTestDB(t *testing.T, policy SessionPolicy) (*APIKeyDB, *SessionManager, int64) {
+	t.Helper()
+
+	store, err := storage.NewMemory()
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	db, err := NewAPIKeyDBWithStore(store)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.GenerateAPIKey("Session User", "session@example.com", "Session test", nil); err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+	key, err := db.GetAPIKeyByEmail("session@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get API key: %v", err)
+	}
+
+	return db, NewSessionManagerWithPolicy(db.DB(), policy), key.ID
+}
+
+func TestSessionRotation(t *testing.T) {
+	_, sm, keyID := newSessionTestDB(t, SessionPolicy{})
+
+	token, err := sm.GenerateSessionToken(keyID, time.Hour, "10.0.0.1", "curl/8.0")
+	if err != nil {
+		t.Fatalf("Failed to generate session: %v", err)
+	}
+
+	rotated, old, err := sm.RotateSessionToken(token, time.Hour, "10.0.0.1", "curl/8.0")
+	if err != nil {
+		t.Fatalf("Failed to rotate session: %v", err)
+	}
+	if rotated == token {
+		t.Error("Rotated token should differ from the original")
+	}
+
+	session, _, err := sm.ValidateSessionToken(rotated, "10.0.0.1", "curl/8.0")
+	if err != nil {
+		t.Fatalf("Rotated token should be valid, got: %v", err)
+	}
+	if session.ParentID == nil || *session.ParentID != old.ID {
+		t.Error("Rotated session should record its parent")
+	}
+	if session.FamilyID != old.FamilyID {
+		t.Errorf("Expected family %d, got: %d", old.FamilyID, session.FamilyID)
+	}
+
+	// Replaying the old token revokes the whole family
+	if _, _, err := sm.ValidateSessionToken(token, "10.0.0.1", "curl/8.0"); !errors.Is(err, ErrSessionReused) {
+		t.Fatalf("Expected ErrSessionReused, got: %v", err)
+	}
+	if _, _, err := sm.ValidateSessionToken(rotated, "10.0.0.1", "curl/8.0"); err == nil {
+		t.Error("Family should be revoked after reuse")
+	}
+
+	// A rotated token cannot be rotated twice
+	if _, _, err := sm.RotateSessionToken(token, time.Hour, "10.0.0.1", "curl/8.0"); err == nil {
+		t.Error("Expected error rotating an already rotated token")
+	}
+}
+
+func TestSessionBinding(t *testing.T) {
+	_, sm, keyID := newSessionTestDB(t, SessionPolicy{BindSubnetV4: 24, BindUserAgent: true})
+
+	token, err := sm.GenerateSessionToken(keyID, time.Hour, "192.168.1.10", "curl/8.0")
+	if err != nil {
+		t.Fatalf("Failed to generate session: %v", err)
+	}
+
+	if _, _, err := sm.ValidateSessionToken(token, "192.168.1.99", "curl/8.0"); err != nil {
+		t.Errorf("Same subnet should be accepted, got: %v", err)
+	}
+	if _, _, err := sm.ValidateSessionToken(token, "192.168.2.10", "curl/8.0"); !errors.Is(err, ErrSessionBindingMismatch) {
+		t.Errorf("Expected ErrSessionBindingMismatch for other subnet, got: %v", err)
+	}
+	if _, _, err := sm.ValidateSessionToken(token, "192.168.1.10", "python-requests"); !errors.Is(err, ErrSessionBindingMismatch) {
+		t.Errorf("Expected ErrSessionBindingMismatch for other user agent, got: %v", err)
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	_, sm, keyID := newSessionTestDB(t, SessionPolicy{IdleTimeout: time.Minute})
+
+	token, err := sm.GenerateSessionToken(keyID, time.Hour, "10.0.0.1", "curl/8.0")
+	if err != nil {
+		t.Fatalf("Failed to generate session: %v", err)
+	}
+
+	if _, err := sm.db.Exec("UPDATE session_tokens SET last_used_at = ? WHERE token = ?",
+		time.Now().Add(-2*time.Minute), token); err != nil {
+		t.Fatalf("Failed to age session: %v", err)
+	}
+
+	if _, _, err := sm.ValidateSessionToken(token, "10.0.0.1", "curl/8.0"); !errors.Is(err, ErrSessionIdle) {
+		t.Errorf("Expected ErrSessionIdle, got: %v", err)
+	}
+}
+
+func TestSessionCap(t *testing.T) {
+	_, sm, keyID := newSessionTestDB(t, SessionPolicy{MaxSessionsPerKey: 2})
+
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		token, err := sm.GenerateSessionToken(keyID, time.Hour, "10.0.0.1", "curl/8.0")
+		if err != nil {
+			t.Fatalf("Failed to generate session: %v", err)
+		}
+		tokens = append(tokens, token)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sessions, err := sm.ListUserSessions(keyID)
+	if err != nil {
+		t.Fatalf("Failed to list sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Errorf("Expected 2 active sessions, got: %d", len(sessions))
+	}
+	if _, _, err := sm.ValidateSessionToken(tokens[0], "10.0.0.1", "curl/8.0"); err == nil {
+		t.Error("Oldest session should have been evicted")
+	}
+}
@@ -0,0 +1,510 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4DateFormat      = "20060102"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// SigV4Credential is a proxy-issued AWS-style access key bound to an API key
+type SigV4Credential struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	APIKeyID        int64
+	IsActive        bool
+	CreatedAt       time.Time
+}
+
+// SetKeyring sets the master keys that encrypt SigV4 secrets at rest. SigV4
+// signatures are HMACs keyed by the secret itself, so the proxy must be able
+// to recover it: secrets are encrypted, never hashed.
+func (db *APIKeyDB) SetKeyring(keyring *Keyring) {
+	db.keyring = keyring
+}
+
+// GenerateSigV4Credentials issues a new access key ID and secret for an API key
+func (db *APIKeyDB) GenerateSigV4Credentials(apiKeyID int64) (string, string, error) {
+	idBytes := make([]byte, 10)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate access key ID: %w", err)
+	}
+	// 20 characters like AWS access key IDs: BDRK + 16 base32 chars
+	accessKeyID := "BDRK" + base32.StdEncoding.EncodeToString(idBytes)[:16]
+
+	secretBytes := make([]byte, 30)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate secret access key: %w", err)
+	}
+	secret := base64.StdEncoding.EncodeToString(secretBytes)
+
+	storedSecret, keyID, err := db.sealSigV4Secret(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = db.db.Exec(`
+		INSERT INTO api_key_sigv4_credentials (access_key_id, api_key_id, secret_access_key, secret_key_id)
+		VALUES (?, ?, ?, ?)
+	`, accessKeyID, apiKeyID, storedSecret, keyID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to store SigV4 credentials: %w", err)
+	}
+
+	return accessKeyID, secret, nil
+}
+
+// GetSigV4Credential looks up an active access key ID
+func (db *APIKeyDB) GetSigV4Credential(accessKeyID string) (*SigV4Credential, error) {
+	var cred SigV4Credential
+	var keyID sql.NullString
+	err := db.db.QueryRow(`
+		SELECT access_key_id, secret_access_key, secret_key_id, api_key_id, is_active, created_at
+		FROM api_key_sigv4_credentials
+		WHERE access_key_id = ? AND is_active = TRUE
+	`, accessKeyID).Scan(&cred.AccessKeyID, &cred.SecretAccessKey, &keyID, &cred.APIKeyID, &cred.IsActive, &cred.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown access key ID: %s", accessKeyID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SigV4 credential: %w", err)
+	}
+
+	cred.SecretAccessKey, err = db.openSigV4Secret(cred.SecretAccessKey, keyID.String)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cred, nil
+}
+
+// ListSigV4Credentials returns the access keys of an API key, newest first,
+// without their secrets
+func (db *APIKeyDB) ListSigV4Credentials(apiKeyID int64) ([]SigV4Credential, error) {
+	rows, err := db.db.Query(`
+		SELECT access_key_id, api_key_id, is_active, created_at
+		FROM api_key_sigv4_credentials
+		WHERE api_key_id = ?
+		ORDER BY created_at DESC, access_key_id
+	`, apiKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SigV4 credentials: %w", err)
+	}
+	defer rows.Close()
+
+	var creds []SigV4Credential
+	for rows.Next() {
+		var cred SigV4Credential
+		if err := rows.Scan(&cred.AccessKeyID, &cred.APIKeyID, &cred.IsActive, &cred.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to list SigV4 credentials: %w", err)
+		}
+		creds = append(creds, cred)
+	}
+
+	return creds, rows.Err()
+}
+
+// RevokeSigV4Credential deactivates an access key ID of an API key
+func (db *APIKeyDB) RevokeSigV4Credential(apiKeyID int64, accessKeyID string) error {
+	result, err := db.db.Exec(
+		"UPDATE api_key_sigv4_credentials SET is_active = FALSE WHERE access_key_id = ? AND api_key_id = ?",
+		accessKeyID, apiKeyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke SigV4 credential: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke SigV4 credential: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("unknown access key ID: %s", accessKeyID)
+	}
+	return nil
+}
+
+// ReencryptSigV4Secrets re-seals every SigV4 secret not under the primary
+// master key (including plaintext rows) and returns how many were rewritten
+func (db *APIKeyDB) ReencryptSigV4Secrets() (int, error) {
+	if db.keyring == nil {
+		return 0, fmt.Errorf("no master key configured")
+	}
+
+	rows, err := db.db.Query(`
+		SELECT access_key_id, secret_access_key, secret_key_id
+		FROM api_key_sigv4_credentials
+		WHERE secret_key_id IS NULL OR secret_key_id != ?
+	`, db.keyring.PrimaryID())
+	if err != nil {
+		return 0, fmt.Errorf("failed to query SigV4 secrets: %w", err)
+	}
+
+	type pending struct {
+		accessKeyID string
+		secret      string
+		keyID       string
+	}
+	var stale []pending
+	for rows.Next() {
+		var p pending
+		var keyID sql.NullString
+		if err := rows.Scan(&p.accessKeyID, &p.secret, &keyID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to query SigV4 secrets: %w", err)
+		}
+		p.keyID = keyID.String
+		stale = append(stale, p)
+	}
+	rows.Close()
+
+	for i, p := range stale {
+		secret, err := db.openSigV4Secret(p.secret, p.keyID)
+		if err != nil {
+			return i, fmt.Errorf("failed to re-encrypt secret of %s: %w", p.accessKeyID, err)
+		}
+		storedSecret, keyID, err := db.sealSigV4Secret(secret)
+		if err != nil {
+			return i, err
+		}
+		if _, err := db.db.Exec(
+			"UPDATE api_key_sigv4_credentials SET secret_access_key = ?, secret_key_id = ? WHERE access_key_id = ?",
+			storedSecret, keyID, p.accessKeyID,
+		); err != nil {
+			return i, fmt.Errorf("failed to store SigV4 secret: %w", err)
+		}
+	}
+
+	return len(stale), nil
+}
+
+// sealSigV4Secret encrypts a secret when a keyring is configured, refusing
+// plaintext like TOTPManager.sealSecret
+func (db *APIKeyDB) sealSigV4Secret(secret string) (string, any, error) {
+	if db.keyring == nil {
+		if err := refuseUnencrypted(db.db, "api_key_sigv4_credentials"); err != nil {
+			return "", nil, err
+		}
+		return secret, nil, nil
+	}
+
+	envelope, keyID, err := db.keyring.Seal([]byte(secret))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encrypt SigV4 secret: %w", err)
+	}
+	return envelope, keyID, nil
+}
+
+// openSigV4Secret decrypts a stored secret; an empty key ID means plaintext
+func (db *APIKeyDB) openSigV4Secret(storedSecret, keyID string) (string, error) {
+	if keyID == "" {
+		return storedSecret, nil
+	}
+	if db.keyring == nil {
+		return "", fmt.Errorf("SigV4 secret is encrypted but no master key is configured")
+	}
+
+	secret, err := db.keyring.Open(storedSecret, keyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt SigV4 secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+// SigV4VerifierConfig configures inbound SigV4 verification
+type SigV4VerifierConfig struct {
+	// Service expected in the credential scope (default: bedrock)
+	Service string
+
+	// Regions accepted in the credential scope; empty accepts any region
+	Regions []string
+
+	// MaxClockSkew is the allowed difference between X-Amz-Date and now (default: 5m)
+	MaxClockSkew time.Duration
+
+	// AllowUnsignedPayload accepts X-Amz-Content-Sha256: UNSIGNED-PAYLOAD
+	AllowUnsignedPayload bool
+}
+
+// SigV4Verifier verifies AWS Signature Version 4 signed requests against
+// credentials issued by the proxy
+type SigV4Verifier struct {
+	db     *APIKeyDB
+	config SigV4VerifierConfig
+	now    func() time.Time
+}
+
+// NewSigV4Verifier creates a new SigV4 verifier
+func NewSigV4Verifier(db *APIKeyDB, config SigV4VerifierConfig) *SigV4Verifier {
+	if config.Service == "" {
+		config.Service = "bedrock"
+	}
+	if config.MaxClockSkew == 0 {
+		config.MaxClockSkew = 5 * time.Minute
+	}
+	return &SigV4Verifier{db: db, config: config, now: time.Now}
+}
+
+// sigV4Authorization is the parsed Authorization header
+type sigV4Authorization struct {
+	AccessKeyID   string
+	Date          string
+	Region        string
+	Service       string
+	SignedHeaders []string
+	Signature     string
+}
+
+// IsSigV4Request reports whether the request carries a SigV4 Authorization header
+func IsSigV4Request(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("Authorization"), sigV4Algorithm+" ")
+}
+
+// SigV4AccessKeyID returns the access key ID a request claims to be signed
+// with, or empty if its Authorization header cannot be parsed
+func SigV4AccessKeyID(req *http.Request) string {
+	authz, err := parseSigV4Authorization(req.Header.Get("Authorization"))
+	if err != nil {
+		return ""
+	}
+	return authz.AccessKeyID
+}
+
+// Verify checks the request signature and returns the API key it maps to
+func (v *SigV4Verifier) Verify(req *http.Request, body []byte) (*APIKey, *SigV4Credential, error) {
+	authz, err := parseSigV4Authorization(req.Header.Get("Authorization"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if authz.Service != v.config.Service {
+		return nil, nil, fmt.Errorf("unexpected service %q in credential scope", authz.Service)
+	}
+	if len(v.config.Regions) > 0 && !containsFold(v.config.Regions, authz.Region) {
+		return nil, nil, fmt.Errorf("region %q not accepted", authz.Region)
+	}
+
+	// Clock skew window
+	amzDate := req.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse(sigV4TimeFormat, amzDate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("missing or invalid X-Amz-Date header")
+	}
+	if skew := v.now().Sub(signedAt); skew > v.config.MaxClockSkew || skew < -v.config.MaxClockSkew {
+		return nil, nil, fmt.Errorf("request time %s outside allowed clock skew", amzDate)
+	}
+	if signedAt.Format(sigV4DateFormat) != authz.Date {
+		return nil, nil, fmt.Errorf("credential scope date does not match X-Amz-Date")
+	}
+
+	// Payload hash
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	if declared := req.Header.Get("X-Amz-Content-Sha256"); declared != "" {
+		if declared == sigV4UnsignedPayload {
+			if !v.config.AllowUnsignedPayload {
+				return nil, nil, fmt.Errorf("unsigned payloads are not accepted")
+			}
+			payloadHash = sigV4UnsignedPayload
+		} else if subtle.ConstantTimeCompare([]byte(declared), []byte(payloadHash)) != 1 {
+			return nil, nil, fmt.Errorf("payload hash does not match X-Amz-Content-Sha256")
+		}
+	}
+
+	cred, err := v.db.GetSigV4Credential(authz.AccessKeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	canonicalRequest, err := buildCanonicalRequest(req, authz.SignedHeaders, payloadHash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scope := strings.Join([]string{authz.Date, authz.Region, authz.Service, "aws4_request"}, "/")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	signingKey := deriveSigV4Key(cred.SecretAccessKey, authz.Date, authz.Region, authz.Service)
+	expected := hex.EncodeToString(hmacSHA256(signingKey, []byte(stringToSign)))
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(authz.Signature)) != 1 {
+		return nil, nil, fmt.Errorf("signature does not match")
+	}
+
+	key, err := v.db.GetAPIKeyByID(cred.APIKeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key.ExpiresAt != nil && v.now().After(*key.ExpiresAt) {
+		return nil, nil, fmt.Errorf("API key expired")
+	}
+
+	return key, cred, nil
+}
+
+// parseSigV4Authorization parses
+// "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/bedrock/aws4_request, SignedHeaders=host;x-amz-date, Signature=..."
+func parseSigV4Authorization(header string) (*sigV4Authorization, error) {
+	if !strings.HasPrefix(header, sigV4Algorithm+" ") {
+		return nil, fmt.Errorf("authorization header is not %s", sigV4Algorithm)
+	}
+
+	authz := &sigV4Authorization{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, sigV4Algorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed authorization header")
+		}
+		switch kv[0] {
+		case "Credential":
+			scope := strings.Split(kv[1], "/")
+			if len(scope) != 5 || scope[4] != "aws4_request" {
+				return nil, fmt.Errorf("malformed credential scope")
+			}
+			authz.AccessKeyID, authz.Date, authz.Region, authz.Service = scope[0], scope[1], scope[2], scope[3]
+		case "SignedHeaders":
+			authz.SignedHeaders = strings.Split(kv[1], ";")
+		case "Signature":
+			authz.Signature = kv[1]
+		}
+	}
+
+	if authz.AccessKeyID == "" || authz.Signature == "" || len(authz.SignedHeaders) == 0 {
+		return nil, fmt.Errorf("incomplete authorization header")
+	}
+
+	hasHost := false
+	for _, h := range authz.SignedHeaders {
+		if h == "host" {
+			hasHost = true
+		}
+	}
+	if !hasHost {
+		return nil, fmt.Errorf("host header must be signed")
+	}
+
+	return authz, nil
+}
+
+// buildCanonicalRequest builds the SigV4 canonical request for an inbound request
+func buildCanonicalRequest(req *http.Request, signedHeaders []string, payloadHash string) (string, error) {
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		switch name {
+		case "host":
+			values = []string{req.Host}
+		case "content-length":
+			values = []string{strconv.FormatInt(req.ContentLength, 10)}
+		default:
+			values = req.Header.Values(name)
+			if len(values) == 0 {
+				return "", fmt.Errorf("signed header %q missing from request", name)
+			}
+		}
+
+		cleaned := make([]string, len(values))
+		for i, value := range values {
+			cleaned[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(strings.Join(cleaned, ","))
+		headers.WriteByte('\n')
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n"), nil
+}
+
+// canonicalURI escapes the already-escaped path again, as AWS does for
+// every service except S3
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return sigV4Escape(path, false)
+}
+
+// canonicalQuery sorts and encodes query parameters
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, sigV4Escape(k, true)+"="+sigV4Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// sigV4Escape percent-encodes everything except unreserved characters
+func sigV4Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// deriveSigV4Key derives the SigV4 signing key for a date/region/service scope
+func deriveSigV4Key(secret, date, region, service string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+secret), []byte(date))
+	kRegion := hmacSHA256(kDate, []byte(region))
+	kService := hmacSHA256(kRegion, []byte(service))
+	return hmacSHA256(kService, []byte("aws4_request"))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
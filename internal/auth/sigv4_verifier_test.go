@@ -0,0 +1,212 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+func TestSigV4Verifier(t *testing.T) {
+	db, err := NewAPIKeyDB(filepath.Join(t.TempDir(), "sigv4.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.GenerateAPIKey("SDK User", "sdk@example.com", "SigV4 test", nil); err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+	key, err := db.GetAPIKeyByEmail("sdk@example.com")
+	if err != nil {
+		t.Fatalf("Failed to get API key: %v", err)
+	}
+
+	accessKeyID, secret, err := db.GenerateSigV4Credentials(key.ID)
+	if err != nil {
+		t.Fatalf("Failed to generate SigV4 credentials: %v", err)
+	}
+	if len(accessKeyID) != 20 {
+		t.Errorf("Access key ID should be 20 chars, got: %d", len(accessKeyID))
+	}
+
+	verifier := NewSigV4Verifier(db, SigV4VerifierConfig{Service: "bedrock", Regions: []string{"us-east-1"}})
+
+	var verifyErr error
+	var verifiedKey *APIKey
+	var claimedKeyID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		claimedKeyID = SigV4AccessKeyID(r)
+		verifiedKey, _, verifyErr = verifier.Verify(r, body)
+	}))
+	defer server.Close()
+
+	send := func(t *testing.T, secret string, body []byte, signedAt time.Time, tamper func(*http.Request)) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost,
+			server.URL+"/model/anthropic.claude-3-sonnet-20240229-v1%3A0/invoke?trace=on", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		sum := sha256.Sum256(body)
+		creds := aws.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secret}
+		if err := v4.NewSigner().SignHTTP(context.Background(), creds, req, hex.EncodeToString(sum[:]), "bedrock", "us-east-1", signedAt); err != nil {
+			t.Fatalf("Failed to sign request: %v", err)
+		}
+		if tamper != nil {
+			tamper(req)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	body := []byte(`{"messages":[{"role":"user","content":"hi"}]}`)
+
+	t.Run("ValidSignature", func(t *testing.T) {
+		send(t, secret, body, time.Now(), nil)
+		if verifyErr != nil {
+			t.Fatalf("Expected valid signature, got: %v", verifyErr)
+		}
+		if verifiedKey.ID != key.ID {
+			t.Errorf("Expected API key %d, got: %d", key.ID, verifiedKey.ID)
+		}
+		if claimedKeyID != accessKeyID {
+			t.Errorf("Expected access key ID %s for lockouts, got: %q", accessKeyID, claimedKeyID)
+		}
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		send(t, "not-the-secret", body, time.Now(), nil)
+		if verifyErr == nil {
+			t.Error("Expected error for wrong secret, got nil")
+		}
+	})
+
+	t.Run("ClockSkew", func(t *testing.T) {
+		send(t, secret, body, time.Now().Add(-10*time.Minute), nil)
+		if verifyErr == nil {
+			t.Error("Expected error for stale signature, got nil")
+		}
+	})
+
+	t.Run("PayloadHashMismatch", func(t *testing.T) {
+		send(t, secret, body, time.Now(), func(req *http.Request) {
+			req.Header.Set("X-Amz-Content-Sha256", "0000")
+		})
+		if verifyErr == nil {
+			t.Error("Expected error for payload hash mismatch, got nil")
+		}
+	})
+
+	t.Run("RevokedCredential", func(t *testing.T) {
+		if err := db.RevokeSigV4Credential(key.ID, accessKeyID); err != nil {
+			t.Fatalf("Failed to revoke credential: %v", err)
+		}
+		send(t, secret, body, time.Now(), nil)
+		if verifyErr == nil {
+			t.Error("Expected error for revoked credential, got nil")
+		}
+	})
+}
+
+func TestSigV4CredentialSecrets(t *testing.T) {
+	db, err := NewAPIKeyDB(filepath.Join(t.TempDir(), "sigv4.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	_, key, err := db.CreateAPIKey("SDK User", "sdk@example.com", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+
+	storedSecret := func(accessKeyID string) string {
+		var secret string
+		if err := db.DB().QueryRow(
+			"SELECT secret_access_key FROM api_key_sigv4_credentials WHERE access_key_id = ?", accessKeyID,
+		).Scan(&secret); err != nil {
+			t.Fatalf("Failed to read stored secret: %v", err)
+		}
+		return secret
+	}
+
+	// Written before a master key was configured
+	plainID, plainSecret, err := db.GenerateSigV4Credentials(key.ID)
+	if err != nil {
+		t.Fatalf("Failed to generate credentials: %v", err)
+	}
+	if storedSecret(plainID) != plainSecret {
+		t.Error("Expected a plaintext secret without a master key")
+	}
+
+	db.SetKeyring(NewKeyring(bytes.Repeat([]byte{7}, 32)))
+	sealedID, sealedSecret, err := db.GenerateSigV4Credentials(key.ID)
+	if err != nil {
+		t.Fatalf("Failed to generate credentials: %v", err)
+	}
+	if storedSecret(sealedID) == sealedSecret {
+		t.Error("Expected the secret encrypted at rest")
+	}
+
+	n, err := db.ReencryptSigV4Secrets()
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 secret re-encrypted, got %d: %v", n, err)
+	}
+	if storedSecret(plainID) == plainSecret {
+		t.Error("Expected the plaintext secret encrypted after re-encryption")
+	}
+
+	for id, secret := range map[string]string{plainID: plainSecret, sealedID: sealedSecret} {
+		cred, err := db.GetSigV4Credential(id)
+		if err != nil {
+			t.Fatalf("Failed to get credential: %v", err)
+		}
+		if cred.SecretAccessKey != secret {
+			t.Errorf("Expected the original secret for %s", id)
+		}
+	}
+
+	// Without the master key encrypted secrets cannot be used
+	db.SetKeyring(nil)
+	if _, err := db.GetSigV4Credential(sealedID); err == nil {
+		t.Error("Expected an error without the master key, got nil")
+	}
+
+	creds, err := db.ListSigV4Credentials(key.ID)
+	if err != nil {
+		t.Fatalf("Failed to list credentials: %v", err)
+	}
+	if len(creds) != 2 || creds[0].SecretAccessKey != "" {
+		t.Errorf("Expected 2 credentials without secrets, got %+v", creds)
+	}
+
+	if err := db.RevokeSigV4Credential(key.ID+1, plainID); err == nil {
+		t.Error("Expected an error revoking another key's credential, got nil")
+	}
+	if err := db.RevokeSigV4Credential(key.ID, plainID); err != nil {
+		t.Fatalf("Failed to revoke credential: %v", err)
+	}
+	creds, _ = db.ListSigV4Credentials(key.ID)
+	for _, cred := range creds {
+		if cred.IsActive != (cred.AccessKeyID == sealedID) {
+			t.Errorf("Expected only %s active, got %+v", sealedID, cred)
+		}
+	}
+}
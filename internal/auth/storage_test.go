@@ -0,0 +1,160 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+// TestPostgresStore runs keys, sessions and TOTP against a real PostgreSQL
+// database. Set TEST_POSTGRES_DSN to a disposable database to enable it.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+
+	store, err := storage.Open(storage.Config{Backend: storage.BackendPostgres, DSN: dsn})
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	db, err := NewAPIKeyDBWithStore(store)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	email := "pg-" + time.Now().Format("150405.000000") + "@example.com"
+	apiKey, err := db.GenerateAPIKey("Postgres", email, "Postgres test", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate API key: %v", err)
+	}
+
+	key, err := db.ValidateAPIKey(apiKey)
+	if err != nil {
+		t.Fatalf("Failed to validate API key: %v", err)
+	}
+
+	// A second replica shares sessions and revocations through the database
+	replicaA := NewSessionManager(db.DB())
+	replicaB := NewSessionManager(db.DB())
+
+	token, err := replicaA.GenerateSessionToken(key.ID, time.Hour, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, _, err := replicaB.ValidateSessionToken(token, "127.0.0.1", "test"); err != nil {
+		t.Fatalf("Session not visible to second replica: %v", err)
+	}
+	if err := replicaA.RevokeSessionToken(token); err != nil {
+		t.Fatalf("Failed to revoke session: %v", err)
+	}
+	if _, _, err := replicaB.ValidateSessionToken(token, "127.0.0.1", "test"); err == nil {
+		t.Error("Revoked session still valid on second replica")
+	}
+
+	totpManager := NewTOTPManager(db.DB())
+	if _, _, err := totpManager.GenerateTOTP(key.ID, email, "Test"); err != nil {
+		t.Fatalf("Failed to generate TOTP: %v", err)
+	}
+	if enabled, _ := totpManager.IsTOTPEnabled(key.ID); !enabled {
+		t.Error("Expected TOTP to be enabled")
+	}
+
+	if err := db.RevokeAPIKey(key.ID); err != nil {
+		t.Fatalf("Failed to revoke API key: %v", err)
+	}
+	if _, err := db.ValidateAPIKey(apiKey); err == nil {
+		t.Error("Revoked API key still valid")
+	}
+}
+
+func TestMigrateExistingDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	// Schema as created by releases before versioned migrations, partially
+	// upgraded in place (session rotation columns already present)
+	store, err := storage.Open(storage.Config{Backend: storage.BackendSQLite, DSN: dbPath})
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	err = storage.ExecSchema(store, `
+		CREATE TABLE api_keys (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key_hash TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL,
+			email TEXT,
+			description TEXT,
+			is_active BOOLEAN DEFAULT 1,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP,
+			expires_at TIMESTAMP,
+			permissions TEXT DEFAULT '[]',
+			metadata TEXT DEFAULT '{}'
+		);
+		CREATE TABLE session_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token TEXT NOT NULL UNIQUE,
+			api_key_id INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			last_used_at TIMESTAMP,
+			ip_address TEXT,
+			user_agent TEXT,
+			is_active BOOLEAN DEFAULT 1,
+			parent_id INTEGER,
+			family_id INTEGER
+		);
+		INSERT INTO api_keys (key_hash, name, email, description) VALUES ('legacy-hash', 'Legacy', 'legacy@example.com', '');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+	store.Close()
+
+	for run := 1; run <= 2; run++ {
+		db, err := NewAPIKeyDB(dbPath)
+		if err != nil {
+			t.Fatalf("Run %d: failed to open legacy database: %v", run, err)
+		}
+
+		migrator, err := NewMigrator(db.DB())
+		if err != nil {
+			t.Fatalf("Failed to create migrator: %v", err)
+		}
+		pending, err := migrator.Pending()
+		if err != nil {
+			t.Fatalf("Failed to list pending migrations: %v", err)
+		}
+		if len(pending) != 0 {
+			t.Errorf("Run %d: expected no pending migrations, got %d", run, len(pending))
+		}
+
+		columns, err := storage.Columns(db.DB(), db.DB().Dialect(), "session_tokens")
+		if err != nil {
+			t.Fatalf("Failed to list columns: %v", err)
+		}
+		for _, col := range []string{"parent_id", "family_id", "rotated_at", "revoked_reason"} {
+			if !columns[col] {
+				t.Errorf("Run %d: missing session_tokens.%s", run, col)
+			}
+		}
+
+		key, err := db.GetAPIKeyByEmail("legacy@example.com")
+		if err != nil {
+			t.Fatalf("Run %d: legacy key lost: %v", run, err)
+		}
+		if key.HashScheme != HashSchemeBcrypt {
+			t.Errorf("Run %d: expected legacy key to default to bcrypt, got %q", run, key.HashScheme)
+		}
+
+		db.Close()
+	}
+}
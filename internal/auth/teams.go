@@ -0,0 +1,533 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+// Team member roles
+const (
+	TeamRoleAdmin  = "admin"
+	TeamRoleMember = "member"
+)
+
+// Team groups API keys under shared permissions, rate limits and budgets
+type Team struct {
+	ID               int64     `json:"id"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	Permissions      []string  `json:"permissions"`
+	AllowedModels    []string  `json:"allowed_models"`
+	RateLimitRPM     int       `json:"rate_limit_rpm"`
+	MonthlyBudgetUSD float64   `json:"monthly_budget_usd"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// TeamMember is a user (by email) belonging to a team
+type TeamMember struct {
+	TeamID    int64     `json:"team_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KeyLimits are per-key restrictions that narrow the team's
+type KeyLimits struct {
+	Permissions      []string `json:"permissions,omitempty"`
+	AllowedModels    []string `json:"allowed_models,omitempty"`
+	RateLimitRPM     int      `json:"rate_limit_rpm,omitempty"`
+	MonthlyBudgetUSD float64  `json:"monthly_budget_usd,omitempty"`
+}
+
+// KeyPolicy is the effective policy of an API key after applying its team
+type KeyPolicy struct {
+	APIKeyID int64
+	TeamID   int64
+	TeamName string
+
+	// Permissions and AllowedModels are nil when unrestricted. Team keys
+	// always have a Permissions list, empty when the team grants none.
+	Permissions   []string
+	AllowedModels []string
+
+	// RateLimitRPM and MonthlyBudgetUSD apply to the key alone (0 = none)
+	RateLimitRPM     int
+	MonthlyBudgetUSD float64
+
+	// TeamRateLimitRPM and TeamMonthlyBudgetUSD are shared by all team keys
+	TeamRateLimitRPM     int
+	TeamMonthlyBudgetUSD float64
+
+	// DefaultTags are the key's cost-center tags, nil when it has none
+	DefaultTags map[string]string
+}
+
+// AllowsModel reports whether the policy permits the model
+func (p *KeyPolicy) AllowsModel(model string) bool {
+	if p.AllowedModels == nil {
+		return true
+	}
+	for _, allowed := range p.AllowedModels {
+		if allowed == "*" || strings.EqualFold(allowed, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateTeam creates a team and returns its ID
+func (db *APIKeyDB) CreateTeam(team *Team) (int64, error) {
+	permissions, _ := json.Marshal(nonNil(team.Permissions))
+	models, _ := json.Marshal(nonNil(team.AllowedModels))
+
+	var id int64
+	err := db.db.QueryRow(`
+		INSERT INTO teams (name, description, permissions, allowed_models, rate_limit_rpm, monthly_budget_usd)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`, team.Name, team.Description, string(permissions), string(models), team.RateLimitRPM, team.MonthlyBudgetUSD).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create team: %w", err)
+	}
+
+	return id, nil
+}
+
+// UpdateTeam replaces a team's description, permissions and limits
+func (db *APIKeyDB) UpdateTeam(team *Team) error {
+	permissions, _ := json.Marshal(nonNil(team.Permissions))
+	models, _ := json.Marshal(nonNil(team.AllowedModels))
+
+	_, err := db.db.Exec(`
+		UPDATE teams
+		SET description = ?, permissions = ?, allowed_models = ?, rate_limit_rpm = ?, monthly_budget_usd = ?
+		WHERE id = ?
+	`, team.Description, string(permissions), string(models), team.RateLimitRPM, team.MonthlyBudgetUSD, team.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update team: %w", err)
+	}
+	return nil
+}
+
+// GetTeam returns a team by ID
+func (db *APIKeyDB) GetTeam(teamID int64) (*Team, error) {
+	team, err := scanTeam(db.db.QueryRow(`
+		SELECT id, name, description, permissions, allowed_models, rate_limit_rpm, monthly_budget_usd, created_at
+		FROM teams
+		WHERE id = ?
+	`, teamID))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("team not found: %d", teamID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team: %w", err)
+	}
+	return team, nil
+}
+
+// ListTeams returns all teams
+func (db *APIKeyDB) ListTeams() ([]Team, error) {
+	rows, err := db.db.Query(`
+		SELECT id, name, description, permissions, allowed_models, rate_limit_rpm, monthly_budget_usd, created_at
+		FROM teams
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query teams: %w", err)
+	}
+	defer rows.Close()
+
+	var teams []Team
+	for rows.Next() {
+		team, err := scanTeam(rows)
+		if err != nil {
+			continue
+		}
+		teams = append(teams, *team)
+	}
+	return teams, nil
+}
+
+// AddTeamMember adds a user to a team or changes their role
+func (db *APIKeyDB) AddTeamMember(teamID int64, email, role string) error {
+	if role != TeamRoleAdmin && role != TeamRoleMember {
+		return fmt.Errorf("invalid team role: %s", role)
+	}
+
+	_, err := db.db.Exec(`
+		INSERT INTO team_members (team_id, email, role)
+		VALUES (?, ?, ?)
+		ON CONFLICT(team_id, email) DO UPDATE SET role = excluded.role
+	`, teamID, email, role)
+	if err != nil {
+		return fmt.Errorf("failed to add team member: %w", err)
+	}
+	return nil
+}
+
+// RemoveTeamMember removes a user from a team and detaches their keys
+func (db *APIKeyDB) RemoveTeamMember(teamID int64, email string) error {
+	if _, err := db.db.Exec("DELETE FROM team_members WHERE team_id = ? AND email = ?", teamID, email); err != nil {
+		return fmt.Errorf("failed to remove team member: %w", err)
+	}
+	if _, err := db.db.Exec("UPDATE api_keys SET team_id = NULL WHERE team_id = ? AND email = ?", teamID, email); err != nil {
+		return fmt.Errorf("failed to detach member keys: %w", err)
+	}
+	return nil
+}
+
+// ListTeamMembers returns the members of a team
+func (db *APIKeyDB) ListTeamMembers(teamID int64) ([]TeamMember, error) {
+	rows, err := db.db.Query(`
+		SELECT team_id, email, role, created_at
+		FROM team_members
+		WHERE team_id = ?
+		ORDER BY email
+	`, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query team members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []TeamMember
+	for rows.Next() {
+		var m TeamMember
+		if err := rows.Scan(&m.TeamID, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			continue
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// TeamRole returns the role of email in a team, or "" if not a member
+func (db *APIKeyDB) TeamRole(teamID int64, email string) (string, error) {
+	var role string
+	err := db.db.QueryRow(
+		"SELECT role FROM team_members WHERE team_id = ? AND email = ?", teamID, email,
+	).Scan(&role)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get team role: %w", err)
+	}
+	return role, nil
+}
+
+// AssignKeyToTeam moves a key into a team; the key owner must be a member
+func (db *APIKeyDB) AssignKeyToTeam(keyID, teamID int64) error {
+	key, err := db.GetAPIKeyByID(keyID)
+	if err != nil {
+		return err
+	}
+
+	role, err := db.TeamRole(teamID, key.Email)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return fmt.Errorf("key owner %s is not a member of team %d", key.Email, teamID)
+	}
+
+	if _, err := db.db.Exec("UPDATE api_keys SET team_id = ? WHERE id = ?", teamID, keyID); err != nil {
+		return fmt.Errorf("failed to assign key to team: %w", err)
+	}
+	return nil
+}
+
+// GenerateTeamAPIKey creates an API key for a team member, owned by the
+// team, with its limits stored in the same transaction
+func (db *APIKeyDB) GenerateTeamAPIKey(teamID int64, name, email, description string, expiresIn *time.Duration, limits KeyLimits) (string, *APIKey, error) {
+	role, err := db.TeamRole(teamID, email)
+	if err != nil {
+		return "", nil, err
+	}
+	if role == "" {
+		return "", nil, fmt.Errorf("%s is not a member of team %d", email, teamID)
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin key creation: %w", err)
+	}
+	defer tx.Rollback()
+
+	apiKey, id, err := db.insertAPIKey(tx, name, email, description, expiryFrom(expiresIn))
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := tx.Exec("UPDATE api_keys SET team_id = ? WHERE id = ?", teamID, id); err != nil {
+		return "", nil, fmt.Errorf("failed to assign key to team: %w", err)
+	}
+	if err := setKeyLimits(tx, id, limits); err != nil {
+		return "", nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", nil, fmt.Errorf("failed to commit key creation: %w", err)
+	}
+
+	key, err := db.GetAPIKeyByID(id)
+	if err != nil {
+		return "", nil, err
+	}
+	return apiKey, key, nil
+}
+
+// GetTeamKey returns a key of the team by ID, including revoked keys
+func (db *APIKeyDB) GetTeamKey(teamID, keyID int64) (*APIKey, error) {
+	key, err := scanAPIKey(db.db.QueryRow(`
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE id = ? AND team_id = ?
+	`, keyID, teamID))
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API key %d not found in team %d", keyID, teamID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return key, nil
+}
+
+// ListTeamKeys returns the API keys of a team
+func (db *APIKeyDB) ListTeamKeys(teamID int64) ([]APIKey, error) {
+	rows, err := db.db.Query(`
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE team_id = ?
+		ORDER BY created_at DESC
+	`, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, *key)
+	}
+	return keys, nil
+}
+
+// SetKeyLimits replaces the per-key restrictions of an API key
+func (db *APIKeyDB) SetKeyLimits(keyID int64, limits KeyLimits) error {
+	return setKeyLimits(db.db, keyID, limits)
+}
+
+func setKeyLimits(q storage.Querier, keyID int64, limits KeyLimits) error {
+	permissions, _ := json.Marshal(nonNil(limits.Permissions))
+	models, _ := json.Marshal(nonNil(limits.AllowedModels))
+
+	_, err := q.Exec(`
+		UPDATE api_keys
+		SET permissions = ?, allowed_models = ?, rate_limit_rpm = ?, monthly_budget_usd = ?
+		WHERE id = ?
+	`, string(permissions), string(models), limits.RateLimitRPM, limits.MonthlyBudgetUSD, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to update key limits: %w", err)
+	}
+	return nil
+}
+
+// EffectivePolicy resolves a key's permissions and limits against its team.
+// Keys inherit team settings they leave unset and can only narrow the rest.
+func (db *APIKeyDB) EffectivePolicy(keyID int64) (*KeyPolicy, error) {
+	var keyPermissions, keyModels, keyMeta string
+	var keyRPM int
+	var keyBudget float64
+	var teamID sql.NullInt64
+
+	err := db.db.QueryRow(`
+		SELECT COALESCE(permissions, '[]'), COALESCE(allowed_models, '[]'),
+			COALESCE(rate_limit_rpm, 0), COALESCE(monthly_budget_usd, 0), team_id, COALESCE(metadata, '{}')
+		FROM api_keys
+		WHERE id = ?
+	`, keyID).Scan(&keyPermissions, &keyModels, &keyRPM, &keyBudget, &teamID, &keyMeta)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API key not found: %d", keyID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key policy: %w", err)
+	}
+
+	policy := &KeyPolicy{
+		APIKeyID:         keyID,
+		Permissions:      decodeList(keyPermissions),
+		AllowedModels:    decodeList(keyModels),
+		RateLimitRPM:     keyRPM,
+		MonthlyBudgetUSD: keyBudget,
+		DefaultTags:      decodeKeyMetadata(keyMeta).Tags,
+	}
+
+	if !teamID.Valid {
+		return policy, nil
+	}
+
+	team, err := db.GetTeam(teamID.Int64)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.TeamID = team.ID
+	policy.TeamName = team.Name
+	policy.Permissions = narrowPermissions(team.Permissions, policy.Permissions)
+	policy.AllowedModels = narrowList(team.AllowedModels, policy.AllowedModels)
+	policy.RateLimitRPM = narrowLimit(team.RateLimitRPM, policy.RateLimitRPM)
+	policy.MonthlyBudgetUSD = narrowBudget(team.MonthlyBudgetUSD, policy.MonthlyBudgetUSD)
+	policy.TeamRateLimitRPM = team.RateLimitRPM
+	policy.TeamMonthlyBudgetUSD = team.MonthlyBudgetUSD
+
+	return policy, nil
+}
+
+// RecordSpend adds cost to the key's (and thereby its team's) monthly spend
+func (db *APIKeyDB) RecordSpend(keyID, teamID int64, amountUSD float64, at time.Time) error {
+	if amountUSD <= 0 {
+		return nil
+	}
+
+	_, err := db.db.Exec(`
+		INSERT INTO team_spend (period, api_key_id, team_id, amount_usd)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(period, api_key_id) DO UPDATE SET
+			amount_usd = team_spend.amount_usd + excluded.amount_usd,
+			team_id = excluded.team_id
+	`, spendPeriod(at), keyID, nullableID(teamID), amountUSD)
+	if err != nil {
+		return fmt.Errorf("failed to record spend: %w", err)
+	}
+	return nil
+}
+
+// KeySpend returns a key's spend in the month containing at
+func (db *APIKeyDB) KeySpend(keyID int64, at time.Time) (float64, error) {
+	var total float64
+	err := db.db.QueryRow(
+		"SELECT COALESCE(SUM(amount_usd), 0) FROM team_spend WHERE period = ? AND api_key_id = ?",
+		spendPeriod(at), keyID,
+	).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get key spend: %w", err)
+	}
+	return total, nil
+}
+
+// TeamSpend returns a team's spend in the month containing at
+func (db *APIKeyDB) TeamSpend(teamID int64, at time.Time) (float64, error) {
+	var total float64
+	err := db.db.QueryRow(
+		"SELECT COALESCE(SUM(amount_usd), 0) FROM team_spend WHERE period = ? AND team_id = ?",
+		spendPeriod(at), teamID,
+	).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get team spend: %w", err)
+	}
+	return total, nil
+}
+
+// scanTeam reads one teams row
+func scanTeam(row rowScanner) (*Team, error) {
+	var team Team
+	var permissions, models string
+
+	err := row.Scan(
+		&team.ID, &team.Name, &team.Description, &permissions, &models,
+		&team.RateLimitRPM, &team.MonthlyBudgetUSD, &team.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	team.Permissions = decodeList(permissions)
+	team.AllowedModels = decodeList(models)
+	return &team, nil
+}
+
+// spendPeriod returns the budget period (calendar month, UTC) for a time
+func spendPeriod(at time.Time) string {
+	return at.UTC().Format("2006-01")
+}
+
+// decodeList parses a JSON string array; empty or invalid means unrestricted (nil)
+func decodeList(data string) []string {
+	var list []string
+	if err := json.Unmarshal([]byte(data), &list); err != nil || len(list) == 0 {
+		return nil
+	}
+	return list
+}
+
+// narrowList intersects a key list with its team list; nil means
+// unrestricted and a "*" key entry takes the team list
+func narrowList(team, key []string) []string {
+	if team == nil {
+		return key
+	}
+	if key == nil || slices.Contains(key, "*") {
+		return team
+	}
+
+	allowed := make(map[string]bool, len(team))
+	for _, v := range team {
+		allowed[strings.ToLower(v)] = true
+	}
+
+	narrowed := []string{}
+	for _, v := range key {
+		if allowed["*"] || allowed[strings.ToLower(v)] {
+			narrowed = append(narrowed, v)
+		}
+	}
+	return narrowed
+}
+
+// narrowPermissions intersects a key's permissions with its team's. Unlike
+// models, a team without permissions grants none, so a team key never
+// holds a permission its team was not given.
+func narrowPermissions(team, key []string) []string {
+	if team == nil {
+		return []string{}
+	}
+	return narrowList(team, key)
+}
+
+// narrowLimit returns the stricter of two limits where 0 means unlimited
+func narrowLimit(team, key int) int {
+	if team == 0 || (key != 0 && key < team) {
+		return key
+	}
+	return team
+}
+
+// narrowBudget returns the stricter of two budgets where 0 means unlimited
+func narrowBudget(team, key float64) float64 {
+	if team == 0 || (key != 0 && key < team) {
+		return key
+	}
+	return team
+}
+
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
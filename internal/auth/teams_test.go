@@ -0,0 +1,276 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+)
+
+func TestTeamPolicy(t *testing.T) {
+	store, err := storage.NewMemory()
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	db, err := NewAPIKeyDBWithStore(store)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	teamID, err := db.CreateTeam(&Team{
+		Name:             "ml-platform",
+		Permissions:      []string{"invoke", "list_models"},
+		AllowedModels:    []string{"claude-3-sonnet", "claude-3-haiku"},
+		RateLimitRPM:     100,
+		MonthlyBudgetUSD: 500,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create team: %v", err)
+	}
+
+	if err := db.AddTeamMember(teamID, "alice@example.com", TeamRoleAdmin); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+	if err := db.AddTeamMember(teamID, "bob@example.com", "owner"); err == nil {
+		t.Error("Expected invalid role to be rejected")
+	}
+
+	t.Run("non-member cannot get a team key", func(t *testing.T) {
+		if _, _, err := db.GenerateTeamAPIKey(teamID, "key", "mallory@example.com", "", nil, KeyLimits{}); err == nil {
+			t.Error("Expected error for non-member")
+		}
+	})
+
+	_, key, err := db.GenerateTeamAPIKey(teamID, "alice-key", "alice@example.com", "", nil, KeyLimits{})
+	if err != nil {
+		t.Fatalf("Failed to create team key: %v", err)
+	}
+	if key.TeamID != teamID {
+		t.Errorf("Expected team %d, got %d", teamID, key.TeamID)
+	}
+
+	t.Run("key inherits team settings", func(t *testing.T) {
+		policy, err := db.EffectivePolicy(key.ID)
+		if err != nil {
+			t.Fatalf("Failed to get policy: %v", err)
+		}
+		if !reflect.DeepEqual(policy.Permissions, []string{"invoke", "list_models"}) {
+			t.Errorf("Unexpected permissions: %v", policy.Permissions)
+		}
+		if policy.RateLimitRPM != 100 || policy.MonthlyBudgetUSD != 500 {
+			t.Errorf("Unexpected limits: rpm=%d budget=%v", policy.RateLimitRPM, policy.MonthlyBudgetUSD)
+		}
+		if !policy.AllowsModel("claude-3-haiku") || policy.AllowsModel("gpt-4") {
+			t.Error("Model allowlist not inherited")
+		}
+	})
+
+	t.Run("key can only narrow team settings", func(t *testing.T) {
+		err := db.SetKeyLimits(key.ID, KeyLimits{
+			Permissions:      []string{"invoke", "admin"},
+			AllowedModels:    []string{"claude-3-haiku", "gpt-4"},
+			RateLimitRPM:     500,
+			MonthlyBudgetUSD: 50,
+		})
+		if err != nil {
+			t.Fatalf("Failed to set limits: %v", err)
+		}
+
+		policy, err := db.EffectivePolicy(key.ID)
+		if err != nil {
+			t.Fatalf("Failed to get policy: %v", err)
+		}
+		if !reflect.DeepEqual(policy.Permissions, []string{"invoke"}) {
+			t.Errorf("Expected key permissions narrowed to team, got %v", policy.Permissions)
+		}
+		if !reflect.DeepEqual(policy.AllowedModels, []string{"claude-3-haiku"}) {
+			t.Errorf("Expected models narrowed to team, got %v", policy.AllowedModels)
+		}
+		if policy.RateLimitRPM != 100 {
+			t.Errorf("Expected team rate limit to cap key, got %d", policy.RateLimitRPM)
+		}
+		if policy.MonthlyBudgetUSD != 50 {
+			t.Errorf("Expected stricter key budget, got %v", policy.MonthlyBudgetUSD)
+		}
+		if policy.TeamRateLimitRPM != 100 || policy.TeamMonthlyBudgetUSD != 500 {
+			t.Error("Expected shared team limits on policy")
+		}
+	})
+
+	t.Run("wildcard key permissions take the team list", func(t *testing.T) {
+		err := db.SetKeyLimits(key.ID, KeyLimits{
+			Permissions:   []string{"*"},
+			AllowedModels: []string{"*"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to set limits: %v", err)
+		}
+
+		policy, err := db.EffectivePolicy(key.ID)
+		if err != nil {
+			t.Fatalf("Failed to get policy: %v", err)
+		}
+		if !reflect.DeepEqual(policy.Permissions, []string{"invoke", "list_models"}) {
+			t.Errorf("Expected team permissions for wildcard key, got %v", policy.Permissions)
+		}
+		if !reflect.DeepEqual(policy.AllowedModels, []string{"claude-3-sonnet", "claude-3-haiku"}) {
+			t.Errorf("Expected team models for wildcard key, got %v", policy.AllowedModels)
+		}
+	})
+
+	t.Run("team without permissions grants none", func(t *testing.T) {
+		bareID, err := db.CreateTeam(&Team{Name: "bare"})
+		if err != nil {
+			t.Fatalf("Failed to create team: %v", err)
+		}
+		if err := db.AddTeamMember(bareID, "alice@example.com", TeamRoleMember); err != nil {
+			t.Fatalf("Failed to add member: %v", err)
+		}
+		_, bareKey, err := db.GenerateTeamAPIKey(bareID, "bare-key", "alice@example.com", "", nil, KeyLimits{})
+		if err != nil {
+			t.Fatalf("Failed to create team key: %v", err)
+		}
+		if err := db.SetKeyLimits(bareKey.ID, KeyLimits{Permissions: []string{"admin"}}); err != nil {
+			t.Fatalf("Failed to set limits: %v", err)
+		}
+
+		policy, err := db.EffectivePolicy(bareKey.ID)
+		if err != nil {
+			t.Fatalf("Failed to get policy: %v", err)
+		}
+		if policy.Permissions == nil || len(policy.Permissions) != 0 {
+			t.Errorf("Expected an empty permission list, got %#v", policy.Permissions)
+		}
+	})
+
+	t.Run("spend rolls up to team", func(t *testing.T) {
+		now := time.Now()
+		if err := db.RecordSpend(key.ID, teamID, 1.25, now); err != nil {
+			t.Fatalf("Failed to record spend: %v", err)
+		}
+		if err := db.RecordSpend(key.ID, teamID, 0.75, now); err != nil {
+			t.Fatalf("Failed to record spend: %v", err)
+		}
+
+		keySpend, _ := db.KeySpend(key.ID, now)
+		teamSpend, _ := db.TeamSpend(teamID, now)
+		if keySpend != 2 || teamSpend != 2 {
+			t.Errorf("Expected spend 2, got key=%v team=%v", keySpend, teamSpend)
+		}
+
+		lastMonth, _ := db.TeamSpend(teamID, now.AddDate(0, -1, 0))
+		if lastMonth != 0 {
+			t.Errorf("Expected no spend last month, got %v", lastMonth)
+		}
+	})
+
+	t.Run("audit records carry team", func(t *testing.T) {
+		if err := db.LogAPIKeyUsage(key.ID, "test", "127.0.0.1", "test", "/v1/test", 200, "{}"); err != nil {
+			t.Fatalf("Failed to log usage: %v", err)
+		}
+
+		var auditTeam int64
+		err := db.DB().QueryRow(
+			"SELECT team_id FROM api_key_audit WHERE api_key_id = ? AND action = 'test'", key.ID,
+		).Scan(&auditTeam)
+		if err != nil {
+			t.Fatalf("Failed to read audit: %v", err)
+		}
+		if auditTeam != teamID {
+			t.Errorf("Expected audit team %d, got %d", teamID, auditTeam)
+		}
+	})
+
+	t.Run("removing member detaches keys", func(t *testing.T) {
+		if err := db.RemoveTeamMember(teamID, "alice@example.com"); err != nil {
+			t.Fatalf("Failed to remove member: %v", err)
+		}
+
+		policy, err := db.EffectivePolicy(key.ID)
+		if err != nil {
+			t.Fatalf("Failed to get policy: %v", err)
+		}
+		if policy.TeamID != 0 {
+			t.Errorf("Expected key detached from team, got %d", policy.TeamID)
+		}
+	})
+}
+
+func TestDefaultTags(t *testing.T) {
+	db := newAuditTestDB(t)
+
+	_, key, err := db.CreateAPIKey("ci", "ci@example.com", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err := db.db.Exec(`UPDATE api_keys SET metadata = '{"owner":"platform"}' WHERE id = ?`, key.ID); err != nil {
+		t.Fatalf("Failed to set metadata: %v", err)
+	}
+
+	want := map[string]string{"project": "search", "env": "prod"}
+	if err := db.SetDefaultTags(key.ID, want); err != nil {
+		t.Fatalf("SetDefaultTags failed: %v", err)
+	}
+	policy, err := db.EffectivePolicy(key.ID)
+	if err != nil {
+		t.Fatalf("EffectivePolicy failed: %v", err)
+	}
+	if !reflect.DeepEqual(policy.DefaultTags, want) {
+		t.Errorf("Expected default tags %v, got %v", want, policy.DefaultTags)
+	}
+	key, _ = db.GetAPIKeyByID(key.ID)
+	if key.Metadata != `{"owner":"platform","tags":{"env":"prod","project":"search"}}` {
+		t.Errorf("Expected other metadata to be kept, got %s", key.Metadata)
+	}
+
+	if err := db.SetDefaultTags(key.ID, nil); err != nil {
+		t.Fatalf("SetDefaultTags failed: %v", err)
+	}
+	key, _ = db.GetAPIKeyByID(key.ID)
+	if key.DefaultTags() != nil || key.Metadata != `{"owner":"platform"}` {
+		t.Errorf("Expected tags to be removed, got %s", key.Metadata)
+	}
+}
+
+func TestGenerateTeamAPIKeyLimits(t *testing.T) {
+	db := newAuditTestDB(t)
+
+	teamID, err := db.CreateTeam(&Team{Name: "ml-platform", Permissions: []string{"invoke"}})
+	if err != nil {
+		t.Fatalf("Failed to create team: %v", err)
+	}
+	if err := db.AddTeamMember(teamID, "alice@example.com", TeamRoleMember); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+
+	_, key, err := db.GenerateTeamAPIKey(teamID, "notebook", "alice@example.com", "", nil, KeyLimits{RateLimitRPM: 30})
+	if err != nil {
+		t.Fatalf("Failed to create team key: %v", err)
+	}
+	policy, err := db.EffectivePolicy(key.ID)
+	if err != nil {
+		t.Fatalf("Failed to get policy: %v", err)
+	}
+	if policy.TeamID != teamID || policy.RateLimitRPM != 30 {
+		t.Errorf("Expected the key in team %d limited to 30 rpm, got team %d and %d rpm", teamID, policy.TeamID, policy.RateLimitRPM)
+	}
+
+	// A failure storing the limits must not leave an unrestricted key behind
+	mustExec(t, db, `CREATE TRIGGER reject_limits BEFORE UPDATE OF rate_limit_rpm ON api_keys
+		BEGIN SELECT RAISE(FAIL, 'rejected'); END`)
+	if _, _, err := db.GenerateTeamAPIKey(teamID, "batch", "alice@example.com", "", nil, KeyLimits{RateLimitRPM: 10}); err == nil {
+		t.Fatal("Expected an error when the limits cannot be stored")
+	}
+	keys, err := db.ListTeamKeys(teamID)
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("Expected only the first key, got %d keys", len(keys))
+	}
+}
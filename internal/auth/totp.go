@@ -7,25 +7,140 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
 	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	// ErrTOTPLocked is returned while 2FA is locked after repeated failures
+	ErrTOTPLocked = errors.New("too many failed 2FA attempts, try again later")
+
+	// ErrTOTPAlreadyEnabled is returned when enrolling a key that already has 2FA
+	ErrTOTPAlreadyEnabled = errors.New("2FA is already enabled for this API key")
+
+	// ErrTOTPNoEnrollment is returned when confirming without a pending enrollment
+	ErrTOTPNoEnrollment = errors.New("no pending 2FA enrollment for this API key")
+)
+
+// TOTPOptions controls secret encryption and lockout for TOTP
+type TOTPOptions struct {
+	// Keyring encrypts TOTP secrets at rest; nil stores them in plaintext
+	Keyring *Keyring
+
+	// MaxFailedAttempts locks 2FA after this many consecutive failures (0 disables)
+	MaxFailedAttempts int
+
+	// LockoutDuration is how long 2FA stays locked
+	LockoutDuration time.Duration
+}
+
 // TOTPManager manages TOTP (Time-based One-Time Passwords) for 2FA
 type TOTPManager struct {
-	db *sql.DB
+	db      storage.Store
+	options TOTPOptions
 }
 
 // NewTOTPManager creates a new TOTP manager
-func NewTOTPManager(db *sql.DB) *TOTPManager {
-	return &TOTPManager{db: db}
+func NewTOTPManager(db storage.Store) *TOTPManager {
+	return NewTOTPManagerWithOptions(db, TOTPOptions{
+		MaxFailedAttempts: 5,
+		LockoutDuration:   15 * time.Minute,
+	})
+}
+
+// NewTOTPManagerWithOptions creates a TOTP manager with encryption and lockout settings
+func NewTOTPManagerWithOptions(db storage.Store, options TOTPOptions) *TOTPManager {
+	return &TOTPManager{db: db, options: options}
 }
 
-// GenerateTOTP creates a new TOTP secret for a user
+// GenerateTOTP creates a new TOTP secret for a user and enables it immediately
 func (m *TOTPManager) GenerateTOTP(apiKeyID int64, accountName, issuer string) (*otp.Key, []string, error) {
+	key, err := m.storeNewSecret(apiKeyID, accountName, issuer, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	backupCodes, err := m.RegenerateBackupCodes(apiKeyID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, backupCodes, nil
+}
+
+// BeginEnrollment creates a pending TOTP secret for self-enrollment. 2FA is
+// not enforced until ConfirmEnrollment sees the first valid code.
+func (m *TOTPManager) BeginEnrollment(apiKeyID int64, accountName, issuer string) (*otp.Key, error) {
+	enabled, err := m.IsTOTPEnabled(apiKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TOTP: %w", err)
+	}
+	if enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	return m.storeNewSecret(apiKeyID, accountName, issuer, false)
+}
+
+// ConfirmEnrollment enables a pending enrollment once code matches its
+// secret and returns a fresh set of backup codes
+func (m *TOTPManager) ConfirmEnrollment(apiKeyID int64, code string) ([]string, error) {
+	var storedSecret string
+	var keyID sql.NullString
+	var isEnabled bool
+	var lockedUntil sql.NullTime
+
+	err := m.db.QueryRow(`
+		SELECT totp_secret, secret_key_id, is_enabled, locked_until
+		FROM api_key_2fa
+		WHERE api_key_id = ?
+	`, apiKeyID).Scan(&storedSecret, &keyID, &isEnabled, &lockedUntil)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrTOTPNoEnrollment
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TOTP: %w", err)
+	}
+	if isEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if lockedUntil.Valid && time.Now().Before(lockedUntil.Time) {
+		return nil, ErrTOTPLocked
+	}
+
+	secret, err := m.openSecret(storedSecret, keyID.String)
+	if err != nil {
+		return nil, err
+	}
+
+	if !totp.Validate(code, secret) {
+		if err := m.recordFailure(apiKeyID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invalid TOTP code")
+	}
+
+	if _, err := m.db.Exec(`
+		UPDATE api_key_2fa
+		SET is_enabled = TRUE, failed_attempts = 0, locked_until = NULL
+		WHERE api_key_id = ?
+	`, apiKeyID); err != nil {
+		return nil, fmt.Errorf("failed to enable TOTP: %w", err)
+	}
+
+	return m.RegenerateBackupCodes(apiKeyID)
+}
+
+// storeNewSecret generates a TOTP key and writes it, replacing any previous one
+func (m *TOTPManager) storeNewSecret(apiKeyID int64, accountName, issuer string, enabled bool) (*otp.Key, error) {
 	// Generate TOTP key
 	key, err := totp.Generate(totp.GenerateOpts{
 		Issuer:      issuer,
@@ -34,48 +149,89 @@ func (m *TOTPManager) GenerateTOTP(apiKeyID int64, accountName, issuer string) (
 		Digits:      otp.DigitsSix,
 	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate TOTP: %w", err)
+		return nil, fmt.Errorf("failed to generate TOTP: %w", err)
+	}
+
+	storedSecret, keyID, err := m.sealSecret(key.Secret())
+	if err != nil {
+		return nil, err
+	}
+
+	// Store in database
+	_, err = m.db.Exec(`
+		INSERT INTO api_key_2fa (api_key_id, totp_secret, secret_key_id, backup_codes, is_enabled, failed_attempts, locked_until)
+		VALUES (?, ?, ?, '', ?, 0, NULL)
+		ON CONFLICT(api_key_id) DO UPDATE SET
+			totp_secret = excluded.totp_secret,
+			secret_key_id = excluded.secret_key_id,
+			backup_codes = '',
+			is_enabled = excluded.is_enabled,
+			failed_attempts = 0,
+			locked_until = NULL
+	`, apiKeyID, storedSecret, keyID, enabled)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to store TOTP: %w", err)
 	}
 
-	// Generate backup codes
+	return key, nil
+}
+
+// RegenerateBackupCodes replaces all backup codes for an API key
+func (m *TOTPManager) RegenerateBackupCodes(apiKeyID int64) ([]string, error) {
 	backupCodes := make([]string, 10)
+	hashes := make([]string, 10)
 	for i := 0; i < 10; i++ {
 		code, err := generateBackupCode()
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to generate backup code: %w", err)
+			return nil, fmt.Errorf("failed to generate backup code: %w", err)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash backup code: %w", err)
 		}
 		backupCodes[i] = code
+		hashes[i] = string(hash)
 	}
 
-	// Store in database
-	backupCodesStr := strings.Join(backupCodes, ",")
-	_, err = m.db.Exec(`
-		INSERT INTO api_key_2fa (api_key_id, totp_secret, backup_codes, is_enabled)
-		VALUES (?, ?, ?, 1)
-		ON CONFLICT(api_key_id) DO UPDATE SET
-			totp_secret = excluded.totp_secret,
-			backup_codes = excluded.backup_codes,
-			is_enabled = 1
-	`, apiKeyID, key.Secret(), backupCodesStr)
-
+	tx, err := m.db.Begin()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to store TOTP: %w", err)
+		return nil, fmt.Errorf("failed to store backup codes: %w", err)
 	}
+	defer tx.Rollback()
 
-	return key, backupCodes, nil
+	if _, err := tx.Exec("DELETE FROM api_key_2fa_backup_codes WHERE api_key_id = ?", apiKeyID); err != nil {
+		return nil, fmt.Errorf("failed to store backup codes: %w", err)
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec(
+			"INSERT INTO api_key_2fa_backup_codes (api_key_id, code_hash) VALUES (?, ?)",
+			apiKeyID, hash,
+		); err != nil {
+			return nil, fmt.Errorf("failed to store backup codes: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to store backup codes: %w", err)
+	}
+
+	return backupCodes, nil
 }
 
 // ValidateTOTP validates a TOTP code for an API key
 func (m *TOTPManager) ValidateTOTP(apiKeyID int64, code string) (bool, error) {
-	var secret string
-	var backupCodes string
+	var storedSecret string
+	var keyID sql.NullString
+	var legacyBackupCodes sql.NullString
 	var isEnabled bool
+	var lockedUntil sql.NullTime
 
 	err := m.db.QueryRow(`
-		SELECT totp_secret, backup_codes, is_enabled
+		SELECT totp_secret, secret_key_id, backup_codes, is_enabled, locked_until
 		FROM api_key_2fa
 		WHERE api_key_id = ?
-	`, apiKeyID).Scan(&secret, &backupCodes, &isEnabled)
+	`, apiKeyID).Scan(&storedSecret, &keyID, &legacyBackupCodes, &isEnabled, &lockedUntil)
 
 	if err == sql.ErrNoRows {
 		return false, fmt.Errorf("2FA not configured for this API key")
@@ -88,45 +244,290 @@ func (m *TOTPManager) ValidateTOTP(apiKeyID int64, code string) (bool, error) {
 		return false, fmt.Errorf("2FA is disabled for this API key")
 	}
 
-	// Try TOTP code first
+	if lockedUntil.Valid && time.Now().Before(lockedUntil.Time) {
+		return false, ErrTOTPLocked
+	}
+
+	secret, err := m.openSecret(storedSecret, keyID.String)
+	if err != nil {
+		return false, err
+	}
+
+	// Move rows written before encryption or hashing existed
+	if err := m.upgradeRow(apiKeyID, secret, keyID.String, legacyBackupCodes.String); err != nil {
+		return false, err
+	}
+
+	// Try TOTP code first, then a single-use backup code
 	valid := totp.Validate(code, secret)
+	if !valid {
+		valid, err = m.consumeBackupCode(apiKeyID, code)
+		if err != nil {
+			return false, err
+		}
+	}
+
 	if valid {
+		m.db.Exec("UPDATE api_key_2fa SET failed_attempts = 0, locked_until = NULL WHERE api_key_id = ?", apiKeyID)
 		return true, nil
 	}
 
-	// Try backup codes
-	codes := strings.Split(backupCodes, ",")
-	for i, backupCode := range codes {
-		if backupCode == code {
-			// Remove used backup code
-			codes = append(codes[:i], codes[i+1:]...)
-			newBackupCodes := strings.Join(codes, ",")
+	if err := m.recordFailure(apiKeyID); err != nil {
+		return false, err
+	}
 
-			_, err := m.db.Exec(`
-				UPDATE api_key_2fa
-				SET backup_codes = ?
-				WHERE api_key_id = ?
-			`, newBackupCodes, apiKeyID)
+	return false, fmt.Errorf("invalid TOTP code")
+}
 
-			if err != nil {
-				return false, fmt.Errorf("failed to update backup codes: %w", err)
-			}
+// consumeBackupCode marks a matching unused backup code as used
+func (m *TOTPManager) consumeBackupCode(apiKeyID int64, code string) (bool, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 9 || code[4] != '-' {
+		return false, nil
+	}
 
-			return true, nil
+	rows, err := m.db.Query(`
+		SELECT id, code_hash
+		FROM api_key_2fa_backup_codes
+		WHERE api_key_id = ? AND used_at IS NULL
+	`, apiKeyID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get backup codes: %w", err)
+	}
+
+	matchID := int64(0)
+	for rows.Next() {
+		var id int64
+		var hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+			matchID = id
+			break
 		}
 	}
+	rows.Close()
 
-	return false, fmt.Errorf("invalid TOTP code")
+	if matchID == 0 {
+		return false, nil
+	}
+
+	// Only one concurrent request may consume a code
+	result, err := m.db.Exec(
+		"UPDATE api_key_2fa_backup_codes SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now(), matchID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update backup codes: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// recordFailure counts a failed attempt and locks 2FA once the limit is hit
+func (m *TOTPManager) recordFailure(apiKeyID int64) error {
+	if m.options.MaxFailedAttempts <= 0 {
+		return nil
+	}
+
+	var failures int
+	err := m.db.QueryRow(`
+		UPDATE api_key_2fa
+		SET failed_attempts = COALESCE(failed_attempts, 0) + 1
+		WHERE api_key_id = ?
+		RETURNING failed_attempts
+	`, apiKeyID).Scan(&failures)
+	if err != nil {
+		return fmt.Errorf("failed to record 2FA failure: %w", err)
+	}
+
+	if failures >= m.options.MaxFailedAttempts {
+		if _, err := m.db.Exec(
+			"UPDATE api_key_2fa SET failed_attempts = 0, locked_until = ? WHERE api_key_id = ?",
+			time.Now().Add(m.options.LockoutDuration), apiKeyID,
+		); err != nil {
+			return fmt.Errorf("failed to lock 2FA: %w", err)
+		}
+		return ErrTOTPLocked
+	}
+
+	return nil
+}
+
+// UnlockTOTP clears a 2FA lockout for an API key
+func (m *TOTPManager) UnlockTOTP(apiKeyID int64) error {
+	_, err := m.db.Exec(`
+		UPDATE api_key_2fa
+		SET failed_attempts = 0, locked_until = NULL
+		WHERE api_key_id = ?
+	`, apiKeyID)
+
+	return err
+}
+
+// ReencryptSecrets re-seals every TOTP secret not under the primary master
+// key (including plaintext rows) and returns how many rows were rewritten
+func (m *TOTPManager) ReencryptSecrets() (int, error) {
+	if m.options.Keyring == nil {
+		return 0, fmt.Errorf("no master key configured")
+	}
+
+	rows, err := m.db.Query(`
+		SELECT api_key_id, totp_secret, secret_key_id
+		FROM api_key_2fa
+		WHERE secret_key_id IS NULL OR secret_key_id != ?
+	`, m.options.Keyring.PrimaryID())
+	if err != nil {
+		return 0, fmt.Errorf("failed to query TOTP secrets: %w", err)
+	}
+
+	type pending struct {
+		apiKeyID int64
+		secret   string
+		keyID    string
+	}
+	var stale []pending
+	for rows.Next() {
+		var p pending
+		var keyID sql.NullString
+		if err := rows.Scan(&p.apiKeyID, &p.secret, &keyID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to query TOTP secrets: %w", err)
+		}
+		p.keyID = keyID.String
+		stale = append(stale, p)
+	}
+	rows.Close()
+
+	for i, p := range stale {
+		secret, err := m.openSecret(p.secret, p.keyID)
+		if err != nil {
+			return i, fmt.Errorf("failed to re-encrypt secret for key %d: %w", p.apiKeyID, err)
+		}
+		if err := m.storeSecret(p.apiKeyID, secret); err != nil {
+			return i, err
+		}
+	}
+
+	return len(stale), nil
+}
+
+// upgradeRow encrypts a plaintext secret and hashes legacy comma-joined backup codes
+func (m *TOTPManager) upgradeRow(apiKeyID int64, secret, keyID, legacyBackupCodes string) error {
+	if keyID == "" && m.options.Keyring != nil {
+		if err := m.storeSecret(apiKeyID, secret); err != nil {
+			return err
+		}
+	}
+
+	if legacyBackupCodes == "" {
+		return nil
+	}
+
+	for _, code := range strings.Split(legacyBackupCodes, ",") {
+		if code == "" {
+			continue
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash backup code: %w", err)
+		}
+		if _, err := m.db.Exec(
+			"INSERT INTO api_key_2fa_backup_codes (api_key_id, code_hash) VALUES (?, ?)",
+			apiKeyID, string(hash),
+		); err != nil {
+			return fmt.Errorf("failed to store backup codes: %w", err)
+		}
+	}
+
+	if _, err := m.db.Exec("UPDATE api_key_2fa SET backup_codes = '' WHERE api_key_id = ?", apiKeyID); err != nil {
+		return fmt.Errorf("failed to update backup codes: %w", err)
+	}
+
+	return nil
+}
+
+// storeSecret seals and writes a TOTP secret under the primary master key
+func (m *TOTPManager) storeSecret(apiKeyID int64, secret string) error {
+	storedSecret, keyID, err := m.sealSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.db.Exec(
+		"UPDATE api_key_2fa SET totp_secret = ?, secret_key_id = ? WHERE api_key_id = ?",
+		storedSecret, keyID, apiKeyID,
+	); err != nil {
+		return fmt.Errorf("failed to store TOTP: %w", err)
+	}
+
+	return nil
+}
+
+// sealSecret encrypts a secret when a keyring is configured. Without one it
+// refuses to write plaintext next to encrypted secrets: the server holds a
+// master key this process did not load.
+func (m *TOTPManager) sealSecret(secret string) (string, any, error) {
+	if m.options.Keyring == nil {
+		if err := refuseUnencrypted(m.db, "api_key_2fa"); err != nil {
+			return "", nil, err
+		}
+		return secret, nil, nil
+	}
+
+	envelope, keyID, err := m.options.Keyring.Seal([]byte(secret))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+	return envelope, keyID, nil
+}
+
+// refuseUnencrypted fails if table already holds secrets encrypted under a
+// master key, so a process without the key never stores plaintext among them
+func refuseUnencrypted(q storage.Querier, table string) error {
+	var keyID string
+	err := q.QueryRow("SELECT secret_key_id FROM " + table + " WHERE secret_key_id IS NOT NULL LIMIT 1").Scan(&keyID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check secret encryption: %w", err)
+	}
+	return fmt.Errorf("secrets in %s are encrypted under master key %s; configure the master key (auth.totp.master_key_file) to write new ones", table, keyID)
+}
+
+// openSecret decrypts a stored secret; an empty key ID means plaintext
+func (m *TOTPManager) openSecret(storedSecret, keyID string) (string, error) {
+	if keyID == "" {
+		return storedSecret, nil
+	}
+	if m.options.Keyring == nil {
+		return "", fmt.Errorf("TOTP secret is encrypted but no master key is configured")
+	}
+
+	secret, err := m.options.Keyring.Open(storedSecret, keyID)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	return string(secret), nil
 }
 
-// DisableTOTP disables 2FA for an API key
+// DisableTOTP disables 2FA for an API key and discards its backup codes
 func (m *TOTPManager) DisableTOTP(apiKeyID int64) error {
 	_, err := m.db.Exec(`
 		UPDATE api_key_2fa
-		SET is_enabled = 0
+		SET is_enabled = FALSE
 		WHERE api_key_id = ?
 	`, apiKeyID)
+	if err != nil {
+		return err
+	}
 
+	_, err = m.db.Exec("DELETE FROM api_key_2fa_backup_codes WHERE api_key_id = ?", apiKeyID)
 	return err
 }
 
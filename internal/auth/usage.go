@@ -0,0 +1,460 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tags"
+)
+
+// usageDayFormat is the layout of usage days and date-range parameters
+const usageDayFormat = "2006-01-02"
+
+// defaultUsageDays is the date range of a usage query without from
+const defaultUsageDays = 30
+
+// UsageRecord is the usage of one completed request
+type UsageRecord struct {
+	RequestID     string            `json:"request_id"`
+	APIKeyID      int64             `json:"api_key_id,omitempty"`
+	TeamID        int64             `json:"team_id,omitempty"`
+	Provider      string            `json:"provider"`
+	Model         string            `json:"model"`
+	ProviderModel string            `json:"provider_model,omitempty"`
+	InputTokens   int               `json:"input_tokens"`
+	OutputTokens  int               `json:"output_tokens"`
+	CostUSD       float64           `json:"cost_usd"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	Timestamp     time.Time         `json:"timestamp"`
+}
+
+// RecordUsage stores a usage record and adds it to its day's rollup
+func (db *APIKeyDB) RecordUsage(record *UsageRecord) error {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	tags := "{}"
+	if len(record.Tags) > 0 {
+		data, err := json.Marshal(record.Tags)
+		if err != nil {
+			return fmt.Errorf("failed to encode usage tags: %w", err)
+		}
+		tags = string(data)
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO usage_records (request_id, api_key_id, team_id, provider, model, provider_model,
+			input_tokens, output_tokens, cost_usd, tags, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, record.RequestID, nullableID(record.APIKeyID), nullableID(record.TeamID), record.Provider, record.Model,
+		record.ProviderModel, record.InputTokens, record.OutputTokens, record.CostUSD, tags, record.Timestamp.UTC(),
+	); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO usage_daily (day, api_key_id, team_id, provider, model, requests, input_tokens, output_tokens, cost_usd)
+		VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?)
+		ON CONFLICT(day, api_key_id, team_id, provider, model) DO UPDATE SET
+			requests = usage_daily.requests + 1,
+			input_tokens = usage_daily.input_tokens + excluded.input_tokens,
+			output_tokens = usage_daily.output_tokens + excluded.output_tokens,
+			cost_usd = usage_daily.cost_usd + excluded.cost_usd
+	`, record.Timestamp.UTC().Format(usageDayFormat), record.APIKeyID, record.TeamID, record.Provider, record.Model,
+		record.InputTokens, record.OutputTokens, record.CostUSD,
+	); err != nil {
+		return fmt.Errorf("failed to update usage rollup: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+// Usage group-by dimensions
+const (
+	UsageGroupDay      = "day"
+	UsageGroupKey      = "key"
+	UsageGroupTeam     = "team"
+	UsageGroupProvider = "provider"
+	UsageGroupModel    = "model"
+
+	// UsageGroupTag prefixes a tag key to group by its values, e.g.
+	// "tag:project"
+	UsageGroupTag = "tag:"
+)
+
+// usageGroupColumns maps group-by dimensions to rollup columns
+var usageGroupColumns = map[string]string{
+	UsageGroupDay:      "day",
+	UsageGroupKey:      "api_key_id",
+	UsageGroupTeam:     "team_id",
+	UsageGroupProvider: "provider",
+	UsageGroupModel:    "model",
+}
+
+// UsageQuery selects and groups daily usage. Zero filters match everything.
+type UsageQuery struct {
+	APIKeyID int64
+	TeamID   int64
+	Provider string
+	Model    string
+
+	// Tags matches requests carrying all of these tags
+	Tags map[string]string
+
+	// From and To are inclusive UTC days
+	From time.Time
+	To   time.Time
+
+	// GroupBy lists the dimensions to total by, in output order
+	GroupBy []string
+}
+
+// UsageRow is the usage total of one group. Only the grouped dimensions
+// are set.
+type UsageRow struct {
+	Day      string `json:"day,omitempty"`
+	APIKeyID int64  `json:"api_key_id,omitempty"`
+	TeamID   int64  `json:"team_id,omitempty"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+
+	// Tags holds the values of grouped tags; requests without the tag
+	// total under an empty value
+	Tags map[string]string `json:"tags,omitempty"`
+
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// QueryUsage totals the usage matching the query by its groups. Queries
+// without tags read the daily rollups; tag filters and groups read the
+// per-request records, which carry the tags.
+func (db *APIKeyDB) QueryUsage(q UsageQuery) ([]UsageRow, error) {
+	byTag := len(q.Tags) > 0
+	for _, group := range q.GroupBy {
+		if tagKey, ok := strings.CutPrefix(group, UsageGroupTag); ok {
+			if !validTagKey(tagKey) {
+				return nil, fmt.Errorf("invalid usage group %q", group)
+			}
+			byTag = true
+		} else if _, ok := usageGroupColumns[group]; !ok {
+			return nil, fmt.Errorf("unknown usage group %q", group)
+		}
+	}
+	for key := range q.Tags {
+		if !validTagKey(key) {
+			return nil, fmt.Errorf("invalid usage tag %q", key)
+		}
+	}
+
+	table := "usage_daily"
+	column := func(group string) string { return usageGroupColumns[group] }
+	totals := []string{"COALESCE(SUM(requests), 0)"}
+	if byTag {
+		table = "usage_records"
+		column = func(group string) string { return usageRecordColumn(db.db.Dialect(), group) }
+		totals = []string{"COUNT(*)"}
+	}
+	totals = append(totals, "COALESCE(SUM(input_tokens), 0)",
+		"COALESCE(SUM(output_tokens), 0)", "COALESCE(SUM(cost_usd), 0)")
+
+	var columns []string
+	for _, group := range q.GroupBy {
+		columns = append(columns, column(group))
+	}
+
+	var where []string
+	var args []any
+	if q.APIKeyID != 0 {
+		where = append(where, "api_key_id = ?")
+		args = append(args, q.APIKeyID)
+	}
+	if q.TeamID != 0 {
+		where = append(where, "team_id = ?")
+		args = append(args, q.TeamID)
+	}
+	if q.Provider != "" {
+		where = append(where, "provider = ?")
+		args = append(args, q.Provider)
+	}
+	if q.Model != "" {
+		where = append(where, "model = ?")
+		args = append(args, q.Model)
+	}
+	for _, key := range sortedTagKeys(q.Tags) {
+		where = append(where, column(UsageGroupTag+key)+" = ?")
+		args = append(args, q.Tags[key])
+	}
+	if !byTag {
+		if !q.From.IsZero() {
+			where = append(where, "day >= ?")
+			args = append(args, q.From.UTC().Format(usageDayFormat))
+		}
+		if !q.To.IsZero() {
+			where = append(where, "day <= ?")
+			args = append(args, q.To.UTC().Format(usageDayFormat))
+		}
+	} else {
+		if !q.From.IsZero() {
+			where = append(where, "created_at >= ?")
+			args = append(args, q.From.UTC().Truncate(24*time.Hour))
+		}
+		if !q.To.IsZero() {
+			where = append(where, "created_at < ?")
+			args = append(args, q.To.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1))
+		}
+	}
+
+	selected := append(append([]string(nil), columns...), totals...)
+	query := "SELECT " + strings.Join(selected, ", ") + " FROM " + table
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if len(columns) > 0 {
+		query += " GROUP BY " + strings.Join(columns, ", ") + " ORDER BY " + strings.Join(columns, ", ")
+	}
+
+	rows, err := db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %w", err)
+	}
+	defer rows.Close()
+
+	result := []UsageRow{}
+	for rows.Next() {
+		var row UsageRow
+		tagValues := make([]string, len(q.GroupBy))
+		dest := make([]any, 0, len(selected))
+		for i, group := range q.GroupBy {
+			if strings.HasPrefix(group, UsageGroupTag) {
+				dest = append(dest, &tagValues[i])
+			} else {
+				dest = append(dest, row.field(group))
+			}
+		}
+		dest = append(dest, &row.Requests, &row.InputTokens, &row.OutputTokens, &row.CostUSD)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to read usage: %w", err)
+		}
+		for i, group := range q.GroupBy {
+			if tagKey, ok := strings.CutPrefix(group, UsageGroupTag); ok {
+				if row.Tags == nil {
+					row.Tags = make(map[string]string)
+				}
+				row.Tags[tagKey] = tagValues[i]
+			}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+	return result, nil
+}
+
+// usageRecordColumn returns the usage_records expression of a group-by
+// dimension. Tag keys are validated before they reach the query text.
+func usageRecordColumn(dialect storage.Dialect, group string) string {
+	if tagKey, ok := strings.CutPrefix(group, UsageGroupTag); ok {
+		if dialect == storage.DialectPostgres {
+			return "COALESCE(CAST(tags AS jsonb) ->> '" + tagKey + "', '')"
+		}
+		return `COALESCE(json_extract(tags, '$."` + tagKey + `"'), '')`
+	}
+
+	switch group {
+	case UsageGroupDay:
+		if dialect == storage.DialectPostgres {
+			return "to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')"
+		}
+		return "substr(created_at, 1, 10)"
+	case UsageGroupKey:
+		return "COALESCE(api_key_id, 0)"
+	case UsageGroupTeam:
+		return "COALESCE(team_id, 0)"
+	default:
+		return usageGroupColumns[group]
+	}
+}
+
+// validTagKey reports whether key is a valid tag key
+func validTagKey(key string) bool {
+	return tags.Validate(map[string]string{key: "x"}) == nil
+}
+
+// sortedTagKeys returns the keys of a tag set in order
+func sortedTagKeys(tagSet map[string]string) []string {
+	keys := make([]string, 0, len(tagSet))
+	for key := range tagSet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// field returns the scan destination for a group-by dimension
+func (r *UsageRow) field(group string) any {
+	switch group {
+	case UsageGroupDay:
+		return &r.Day
+	case UsageGroupKey:
+		return &r.APIKeyID
+	case UsageGroupTeam:
+		return &r.TeamID
+	case UsageGroupProvider:
+		return &r.Provider
+	default:
+		return &r.Model
+	}
+}
+
+// ParseUsageQuery reads a usage query from query parameters: from and to
+// (YYYY-MM-DD, inclusive; the last 30 days by default), group_by (comma
+// separated: day, key, team, provider, model or tag:<key>; day by default),
+// api_key_id, team_id, provider, model and tag (key=value, repeatable)
+func ParseUsageQuery(query url.Values, now time.Time) (UsageQuery, error) {
+	var q UsageQuery
+	var err error
+
+	for _, p := range []struct {
+		param string
+		dest  *int64
+	}{
+		{"api_key_id", &q.APIKeyID},
+		{"team_id", &q.TeamID},
+	} {
+		if v := query.Get(p.param); v != "" {
+			if *p.dest, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return q, fmt.Errorf("invalid %s", p.param)
+			}
+		}
+	}
+
+	q.To = now.UTC().Truncate(24 * time.Hour)
+	if v := query.Get("to"); v != "" {
+		if q.To, err = time.Parse(usageDayFormat, v); err != nil {
+			return q, errors.New("invalid to (use YYYY-MM-DD)")
+		}
+	}
+	q.From = q.To.AddDate(0, 0, -(defaultUsageDays - 1))
+	if v := query.Get("from"); v != "" {
+		if q.From, err = time.Parse(usageDayFormat, v); err != nil {
+			return q, errors.New("invalid from (use YYYY-MM-DD)")
+		}
+	}
+	if q.From.After(q.To) {
+		return q, errors.New("from is after to")
+	}
+
+	q.GroupBy = []string{UsageGroupDay}
+	if v := query.Get("group_by"); v != "" {
+		q.GroupBy = nil
+		seen := make(map[string]bool)
+		for _, group := range strings.Split(v, ",") {
+			group = strings.TrimSpace(group)
+			_, known := usageGroupColumns[group]
+			if tagKey, ok := strings.CutPrefix(group, UsageGroupTag); ok {
+				known = validTagKey(tagKey)
+			}
+			if !known {
+				return q, fmt.Errorf("invalid group_by %q (use day, key, team, provider, model or tag:<key>)", group)
+			}
+			if !seen[group] {
+				seen[group] = true
+				q.GroupBy = append(q.GroupBy, group)
+			}
+		}
+	}
+
+	for _, v := range query["tag"] {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return q, fmt.Errorf("invalid tag %q (use key=value)", v)
+		}
+		if q.Tags == nil {
+			q.Tags = make(map[string]string)
+		}
+		q.Tags[key] = value
+	}
+	if err := tags.Validate(q.Tags); err != nil {
+		return q, err
+	}
+
+	q.Provider = query.Get("provider")
+	q.Model = query.Get("model")
+	return q, nil
+}
+
+// WriteUsageCSV writes usage rows as CSV with a column per grouped
+// dimension followed by the totals
+func WriteUsageCSV(w io.Writer, groupBy []string, rows []UsageRow) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, 0, len(groupBy)+4)
+	for _, group := range groupBy {
+		if strings.HasPrefix(group, UsageGroupTag) {
+			header = append(header, group)
+		} else {
+			header = append(header, usageGroupColumns[group])
+		}
+	}
+	header = append(header, "requests", "input_tokens", "output_tokens", "cost_usd")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		record := make([]string, 0, len(header))
+		for _, group := range groupBy {
+			switch group {
+			case UsageGroupDay:
+				record = append(record, row.Day)
+			case UsageGroupKey:
+				record = append(record, strconv.FormatInt(row.APIKeyID, 10))
+			case UsageGroupTeam:
+				record = append(record, strconv.FormatInt(row.TeamID, 10))
+			case UsageGroupProvider:
+				record = append(record, csvSafe(row.Provider))
+			case UsageGroupModel:
+				record = append(record, csvSafe(row.Model))
+			default:
+				record = append(record, csvSafe(row.Tags[strings.TrimPrefix(group, UsageGroupTag)]))
+			}
+		}
+		record = append(record,
+			strconv.FormatInt(row.Requests, 10),
+			strconv.FormatInt(row.InputTokens, 10),
+			strconv.FormatInt(row.OutputTokens, 10),
+			strconv.FormatFloat(row.CostUSD, 'f', 6, 64),
+		)
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
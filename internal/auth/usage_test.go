@@ -0,0 +1,204 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUsageRollups(t *testing.T) {
+	db := newAuditTestDB(t)
+
+	day1 := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	for _, record := range []UsageRecord{
+		{APIKeyID: 1, TeamID: 7, Provider: "openai", Model: "gpt-4", InputTokens: 10, OutputTokens: 20, CostUSD: 0.5, Timestamp: day1},
+		{APIKeyID: 1, TeamID: 7, Provider: "openai", Model: "gpt-4", InputTokens: 5, OutputTokens: 5, CostUSD: 0.25, Timestamp: day1.Add(time.Hour)},
+		{APIKeyID: 2, TeamID: 7, Provider: "bedrock", Model: "claude-3", InputTokens: 100, OutputTokens: 1, CostUSD: 1, Timestamp: day1},
+		{APIKeyID: 1, TeamID: 7, Provider: "openai", Model: "gpt-4", InputTokens: 1, OutputTokens: 1, Timestamp: day2, Tags: map[string]string{"project": "x"}},
+		{Provider: "openai", Model: "gpt-4", InputTokens: 3, OutputTokens: 3, Timestamp: day2},
+	} {
+		record.RequestID = "req"
+		if err := db.RecordUsage(&record); err != nil {
+			t.Fatalf("RecordUsage failed: %v", err)
+		}
+	}
+
+	rows, err := db.QueryUsage(UsageQuery{APIKeyID: 1, GroupBy: []string{UsageGroupDay}})
+	if err != nil {
+		t.Fatalf("QueryUsage failed: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Day != "2025-03-01" || rows[0].Requests != 2 ||
+		rows[0].InputTokens != 15 || rows[0].OutputTokens != 25 || rows[0].CostUSD != 0.75 {
+		t.Fatalf("Unexpected daily usage %+v", rows)
+	}
+
+	rows, err = db.QueryUsage(UsageQuery{From: day1, To: day1, GroupBy: []string{UsageGroupProvider, UsageGroupModel}})
+	if err != nil {
+		t.Fatalf("QueryUsage failed: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Provider != "bedrock" || rows[1].Model != "gpt-4" || rows[1].Requests != 2 || rows[1].Day != "" {
+		t.Fatalf("Unexpected provider usage %+v", rows)
+	}
+
+	rows, err = db.QueryUsage(UsageQuery{TeamID: 7})
+	if err != nil {
+		t.Fatalf("QueryUsage failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Requests != 4 || rows[0].InputTokens != 116 {
+		t.Fatalf("Unexpected team total %+v", rows)
+	}
+
+	if _, err := db.QueryUsage(UsageQuery{GroupBy: []string{"tags"}}); err == nil {
+		t.Error("Expected an unknown group to be rejected")
+	}
+	if _, err := db.QueryUsage(UsageQuery{GroupBy: []string{"tag:x') --"}}); err == nil {
+		t.Error("Expected an invalid tag key to be rejected")
+	}
+}
+
+func TestUsageByTag(t *testing.T) {
+	db := newAuditTestDB(t)
+
+	day1 := time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)
+	day2 := day1.Add(time.Hour)
+	for _, record := range []UsageRecord{
+		{APIKeyID: 1, Provider: "openai", Model: "gpt-4", InputTokens: 10, CostUSD: 0.5, Timestamp: day1,
+			Tags: map[string]string{"project": "search", "env": "prod"}},
+		{APIKeyID: 2, Provider: "openai", Model: "gpt-4", InputTokens: 20, CostUSD: 1, Timestamp: day2,
+			Tags: map[string]string{"project": "search", "env": "dev"}},
+		{APIKeyID: 1, Provider: "bedrock", Model: "claude-3", InputTokens: 40, CostUSD: 2, Timestamp: day2,
+			Tags: map[string]string{"project": "ads"}},
+		{Provider: "bedrock", Model: "claude-3", InputTokens: 80, Timestamp: day2},
+	} {
+		record.RequestID = "req"
+		if err := db.RecordUsage(&record); err != nil {
+			t.Fatalf("RecordUsage failed: %v", err)
+		}
+	}
+
+	t.Run("group by tag", func(t *testing.T) {
+		rows, err := db.QueryUsage(UsageQuery{GroupBy: []string{UsageGroupTag + "project"}})
+		if err != nil {
+			t.Fatalf("QueryUsage failed: %v", err)
+		}
+		want := []UsageRow{
+			{Tags: map[string]string{"project": ""}, Requests: 1, InputTokens: 80},
+			{Tags: map[string]string{"project": "ads"}, Requests: 1, InputTokens: 40, CostUSD: 2},
+			{Tags: map[string]string{"project": "search"}, Requests: 2, InputTokens: 30, CostUSD: 1.5},
+		}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("Expected %+v, got %+v", want, rows)
+		}
+	})
+
+	t.Run("group by day and tag", func(t *testing.T) {
+		rows, err := db.QueryUsage(UsageQuery{
+			APIKeyID: 1,
+			GroupBy:  []string{UsageGroupDay, UsageGroupKey, UsageGroupTag + "project"},
+		})
+		if err != nil {
+			t.Fatalf("QueryUsage failed: %v", err)
+		}
+		if len(rows) != 2 || rows[0].Day != "2025-03-01" || rows[0].APIKeyID != 1 || rows[0].Tags["project"] != "search" ||
+			rows[1].Day != "2025-03-02" || rows[1].Tags["project"] != "ads" {
+			t.Errorf("Unexpected usage %+v", rows)
+		}
+	})
+
+	t.Run("filter by tags", func(t *testing.T) {
+		rows, err := db.QueryUsage(UsageQuery{
+			Tags:    map[string]string{"project": "search", "env": "dev"},
+			From:    day2,
+			To:      day2,
+			GroupBy: []string{UsageGroupKey},
+		})
+		if err != nil {
+			t.Fatalf("QueryUsage failed: %v", err)
+		}
+		if len(rows) != 1 || rows[0].APIKeyID != 2 || rows[0].Requests != 1 || rows[0].InputTokens != 20 {
+			t.Errorf("Unexpected usage %+v", rows)
+		}
+
+		rows, err = db.QueryUsage(UsageQuery{Tags: map[string]string{"project": "search"}, From: day1, To: day1})
+		if err != nil {
+			t.Fatalf("QueryUsage failed: %v", err)
+		}
+		if len(rows) != 1 || rows[0].Requests != 1 || rows[0].CostUSD != 0.5 {
+			t.Errorf("Expected only the first day's record, got %+v", rows)
+		}
+	})
+}
+
+func TestParseUsageQuery(t *testing.T) {
+	now := time.Date(2025, 3, 31, 15, 0, 0, 0, time.UTC)
+
+	q, err := ParseUsageQuery(url.Values{}, now)
+	if err != nil {
+		t.Fatalf("ParseUsageQuery failed: %v", err)
+	}
+	if got := q.From.Format(usageDayFormat); got != "2025-03-02" {
+		t.Errorf("Expected a 30-day default range, got from %s", got)
+	}
+	if len(q.GroupBy) != 1 || q.GroupBy[0] != UsageGroupDay {
+		t.Errorf("Expected day grouping by default, got %v", q.GroupBy)
+	}
+
+	q, err = ParseUsageQuery(url.Values{"group_by": {"team, model,team"}, "team_id": {"3"}}, now)
+	if err != nil {
+		t.Fatalf("ParseUsageQuery failed: %v", err)
+	}
+	if len(q.GroupBy) != 2 || q.GroupBy[0] != UsageGroupTeam || q.GroupBy[1] != UsageGroupModel || q.TeamID != 3 {
+		t.Errorf("Unexpected query %+v", q)
+	}
+
+	q, err = ParseUsageQuery(url.Values{"group_by": {"tag:project"}, "tag": {"env=prod", "team=ml"}}, now)
+	if err != nil {
+		t.Fatalf("ParseUsageQuery failed: %v", err)
+	}
+	if !reflect.DeepEqual(q.GroupBy, []string{"tag:project"}) ||
+		!reflect.DeepEqual(q.Tags, map[string]string{"env": "prod", "team": "ml"}) {
+		t.Errorf("Unexpected tag query %+v", q)
+	}
+
+	for _, bad := range []url.Values{
+		{"group_by": {"tenant"}},
+		{"from": {"2025-04-01"}},
+		{"to": {"yesterday"}},
+		{"api_key_id": {"x"}},
+		{"group_by": {"tag:Project"}},
+		{"tag": {"project"}},
+		{"tag": {"project=a b"}},
+	} {
+		if _, err := ParseUsageQuery(bad, now); err == nil {
+			t.Errorf("Expected %v to be rejected", bad)
+		}
+	}
+}
+
+func TestWriteUsageCSV(t *testing.T) {
+	var buf bytes.Buffer
+	rows := []UsageRow{{Day: "2025-03-01", Model: "=cmd", Requests: 2, InputTokens: 3, OutputTokens: 4, CostUSD: 0.5}}
+	if err := WriteUsageCSV(&buf, []string{UsageGroupDay, UsageGroupModel}, rows); err != nil {
+		t.Fatalf("WriteUsageCSV failed: %v", err)
+	}
+	want := "day,model,requests,input_tokens,output_tokens,cost_usd\n2025-03-01,'=cmd,2,3,4,0.500000\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected CSV:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	rows = []UsageRow{{Provider: "openai", Tags: map[string]string{"project": "search"}, Requests: 1}}
+	if err := WriteUsageCSV(&buf, []string{UsageGroupProvider, UsageGroupTag + "project"}, rows); err != nil {
+		t.Fatalf("WriteUsageCSV failed: %v", err)
+	}
+	want = "provider,tag:project,requests,input_tokens,output_tokens,cost_usd\nopenai,search,1,0,0,0.000000\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected CSV:\n%s", buf.String())
+	}
+}
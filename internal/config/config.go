@@ -0,0 +1,546 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package config holds the typed server configuration: listeners, TLS,
+// authentication, the auth database, providers and optional features. It is
+// read from a YAML file and overridden by the environment variables the
+// server has always accepted.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	TLS       TLSConfig       `yaml:"tls"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Providers ProvidersConfig `yaml:"providers"`
+	Features  FeaturesConfig  `yaml:"features"`
+
+	// envProblems are environment overrides that could not be parsed
+	envProblems []string
+}
+
+// ServerConfig configures the HTTP listeners and shutdown timing
+type ServerConfig struct {
+	Port    string `yaml:"port"`
+	TLSPort string `yaml:"tls_port"`
+	GinMode string `yaml:"gin_mode"` // debug, release or test
+
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	// WriteTimeout bounds a whole response, so it must outlast the longest
+	// streamed completion
+	WriteTimeout   time.Duration `yaml:"write_timeout"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes int           `yaml:"max_header_bytes"`
+
+	// ShutdownDelay keeps serving after readiness turns false so load
+	// balancers stop routing here before the listeners close
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	// ShutdownTimeout bounds the drain of in-flight and streaming requests
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// TLSConfig configures the HTTPS listener and mTLS client certificates
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+
+	// ClientCAFile and IdentityMapping are used by the mtls auth mode
+	ClientCAFile    string `yaml:"client_ca_file"`
+	IdentityMapping string `yaml:"identity_mapping"`
+}
+
+// AuthConfig selects how clients authenticate
+type AuthConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Mode       string `yaml:"mode"`
+	Require2FA bool   `yaml:"require_2fa"`
+
+	// APIKeyPepperFile holds the HMAC key for database API key hashes
+	APIKeyPepperFile string `yaml:"api_key_pepper_file"`
+
+	// BasicCredentials maps users to passwords for the basic mode
+	BasicCredentials map[string]string `yaml:"basic_credentials"`
+	// ServiceAccounts lists namespace/name accounts for the
+	// service_account mode
+	ServiceAccounts []string `yaml:"service_accounts"`
+
+	Database DatabaseConfig `yaml:"database"`
+
+	Lockout LockoutConfig `yaml:"lockout"`
+	Session SessionConfig `yaml:"session"`
+	TOTP    TOTPConfig    `yaml:"totp"`
+	SigV4   SigV4Config   `yaml:"sigv4"`
+	Audit   AuditConfig   `yaml:"audit"`
+}
+
+// DatabaseConfig selects the auth database
+type DatabaseConfig struct {
+	Backend string `yaml:"backend"` // sqlite, postgres or memory
+	Path    string `yaml:"path"`    // sqlite file; unset disables the database
+	DSN     string `yaml:"dsn"`     // postgres connection string
+	DSNFile string `yaml:"dsn_file"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+
+	// AutoMigrate applies pending migrations on startup; when false the
+	// server refuses to start until "migrate up" has run
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// Configured reports whether an auth database is configured. A postgres
+// backend always is; validation reports a missing DSN.
+func (d DatabaseConfig) Configured() bool {
+	return d.Backend != storage.BackendSQLite || d.Path != ""
+}
+
+// LockoutConfig configures brute-force protection for database-backed
+// authentication
+type LockoutConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Store keeps lockouts in the auth database, shared by all replicas,
+	// or in memory
+	Store string `yaml:"store"` // database or memory
+
+	Threshold    int           `yaml:"threshold"`
+	BaseDuration time.Duration `yaml:"base_duration"`
+	MaxDuration  time.Duration `yaml:"max_duration"`
+	ResetAfter   time.Duration `yaml:"reset_after"`
+}
+
+// SessionConfig configures session tokens and their client binding
+type SessionConfig struct {
+	Duration    time.Duration `yaml:"duration"`
+	IdleTimeout time.Duration `yaml:"idle_timeout"` // 0 disables
+	MaxPerKey   int           `yaml:"max_per_key"`  // 0 disables
+
+	BindIP        bool `yaml:"bind_ip"`
+	BindUserAgent bool `yaml:"bind_user_agent"`
+	// BindSubnetV4 and BindSubnetV6 are prefix lengths; 0 disables
+	BindSubnetV4 int `yaml:"bind_subnet_v4"`
+	BindSubnetV6 int `yaml:"bind_subnet_v6"`
+}
+
+// TOTPConfig configures 2FA secret encryption and lockouts
+type TOTPConfig struct {
+	// MasterKeyFile encrypts TOTP and SigV4 secrets at rest; previous keys still
+	// decrypt secrets until they are re-encrypted on startup
+	MasterKeyFile          string   `yaml:"master_key_file"`
+	PreviousMasterKeyFiles []string `yaml:"previous_master_key_files"`
+
+	MaxFailedAttempts int           `yaml:"max_failed_attempts"` // 0 disables
+	LockoutDuration   time.Duration `yaml:"lockout_duration"`
+}
+
+// SigV4Config configures verification of AWS SigV4 signed requests, for the
+// sigv4 auth mode and for the legacy Bedrock routes when Enabled
+type SigV4Config struct {
+	Enabled bool   `yaml:"enabled"`
+	Service string `yaml:"service"`
+	// Regions accepted in the credential scope; empty accepts any region
+	Regions              []string `yaml:"regions"`
+	AllowUnsignedPayload bool     `yaml:"allow_unsigned_payload"`
+}
+
+// AuditConfig configures audit log retention and signed checkpoints
+type AuditConfig struct {
+	// RetentionDays archives older records to ArchiveDir; 0 keeps everything
+	RetentionDays     int           `yaml:"retention_days"`
+	ArchiveDir        string        `yaml:"archive_dir"`
+	RetentionInterval time.Duration `yaml:"retention_interval"`
+
+	// SigningKeyFile signs the hash chain head every CheckpointInterval;
+	// VerifyKeyFiles are the public keys of retired signing keys
+	SigningKeyFile     string        `yaml:"signing_key_file"`
+	VerifyKeyFiles     []string      `yaml:"verify_key_files"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval"`
+}
+
+// ProvidersConfig holds provider settings for the model mapping's instances
+// named after their type, such as "bedrock"; it fills the settings their
+// declarations leave empty.
+type ProvidersConfig struct {
+	Bedrock   BedrockConfig   `yaml:"bedrock"`
+	Azure     AzureConfig     `yaml:"azure"`
+	OpenAI    OpenAIConfig    `yaml:"openai"`
+	Anthropic AnthropicConfig `yaml:"anthropic"`
+	Vertex    VertexConfig    `yaml:"vertex"`
+	IBM       IBMConfig       `yaml:"ibm"`
+	Oracle    OracleConfig    `yaml:"oracle"`
+}
+
+// BedrockConfig configures AWS Bedrock; credentials come from the AWS SDK chain
+type BedrockConfig struct {
+	Region string `yaml:"region"`
+}
+
+// AzureConfig configures Azure OpenAI
+type AzureConfig struct {
+	Endpoint   string `yaml:"endpoint"`
+	APIKey     string `yaml:"api_key"`
+	APIVersion string `yaml:"api_version"`
+}
+
+// OpenAIConfig configures OpenAI
+type OpenAIConfig struct {
+	APIKey  string `yaml:"api_key"`
+	BaseURL string `yaml:"base_url"`
+}
+
+// AnthropicConfig configures Anthropic
+type AnthropicConfig struct {
+	APIKey  string `yaml:"api_key"`
+	BaseURL string `yaml:"base_url"`
+}
+
+// VertexConfig configures Google Vertex AI; without an access token
+// Application Default Credentials are used
+type VertexConfig struct {
+	ProjectID   string `yaml:"project_id"`
+	Location    string `yaml:"location"`
+	AccessToken string `yaml:"access_token"`
+}
+
+// IBMConfig configures IBM watsonx.ai
+type IBMConfig struct {
+	APIKey    string `yaml:"api_key"`
+	ProjectID string `yaml:"project_id"`
+	BaseURL   string `yaml:"base_url"`
+}
+
+// OracleConfig configures Oracle Cloud AI
+type OracleConfig struct {
+	Endpoint      string `yaml:"endpoint"`
+	AuthToken     string `yaml:"auth_token"`
+	CompartmentID string `yaml:"compartment_id"`
+}
+
+// FeaturesConfig points at the feature config files and sets feature flags
+type FeaturesConfig struct {
+	ModelMapping  string `yaml:"model_mapping"`
+	DLP           string `yaml:"dlp"`
+	Transcripts   string `yaml:"transcripts"`
+	Notifications string `yaml:"notifications"`
+
+	// MetricsTenantLabel is the tenant label on request metrics: team, key
+	// or none
+	MetricsTenantLabel string `yaml:"metrics_tenant_label"`
+	// RequestTags is the cost-center tag allowlist, e.g.
+	// "project,env=dev|prod"; empty disables request tags
+	RequestTags string `yaml:"request_tags"`
+
+	Tracing TracingConfig `yaml:"tracing"`
+}
+
+// TracingConfig enables OTLP tracing; the exporter reads the standard OTEL_*
+// variables
+type TracingConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	ServiceName string `yaml:"service_name"`
+}
+
+// AuthModes are the accepted values of auth.mode
+var AuthModes = []string{"api_key", "basic", "service_account", "api_key_db", "session", "hybrid", "mtls", "sigv4"}
+
+// Default returns the configuration used for settings left unset
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:              "8080",
+			TLSPort:           "8443",
+			GinMode:           "release",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       60 * time.Second,
+			WriteTimeout:      10 * time.Minute,
+			IdleTimeout:       120 * time.Second,
+			MaxHeaderBytes:    64 << 10,
+			ShutdownDelay:     5 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		TLS: TLSConfig{
+			CertFile:        "/etc/tls/tls.crt",
+			KeyFile:         "/etc/tls/tls.key",
+			ClientCAFile:    "/etc/tls/client-ca.crt",
+			IdentityMapping: "configs/mtls-identities.yaml",
+		},
+		Auth: AuthConfig{
+			Mode: "api_key",
+			Database: DatabaseConfig{
+				Backend:     storage.BackendSQLite,
+				AutoMigrate: true,
+			},
+			Lockout: LockoutConfig{
+				Enabled:      true,
+				Store:        "database",
+				Threshold:    5,
+				BaseDuration: 30 * time.Second,
+				MaxDuration:  time.Hour,
+				ResetAfter:   time.Hour,
+			},
+			Session: SessionConfig{Duration: 12 * time.Hour},
+			TOTP: TOTPConfig{
+				MaxFailedAttempts: 5,
+				LockoutDuration:   15 * time.Minute,
+			},
+			SigV4: SigV4Config{Service: "bedrock"},
+			Audit: AuditConfig{
+				RetentionInterval:  time.Hour,
+				CheckpointInterval: time.Hour,
+			},
+		},
+		Providers: ProvidersConfig{
+			Bedrock:   BedrockConfig{Region: "us-east-1"},
+			Azure:     AzureConfig{APIVersion: "2024-02-15-preview"},
+			OpenAI:    OpenAIConfig{BaseURL: "https://api.openai.com/v1"},
+			Anthropic: AnthropicConfig{BaseURL: "https://api.anthropic.com/v1"},
+			Vertex:    VertexConfig{Location: "us-central1"},
+			IBM:       IBMConfig{BaseURL: "https://us-south.ml.cloud.ibm.com"},
+		},
+		Features: FeaturesConfig{
+			ModelMapping:       "configs/model-mapping.yaml",
+			MetricsTenantLabel: "team",
+			Tracing:            TracingConfig{ServiceName: "bedrock-iam-proxy"},
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file at path
+// (skipped when empty) with ${VAR} references expanded, and then the
+// environment variable overrides. Unknown keys in the file are an error;
+// call Validate for everything else.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read server config: %w", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader([]byte(os.ExpandEnv(string(data)))))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse server config: %w", err)
+		}
+	}
+
+	cfg.applyEnv(os.Getenv)
+	return cfg, nil
+}
+
+// Validate reports every problem with the configuration at once
+func (c *Config) Validate() error {
+	problems := c.Problems()
+	if len(problems) > 0 {
+		return fmt.Errorf("configuration validation failed:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// Problems lists everything wrong with the configuration, including
+// environment overrides that could not be parsed
+func (c *Config) Problems() []string {
+	problems := append([]string(nil), c.envProblems...)
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Listeners
+	checkPort := func(name, port string) {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			add("%s %q is not a valid port", name, port)
+		}
+	}
+	checkPort("server.port", c.Server.Port)
+	if c.TLS.Enabled {
+		checkPort("server.tls_port", c.Server.TLSPort)
+		if c.Server.TLSPort == c.Server.Port {
+			add("server.tls_port must differ from server.port")
+		}
+	}
+	switch c.Server.GinMode {
+	case "debug", "release", "test":
+	default:
+		add("server.gin_mode %q must be debug, release or test", c.Server.GinMode)
+	}
+	for name, d := range map[string]time.Duration{
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+		"server.shutdown_delay":      c.Server.ShutdownDelay,
+		"server.shutdown_timeout":    c.Server.ShutdownTimeout,
+	} {
+		if d < 0 {
+			add("%s must not be negative", name)
+		}
+	}
+	if c.Server.MaxHeaderBytes < 1 {
+		add("server.max_header_bytes must be positive")
+	}
+
+	// TLS
+	if c.TLS.Enabled && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+		add("tls.cert_file and tls.key_file are required when TLS is enabled")
+	}
+
+	// Authentication
+	if !slices.Contains(AuthModes, c.Auth.Mode) {
+		add("auth.mode %q must be one of %s", c.Auth.Mode, strings.Join(AuthModes, ", "))
+	}
+	if c.Auth.Enabled {
+		switch c.Auth.Mode {
+		case "basic":
+			if len(c.Auth.BasicCredentials) == 0 {
+				add("auth.basic_credentials is required for the basic auth mode")
+			}
+		case "service_account":
+			if len(c.Auth.ServiceAccounts) == 0 {
+				add("auth.service_accounts is required for the service_account auth mode")
+			}
+		case "mtls":
+			if !c.TLS.Enabled {
+				add("the mtls auth mode requires tls.enabled")
+			}
+			if c.TLS.ClientCAFile == "" || c.TLS.IdentityMapping == "" {
+				add("tls.client_ca_file and tls.identity_mapping are required for the mtls auth mode")
+			}
+		case "api_key_db", "session", "hybrid", "sigv4":
+			if !c.Auth.Database.Configured() {
+				add("the %s auth mode requires an auth database (auth.database.path)", c.Auth.Mode)
+			}
+		}
+	}
+
+	// Auth database
+	db := c.Auth.Database
+	switch db.Backend {
+	case storage.BackendSQLite, storage.BackendMemory:
+	case storage.BackendPostgres:
+		if db.DSN == "" && db.DSNFile == "" {
+			add("auth.database.dsn or auth.database.dsn_file is required for the postgres backend")
+		}
+	default:
+		add("auth.database.backend %q must be sqlite, postgres or memory", db.Backend)
+	}
+	if db.MaxOpenConns < 0 {
+		add("auth.database.max_open_conns must not be negative")
+	}
+	if db.ConnMaxLifetime < 0 {
+		add("auth.database.conn_max_lifetime must not be negative")
+	}
+
+	// Brute-force lockouts
+	lockout := c.Auth.Lockout
+	switch lockout.Store {
+	case "database", "sqlite", "memory":
+	default:
+		add("auth.lockout.store %q must be database or memory", lockout.Store)
+	}
+	if lockout.Threshold < 1 {
+		add("auth.lockout.threshold must be at least 1")
+	}
+	for name, d := range map[string]time.Duration{
+		"auth.lockout.base_duration": lockout.BaseDuration,
+		"auth.lockout.max_duration":  lockout.MaxDuration,
+		"auth.lockout.reset_after":   lockout.ResetAfter,
+	} {
+		if d <= 0 {
+			add("%s must be positive", name)
+		}
+	}
+
+	// Sessions
+	session := c.Auth.Session
+	if session.Duration <= 0 {
+		add("auth.session.duration must be positive")
+	}
+	if session.IdleTimeout < 0 {
+		add("auth.session.idle_timeout must not be negative")
+	}
+	if session.MaxPerKey < 0 {
+		add("auth.session.max_per_key must not be negative")
+	}
+	if session.BindSubnetV4 < 0 || session.BindSubnetV4 > 32 {
+		add("auth.session.bind_subnet_v4 must be between 0 and 32")
+	}
+	if session.BindSubnetV6 < 0 || session.BindSubnetV6 > 128 {
+		add("auth.session.bind_subnet_v6 must be between 0 and 128")
+	}
+
+	// TOTP
+	totp := c.Auth.TOTP
+	if totp.MaxFailedAttempts < 0 {
+		add("auth.totp.max_failed_attempts must not be negative")
+	}
+	if totp.LockoutDuration < 0 {
+		add("auth.totp.lockout_duration must not be negative")
+	}
+	if len(totp.PreviousMasterKeyFiles) > 0 && totp.MasterKeyFile == "" {
+		add("auth.totp.previous_master_key_files requires auth.totp.master_key_file")
+	}
+
+	// SigV4
+	if c.Auth.SigV4.Service == "" {
+		add("auth.sigv4.service is required")
+	}
+	if c.Auth.SigV4.Enabled && !c.Auth.Database.Configured() {
+		add("auth.sigv4.enabled requires an auth database (auth.database.path)")
+	}
+
+	// Audit
+	audit := c.Auth.Audit
+	if audit.RetentionDays < 0 {
+		add("auth.audit.retention_days must not be negative")
+	}
+	if audit.RetentionDays > 0 && audit.ArchiveDir == "" {
+		add("auth.audit.archive_dir is required with auth.audit.retention_days")
+	}
+	if audit.RetentionInterval <= 0 {
+		add("auth.audit.retention_interval must be positive")
+	}
+	if audit.CheckpointInterval <= 0 {
+		add("auth.audit.checkpoint_interval must be positive")
+	}
+
+	// Providers: a partly configured provider would silently not start
+	p := c.Providers
+	if p.Azure.Endpoint != "" && p.Azure.APIKey == "" {
+		add("providers.azure.api_key is required with providers.azure.endpoint")
+	}
+	if p.IBM.APIKey != "" && p.IBM.ProjectID == "" {
+		add("providers.ibm.project_id is required with providers.ibm.api_key")
+	}
+	if p.Oracle.Endpoint != "" && (p.Oracle.AuthToken == "" || p.Oracle.CompartmentID == "") {
+		add("providers.oracle.auth_token and providers.oracle.compartment_id are required with providers.oracle.endpoint")
+	}
+
+	// Features
+	if c.Features.ModelMapping == "" {
+		add("features.model_mapping is required")
+	}
+	switch c.Features.MetricsTenantLabel {
+	case "team", "key", "none":
+	default:
+		add("features.metrics_tenant_label %q must be team, key or none", c.Features.MetricsTenantLabel)
+	}
+
+	return problems
+}
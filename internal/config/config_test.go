@@ -0,0 +1,221 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadFileAndEnv(t *testing.T) {
+	t.Setenv("TEST_OPENAI_KEY", "sk-from-file")
+	t.Setenv("PORT", "9090")
+	t.Setenv("AUTH_DB_PATH", "/tmp/env.db")
+	t.Setenv("ALLOWED_SERVICE_ACCOUNTS", "ns1/sa1, ns2/sa2")
+
+	path := writeConfig(t, `
+server:
+  port: "8000"
+  write_timeout: 15m
+auth:
+  enabled: true
+  mode: api_key_db
+  database:
+    path: /tmp/file.db
+providers:
+  openai:
+    api_key: ${TEST_OPENAI_KEY}
+features:
+  metrics_tenant_label: key
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.Providers.OpenAI.APIKey != "sk-from-file" {
+		t.Errorf("Expected ${VAR} expansion, got %q", cfg.Providers.OpenAI.APIKey)
+	}
+	if cfg.Server.Port != "9090" || cfg.Auth.Database.Path != "/tmp/env.db" {
+		t.Errorf("Expected environment to override the file, got port %q and db %q", cfg.Server.Port, cfg.Auth.Database.Path)
+	}
+	if cfg.Server.WriteTimeout != 15*time.Minute || cfg.Features.MetricsTenantLabel != "key" {
+		t.Errorf("Expected file settings, got %+v", cfg.Server)
+	}
+	if cfg.Server.ReadTimeout != Default().Server.ReadTimeout || !cfg.Auth.Database.AutoMigrate {
+		t.Error("Expected defaults for settings the file leaves unset")
+	}
+	if len(cfg.Auth.ServiceAccounts) != 2 || cfg.Auth.ServiceAccounts[1] != "ns2/sa2" {
+		t.Errorf("Unexpected service accounts: %v", cfg.Auth.ServiceAccounts)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected a valid config: %v", err)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := writeConfig(t, "server:\n  prot: 8080\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Errorf("Expected an unknown key error, got %v", err)
+	}
+}
+
+func TestProblemsReportsEverything(t *testing.T) {
+	t.Setenv("TLS_ENABLED", "yes please")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "forever")
+
+	path := writeConfig(t, `
+server:
+  port: "http"
+auth:
+  enabled: true
+  mode: session
+  database:
+    backend: postgres
+providers:
+  bedrock:
+    region: ""
+  azure:
+    endpoint: https://example.openai.azure.com
+features:
+  metrics_tenant_label: tenant
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	problems := strings.Join(cfg.Problems(), "\n")
+	for _, want := range []string{
+		"TLS_ENABLED: invalid boolean",
+		"HTTP_WRITE_TIMEOUT: invalid duration",
+		`server.port "http" is not a valid port`,
+		"auth.database.dsn or auth.database.dsn_file is required",
+		"providers.azure.api_key is required",
+		"features.metrics_tenant_label",
+	} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("Expected problem %q in:\n%s", want, problems)
+		}
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected Validate to fail")
+	}
+}
+
+func TestAuthSettings(t *testing.T) {
+	t.Setenv("SESSION_DURATION", "8h")
+	t.Setenv("SIGV4_REGIONS", "us-east-1, eu-west-1")
+
+	path := writeConfig(t, `
+auth:
+  lockout:
+    store: memory
+    threshold: 3
+  session:
+    bind_subnet_v4: 24
+  totp:
+    master_key_file: /etc/totp/key
+  audit:
+    retention_days: 30
+    archive_dir: /var/lib/audit
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.Auth.Lockout.Store != "memory" || cfg.Auth.Lockout.Threshold != 3 || cfg.Auth.Lockout.BaseDuration != 30*time.Second {
+		t.Errorf("Unexpected lockout settings: %+v", cfg.Auth.Lockout)
+	}
+	if cfg.Auth.Session.Duration != 8*time.Hour || cfg.Auth.Session.BindSubnetV4 != 24 {
+		t.Errorf("Unexpected session settings: %+v", cfg.Auth.Session)
+	}
+	if cfg.Auth.TOTP.MaxFailedAttempts != 5 || cfg.Auth.TOTP.LockoutDuration != 15*time.Minute {
+		t.Errorf("Expected TOTP lockout defaults, got %+v", cfg.Auth.TOTP)
+	}
+	if len(cfg.Auth.SigV4.Regions) != 2 || cfg.Auth.SigV4.Regions[1] != "eu-west-1" || cfg.Auth.SigV4.Service != "bedrock" {
+		t.Errorf("Unexpected SigV4 settings: %+v", cfg.Auth.SigV4)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected a valid config: %v", err)
+	}
+
+	bad := Default()
+	bad.applyEnv(func(name string) string {
+		return map[string]string{
+			"LOCKOUT_THRESHOLD":        "0",
+			"LOCKOUT_STORE":            "redis",
+			"SESSION_DURATION":         "a while",
+			"SESSION_BIND_SUBNET_V6":   "129",
+			"TOTP_MAX_FAILED_ATTEMPTS": "-1",
+			"SIGV4_AUTH_ENABLED":       "true",
+			"AUDIT_RETENTION_DAYS":     "30",
+		}[name]
+	})
+	problems := strings.Join(bad.Problems(), "\n")
+	for _, want := range []string{
+		"auth.lockout.threshold",
+		`auth.lockout.store "redis"`,
+		"SESSION_DURATION: invalid duration",
+		"auth.session.bind_subnet_v6",
+		"auth.totp.max_failed_attempts",
+		"auth.sigv4.enabled requires an auth database",
+		"auth.audit.archive_dir is required",
+	} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("Expected problem %q in:\n%s", want, problems)
+		}
+	}
+}
+
+func TestAuthModeRequirements(t *testing.T) {
+	for mode, want := range map[string]string{
+		"basic":           "auth.basic_credentials",
+		"service_account": "auth.service_accounts",
+		"mtls":            "requires tls.enabled",
+		"api_key_db":      "requires an auth database",
+		"oauth":           `auth.mode "oauth"`,
+	} {
+		cfg := Default()
+		cfg.Auth.Enabled = true
+		cfg.Auth.Mode = mode
+		if problems := strings.Join(cfg.Problems(), "\n"); !strings.Contains(problems, want) {
+			t.Errorf("%s: expected %q in %q", mode, want, problems)
+		}
+	}
+
+	cfg := Default()
+	cfg.applyEnv(func(name string) string {
+		return map[string]string{"AUTH_ENABLED": "true", "AUTH_MODE": "basic", "BASIC_AUTH_CREDENTIALS": "alice:s3cret,bob:pa:ss"}[name]
+	})
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected basic auth from the environment to validate: %v", err)
+	}
+	if cfg.Auth.BasicCredentials["bob"] != "pa:ss" {
+		t.Errorf("Unexpected credentials: %v", cfg.Auth.BasicCredentials)
+	}
+}
+
+func TestSampleConfig(t *testing.T) {
+	cfg, err := Load("../../configs/server.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load the sample config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the sample config to validate: %v", err)
+	}
+}
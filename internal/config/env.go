@@ -0,0 +1,197 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envOverride sets a setting from the value of an environment variable
+type envOverride struct {
+	name string
+	set  func(string) error
+}
+
+// overrides lists the environment variables that override the file, by
+// their historical names
+func (c *Config) overrides() []envOverride {
+	return []envOverride{
+		// Listeners
+		{"PORT", setString(&c.Server.Port)},
+		{"TLS_PORT", setString(&c.Server.TLSPort)},
+		{"GIN_MODE", setString(&c.Server.GinMode)},
+		{"HTTP_READ_HEADER_TIMEOUT", setDuration(&c.Server.ReadHeaderTimeout)},
+		{"HTTP_READ_TIMEOUT", setDuration(&c.Server.ReadTimeout)},
+		{"HTTP_WRITE_TIMEOUT", setDuration(&c.Server.WriteTimeout)},
+		{"HTTP_IDLE_TIMEOUT", setDuration(&c.Server.IdleTimeout)},
+		{"HTTP_MAX_HEADER_BYTES", setInt(&c.Server.MaxHeaderBytes)},
+		{"SHUTDOWN_DELAY", setDuration(&c.Server.ShutdownDelay)},
+		{"SHUTDOWN_TIMEOUT", setDuration(&c.Server.ShutdownTimeout)},
+
+		// TLS
+		{"TLS_ENABLED", setBool(&c.TLS.Enabled)},
+		{"TLS_CERT_FILE", setString(&c.TLS.CertFile)},
+		{"TLS_KEY_FILE", setString(&c.TLS.KeyFile)},
+		{"MTLS_CLIENT_CA_FILE", setString(&c.TLS.ClientCAFile)},
+		{"MTLS_IDENTITY_MAPPING", setString(&c.TLS.IdentityMapping)},
+
+		// Authentication
+		{"AUTH_ENABLED", setBool(&c.Auth.Enabled)},
+		{"AUTH_MODE", setString(&c.Auth.Mode)},
+		{"REQUIRE_2FA", setBool(&c.Auth.Require2FA)},
+		{"API_KEY_PEPPER_FILE", setString(&c.Auth.APIKeyPepperFile)},
+		{"BASIC_AUTH_CREDENTIALS", setCredentials(&c.Auth.BasicCredentials)},
+		{"ALLOWED_SERVICE_ACCOUNTS", setList(&c.Auth.ServiceAccounts)},
+		{"AUTH_DB_BACKEND", setString(&c.Auth.Database.Backend)},
+		{"AUTH_DB_PATH", setString(&c.Auth.Database.Path)},
+		{"AUTH_DB_DSN", setString(&c.Auth.Database.DSN)},
+		{"AUTH_DB_DSN_FILE", setString(&c.Auth.Database.DSNFile)},
+		{"AUTH_DB_MAX_OPEN_CONNS", setInt(&c.Auth.Database.MaxOpenConns)},
+		{"AUTH_DB_CONN_MAX_LIFETIME", setDuration(&c.Auth.Database.ConnMaxLifetime)},
+		{"AUTH_DB_AUTO_MIGRATE", setBool(&c.Auth.Database.AutoMigrate)},
+		{"LOCKOUT_ENABLED", setBool(&c.Auth.Lockout.Enabled)},
+		{"LOCKOUT_STORE", setString(&c.Auth.Lockout.Store)},
+		{"LOCKOUT_THRESHOLD", setInt(&c.Auth.Lockout.Threshold)},
+		{"LOCKOUT_BASE_DURATION", setDuration(&c.Auth.Lockout.BaseDuration)},
+		{"LOCKOUT_MAX_DURATION", setDuration(&c.Auth.Lockout.MaxDuration)},
+		{"LOCKOUT_RESET_AFTER", setDuration(&c.Auth.Lockout.ResetAfter)},
+		{"SESSION_DURATION", setDuration(&c.Auth.Session.Duration)},
+		{"SESSION_IDLE_TIMEOUT", setDuration(&c.Auth.Session.IdleTimeout)},
+		{"SESSION_MAX_PER_KEY", setInt(&c.Auth.Session.MaxPerKey)},
+		{"SESSION_BIND_IP", setBool(&c.Auth.Session.BindIP)},
+		{"SESSION_BIND_USER_AGENT", setBool(&c.Auth.Session.BindUserAgent)},
+		{"SESSION_BIND_SUBNET_V4", setInt(&c.Auth.Session.BindSubnetV4)},
+		{"SESSION_BIND_SUBNET_V6", setInt(&c.Auth.Session.BindSubnetV6)},
+		{"TOTP_MASTER_KEY_FILE", setString(&c.Auth.TOTP.MasterKeyFile)},
+		{"TOTP_PREVIOUS_MASTER_KEY_FILES", setList(&c.Auth.TOTP.PreviousMasterKeyFiles)},
+		{"TOTP_MAX_FAILED_ATTEMPTS", setInt(&c.Auth.TOTP.MaxFailedAttempts)},
+		{"TOTP_LOCKOUT_DURATION", setDuration(&c.Auth.TOTP.LockoutDuration)},
+		{"SIGV4_AUTH_ENABLED", setBool(&c.Auth.SigV4.Enabled)},
+		{"SIGV4_SERVICE", setString(&c.Auth.SigV4.Service)},
+		{"SIGV4_REGIONS", setList(&c.Auth.SigV4.Regions)},
+		{"SIGV4_ALLOW_UNSIGNED_PAYLOAD", setBool(&c.Auth.SigV4.AllowUnsignedPayload)},
+		{"AUDIT_RETENTION_DAYS", setInt(&c.Auth.Audit.RetentionDays)},
+		{"AUDIT_ARCHIVE_DIR", setString(&c.Auth.Audit.ArchiveDir)},
+		{"AUDIT_RETENTION_INTERVAL", setDuration(&c.Auth.Audit.RetentionInterval)},
+		{"AUDIT_SIGNING_KEY_FILE", setString(&c.Auth.Audit.SigningKeyFile)},
+		{"AUDIT_VERIFY_KEY_FILES", setList(&c.Auth.Audit.VerifyKeyFiles)},
+		{"AUDIT_CHECKPOINT_INTERVAL", setDuration(&c.Auth.Audit.CheckpointInterval)},
+
+		// Providers
+		{"AWS_REGION", setString(&c.Providers.Bedrock.Region)},
+		{"AZURE_OPENAI_ENDPOINT", setString(&c.Providers.Azure.Endpoint)},
+		{"AZURE_OPENAI_API_KEY", setString(&c.Providers.Azure.APIKey)},
+		{"AZURE_API_VERSION", setString(&c.Providers.Azure.APIVersion)},
+		{"OPENAI_API_KEY", setString(&c.Providers.OpenAI.APIKey)},
+		{"OPENAI_BASE_URL", setString(&c.Providers.OpenAI.BaseURL)},
+		{"ANTHROPIC_API_KEY", setString(&c.Providers.Anthropic.APIKey)},
+		{"ANTHROPIC_BASE_URL", setString(&c.Providers.Anthropic.BaseURL)},
+		{"GCP_PROJECT_ID", setString(&c.Providers.Vertex.ProjectID)},
+		{"GCP_LOCATION", setString(&c.Providers.Vertex.Location)},
+		{"GCP_ACCESS_TOKEN", setString(&c.Providers.Vertex.AccessToken)},
+		{"IBM_API_KEY", setString(&c.Providers.IBM.APIKey)},
+		{"IBM_PROJECT_ID", setString(&c.Providers.IBM.ProjectID)},
+		{"IBM_BASE_URL", setString(&c.Providers.IBM.BaseURL)},
+		{"ORACLE_ENDPOINT", setString(&c.Providers.Oracle.Endpoint)},
+		{"ORACLE_AUTH_TOKEN", setString(&c.Providers.Oracle.AuthToken)},
+		{"ORACLE_COMPARTMENT_ID", setString(&c.Providers.Oracle.CompartmentID)},
+
+		// Features
+		{"MODEL_MAPPING_CONFIG", setString(&c.Features.ModelMapping)},
+		{"DLP_CONFIG", setString(&c.Features.DLP)},
+		{"TRANSCRIPT_CONFIG", setString(&c.Features.Transcripts)},
+		{"NOTIFY_CONFIG", setString(&c.Features.Notifications)},
+		{"METRICS_TENANT_LABEL", setString(&c.Features.MetricsTenantLabel)},
+		{"REQUEST_TAGS_ALLOWLIST", setString(&c.Features.RequestTags)},
+		{"TRACING_ENABLED", setBool(&c.Features.Tracing.Enabled)},
+		{"OTEL_SERVICE_NAME", setString(&c.Features.Tracing.ServiceName)},
+	}
+}
+
+// applyEnv applies the environment overrides that are set, recording the
+// ones that cannot be parsed
+func (c *Config) applyEnv(getenv func(string) string) {
+	for _, o := range c.overrides() {
+		v := getenv(o.name)
+		if v == "" {
+			continue
+		}
+		if err := o.set(v); err != nil {
+			c.envProblems = append(c.envProblems, fmt.Sprintf("%s: %v", o.name, err))
+		}
+	}
+}
+
+func setString(target *string) func(string) error {
+	return func(v string) error {
+		*target = v
+		return nil
+	}
+}
+
+func setBool(target *bool) func(string) error {
+	return func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		*target = b
+		return nil
+	}
+}
+
+func setInt(target *int) func(string) error {
+	return func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*target = n
+		return nil
+	}
+}
+
+func setDuration(target *time.Duration) func(string) error {
+	return func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		*target = d
+		return nil
+	}
+}
+
+// setList parses a comma-separated list, e.g. "ns1/sa1,ns2/sa2"
+func setList(target *[]string) func(string) error {
+	return func(v string) error {
+		var list []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*target = list
+		return nil
+	}
+}
+
+// setCredentials parses "user1:pass1,user2:pass2"
+func setCredentials(target *map[string]string) func(string) error {
+	return func(v string) error {
+		creds := make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			user, pass, ok := strings.Cut(pair, ":")
+			if !ok || user == "" {
+				return fmt.Errorf("expected user:password pairs")
+			}
+			creds[user] = pass
+		}
+		*target = creds
+		return nil
+	}
+}
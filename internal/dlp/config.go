@@ -0,0 +1,171 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dlp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy actions
+const (
+	ActionRedact = "redact" // replace PII with placeholders and restore it in responses
+	ActionBlock  = "block"  // reject requests containing PII
+	ActionAllow  = "allow"  // send prompts unchanged
+)
+
+// Detector types
+const (
+	TypeRegex      = "regex"
+	TypeDictionary = "dictionary"
+)
+
+// Config configures PII detection
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Builtin selects built-in detectors by name; unset enables all of them
+	Builtin   []string         `yaml:"builtin"`
+	Detectors []DetectorConfig `yaml:"detectors"`
+	Policy    PolicyConfig     `yaml:"policy"`
+}
+
+// DetectorConfig defines a custom detector. Name becomes the placeholder
+// prefix, so a detector named EMPLOYEE_ID produces <EMPLOYEE_ID_1>.
+type DetectorConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"` // regex or dictionary
+
+	// Regex detectors
+	Pattern  string `yaml:"pattern"`
+	Validate string `yaml:"validate"` // optional checksum: luhn, ssn or iban
+
+	// Dictionary detectors
+	Words         []string `yaml:"words"`
+	WordsFile     string   `yaml:"words_file"` // one term per line
+	CaseSensitive bool     `yaml:"case_sensitive"`
+}
+
+// PolicyConfig chooses the action for a request. A model entry takes
+// precedence over a provider entry, which takes precedence over the default.
+// Provider entries name a provider type, covering all of its instances, or
+// a single instance.
+type PolicyConfig struct {
+	Default   string            `yaml:"default"` // defaults to redact
+	Providers map[string]string `yaml:"providers"`
+	Models    map[string]string `yaml:"models"`
+}
+
+var detectorName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// LoadConfig loads DLP settings from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DLP config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse DLP config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Validate checks the policy actions and fills in the default
+func (p *PolicyConfig) Validate() error {
+	if p.Default == "" {
+		p.Default = ActionRedact
+	}
+	if err := checkAction(p.Default); err != nil {
+		return fmt.Errorf("default policy: %w", err)
+	}
+	for provider, action := range p.Providers {
+		if err := checkAction(action); err != nil {
+			return fmt.Errorf("policy for provider %q: %w", provider, err)
+		}
+	}
+	for model, action := range p.Models {
+		if err := checkAction(action); err != nil {
+			return fmt.Errorf("policy for model %q: %w", model, err)
+		}
+	}
+	return nil
+}
+
+func checkAction(action string) error {
+	switch action {
+	case ActionRedact, ActionBlock, ActionAllow:
+		return nil
+	}
+	return fmt.Errorf("unknown action %q", action)
+}
+
+// compile builds a detector from its configuration
+func (d DetectorConfig) compile() (*detector, error) {
+	if !detectorName.MatchString(d.Name) {
+		return nil, fmt.Errorf("detector name %q must be upper case letters, digits and underscores", d.Name)
+	}
+
+	switch d.Type {
+	case TypeRegex:
+		if d.Pattern == "" {
+			return nil, fmt.Errorf("detector %s: pattern is required", d.Name)
+		}
+		pattern, err := regexp.Compile(d.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("detector %s: invalid pattern: %w", d.Name, err)
+		}
+		var validate func(string) bool
+		if d.Validate != "" {
+			if validate = validators[d.Validate]; validate == nil {
+				return nil, fmt.Errorf("detector %s: unknown validator %q", d.Name, d.Validate)
+			}
+		}
+		return &detector{name: d.Name, pattern: pattern, validate: validate}, nil
+
+	case TypeDictionary:
+		words := append([]string(nil), d.Words...)
+		if d.WordsFile != "" {
+			fileWords, err := readWords(d.WordsFile)
+			if err != nil {
+				return nil, fmt.Errorf("detector %s: %w", d.Name, err)
+			}
+			words = append(words, fileWords...)
+		}
+		pattern, err := dictionaryPattern(words, d.CaseSensitive)
+		if err != nil {
+			return nil, fmt.Errorf("detector %s: %w", d.Name, err)
+		}
+		return &detector{name: d.Name, pattern: pattern}, nil
+	}
+
+	return nil, fmt.Errorf("detector %s: unknown type %q", d.Name, d.Type)
+}
+
+// readWords reads one dictionary term per line, skipping blanks and # comments
+func readWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read words file: %w", err)
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read words file: %w", err)
+	}
+	return words, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dlp
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// detector finds one kind of sensitive value
+type detector struct {
+	name     string
+	pattern  *regexp.Regexp
+	validate func(string) bool // rejects pattern matches that fail a checksum
+}
+
+// builtinDetectors are enabled unless the config selects a subset
+var builtinDetectors = []*detector{
+	{
+		name:    "EMAIL",
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	{
+		name:     "CREDIT_CARD",
+		pattern:  regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		validate: validLuhn,
+	},
+	{
+		name:     "SSN",
+		pattern:  regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+		validate: validSSN,
+	},
+	{
+		name:     "IBAN",
+		pattern:  regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`),
+		validate: validIBAN,
+	},
+	{
+		name:    "PHONE",
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`),
+	},
+}
+
+// validators are the checksums custom regex detectors can require
+var validators = map[string]func(string) bool{
+	"luhn": validLuhn,
+	"ssn":  validSSN,
+	"iban": validIBAN,
+}
+
+// digits returns the decimal digits of s
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// validLuhn reports whether s holds 13-19 digits with a valid Luhn checksum
+func validLuhn(s string) bool {
+	d := digits(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(d) - 1; i >= 0; i-- {
+		n := int(d[i] - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validSSN rejects US Social Security numbers that are never issued
+func validSSN(s string) bool {
+	d := digits(s)
+	if len(d) != 9 {
+		return false
+	}
+	area, group, serial := d[0:3], d[3:5], d[5:9]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validIBAN checks the ISO 13616 mod-97 checksum
+func validIBAN(s string) bool {
+	iban := strings.ReplaceAll(s, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	var numeric strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			numeric.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			fmt.Fprintf(&numeric, "%d", r-'A'+10)
+		default:
+			return false
+		}
+	}
+
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// dictionaryPattern matches any of words as whole words, longest first
+func dictionaryPattern(words []string, caseSensitive bool) (*regexp.Regexp, error) {
+	var terms []string
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			terms = append(terms, regexp.QuoteMeta(w))
+		}
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("dictionary has no words")
+	}
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+
+	flags := "(?i)"
+	if caseSensitive {
+		flags = ""
+	}
+	return regexp.Compile(flags + `\b(?:` + strings.Join(terms, "|") + `)\b`)
+}
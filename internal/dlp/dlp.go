@@ -0,0 +1,263 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package dlp detects personal data in prompts and replaces it with
+// placeholders that are restored in the provider's response
+package dlp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/translator"
+)
+
+// Scanner detects PII and decides what to do about it
+type Scanner struct {
+	detectors []*detector
+	policy    PolicyConfig
+}
+
+// New builds a scanner from cfg
+func New(cfg *Config) (*Scanner, error) {
+	if err := cfg.Policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Scanner{policy: cfg.Policy}
+	if cfg.Builtin == nil {
+		s.detectors = append(s.detectors, builtinDetectors...)
+	}
+	for _, name := range cfg.Builtin {
+		d := builtinDetector(name)
+		if d == nil {
+			return nil, fmt.Errorf("unknown built-in detector %q", name)
+		}
+		s.detectors = append(s.detectors, d)
+	}
+
+	seen := make(map[string]bool)
+	for _, d := range s.detectors {
+		seen[d.name] = true
+	}
+	for _, dc := range cfg.Detectors {
+		d, err := dc.compile()
+		if err != nil {
+			return nil, err
+		}
+		if seen[d.name] {
+			return nil, fmt.Errorf("duplicate detector %s", d.name)
+		}
+		seen[d.name] = true
+		s.detectors = append(s.detectors, d)
+	}
+
+	if len(s.detectors) == 0 {
+		return nil, fmt.Errorf("no detectors configured")
+	}
+	return s, nil
+}
+
+func builtinDetector(name string) *detector {
+	for _, d := range builtinDetectors {
+		if d.name == strings.ToUpper(name) {
+			return d
+		}
+	}
+	return nil
+}
+
+// Action returns the policy action for a request routed to a provider
+// instance of providerType. A provider entry naming the instance, e.g.
+// "azure-sweden", overrides the entry for its type.
+func (s *Scanner) Action(instance, providerType, model string) string {
+	if action, ok := s.policy.Models[model]; ok {
+		return action
+	}
+	if action, ok := s.policy.Providers[instance]; ok {
+		return action
+	}
+	if action, ok := s.policy.Providers[providerType]; ok {
+		return action
+	}
+	return s.policy.Default
+}
+
+// Match is one detected value
+type Match struct {
+	Detector   string
+	Start, End int
+}
+
+// Scan returns non-overlapping matches in text, ordered by position. Where
+// detections overlap the longest one wins.
+func (s *Scanner) Scan(text string) []Match {
+	var matches []Match
+	for _, d := range s.detectors {
+		for _, loc := range d.pattern.FindAllStringIndex(text, -1) {
+			if d.validate != nil && !d.validate(text[loc[0]:loc[1]]) {
+				continue
+			}
+			matches = append(matches, Match{Detector: d.name, Start: loc[0], End: loc[1]})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+
+	var kept []Match
+	for _, m := range matches {
+		if n := len(kept); n > 0 && m.Start < kept[n-1].End {
+			if m.End-m.Start <= kept[n-1].End-kept[n-1].Start {
+				continue
+			}
+			kept = kept[:n-1]
+		}
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+// Session redacts one request and restores its response. Equal values get
+// the same placeholder, so the model can still refer to them consistently.
+// A nil Session leaves text unchanged.
+type Session struct {
+	scanner      *Scanner
+	placeholders map[string]string // value -> placeholder
+	values       map[string]string // placeholder -> value
+	counts       map[string]int    // detector -> placeholders issued
+	findings     map[string]int    // detector -> occurrences
+	longest      int
+}
+
+// NewSession starts redacting a request
+func (s *Scanner) NewSession() *Session {
+	return &Session{
+		scanner:      s,
+		placeholders: make(map[string]string),
+		values:       make(map[string]string),
+		counts:       make(map[string]int),
+		findings:     make(map[string]int),
+	}
+}
+
+// Redact replaces detected values in text with placeholders
+func (s *Session) Redact(text string) string {
+	matches := s.scanner.Scan(text)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.Start])
+		b.WriteString(s.placeholder(m.Detector, text[m.Start:m.End]))
+		s.findings[m.Detector]++
+		last = m.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func (s *Session) placeholder(detector, value string) string {
+	if p, ok := s.placeholders[value]; ok {
+		return p
+	}
+	s.counts[detector]++
+	p := fmt.Sprintf("<%s_%d>", detector, s.counts[detector])
+	s.placeholders[value] = p
+	s.values[p] = value
+	if len(p) > s.longest {
+		s.longest = len(p)
+	}
+	return p
+}
+
+// Findings returns the number of values detected per detector
+func (s *Session) Findings() map[string]int {
+	if s == nil {
+		return nil
+	}
+	return s.findings
+}
+
+// String summarizes findings as "EMAIL=2, PHONE=1" for logs
+func (s *Session) String() string {
+	names := make([]string, 0, len(s.findings))
+	for name := range s.findings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%d", name, s.findings[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+var placeholderPattern = regexp.MustCompile(`<[A-Z][A-Z0-9_]*_\d+>`)
+
+// Restore puts the original values back in place of placeholders
+func (s *Session) Restore(text string) string {
+	if s == nil || len(s.values) == 0 || !strings.Contains(text, "<") {
+		return text
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(p string) string {
+		if value, ok := s.values[p]; ok {
+			return value
+		}
+		return p
+	})
+}
+
+// RedactRequest redacts message text, content parts and tool call arguments
+func (s *Session) RedactRequest(req *translator.ChatCompletionRequest) {
+	for i := range req.Messages {
+		msg := &req.Messages[i]
+		switch content := msg.Content.(type) {
+		case string:
+			msg.Content = s.Redact(content)
+		case []interface{}:
+			for _, part := range content {
+				if p, ok := part.(map[string]interface{}); ok {
+					if text, ok := p["text"].(string); ok {
+						p["text"] = s.Redact(text)
+					}
+				}
+			}
+		}
+		for j := range msg.ToolCalls {
+			msg.ToolCalls[j].Function.Arguments = s.Redact(msg.ToolCalls[j].Function.Arguments)
+		}
+		if msg.FunctionCall != nil {
+			msg.FunctionCall.Arguments = s.Redact(msg.FunctionCall.Arguments)
+		}
+	}
+}
+
+// RestoreResponse restores placeholders in a complete response
+func (s *Session) RestoreResponse(resp *translator.ChatCompletionResponse) {
+	if s == nil {
+		return
+	}
+	for i := range resp.Choices {
+		msg := &resp.Choices[i].Message
+		if content, ok := msg.Content.(string); ok {
+			msg.Content = s.Restore(content)
+		}
+		for j := range msg.ToolCalls {
+			msg.ToolCalls[j].Function.Arguments = s.Restore(msg.ToolCalls[j].Function.Arguments)
+		}
+		if msg.FunctionCall != nil {
+			msg.FunctionCall.Arguments = s.Restore(msg.FunctionCall.Arguments)
+		}
+	}
+}
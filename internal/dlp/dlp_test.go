@@ -0,0 +1,205 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dlp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/translator"
+)
+
+func newTestScanner(t *testing.T) *Scanner {
+	t.Helper()
+
+	wordsFile := filepath.Join(t.TempDir(), "projects.txt")
+	os.WriteFile(wordsFile, []byte("# internal code names\nBlue Falcon\n\nNightjar\n"), 0600)
+
+	s, err := New(&Config{
+		Detectors: []DetectorConfig{
+			{Name: "EMPLOYEE_ID", Type: TypeRegex, Pattern: `\bEMP-\d{6}\b`},
+			{Name: "PROJECT", Type: TypeDictionary, WordsFile: wordsFile},
+		},
+		Policy: PolicyConfig{
+			Providers: map[string]string{"bedrock": ActionAllow, "openai": ActionBlock, "azure": ActionBlock, "azure-us": ActionRedact},
+			Models:    map[string]string{"gpt-4": ActionRedact},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create scanner: %v", err)
+	}
+	return s
+}
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid func(string) bool
+		value string
+		want  bool
+	}{
+		{"visa", validLuhn, "4111 1111 1111 1111", true},
+		{"amex", validLuhn, "3782-822463-10005", true},
+		{"bad checksum", validLuhn, "4111 1111 1111 1112", false},
+		{"too short", validLuhn, "4111 1111 11", false},
+		{"ssn", validSSN, "123-45-6789", true},
+		{"ssn area 000", validSSN, "000-12-3456", false},
+		{"ssn area 9xx", validSSN, "912-34-5678", false},
+		{"ssn group 00", validSSN, "123-00-4567", false},
+		{"iban", validIBAN, "GB82 WEST 1234 5698 7654 32", true},
+		{"iban compact", validIBAN, "DE89370400440532013000", true},
+		{"bad iban", validIBAN, "GB82 WEST 1234 5698 7654 33", false},
+	}
+	for _, tt := range tests {
+		if got := tt.valid(tt.value); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	s := newTestScanner(t)
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"mail bob@example.com now", []string{"EMAIL"}},
+		{"card 4111 1111 1111 1111 ok", []string{"CREDIT_CARD"}},
+		{"card 4111 1111 1111 1112 ok", nil}, // fails Luhn
+		{"ssn 123-45-6789", []string{"SSN"}},
+		{"call +1 415-555-0100", []string{"PHONE"}},
+		{"pay to DE89 3704 0044 0532 0130 00", []string{"IBAN"}},
+		{"ask EMP-004211 about blue falcon", []string{"EMPLOYEE_ID", "PROJECT"}},
+		{"nothing to see", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range s.Scan(tt.text) {
+			got = append(got, m.Detector)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Scan(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRedactRestore(t *testing.T) {
+	s := newTestScanner(t)
+	session := s.NewSession()
+
+	req := &translator.ChatCompletionRequest{Messages: []translator.ChatMessage{
+		{Role: "user", Content: "Email bob@example.com and alice@example.com"},
+		{Role: "user", Content: []interface{}{
+			map[string]interface{}{"type": "text", "text": "Again: bob@example.com"},
+		}},
+		{Role: "assistant", ToolCalls: []translator.ToolCall{{
+			Function: translator.FunctionCall{Name: "lookup", Arguments: `{"card":"4111111111111111"}`},
+		}}},
+	}}
+	session.RedactRequest(req)
+
+	if got := req.Messages[0].Content; got != "Email <EMAIL_1> and <EMAIL_2>" {
+		t.Errorf("Unexpected redaction %q", got)
+	}
+	part := req.Messages[1].Content.([]interface{})[0].(map[string]interface{})
+	if part["text"] != "Again: <EMAIL_1>" {
+		t.Errorf("Expected a stable placeholder, got %q", part["text"])
+	}
+	if got := req.Messages[2].ToolCalls[0].Function.Arguments; got != `{"card":"<CREDIT_CARD_1>"}` {
+		t.Errorf("Unexpected tool call redaction %q", got)
+	}
+	if findings := session.Findings(); findings["EMAIL"] != 3 || findings["CREDIT_CARD"] != 1 {
+		t.Errorf("Unexpected findings %v", findings)
+	}
+
+	resp := &translator.ChatCompletionResponse{Choices: []translator.ChatCompletionChoice{{
+		Message: translator.ChatMessage{Content: "Sent to <EMAIL_2>, not <EMAIL_9>"},
+	}}}
+	session.RestoreResponse(resp)
+	if got := resp.Choices[0].Message.Content; got != "Sent to alice@example.com, not <EMAIL_9>" {
+		t.Errorf("Unexpected restoration %q", got)
+	}
+}
+
+func TestStreamRestorer(t *testing.T) {
+	s := newTestScanner(t)
+	session := s.NewSession()
+	session.Redact("bob@example.com EMP-004211")
+
+	restorer := session.NewStreamRestorer()
+	var out strings.Builder
+	for i, delta := range []string{"Hi <EM", "AIL_1>", ", id <", "EMPLOYEE_ID", "_1", "> a<b <EMP"} {
+		chunk := translator.NewStreamChunk(translator.ChatMessageDelta{Content: delta}, "")
+		if i == 5 {
+			finish := "stop"
+			chunk.Choices[0].FinishReason = &finish
+		}
+		restorer.RestoreChunk(chunk)
+		out.WriteString(chunk.Choices[0].Delta.Content)
+	}
+	if got, want := out.String(), "Hi bob@example.com, id EMP-004211 a<b <EMP"; got != want {
+		t.Errorf("Got %q, want %q", got, want)
+	}
+	if restorer.Flush() != nil {
+		t.Error("Expected nothing held back after finish")
+	}
+
+	// Tool call arguments split mid-placeholder, stream ended without a finish
+	index := 0
+	chunk := translator.NewStreamChunk(translator.ChatMessageDelta{ToolCalls: []translator.ToolCall{{
+		Index: &index, Function: translator.FunctionCall{Arguments: `{"to":"<EMAIL_`},
+	}}}, "")
+	restorer.RestoreChunk(chunk)
+	if got := chunk.Choices[0].Delta.ToolCalls[0].Function.Arguments; got != `{"to":"` {
+		t.Errorf("Expected partial placeholder to be held, got %q", got)
+	}
+	rest := restorer.Flush()
+	if rest == nil || rest.Choices[0].Delta.ToolCalls[0].Function.Arguments != "<EMAIL_" {
+		t.Errorf("Expected held text to be flushed, got %+v", rest)
+	}
+
+	if (&Session{}).NewStreamRestorer() != nil {
+		t.Error("Expected no restorer when nothing was redacted")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	s := newTestScanner(t)
+
+	tests := []struct {
+		instance, providerType, model, want string
+	}{
+		{"vertex", "vertex", "gemini-pro", ActionRedact}, // default
+		{"bedrock", "bedrock", "claude-3-sonnet", ActionAllow},
+		{"openai", "openai", "gpt-3.5-turbo", ActionBlock},
+		{"openai", "openai", "gpt-4", ActionRedact},            // model wins over provider
+		{"azure-sweden", "azure", "gpt-35-turbo", ActionBlock}, // type covers its instances
+		{"azure-us", "azure", "gpt-35-turbo", ActionRedact},    // instance wins over type
+	}
+	for _, tt := range tests {
+		if got := s.Action(tt.instance, tt.providerType, tt.model); got != tt.want {
+			t.Errorf("Action(%q, %q, %q) = %q, want %q", tt.instance, tt.providerType, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	configs := map[string]Config{
+		"unknown action":    {Policy: PolicyConfig{Default: "quarantine"}},
+		"unknown builtin":   {Builtin: []string{"PASSPORT"}},
+		"no detectors":      {Builtin: []string{}},
+		"bad name":          {Detectors: []DetectorConfig{{Name: "email", Type: TypeRegex, Pattern: "x"}}},
+		"duplicate":         {Detectors: []DetectorConfig{{Name: "EMAIL", Type: TypeRegex, Pattern: "x"}}},
+		"unknown validator": {Detectors: []DetectorConfig{{Name: "ID", Type: TypeRegex, Pattern: "x", Validate: "crc"}}},
+		"empty dictionary":  {Detectors: []DetectorConfig{{Name: "ID", Type: TypeDictionary}}},
+	}
+	for name, cfg := range configs {
+		if _, err := New(&cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package dlp
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/translator"
+)
+
+// StreamRestorer restores placeholders in streamed chunks. A placeholder
+// can be split across chunks, so text that may be the start of one is held
+// back until the next chunk or the end of the choice.
+type StreamRestorer struct {
+	session *Session
+	pending map[streamKey]string
+}
+
+// streamKey identifies a text stream: a choice's content (tool -1) or the
+// arguments of one of its tool calls
+type streamKey struct {
+	choice int
+	tool   int
+}
+
+// NewStreamRestorer returns a restorer for the session's streamed
+// response, or nil when nothing was redacted
+func (s *Session) NewStreamRestorer() *StreamRestorer {
+	if s == nil || len(s.values) == 0 {
+		return nil
+	}
+	return &StreamRestorer{session: s, pending: make(map[streamKey]string)}
+}
+
+var partialPlaceholder = regexp.MustCompile(`^<[A-Z0-9_]*$`)
+
+// restore returns the restorable prefix of the pending text plus text
+func (r *StreamRestorer) restore(key streamKey, text string) string {
+	buf := r.pending[key] + text
+	delete(r.pending, key)
+
+	if i := strings.LastIndexByte(buf, '<'); i >= 0 {
+		tail := buf[i:]
+		if len(tail) < r.session.longest && partialPlaceholder.MatchString(tail) {
+			r.pending[key] = tail
+			buf = buf[:i]
+		}
+	}
+	return r.session.Restore(buf)
+}
+
+// flush returns the held-back text for key
+func (r *StreamRestorer) flush(key streamKey) string {
+	buf := r.pending[key]
+	delete(r.pending, key)
+	return r.session.Restore(buf)
+}
+
+// RestoreChunk restores placeholders in a chunk in place, releasing held
+// text when a choice finishes
+func (r *StreamRestorer) RestoreChunk(chunk *translator.ChatCompletionStreamResponse) {
+	if r == nil {
+		return
+	}
+
+	for i := range chunk.Choices {
+		choice := &chunk.Choices[i]
+		content := streamKey{choice: choice.Index, tool: -1}
+		choice.Delta.Content = r.restore(content, choice.Delta.Content)
+
+		for j := range choice.Delta.ToolCalls {
+			tc := &choice.Delta.ToolCalls[j]
+			index := j
+			if tc.Index != nil {
+				index = *tc.Index
+			}
+			key := streamKey{choice: choice.Index, tool: index}
+			tc.Function.Arguments = r.restore(key, tc.Function.Arguments)
+		}
+
+		if choice.FinishReason != nil {
+			choice.Delta.Content += r.flush(content)
+			choice.Delta.ToolCalls = append(choice.Delta.ToolCalls, r.flushTools(choice.Index)...)
+		}
+	}
+}
+
+// Flush returns a chunk with any text still held back, or nil. It is
+// needed only when a stream ends without finishing its choices.
+func (r *StreamRestorer) Flush() *translator.ChatCompletionStreamResponse {
+	if r == nil || len(r.pending) == 0 {
+		return nil
+	}
+
+	choices := make(map[int]bool)
+	for key := range r.pending {
+		choices[key.choice] = true
+	}
+	indexes := make([]int, 0, len(choices))
+	for index := range choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	chunk := &translator.ChatCompletionStreamResponse{Object: "chat.completion.chunk"}
+	for _, index := range indexes {
+		chunk.Choices = append(chunk.Choices, translator.ChatCompletionStreamChoice{
+			Index: index,
+			Delta: translator.ChatMessageDelta{
+				Content:   r.flush(streamKey{choice: index, tool: -1}),
+				ToolCalls: r.flushTools(index),
+			},
+		})
+	}
+	return chunk
+}
+
+// flushTools returns tool call deltas carrying the held-back arguments of
+// a choice's tool calls
+func (r *StreamRestorer) flushTools(choice int) []translator.ToolCall {
+	var keys []streamKey
+	for key := range r.pending {
+		if key.choice == choice && key.tool >= 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].tool < keys[j].tool })
+
+	var calls []translator.ToolCall
+	for _, key := range keys {
+		index := key.tool
+		calls = append(calls, translator.ToolCall{
+			Index:    &index,
+			Function: translator.FunctionCall{Arguments: r.flush(key)},
+		})
+	}
+	return calls
+}
@@ -0,0 +1,807 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tags"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler handles administrative endpoints
+type AdminHandler struct {
+	apiKeyDB       *auth.APIKeyDB
+	sessionManager *auth.SessionManager
+	totpManager    *auth.TOTPManager
+	guard          *auth.BruteForceGuard
+
+	// auditKeys verify audit checkpoint signatures
+	auditKeys []ed25519.PublicKey
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(
+	apiKeyDB *auth.APIKeyDB,
+	sessionManager *auth.SessionManager,
+	totpManager *auth.TOTPManager,
+	guard *auth.BruteForceGuard,
+) *AdminHandler {
+	return &AdminHandler{
+		apiKeyDB:       apiKeyDB,
+		sessionManager: sessionManager,
+		totpManager:    totpManager,
+		guard:          guard,
+	}
+}
+
+// auditFlushEvery is how many exported audit records are buffered per flush
+const auditFlushEvery = 500
+
+// KeyInfo is the admin API representation of an API key (never the hash)
+type KeyInfo struct {
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	Email       string            `json:"email"`
+	Description string            `json:"description"`
+	KeyPrefix   string            `json:"key_prefix"`
+	IsActive    bool              `json:"is_active"`
+	TeamID      int64             `json:"team_id,omitempty"`
+	Permissions []string          `json:"permissions"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	LastUsedAt  *time.Time        `json:"last_used_at,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+}
+
+// NewKeyInfo converts a stored API key to its admin API representation
+func NewKeyInfo(key *auth.APIKey) KeyInfo {
+	permissions := key.PermissionList()
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return KeyInfo{
+		ID:          key.ID,
+		Name:        key.Name,
+		Email:       key.Email,
+		Description: key.Description,
+		KeyPrefix:   key.KeyPrefix,
+		IsActive:    key.IsActive,
+		TeamID:      key.TeamID,
+		Permissions: permissions,
+		Tags:        key.DefaultTags(),
+		CreatedAt:   key.CreatedAt,
+		LastUsedAt:  key.LastUsedAt,
+		ExpiresAt:   key.ExpiresAt,
+	}
+}
+
+// SessionInfo is the admin API representation of a session (never the token)
+type SessionInfo struct {
+	ID         int64      `json:"id"`
+	APIKeyID   int64      `json:"api_key_id"`
+	FamilyID   int64      `json:"family_id"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	IsActive   bool       `json:"is_active"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// NewSessionInfo converts a stored session to its admin API representation
+func NewSessionInfo(session *auth.SessionToken) SessionInfo {
+	return SessionInfo{
+		ID:         session.ID,
+		APIKeyID:   session.APIKeyID,
+		FamilyID:   session.FamilyID,
+		IPAddress:  session.IPAddress,
+		UserAgent:  session.UserAgent,
+		IsActive:   session.IsActive,
+		CreatedAt:  session.CreatedAt,
+		ExpiresAt:  session.ExpiresAt,
+		LastUsedAt: session.LastUsedAt,
+	}
+}
+
+// CreateKeyRequest issues a new API key
+type CreateKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Email         string   `json:"email"`
+	Description   string   `json:"description"`
+	ExpiresInDays int      `json:"expires_in_days"`
+	Permissions   []string `json:"permissions"`
+
+	// Tags are the default cost-center tags of the key's requests
+	Tags map[string]string `json:"tags"`
+}
+
+// KeyTagsRequest replaces the default cost-center tags of a key
+type KeyTagsRequest struct {
+	Tags map[string]string `json:"tags"`
+}
+
+// IssuedKey returns a newly created or rotated key; the secret is shown once
+type IssuedKey struct {
+	APIKey  string  `json:"api_key"`
+	Key     KeyInfo `json:"key"`
+	Message string  `json:"message,omitempty"`
+}
+
+// SigV4CredentialInfo is the admin API representation of a SigV4 access key
+// (never the secret)
+type SigV4CredentialInfo struct {
+	AccessKeyID string    `json:"access_key_id"`
+	APIKeyID    int64     `json:"api_key_id"`
+	IsActive    bool      `json:"is_active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewSigV4CredentialInfo converts a stored SigV4 credential to its admin API representation
+func NewSigV4CredentialInfo(cred *auth.SigV4Credential) SigV4CredentialInfo {
+	return SigV4CredentialInfo{
+		AccessKeyID: cred.AccessKeyID,
+		APIKeyID:    cred.APIKeyID,
+		IsActive:    cred.IsActive,
+		CreatedAt:   cred.CreatedAt,
+	}
+}
+
+// IssuedSigV4Credential returns a new SigV4 access key; the secret is shown once
+type IssuedSigV4Credential struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	APIKeyID        int64  `json:"api_key_id"`
+	Message         string `json:"message,omitempty"`
+}
+
+// TOTPEnrollment is an operator-provisioned TOTP secret with backup codes
+type TOTPEnrollment struct {
+	APIKeyID    int64    `json:"api_key_id"`
+	OTPAuthURI  string   `json:"otpauth_uri"`
+	Secret      string   `json:"secret"`
+	Issuer      string   `json:"issuer"`
+	Account     string   `json:"account"`
+	BackupCodes []string `json:"backup_codes"`
+}
+
+// UnlockRequest selects the lockouts to clear
+type UnlockRequest struct {
+	IP        string `json:"ip"`
+	KeyPrefix string `json:"key_prefix"` // e.g. bdrk_live_ab12cd34, from every IP
+	Subject   string `json:"subject"`    // raw subject from GET /admin/lockouts
+	APIKeyID  int64  `json:"api_key_id"` // also clears the 2FA lockout
+}
+
+// ListLockouts returns subjects that are currently locked out
+func (h *AdminHandler) ListLockouts(c *gin.Context) {
+	if h.guard == nil {
+		c.JSON(http.StatusOK, gin.H{"lockouts": []auth.LockoutState{}})
+		return
+	}
+
+	lockouts, err := h.guard.Lockouts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list lockouts",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lockouts": lockouts,
+		"count":    len(lockouts),
+	})
+}
+
+// Unlock clears brute-force and 2FA lockouts
+func (h *AdminHandler) Unlock(c *gin.Context) {
+	var req UnlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	var subjects []string
+	if req.IP != "" {
+		subjects = append(subjects, auth.IPSubject(req.IP))
+	}
+	if req.Subject != "" {
+		subjects = append(subjects, req.Subject)
+	}
+
+	if len(subjects) == 0 && req.KeyPrefix == "" && req.APIKeyID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "Provide ip, key_prefix, subject or api_key_id",
+		})
+		return
+	}
+
+	if h.guard != nil {
+		for _, subject := range subjects {
+			if err := h.guard.Unlock(subject); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to unlock " + subject,
+				})
+				return
+			}
+		}
+
+		// Key lockouts are counted per client IP; clear the prefix from all of them
+		if req.KeyPrefix != "" {
+			cleared, err := h.guard.UnlockKey(req.KeyPrefix)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to unlock " + req.KeyPrefix,
+				})
+				return
+			}
+			subjects = append(subjects, cleared...)
+		}
+	}
+
+	if req.APIKeyID != 0 && h.totpManager != nil {
+		if err := h.totpManager.UnlockTOTP(req.APIKeyID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to unlock 2FA",
+			})
+			return
+		}
+	}
+
+	h.audit(c, "admin_unlock", map[string]any{
+		"subjects":   subjects,
+		"api_key_id": req.APIKeyID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Unlocked",
+		"subjects": subjects,
+	})
+}
+
+// ListKeys handles GET /admin/keys
+func (h *AdminHandler) ListKeys(c *gin.Context) {
+	keys, err := h.apiKeyDB.ListAPIKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list keys",
+		})
+		return
+	}
+
+	result := make([]KeyInfo, 0, len(keys))
+	for i := range keys {
+		result = append(result, NewKeyInfo(&keys[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"keys":  result,
+		"count": len(result),
+	})
+}
+
+// CreateKey handles POST /admin/keys
+func (h *AdminHandler) CreateKey(c *gin.Context) {
+	var req CreateKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	if err := tags.Validate(req.Tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var expiresIn *time.Duration
+	if req.ExpiresInDays > 0 {
+		d := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+		expiresIn = &d
+	}
+
+	apiKey, key, err := h.apiKeyDB.CreateAPIKeyWithSettings(req.Name, req.Email, req.Description, expiresIn, auth.KeySettings{
+		Permissions: req.Permissions,
+		Tags:        req.Tags,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create key",
+		})
+		return
+	}
+
+	h.audit(c, "admin_key_created", map[string]any{"api_key_id": key.ID, "email": req.Email})
+
+	c.JSON(http.StatusCreated, IssuedKey{
+		APIKey:  apiKey,
+		Key:     NewKeyInfo(key),
+		Message: "Store this key securely; it will not be shown again",
+	})
+}
+
+// RevokeKey handles DELETE /admin/keys/:id and ends the key's sessions
+func (h *AdminHandler) RevokeKey(c *gin.Context) {
+	key, ok := h.loadKey(c)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyDB.RevokeAPIKey(key.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke key",
+		})
+		return
+	}
+	if h.sessionManager != nil {
+		if err := h.sessionManager.RevokeAllUserSessions(key.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to revoke sessions",
+			})
+			return
+		}
+	}
+
+	h.audit(c, "admin_key_revoked", map[string]any{"api_key_id": key.ID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Key revoked",
+	})
+}
+
+// RotateKey handles POST /admin/keys/:id/rotate
+func (h *AdminHandler) RotateKey(c *gin.Context) {
+	key, ok := h.loadKey(c)
+	if !ok {
+		return
+	}
+
+	apiKey, rotated, err := h.apiKeyDB.RotateAPIKey(key.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to rotate key",
+		})
+		return
+	}
+
+	h.audit(c, "admin_key_rotated", map[string]any{"api_key_id": key.ID, "new_api_key_id": rotated.ID})
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, IssuedKey{
+		APIKey:  apiKey,
+		Key:     NewKeyInfo(rotated),
+		Message: "The previous key has been revoked; store this key securely",
+	})
+}
+
+// SetKeyTags handles PUT /admin/keys/:id/tags, replacing the key's default
+// cost-center tags. An empty map removes them.
+func (h *AdminHandler) SetKeyTags(c *gin.Context) {
+	key, ok := h.loadKey(c)
+	if !ok {
+		return
+	}
+
+	var req KeyTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+	if err := tags.Validate(req.Tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := h.apiKeyDB.SetDefaultTags(key.ID, req.Tags); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to set tags",
+		})
+		return
+	}
+	key, err := h.apiKeyDB.GetAPIKeyByID(key.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load key",
+		})
+		return
+	}
+
+	h.audit(c, "admin_key_tags_updated", map[string]any{"api_key_id": key.ID, "tags": req.Tags})
+
+	c.JSON(http.StatusOK, NewKeyInfo(key))
+}
+
+// EnrollKeyTOTP handles POST /admin/keys/:id/2fa. 2FA is enabled
+// immediately; ?force=true replaces an existing enrollment.
+func (h *AdminHandler) EnrollKeyTOTP(c *gin.Context) {
+	key, ok := h.loadKey(c)
+	if !ok {
+		return
+	}
+	if h.totpManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "2FA is not available",
+		})
+		return
+	}
+
+	enabled, err := h.totpManager.IsTOTPEnabled(key.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to read 2FA status",
+		})
+		return
+	}
+	if enabled && c.Query("force") != "true" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "2FA already enabled",
+			"message": "Pass force=true to replace the existing secret",
+		})
+		return
+	}
+
+	account := key.Email
+	if account == "" {
+		account = key.Name
+	}
+
+	otpKey, backupCodes, err := h.totpManager.GenerateTOTP(key.ID, account, TOTPIssuer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to enroll 2FA",
+		})
+		return
+	}
+
+	h.audit(c, "admin_2fa_enrolled", map[string]any{"api_key_id": key.ID})
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, TOTPEnrollment{
+		APIKeyID:    key.ID,
+		OTPAuthURI:  otpKey.URL(),
+		Secret:      otpKey.Secret(),
+		Issuer:      TOTPIssuer,
+		Account:     account,
+		BackupCodes: backupCodes,
+	})
+}
+
+// ListKeySessions handles GET /admin/keys/:id/sessions
+func (h *AdminHandler) ListKeySessions(c *gin.Context) {
+	key, ok := h.loadKey(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionManager.ListUserSessions(key.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list sessions",
+		})
+		return
+	}
+
+	result := make([]SessionInfo, 0, len(sessions))
+	for i := range sessions {
+		result = append(result, NewSessionInfo(&sessions[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": result,
+		"count":    len(result),
+	})
+}
+
+// RevokeKeySession handles DELETE /admin/keys/:id/sessions/:sessionID
+func (h *AdminHandler) RevokeKeySession(c *gin.Context) {
+	key, ok := h.loadKey(c)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(c.Param("sessionID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid session ID",
+		})
+		return
+	}
+
+	if err := h.sessionManager.RevokeUserSession(key.ID, sessionID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Session not found",
+		})
+		return
+	}
+
+	h.audit(c, "admin_session_revoked", map[string]any{"api_key_id": key.ID, "session_id": sessionID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked",
+	})
+}
+
+// CreateKeySigV4 handles POST /admin/keys/:id/sigv4, issuing an AWS-style
+// access key for SDK clients that sign requests with SigV4
+func (h *AdminHandler) CreateKeySigV4(c *gin.Context) {
+	key, ok := h.loadKey(c)
+	if !ok {
+		return
+	}
+
+	accessKeyID, secret, err := h.apiKeyDB.GenerateSigV4Credentials(key.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create SigV4 credentials",
+		})
+		return
+	}
+
+	h.audit(c, "admin_sigv4_created", map[string]any{"api_key_id": key.ID, "access_key_id": accessKeyID})
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, IssuedSigV4Credential{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secret,
+		APIKeyID:        key.ID,
+		Message:         "Store the secret access key securely; it will not be shown again",
+	})
+}
+
+// ListKeySigV4 handles GET /admin/keys/:id/sigv4
+func (h *AdminHandler) ListKeySigV4(c *gin.Context) {
+	key, ok := h.loadKey(c)
+	if !ok {
+		return
+	}
+
+	creds, err := h.apiKeyDB.ListSigV4Credentials(key.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list SigV4 credentials",
+		})
+		return
+	}
+
+	result := make([]SigV4CredentialInfo, 0, len(creds))
+	for i := range creds {
+		result = append(result, NewSigV4CredentialInfo(&creds[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"credentials": result,
+		"count":       len(result),
+	})
+}
+
+// RevokeKeySigV4 handles DELETE /admin/keys/:id/sigv4/:accessKeyID
+func (h *AdminHandler) RevokeKeySigV4(c *gin.Context) {
+	key, ok := h.loadKey(c)
+	if !ok {
+		return
+	}
+
+	accessKeyID := c.Param("accessKeyID")
+	if err := h.apiKeyDB.RevokeSigV4Credential(key.ID, accessKeyID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "SigV4 credential not found",
+		})
+		return
+	}
+
+	h.audit(c, "admin_sigv4_revoked", map[string]any{"api_key_id": key.ID, "access_key_id": accessKeyID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "SigV4 credential revoked",
+	})
+}
+
+// ListAudit handles GET /admin/audit. Results are newest first (order=asc
+// for oldest first); pass next_cursor back as cursor for the next page.
+// See auth.ParseAuditFilter for the filters.
+func (h *AdminHandler) ListAudit(c *gin.Context) {
+	filter, err := auth.ParseAuditFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	entries, err := h.apiKeyDB.QueryAudit(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to query audit log",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":     entries,
+		"count":       len(entries),
+		"next_cursor": filter.NextCursor(entries),
+	})
+}
+
+// GetUsage handles GET /admin/usage: daily usage across keys, filtered by
+// from, to, api_key_id, team_id, provider, model and tag and grouped by
+// group_by. format=csv downloads the totals as CSV.
+func (h *AdminHandler) GetUsage(c *gin.Context) {
+	query, err := auth.ParseUsageQuery(c.Request.URL.Query(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if c.Query("format") != "csv" {
+		respondUsage(c, h.apiKeyDB, query)
+		return
+	}
+
+	rows, err := h.apiKeyDB.QueryUsage(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to query usage",
+		})
+		return
+	}
+
+	h.audit(c, "admin_usage_exported", map[string]any{
+		"filter": c.Request.URL.RawQuery,
+		"rows":   len(rows),
+	})
+
+	filename := "usage-" + time.Now().UTC().Format("20060102T150405Z") + ".csv"
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// Headers are sent; a failure can only truncate the output
+	if err := auth.WriteUsageCSV(c.Writer, query.GroupBy, rows); err != nil {
+		c.Error(err)
+	}
+}
+
+// SetAuditKeys sets the public keys that verify audit checkpoint signatures
+func (h *AdminHandler) SetAuditKeys(keys []ed25519.PublicKey) {
+	h.auditKeys = keys
+}
+
+// VerifyAudit handles GET /admin/audit/verify, recomputing the audit hash
+// chain and checking it against the signed checkpoints. A broken chain is
+// reported with ok=false and the first broken link.
+func (h *AdminHandler) VerifyAudit(c *gin.Context) {
+	report, err := h.apiKeyDB.VerifyAudit(h.auditKeys)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify audit log",
+		})
+		return
+	}
+
+	h.audit(c, "admin_audit_verified", map[string]any{"ok": report.OK, "entries": report.Entries})
+	c.JSON(http.StatusOK, report)
+}
+
+// ExportAudit handles GET /admin/audit/export?format=jsonl|csv, streaming
+// every record matching the filters oldest first
+func (h *AdminHandler) ExportAudit(c *gin.Context) {
+	filter, err := auth.ParseAuditFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", auth.AuditFormatJSONL)
+	contentType := "application/x-ndjson"
+	if format == auth.AuditFormatCSV {
+		contentType = "text/csv"
+	}
+
+	writer, err := auth.NewAuditWriter(c.Writer, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, "admin_audit_exported", map[string]any{
+		"format": format,
+		"filter": filter.Values().Encode(),
+	})
+
+	filename := "audit-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// Headers are sent; a failure can only truncate the stream
+	written := 0
+	err = h.apiKeyDB.ExportAudit(filter, func(entry auth.AuditEntry) error {
+		if err := writer.Write(entry); err != nil {
+			return err
+		}
+		if written++; written%auditFlushEvery == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Writer.Flush()
+}
+
+// loadKey loads the active API key named by the :id path parameter
+func (h *AdminHandler) loadKey(c *gin.Context) (*auth.APIKey, bool) {
+	keyID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid key ID",
+		})
+		return nil, false
+	}
+
+	key, err := h.apiKeyDB.GetAPIKeyByID(keyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "API key not found",
+		})
+		return nil, false
+	}
+	return key, true
+}
+
+// audit records an admin action against the caller's key
+func (h *AdminHandler) audit(c *gin.Context, action string, metadata map[string]any) {
+	callerID, _ := c.Get("api_key_id")
+	callerKeyID, _ := callerID.(int64)
+	meta, _ := json.Marshal(metadata)
+
+	h.apiKeyDB.LogAPIKeyUsage(
+		callerKeyID,
+		action,
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+		c.Request.URL.Path,
+		c.Writer.Status(),
+		string(meta),
+	)
+}
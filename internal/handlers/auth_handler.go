@@ -4,10 +4,15 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,6 +22,7 @@ type AuthHandler struct {
 	totpManager    *auth.TOTPManager
 	sessionManager *auth.SessionManager
 	sessionDuration time.Duration
+	guard          *auth.BruteForceGuard
 }
 
 // NewAuthHandler creates a new auth handler
@@ -25,12 +31,14 @@ func NewAuthHandler(
 	totpManager *auth.TOTPManager,
 	sessionManager *auth.SessionManager,
 	sessionDuration time.Duration,
+	guard *auth.BruteForceGuard,
 ) *AuthHandler {
 	return &AuthHandler{
 		apiKeyDB:       apiKeyDB,
 		totpManager:    totpManager,
 		sessionManager: sessionManager,
 		sessionDuration: sessionDuration,
+		guard:          guard,
 	}
 }
 
@@ -61,6 +69,13 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Reject locked-out clients before checking credentials
+	ipSubject, keySubject := auth.IPSubject(c.ClientIP()), auth.KeySubject(c.ClientIP(), req.APIKey)
+	if wait, locked := h.guard.Check(ipSubject, keySubject); locked {
+		middleware.RespondLocked(c, wait)
+		return
+	}
+
 	// Validate API key
 	keyInfo, err := h.apiKeyDB.ValidateAPIKey(req.APIKey)
 	if err != nil {
@@ -73,6 +88,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			401,
 			`{"error":"invalid_api_key"}`,
 		)
+		h.recordFailure(c, 0, ipSubject, keySubject)
 
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "Invalid API key",
@@ -82,6 +98,22 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Validate TOTP
 	valid, err := h.totpManager.ValidateTOTP(keyInfo.ID, req.TOTPCode)
+	if errors.Is(err, auth.ErrTOTPLocked) {
+		h.apiKeyDB.LogAPIKeyUsage(
+			keyInfo.ID,
+			"2fa_locked",
+			c.ClientIP(),
+			c.GetHeader("User-Agent"),
+			c.Request.URL.Path,
+			429,
+			`{"error":"totp_locked"}`,
+		)
+
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": "Too many failed 2FA attempts, try again later",
+		})
+		return
+	}
 	if err != nil || !valid {
 		h.apiKeyDB.LogAPIKeyUsage(
 			keyInfo.ID,
@@ -92,6 +124,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			401,
 			`{"error":"invalid_totp"}`,
 		)
+		h.recordFailure(c, keyInfo.ID, ipSubject, keySubject)
 
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "Invalid TOTP code",
@@ -99,6 +132,8 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	h.guard.RecordSuccess(keySubject)
+
 	// Generate session token
 	sessionToken, err := h.sessionManager.GenerateSessionToken(
 		keyInfo.ID,
@@ -164,42 +199,41 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		return
 	}
 
-	// Validate current token
-	session, apiKeyID, err := h.sessionManager.ValidateSessionToken(sessionToken)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Invalid or expired session token",
-		})
-		return
-	}
-
-	// Revoke old token
-	h.sessionManager.RevokeSessionToken(sessionToken)
-
-	// Generate new token with extended expiration
-	newToken, err := h.sessionManager.GenerateSessionToken(
-		apiKeyID,
+	// Rotate: the old token is invalidated and replaced by a new one
+	newToken, session, err := h.sessionManager.RotateSessionToken(
+		sessionToken,
 		h.sessionDuration,
 		c.ClientIP(),
 		c.GetHeader("User-Agent"),
 	)
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to refresh session",
+		if errors.Is(err, auth.ErrSessionReused) {
+			h.apiKeyDB.LogAPIKeyUsage(
+				0,
+				"session_reuse_detected",
+				c.ClientIP(),
+				c.GetHeader("User-Agent"),
+				c.Request.URL.Path,
+				401,
+				`{"error":"rotated_token_reused"}`,
+			)
+		}
+
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid or expired session token",
 		})
 		return
 	}
 
 	// Log refresh
 	h.apiKeyDB.LogAPIKeyUsage(
-		apiKeyID,
+		session.APIKeyID,
 		"session_refreshed",
 		c.ClientIP(),
 		c.GetHeader("User-Agent"),
 		c.Request.URL.Path,
 		200,
-		`{"old_session_id":` + string(rune(session.ID)) + `}`,
+		fmt.Sprintf(`{"old_session_id":%d,"family_id":%d}`, session.ID, session.FamilyID),
 	)
 
 	expiresAt := time.Now().Add(h.sessionDuration)
@@ -231,7 +265,7 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	}
 
 	// Validate and get session info before revoking
-	session, apiKeyID, err := h.sessionManager.ValidateSessionToken(sessionToken)
+	session, apiKeyID, err := h.sessionManager.ValidateSessionToken(sessionToken, c.ClientIP(), c.GetHeader("User-Agent"))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "Invalid session token",
@@ -255,7 +289,7 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		c.GetHeader("User-Agent"),
 		c.Request.URL.Path,
 		200,
-		`{"session_id":` + string(rune(session.ID)) + `}`,
+		fmt.Sprintf(`{"session_id":%d}`, session.ID),
 	)
 
 	c.JSON(http.StatusOK, gin.H{
@@ -281,7 +315,7 @@ func (h *AuthHandler) ListSessions(c *gin.Context) {
 	}
 
 	// Validate token and get API key ID
-	_, apiKeyID, err := h.sessionManager.ValidateSessionToken(sessionToken)
+	_, apiKeyID, err := h.sessionManager.ValidateSessionToken(sessionToken, c.ClientIP(), c.GetHeader("User-Agent"))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "Invalid session token",
@@ -341,7 +375,7 @@ func (h *AuthHandler) RevokeSession(c *gin.Context) {
 		}
 	}
 
-	_, _, err := h.sessionManager.ValidateSessionToken(currentToken)
+	_, apiKeyID, err := h.sessionManager.ValidateSessionToken(currentToken, c.ClientIP(), c.GetHeader("User-Agent"))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "Invalid session token",
@@ -349,11 +383,42 @@ func (h *AuthHandler) RevokeSession(c *gin.Context) {
 		return
 	}
 
-	// TODO: Verify session belongs to user before revoking
-	// For now, we'll add this in the session manager
+	id, err := strconv.ParseInt(sessionID, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid session ID",
+		})
+		return
+	}
+
+	// Only sessions owned by the caller's API key can be revoked
+	if err := h.sessionManager.RevokeUserSession(apiKeyID, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Session not found",
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Session revoked successfully",
 		"session_id": sessionID,
 	})
 }
+
+// recordFailure counts a failed login and audits any lockout it triggers
+func (h *AuthHandler) recordFailure(c *gin.Context, keyID int64, subjects ...string) {
+	wait := h.guard.RecordFailure(subjects...)
+	if wait <= 0 {
+		return
+	}
+
+	h.apiKeyDB.LogAPIKeyUsage(
+		keyID,
+		"auth_lockout",
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+		c.Request.URL.Path,
+		http.StatusTooManyRequests,
+		fmt.Sprintf(`{"retry_after":%d}`, int(math.Ceil(wait.Seconds()))),
+	)
+}
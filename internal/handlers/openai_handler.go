@@ -4,32 +4,85 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/dlp"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/health"
 	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers"
 	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/router"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tags"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tracing"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/transcript"
 	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/translator"
 	"github.com/bedrock-proxy/bedrock-iam-proxy/pkg/metrics"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // OpenAIHandler handles OpenAI-compatible API requests
 type OpenAIHandler struct {
-	router *router.Router
+	router        *router.Router
+	transcripts   *transcript.Logger
+	dlp           *dlp.Scanner
+	usage         *auth.APIKeyDB
+	health        *health.Prober
+	metricsTenant string
 }
 
+// Tenant label values for request metrics
+const (
+	MetricsTenantTeam = "team" // the key's team name
+	MetricsTenantKey  = "key"  // the API key ID
+	MetricsTenantNone = "none" // no tenant label value
+)
+
 // NewOpenAIHandler creates a new OpenAI handler
 func NewOpenAIHandler(r *router.Router) *OpenAIHandler {
 	return &OpenAIHandler{
-		router: r,
+		router:        r,
+		metricsTenant: MetricsTenantTeam,
 	}
 }
 
+// SetTranscriptLogger records sampled chat completions to l
+func (h *OpenAIHandler) SetTranscriptLogger(l *transcript.Logger) {
+	h.transcripts = l
+}
+
+// SetDLPScanner screens prompts for PII before they are sent to providers
+func (h *OpenAIHandler) SetDLPScanner(s *dlp.Scanner) {
+	h.dlp = s
+}
+
+// SetUsageStore records the usage of each completion in db for usage reporting
+func (h *OpenAIHandler) SetUsageStore(db *auth.APIKeyDB) {
+	h.usage = db
+}
+
+// SetProviderHealth counts provider call outcomes toward their error rates
+func (h *OpenAIHandler) SetProviderHealth(p *health.Prober) {
+	h.health = p
+}
+
+// SetMetricsTenant chooses what the tenant label of request metrics holds:
+// MetricsTenantTeam, MetricsTenantKey or MetricsTenantNone
+func (h *OpenAIHandler) SetMetricsTenant(mode string) {
+	h.metricsTenant = mode
+}
+
 // ChatCompletions handles POST /v1/chat/completions
 func (h *OpenAIHandler) ChatCompletions(c *gin.Context) {
 	startTime := time.Now()
@@ -59,6 +112,20 @@ func (h *OpenAIHandler) ChatCompletions(c *gin.Context) {
 		return
 	}
 
+	// Enforce the key's (or its team's) model allowlist
+	if policy, ok := c.Get("key_policy"); ok {
+		if p, ok := policy.(*auth.KeyPolicy); ok && !p.AllowsModel(req.Model) {
+			c.JSON(http.StatusForbidden, translator.ErrorResponse{
+				Error: translator.ErrorDetail{
+					Message: fmt.Sprintf("Model %q is not allowed for this API key", req.Model),
+					Type:    "permission_error",
+					Code:    "model_not_allowed",
+				},
+			})
+			return
+		}
+	}
+
 	// Generate request ID
 	requestID := fmt.Sprintf("chatcmpl-%s", uuid.New().String()[:8])
 
@@ -70,6 +137,16 @@ func (h *OpenAIHandler) ChatCompletions(c *gin.Context) {
 		req.Temperature = 1.0
 	}
 
+	// Trace the completion; provider calls become child spans
+	ctx, span := tracing.StartChat(c.Request.Context(), tracing.ChatRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+	})
+	defer endChatSpan(c, span)
+	c.Request = c.Request.WithContext(ctx)
+
 	// Route to appropriate provider
 	provider, modelInfo, err := h.router.RouteRequest(c.Request.Context(), req.Model, "")
 	if err != nil {
@@ -85,12 +162,155 @@ func (h *OpenAIHandler) ChatCompletions(c *gin.Context) {
 	}
 
 	log.Printf("Routing model %s to provider %s (model: %s)", req.Model, provider.Name(), modelInfo.Model)
+	span.SetAttributes(tracing.ProviderAttributes(provider.Name(), providers.TypeOf(provider))...)
+	forwardTags(providers.TypeOf(provider), &req, c.GetStringMapString("request_tags"))
+
+	// Keep PII from leaving the network
+	session, ok := h.applyDLP(c, provider, &req, requestID)
+	if !ok {
+		metrics.RecordLLMRequest(h.metricLabels(c, provider.Name(), req.Model), metrics.OutcomeBlocked, time.Since(startTime))
+		return
+	}
 
 	// Handle streaming vs non-streaming
 	if req.Stream {
-		h.handleStreamingRequest(c, provider, &req, modelInfo, requestID)
+		h.handleStreamingRequest(c, provider, &req, modelInfo, requestID, startTime, session)
 	} else {
-		h.handleNonStreamingRequest(c, provider, &req, modelInfo, requestID, startTime)
+		h.handleNonStreamingRequest(c, provider, &req, modelInfo, requestID, startTime, session)
+	}
+}
+
+// forwardTags passes cost-center tags to provider types that accept request
+// metadata: Bedrock requestMetadata, OpenAI metadata and user, and
+// Anthropic metadata.user_id (from user). Other providers get no metadata.
+func forwardTags(providerType string, req *translator.ChatCompletionRequest, requestTags map[string]string) {
+	switch providerType {
+	case "openai":
+		if len(requestTags) > 0 {
+			req.Metadata = tags.Merge(req.Metadata, requestTags)
+			if req.User == "" {
+				req.User = tags.String(requestTags)
+			}
+		}
+	case "bedrock":
+		req.Metadata = requestTags
+	case "anthropic":
+		req.Metadata = nil
+		if req.User == "" && len(requestTags) > 0 {
+			req.User = tags.String(requestTags)
+		}
+	default:
+		req.Metadata = nil
+	}
+}
+
+// applyDLP redacts or blocks PII in the request according to the policy for
+// the provider instance, its type and the model. It returns the session that
+// restores placeholders in the response, and false if the request was
+// rejected.
+func (h *OpenAIHandler) applyDLP(c *gin.Context, provider providers.Provider, req *translator.ChatCompletionRequest, requestID string) (*dlp.Session, bool) {
+	if h.dlp == nil {
+		return nil, true
+	}
+	providerName := provider.Name()
+	action := h.dlp.Action(providerName, providers.TypeOf(provider), req.Model)
+	if action == dlp.ActionAllow {
+		return nil, true
+	}
+
+	session := h.dlp.NewSession()
+	session.RedactRequest(req)
+	findings := session.Findings()
+	if len(findings) == 0 {
+		return nil, true
+	}
+	for detector, count := range findings {
+		metrics.DLPDetections.WithLabelValues(detector, action).Add(float64(count))
+	}
+
+	if action == dlp.ActionBlock {
+		log.Printf("DLP blocked request %s to %s: %s", requestID, providerName, session)
+		detectors := make([]string, 0, len(findings))
+		for detector := range findings {
+			detectors = append(detectors, detector)
+		}
+		sort.Strings(detectors)
+		c.JSON(http.StatusBadRequest, translator.ErrorResponse{
+			Error: translator.ErrorDetail{
+				Message: fmt.Sprintf("Request contains sensitive data (%s) that may not be sent to model %q", strings.Join(detectors, ", "), req.Model),
+				Type:    "invalid_request_error",
+				Code:    "sensitive_data_detected",
+			},
+		})
+		return nil, false
+	}
+
+	log.Printf("DLP redacted request %s to %s: %s", requestID, providerName, session)
+	return session, true
+}
+
+// buildProviderRequest translates an OpenAI request for the provider type,
+// writing an error response and returning false on failure
+func (h *OpenAIHandler) buildProviderRequest(c *gin.Context, providerType string, req *translator.ChatCompletionRequest) (*providers.ProviderRequest, bool) {
+	providerReq, err := newProviderRequest(c.Request.Context(), providerType, req)
+	if err != nil {
+		log.Printf("Translation error: %v", err)
+		c.JSON(http.StatusBadRequest, translator.ErrorResponse{
+			Error: translator.ErrorDetail{
+				Message: fmt.Sprintf("Failed to translate request: %v", err),
+				Type:    "invalid_request_error",
+				Code:    "translation_failed",
+			},
+		})
+		return nil, false
+	}
+	return providerReq, true
+}
+
+// newProviderRequest translates an OpenAI request for the provider type
+func newProviderRequest(ctx context.Context, providerType string, req *translator.ChatCompletionRequest) (*providers.ProviderRequest, error) {
+	if providerType == "bedrock" {
+		// Bedrock uses Converse API
+		providerReq, _, err := translator.TranslateOpenAIToConverseAPI(req)
+		if err != nil {
+			return nil, err
+		}
+		return providerReq, nil
+	}
+
+	// OpenAI and Azure speak OpenAI natively; Anthropic, Vertex, IBM and
+	// Oracle handle translation in their Invoke methods
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return &providers.ProviderRequest{
+		Method: "POST",
+		Path:   "/chat/completions",
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body:    reqBody,
+		Context: ctx,
+	}, nil
+}
+
+// ModelCheck returns a synthetic health check that sends a one-token
+// completion for model to the provider, translated as a client request
+// for it would be
+func ModelCheck(provider providers.Provider, model string) health.ProbeFunc {
+	return func(ctx context.Context) error {
+		req := &translator.ChatCompletionRequest{
+			Model:     model,
+			Messages:  []translator.ChatMessage{{Role: "user", Content: "ping"}},
+			MaxTokens: 1,
+		}
+		providerReq, err := newProviderRequest(ctx, providers.TypeOf(provider), req)
+		if err != nil {
+			return err
+		}
+		_, err = provider.Invoke(ctx, providerReq)
+		return err
 	}
 }
 
@@ -102,73 +322,16 @@ func (h *OpenAIHandler) handleNonStreamingRequest(
 	modelInfo *router.ProviderModelInfo,
 	requestID string,
 	startTime time.Time,
+	session *dlp.Session,
 ) {
-	// Translate OpenAI request to provider format
-	var providerReq *providers.ProviderRequest
-	var err error
-
-	providerName := provider.Name()
+	providerName, providerType := provider.Name(), providers.TypeOf(provider)
+	labels := h.metricLabels(c, providerName, req.Model)
+	record := h.startTranscript(c, providerName, req, modelInfo, requestID, startTime)
 
-	if providerName == "bedrock" {
-		// Bedrock uses Converse API
-		providerReq, _, err = translator.TranslateOpenAIToConverseAPI(req)
-		if err != nil {
-			log.Printf("Translation error: %v", err)
-			c.JSON(http.StatusBadRequest, translator.ErrorResponse{
-				Error: translator.ErrorDetail{
-					Message: fmt.Sprintf("Failed to translate request: %v", err),
-					Type:    "invalid_request_error",
-					Code:    "translation_failed",
-				},
-			})
-			return
-		}
-	} else if providerName == "openai" || providerName == "azure" {
-		// OpenAI and Azure speak OpenAI natively - pass through
-		reqBody, err := json.Marshal(req)
-		if err != nil {
-			log.Printf("Failed to marshal request: %v", err)
-			c.JSON(http.StatusBadRequest, translator.ErrorResponse{
-				Error: translator.ErrorDetail{
-					Message: "Failed to marshal request",
-					Type:    "invalid_request_error",
-					Code:    "marshal_failed",
-				},
-			})
-			return
-		}
-		providerReq = &providers.ProviderRequest{
-			Method: "POST",
-			Path:   "/chat/completions",
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-			Body:    reqBody,
-			Context: c.Request.Context(),
-		}
-	} else {
-		// Anthropic, Vertex, IBM, Oracle handle translation in their Invoke method
-		reqBody, err := json.Marshal(req)
-		if err != nil {
-			log.Printf("Failed to marshal request: %v", err)
-			c.JSON(http.StatusBadRequest, translator.ErrorResponse{
-				Error: translator.ErrorDetail{
-					Message: "Failed to marshal request",
-					Type:    "invalid_request_error",
-					Code:    "marshal_failed",
-				},
-			})
-			return
-		}
-		providerReq = &providers.ProviderRequest{
-			Method: "POST",
-			Path:   "/chat/completions",
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-			},
-			Body:    reqBody,
-			Context: c.Request.Context(),
-		}
+	// Translate OpenAI request to provider format
+	providerReq, ok := h.buildProviderRequest(c, providerType, req)
+	if !ok {
+		return
 	}
 
 	// Invoke provider
@@ -176,13 +339,15 @@ func (h *OpenAIHandler) handleNonStreamingRequest(
 	if err != nil {
 		log.Printf("Provider invocation error: %v", err)
 		h.handleProviderError(c, err)
+		metrics.RecordLLMRequest(labels, failureOutcome(c, metrics.OutcomeError), time.Since(startTime))
+		h.finishTranscript(record, c.Writer.Status(), startTime, nil, 0, err)
 		return
 	}
 
 	// Parse provider response and translate if needed
 	var openaiResp *translator.ChatCompletionResponse
 
-	if providerName == "bedrock" {
+	if providerType == "bedrock" {
 		// Bedrock returns Converse API format - translate to OpenAI
 		var converseResp translator.ConverseResponse
 		if err := json.Unmarshal(providerResp.Body, &converseResp); err != nil {
@@ -194,6 +359,8 @@ func (h *OpenAIHandler) handleNonStreamingRequest(
 					Code:    "response_parse_error",
 				},
 			})
+			metrics.RecordLLMRequest(labels, metrics.OutcomeError, time.Since(startTime))
+			h.finishTranscript(record, http.StatusInternalServerError, startTime, nil, 0, err)
 			return
 		}
 		openaiResp = translator.TranslateConverseToOpenAI(&converseResp, req.Model, requestID)
@@ -208,6 +375,8 @@ func (h *OpenAIHandler) handleNonStreamingRequest(
 					Code:    "response_parse_error",
 				},
 			})
+			metrics.RecordLLMRequest(labels, metrics.OutcomeError, time.Since(startTime))
+			h.finishTranscript(record, http.StatusInternalServerError, startTime, nil, 0, err)
 			return
 		}
 	}
@@ -215,31 +384,334 @@ func (h *OpenAIHandler) handleNonStreamingRequest(
 	// Set metadata
 	openaiResp.ID = requestID
 	openaiResp.Created = startTime.Unix()
+	session.RestoreResponse(openaiResp)
+	traceChatResponse(c, openaiResp, modelInfo.Model)
+
+	cost := h.reportCost(c, provider, modelInfo, openaiResp.Usage)
 
 	// Record metrics
 	duration := time.Since(startTime)
 	metrics.RequestDuration.WithLabelValues("POST", "200").Observe(duration.Seconds())
 	metrics.RequestsTotal.WithLabelValues("POST", "200").Inc()
+	recordUsage(labels, openaiResp.Usage, cost)
+	metrics.RecordLLMRequest(labels, metrics.OutcomeSuccess, duration)
+	h.storeUsage(c, providerName, req.Model, modelInfo.Model, requestID, openaiResp.Usage, cost)
 
 	c.JSON(http.StatusOK, openaiResp)
+	h.finishTranscript(record, http.StatusOK, startTime, openaiResp, cost, nil)
 }
 
-// handleStreamingRequest handles streaming chat completion
+// handleStreamingRequest streams a chat completion as OpenAI server-sent
+// events, translating the provider's native stream where needed
 func (h *OpenAIHandler) handleStreamingRequest(
 	c *gin.Context,
 	provider providers.Provider,
 	req *translator.ChatCompletionRequest,
 	modelInfo *router.ProviderModelInfo,
 	requestID string,
+	startTime time.Time,
+	session *dlp.Session,
 ) {
-	// TODO: Implement streaming support
-	c.JSON(http.StatusNotImplemented, translator.ErrorResponse{
-		Error: translator.ErrorDetail{
-			Message: "Streaming not yet implemented",
-			Type:    "not_implemented_error",
-			Code:    "streaming_not_implemented",
-		},
-	})
+	providerName, providerType := provider.Name(), providers.TypeOf(provider)
+	labels := h.metricLabels(c, providerName, req.Model)
+	record := h.startTranscript(c, providerName, req, modelInfo, requestID, startTime)
+
+	// Ask OpenAI for usage so the cost can be reported even when the client
+	// did not. Older Azure API versions reject stream_options, so Azure only
+	// gets it when the client sent it.
+	upstreamReq := *req
+	if providerType == "openai" {
+		upstreamReq.StreamOptions = &translator.StreamOptions{IncludeUsage: true}
+	}
+
+	providerReq, ok := h.buildProviderRequest(c, providerType, &upstreamReq)
+	if !ok {
+		return
+	}
+
+	body, err := provider.InvokeStreaming(c.Request.Context(), providerReq)
+	if err != nil {
+		log.Printf("Provider streaming error: %v", err)
+		h.handleProviderError(c, err)
+		metrics.RecordLLMRequest(labels, failureOutcome(c, metrics.OutcomeError), time.Since(startTime))
+		h.finishTranscript(record, c.Writer.Status(), startTime, nil, 0, err)
+		return
+	}
+	defer body.Close()
+
+	var decoder translator.StreamDecoder
+	if providerType == "bedrock" {
+		decoder = translator.NewConverseStreamDecoder(body)
+	} else {
+		decoder = translator.NewOpenAIStreamDecoder(body)
+	}
+	includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+	restorer := session.NewStreamRestorer()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	var accumulator translator.StreamAccumulator
+	var firstToken time.Duration
+	var lastToken time.Time
+	var streamErr error
+	for {
+		chunk, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			streamErr = err
+			break
+		}
+
+		chunk.ID = requestID
+		chunk.Object = "chat.completion.chunk"
+		chunk.Created = startTime.Unix()
+		chunk.Model = req.Model
+		restorer.RestoreChunk(chunk)
+		accumulator.Add(chunk)
+		if hasDelta(chunk) {
+			now := time.Now()
+			if firstToken == 0 {
+				firstToken = now.Sub(startTime)
+				metrics.RecordTimeToFirstToken(labels, firstToken)
+			} else {
+				metrics.RecordInterTokenLatency(labels, now.Sub(lastToken))
+			}
+			lastToken = now
+		}
+
+		if chunk.Usage != nil && !includeUsage {
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			chunk.Usage = nil
+		}
+		if err := translator.WriteStreamChunk(c.Writer, chunk); err != nil {
+			streamErr = err
+			break
+		}
+		c.Writer.Flush()
+	}
+
+	// Release text held back by an unfinished placeholder
+	if chunk := restorer.Flush(); chunk != nil && c.Request.Context().Err() == nil {
+		chunk.ID = requestID
+		chunk.Created = startTime.Unix()
+		chunk.Model = req.Model
+		accumulator.Add(chunk)
+		translator.WriteStreamChunk(c.Writer, chunk)
+	}
+
+	if streamErr != nil && c.Request.Context().Err() == nil {
+		log.Printf("Provider stream error: %v", streamErr)
+		translator.WriteStreamError(c.Writer, translator.ErrorDetail{
+			Message: streamErr.Error(),
+			Type:    "api_error",
+			Code:    "stream_error",
+		})
+	}
+	translator.WriteStreamDone(c.Writer)
+	c.Writer.Flush()
+
+	resp := accumulator.Response()
+	traceChatResponse(c, resp, modelInfo.Model)
+	if streamErr != nil {
+		tracing.RecordError(trace.SpanFromContext(c.Request.Context()), streamErr, "stream_error")
+	}
+	cost := h.reportCost(c, provider, modelInfo, resp.Usage)
+
+	// Record metrics
+	duration := time.Since(startTime)
+	metrics.RequestDuration.WithLabelValues("POST", "200").Observe(duration.Seconds())
+	metrics.RequestsTotal.WithLabelValues("POST", "200").Inc()
+	recordUsage(labels, resp.Usage, cost)
+	h.storeUsage(c, providerName, req.Model, modelInfo.Model, requestID, resp.Usage, cost)
+	outcome := metrics.OutcomeSuccess
+	if streamErr != nil {
+		outcome = failureOutcome(c, metrics.OutcomeStreamError)
+	}
+	metrics.RecordLLMRequest(labels, outcome, duration)
+
+	if record != nil {
+		record.TimeToFirstTokenMs = firstToken.Milliseconds()
+	}
+	h.finishTranscript(record, http.StatusOK, startTime, resp, cost, streamErr)
+}
+
+// traceChatResponse records usage and finish reasons on the request's chat span
+func traceChatResponse(c *gin.Context, resp *translator.ChatCompletionResponse, providerModel string) {
+	chat := tracing.ChatResponse{ID: resp.ID, Model: providerModel}
+	if resp.Usage != nil {
+		chat.InputTokens = resp.Usage.PromptTokens
+		chat.OutputTokens = resp.Usage.CompletionTokens
+	}
+	for _, choice := range resp.Choices {
+		if choice.FinishReason != "" {
+			chat.FinishReasons = append(chat.FinishReasons, choice.FinishReason)
+		}
+	}
+	tracing.SetChatResponse(trace.SpanFromContext(c.Request.Context()), chat)
+}
+
+// endChatSpan marks the chat span failed when the request did not succeed
+func endChatSpan(c *gin.Context, span trace.Span) {
+	if status := c.Writer.Status(); status >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(status))
+		span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(status)))
+	}
+	span.End()
+}
+
+// metricLabels identifies a request in metrics
+func (h *OpenAIHandler) metricLabels(c *gin.Context, providerName, model string) metrics.Labels {
+	labels := metrics.Labels{Provider: providerName, Model: model}
+	switch h.metricsTenant {
+	case MetricsTenantTeam:
+		if policy, ok := c.Get("key_policy"); ok {
+			if p, ok := policy.(*auth.KeyPolicy); ok {
+				labels.Tenant = p.TeamName
+			}
+		}
+	case MetricsTenantKey:
+		if keyID := c.GetInt64("api_key_id"); keyID != 0 {
+			labels.Tenant = strconv.FormatInt(keyID, 10)
+		}
+	}
+	return labels
+}
+
+// failureOutcome returns outcome, or canceled when the client went away
+func failureOutcome(c *gin.Context, outcome string) string {
+	if c.Request.Context().Err() != nil {
+		return metrics.OutcomeCanceled
+	}
+	return outcome
+}
+
+// recordUsage records token and cost metrics for a completion
+func recordUsage(labels metrics.Labels, usage *translator.Usage, cost float64) {
+	if usage == nil {
+		return
+	}
+	metrics.RecordTokens(labels, usage.PromptTokens, usage.CompletionTokens)
+	metrics.RecordCost(labels, cost)
+}
+
+// hasDelta reports whether a chunk carries generated content
+func hasDelta(chunk *translator.ChatCompletionStreamResponse) bool {
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content != "" || len(choice.Delta.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// storeUsage adds a completion to the usage records. Failures are logged
+// rather than failing a request that has already been served.
+func (h *OpenAIHandler) storeUsage(c *gin.Context, providerName, model, providerModel, requestID string, usage *translator.Usage, cost float64) {
+	if h.usage == nil {
+		return
+	}
+	record := &auth.UsageRecord{
+		RequestID:     requestID,
+		APIKeyID:      c.GetInt64("api_key_id"),
+		Provider:      providerName,
+		Model:         model,
+		ProviderModel: providerModel,
+		CostUSD:       cost,
+		Tags:          c.GetStringMapString("request_tags"),
+	}
+	if policy, ok := c.Get("key_policy"); ok {
+		if p, ok := policy.(*auth.KeyPolicy); ok {
+			record.TeamID = p.TeamID
+		}
+	}
+	if usage != nil {
+		record.InputTokens = usage.PromptTokens
+		record.OutputTokens = usage.CompletionTokens
+	}
+	if err := h.usage.RecordUsage(record); err != nil {
+		log.Printf("Failed to record usage for %s: %v", requestID, err)
+	}
+}
+
+// reportCost sets the request cost for team and key budgets and returns it
+func (h *OpenAIHandler) reportCost(c *gin.Context, provider providers.Provider, modelInfo *router.ProviderModelInfo, usage *translator.Usage) float64 {
+	if usage == nil {
+		return 0
+	}
+	model, err := provider.GetModelInfo(c.Request.Context(), modelInfo.Model)
+	if err != nil {
+		return 0
+	}
+	cost := model.CalculateCost(usage.PromptTokens, usage.CompletionTokens)
+	c.Set("request_cost_usd", cost)
+	return cost
+}
+
+// startTranscript returns a record for a sampled request, or nil
+func (h *OpenAIHandler) startTranscript(
+	c *gin.Context,
+	providerName string,
+	req *translator.ChatCompletionRequest,
+	modelInfo *router.ProviderModelInfo,
+	requestID string,
+	startTime time.Time,
+) *transcript.Record {
+	keyID := c.GetInt64("api_key_id")
+	if !h.transcripts.Sample(keyID, req.Model) {
+		return nil
+	}
+	return &transcript.Record{
+		Timestamp:     startTime.UTC(),
+		RequestID:     requestID,
+		APIKeyID:      keyID,
+		Model:         req.Model,
+		Provider:      providerName,
+		ProviderModel: modelInfo.Model,
+		Stream:        req.Stream,
+		Request:       req,
+	}
+}
+
+// finishTranscript completes a sampled record and queues it for writing
+func (h *OpenAIHandler) finishTranscript(
+	record *transcript.Record,
+	status int,
+	startTime time.Time,
+	resp *translator.ChatCompletionResponse,
+	cost float64,
+	err error,
+) {
+	if record == nil {
+		return
+	}
+
+	record.StatusCode = status
+	record.LatencyMs = time.Since(startTime).Milliseconds()
+	record.CostUSD = cost
+	if resp != nil {
+		record.Response = resp
+		if resp.Usage != nil {
+			record.PromptTokens = resp.Usage.PromptTokens
+			record.CompletionTokens = resp.Usage.CompletionTokens
+		}
+		if len(resp.Choices) > 0 {
+			if text, ok := resp.Choices[0].Message.Content.(string); ok {
+				record.Completion = text
+			}
+		}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	h.transcripts.Log(record)
 }
 
 // handleProviderError converts provider errors to OpenAI error format
@@ -296,9 +768,12 @@ func (h *OpenAIHandler) ListModels(c *gin.Context) {
 		return
 	}
 
-	// Convert to OpenAI format
+	// Convert to OpenAI format, leaving out models that cannot be routed
 	openaiModels := []translator.Model{}
 	for _, model := range models {
+		if !model.Available {
+			continue
+		}
 		openaiModels = append(openaiModels, translator.Model{
 			ID:      model.ID,
 			Object:  "model",
@@ -318,7 +793,7 @@ func (h *OpenAIHandler) GetModel(c *gin.Context) {
 	modelID := c.Param("model")
 
 	modelInfo, err := h.router.GetModelInfo(c.Request.Context(), modelID)
-	if err != nil {
+	if err != nil || !modelInfo.Available {
 		c.JSON(http.StatusNotFound, translator.ErrorResponse{
 			Error: translator.ErrorDetail{
 				Message: fmt.Sprintf("Model %q not found", modelID),
@@ -0,0 +1,568 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// TeamHandler handles team management. Global admins manage teams and
+// membership; team admins manage the API keys of their own team.
+type TeamHandler struct {
+	apiKeyDB *auth.APIKeyDB
+}
+
+// NewTeamHandler creates a new team handler
+func NewTeamHandler(apiKeyDB *auth.APIKeyDB) *TeamHandler {
+	return &TeamHandler{apiKeyDB: apiKeyDB}
+}
+
+// TeamRequest creates or updates a team
+type TeamRequest struct {
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	Permissions      []string `json:"permissions"`
+	AllowedModels    []string `json:"allowed_models"`
+	RateLimitRPM     int      `json:"rate_limit_rpm"`
+	MonthlyBudgetUSD float64  `json:"monthly_budget_usd"`
+}
+
+// TeamMemberRequest adds a member to a team
+type TeamMemberRequest struct {
+	Email string `json:"email" binding:"required"`
+	Role  string `json:"role"`
+}
+
+// AssignKeyRequest moves an existing key into a team
+type AssignKeyRequest struct {
+	APIKeyID int64 `json:"api_key_id" binding:"required"`
+}
+
+// TeamKeyRequest creates an API key for a team member
+type TeamKeyRequest struct {
+	Email         string `json:"email" binding:"required"`
+	Name          string `json:"name" binding:"required"`
+	Description   string `json:"description"`
+	ExpiresInDays int    `json:"expires_in_days"`
+	auth.KeyLimits
+}
+
+// TeamKey is the API representation of a team's key (never the hash)
+type TeamKey struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	Description string     `json:"description"`
+	KeyPrefix   string     `json:"key_prefix"`
+	IsActive    bool       `json:"is_active"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateTeam handles POST /admin/teams
+func (h *TeamHandler) CreateTeam(c *gin.Context) {
+	var req TeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	team := teamFromRequest(&req)
+	id, err := h.apiKeyDB.CreateTeam(team)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Failed to create team",
+		})
+		return
+	}
+	team.ID = id
+
+	h.audit(c, "team_created", map[string]any{"team_id": id, "name": team.Name})
+
+	c.JSON(http.StatusCreated, team)
+}
+
+// ListTeams handles GET /admin/teams
+func (h *TeamHandler) ListTeams(c *gin.Context) {
+	teams, err := h.apiKeyDB.ListTeams()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list teams",
+		})
+		return
+	}
+	if teams == nil {
+		teams = []auth.Team{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"teams": teams,
+		"count": len(teams),
+	})
+}
+
+// UpdateTeam handles PUT /admin/teams/:id
+func (h *TeamHandler) UpdateTeam(c *gin.Context) {
+	team, ok := h.loadTeam(c)
+	if !ok {
+		return
+	}
+
+	var req TeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	updated := teamFromRequest(&req)
+	updated.ID = team.ID
+	updated.Name = team.Name
+	updated.CreatedAt = team.CreatedAt
+	if err := h.apiKeyDB.UpdateTeam(updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update team",
+		})
+		return
+	}
+
+	h.audit(c, "team_updated", map[string]any{"team_id": team.ID})
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// AddMember handles POST /admin/teams/:id/members
+func (h *TeamHandler) AddMember(c *gin.Context) {
+	team, ok := h.loadTeam(c)
+	if !ok {
+		return
+	}
+
+	var req TeamMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+	if req.Role == "" {
+		req.Role = auth.TeamRoleMember
+	}
+
+	if err := h.apiKeyDB.AddTeamMember(team.ID, req.Email, req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, "team_member_added", map[string]any{
+		"team_id": team.ID,
+		"email":   req.Email,
+		"role":    req.Role,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member added",
+		"team_id": team.ID,
+		"email":   req.Email,
+		"role":    req.Role,
+	})
+}
+
+// RemoveMember handles DELETE /admin/teams/:id/members/:email
+func (h *TeamHandler) RemoveMember(c *gin.Context) {
+	team, ok := h.loadTeam(c)
+	if !ok {
+		return
+	}
+
+	email := c.Param("email")
+	if err := h.apiKeyDB.RemoveTeamMember(team.ID, email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to remove member",
+		})
+		return
+	}
+
+	h.audit(c, "team_member_removed", map[string]any{"team_id": team.ID, "email": email})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member removed",
+	})
+}
+
+// AssignKey handles POST /admin/teams/:id/keys
+func (h *TeamHandler) AssignKey(c *gin.Context) {
+	team, ok := h.loadTeam(c)
+	if !ok {
+		return
+	}
+
+	var req AssignKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+
+	if err := h.apiKeyDB.AssignKeyToTeam(req.APIKeyID, team.ID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, "team_key_assigned", map[string]any{"team_id": team.ID, "api_key_id": req.APIKeyID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Key assigned",
+		"team_id":    team.ID,
+		"api_key_id": req.APIKeyID,
+	})
+}
+
+// GetTeam handles GET /teams/:id
+func (h *TeamHandler) GetTeam(c *gin.Context) {
+	team, ok := h.authorizeTeam(c, false)
+	if !ok {
+		return
+	}
+
+	spent, _ := h.apiKeyDB.TeamSpend(team.ID, time.Now())
+
+	c.JSON(http.StatusOK, gin.H{
+		"team":            team,
+		"month_spend_usd": spent,
+	})
+}
+
+// ListMembers handles GET /teams/:id/members
+func (h *TeamHandler) ListMembers(c *gin.Context) {
+	team, ok := h.authorizeTeam(c, false)
+	if !ok {
+		return
+	}
+
+	members, err := h.apiKeyDB.ListTeamMembers(team.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list members",
+		})
+		return
+	}
+	if members == nil {
+		members = []auth.TeamMember{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"members": members,
+		"count":   len(members),
+	})
+}
+
+// ListKeys handles GET /teams/:id/keys
+func (h *TeamHandler) ListKeys(c *gin.Context) {
+	team, ok := h.authorizeTeam(c, true)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKeyDB.ListTeamKeys(team.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list keys",
+		})
+		return
+	}
+
+	result := make([]TeamKey, 0, len(keys))
+	for i := range keys {
+		result = append(result, teamKeyFrom(&keys[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"keys":  result,
+		"count": len(result),
+	})
+}
+
+// CreateKey handles POST /teams/:id/keys
+func (h *TeamHandler) CreateKey(c *gin.Context) {
+	team, ok := h.authorizeTeam(c, true)
+	if !ok {
+		return
+	}
+
+	var req TeamKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+	if !authorizeKeyPermissions(c, team, req.Permissions) {
+		return
+	}
+
+	var expiresIn *time.Duration
+	if req.ExpiresInDays > 0 {
+		d := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+		expiresIn = &d
+	}
+
+	apiKey, key, err := h.apiKeyDB.GenerateTeamAPIKey(team.ID, req.Name, req.Email, req.Description, expiresIn, req.KeyLimits)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, "team_key_created", map[string]any{
+		"team_id":    team.ID,
+		"api_key_id": key.ID,
+		"email":      req.Email,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"api_key": apiKey,
+		"key":     teamKeyFrom(key),
+		"message": "Store this key securely; it will not be shown again",
+	})
+}
+
+// UpdateKeyLimits handles PATCH /teams/:id/keys/:keyID
+func (h *TeamHandler) UpdateKeyLimits(c *gin.Context) {
+	team, ok := h.authorizeTeam(c, true)
+	if !ok {
+		return
+	}
+	key, ok := h.loadTeamKey(c, team.ID)
+	if !ok {
+		return
+	}
+
+	var limits auth.KeyLimits
+	if err := c.ShouldBindJSON(&limits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request",
+		})
+		return
+	}
+	if !authorizeKeyPermissions(c, team, limits.Permissions) {
+		return
+	}
+
+	if err := h.apiKeyDB.SetKeyLimits(key.ID, limits); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update key limits",
+		})
+		return
+	}
+
+	h.audit(c, "team_key_limits_updated", map[string]any{"team_id": team.ID, "api_key_id": key.ID})
+
+	policy, _ := h.apiKeyDB.EffectivePolicy(key.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Key limits updated",
+		"policy":  policy,
+	})
+}
+
+// RevokeKey handles DELETE /teams/:id/keys/:keyID
+func (h *TeamHandler) RevokeKey(c *gin.Context) {
+	team, ok := h.authorizeTeam(c, true)
+	if !ok {
+		return
+	}
+	key, ok := h.loadTeamKey(c, team.ID)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyDB.RevokeAPIKey(key.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to revoke key",
+		})
+		return
+	}
+
+	h.audit(c, "team_key_revoked", map[string]any{"team_id": team.ID, "api_key_id": key.ID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Key revoked",
+	})
+}
+
+// authorizeTeam loads the team from the path and checks the caller is a
+// global admin, a team admin, or (when adminOnly is false) a team member
+func (h *TeamHandler) authorizeTeam(c *gin.Context, adminOnly bool) (*auth.Team, bool) {
+	team, ok := h.loadTeam(c)
+	if !ok {
+		return nil, false
+	}
+
+	if hasPermission(c, "admin") {
+		return team, true
+	}
+
+	email := c.GetString("user_email")
+	role := ""
+	if email != "" {
+		role, _ = h.apiKeyDB.TeamRole(team.ID, email)
+	}
+
+	if role == auth.TeamRoleAdmin || (!adminOnly && role == auth.TeamRoleMember) {
+		return team, true
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":   "Forbidden",
+		"message": "Requires team admin role",
+	})
+	return nil, false
+}
+
+// authorizeKeyPermissions rejects key permissions the caller may not grant.
+// Team routes never grant "admin", and team admins can only grant what the
+// team holds ("*" takes the team's list).
+func authorizeKeyPermissions(c *gin.Context, team *auth.Team, permissions []string) bool {
+	globalAdmin := hasPermission(c, "admin")
+
+	for _, p := range permissions {
+		var message string
+		switch {
+		case strings.EqualFold(p, "admin"):
+			message = "The admin permission cannot be granted to team keys"
+		case p == "*" || globalAdmin || teamGrants(team, p):
+			continue
+		default:
+			message = "Team does not grant permission: " + p
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": message,
+		})
+		return false
+	}
+	return true
+}
+
+// teamGrants reports whether the team holds permission
+func teamGrants(team *auth.Team, permission string) bool {
+	for _, p := range team.Permissions {
+		if p == "*" || strings.EqualFold(p, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadTeam resolves the :id path parameter to a team
+func (h *TeamHandler) loadTeam(c *gin.Context) (*auth.Team, bool) {
+	teamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid team ID",
+		})
+		return nil, false
+	}
+
+	team, err := h.apiKeyDB.GetTeam(teamID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Team not found",
+		})
+		return nil, false
+	}
+	return team, true
+}
+
+// loadTeamKey resolves the :keyID path parameter to a key of the team
+func (h *TeamHandler) loadTeamKey(c *gin.Context, teamID int64) (*auth.APIKey, bool) {
+	keyID, err := strconv.ParseInt(c.Param("keyID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid key ID",
+		})
+		return nil, false
+	}
+
+	key, err := h.apiKeyDB.GetTeamKey(teamID, keyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Key not found",
+		})
+		return nil, false
+	}
+	return key, true
+}
+
+// audit records a team management action by the calling key
+func (h *TeamHandler) audit(c *gin.Context, action string, metadata map[string]any) {
+	callerID, _ := c.Get("api_key_id")
+	callerKeyID, _ := callerID.(int64)
+	meta, _ := json.Marshal(metadata)
+
+	h.apiKeyDB.LogAPIKeyUsage(
+		callerKeyID,
+		action,
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+		c.Request.URL.Path,
+		c.Writer.Status(),
+		string(meta),
+	)
+}
+
+// hasPermission reports whether the authenticated caller holds permission
+func hasPermission(c *gin.Context, permission string) bool {
+	permissions, _ := c.Get("permissions")
+	list, _ := permissions.([]string)
+	for _, p := range list {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func teamFromRequest(req *TeamRequest) *auth.Team {
+	return &auth.Team{
+		Name:             req.Name,
+		Description:      req.Description,
+		Permissions:      req.Permissions,
+		AllowedModels:    req.AllowedModels,
+		RateLimitRPM:     req.RateLimitRPM,
+		MonthlyBudgetUSD: req.MonthlyBudgetUSD,
+	}
+}
+
+func teamKeyFrom(key *auth.APIKey) TeamKey {
+	return TeamKey{
+		ID:          key.ID,
+		Name:        key.Name,
+		Email:       key.Email,
+		Description: key.Description,
+		KeyPrefix:   key.KeyPrefix,
+		IsActive:    key.IsActive,
+		CreatedAt:   key.CreatedAt,
+		LastUsedAt:  key.LastUsedAt,
+		ExpiresAt:   key.ExpiresAt,
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/middleware"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// teamTestServer serves the admin and team routes like the server does,
+// authenticating database API keys and applying team policy
+type teamTestServer struct {
+	t      *testing.T
+	db     *auth.APIKeyDB
+	router *gin.Engine
+}
+
+func newTeamTestServer(t *testing.T) *teamTestServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	store, err := storage.NewMemory()
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	db, err := auth.NewAPIKeyDBWithStore(store)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.SetPepper(bytes.Repeat([]byte("p"), 32)); err != nil {
+		t.Fatalf("Failed to set pepper: %v", err)
+	}
+
+	totpManager := auth.NewTOTPManager(db.DB())
+	authMiddleware := middleware.EnhancedAPIKeyAuth(db, totpManager, false, nil)
+	teamPolicy := middleware.TeamPolicy(db, middleware.NewRequestLimiter(), middleware.NewBudgetAlerts(nil))
+	adminHandler := NewAdminHandler(db, auth.NewSessionManager(db.DB()), totpManager, nil)
+	teamHandler := NewTeamHandler(db)
+
+	router := gin.New()
+	adminGroup := router.Group("/admin")
+	adminGroup.Use(authMiddleware, teamPolicy, middleware.RequirePermission("admin"))
+	adminGroup.GET("/keys", adminHandler.ListKeys)
+	adminGroup.GET("/teams", teamHandler.ListTeams)
+	adminGroup.POST("/teams/:id/members", teamHandler.AddMember)
+
+	teamGroup := router.Group("/teams")
+	teamGroup.Use(authMiddleware, teamPolicy)
+	teamGroup.GET("/:id", teamHandler.GetTeam)
+	teamGroup.GET("/:id/members", teamHandler.ListMembers)
+	teamGroup.GET("/:id/keys", teamHandler.ListKeys)
+	teamGroup.POST("/:id/keys", teamHandler.CreateKey)
+	teamGroup.PATCH("/:id/keys/:keyID", teamHandler.UpdateKeyLimits)
+	teamGroup.DELETE("/:id/keys/:keyID", teamHandler.RevokeKey)
+
+	// /v1/echo stands in for a completion that costs a dollar
+	v1Group := router.Group("/v1")
+	v1Group.Use(authMiddleware, teamPolicy)
+	v1Group.POST("/chat/completions", NewOpenAIHandler(nil).ChatCompletions)
+	v1Group.POST("/echo", func(c *gin.Context) {
+		c.Set("request_cost_usd", 1.0)
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	return &teamTestServer{t: t, db: db, router: router}
+}
+
+// createKey stores a key for email with permissions and returns the secret
+func (s *teamTestServer) createKey(email string, permissions ...string) (string, int64) {
+	s.t.Helper()
+
+	apiKey, key, err := s.db.CreateAPIKeyWithSettings(email, email, "", nil, auth.KeySettings{Permissions: permissions})
+	if err != nil {
+		s.t.Fatalf("Failed to create key: %v", err)
+	}
+	return apiKey, key.ID
+}
+
+// createTeam creates a team with members given as email to role
+func (s *teamTestServer) createTeam(team *auth.Team, members map[string]string) string {
+	s.t.Helper()
+
+	id, err := s.db.CreateTeam(team)
+	if err != nil {
+		s.t.Fatalf("Failed to create team: %v", err)
+	}
+	for email, role := range members {
+		if err := s.db.AddTeamMember(id, email, role); err != nil {
+			s.t.Fatalf("Failed to add member: %v", err)
+		}
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// do sends a request authenticated with apiKey and decodes a JSON response
+func (s *teamTestServer) do(method, path, apiKey string, body any) (int, map[string]any) {
+	s.t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("X-API-Key", apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	var resp map[string]any
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestTeamKeyPermissions(t *testing.T) {
+	s := newTeamTestServer(t)
+	adminKey, _ := s.createKey("root@example.com", "admin")
+	aliceKey, _ := s.createKey("alice@example.com")
+
+	// A team created without a permissions list grants none
+	teamID := s.createTeam(&auth.Team{Name: "ml-platform"}, map[string]string{
+		"alice@example.com": auth.TeamRoleAdmin,
+	})
+	keys := "/teams/" + teamID + "/keys"
+
+	t.Run("team admin cannot grant admin", func(t *testing.T) {
+		code, resp := s.do("POST", keys, aliceKey, map[string]any{
+			"email": "alice@example.com", "name": "escalate", "permissions": []string{"admin"},
+		})
+		if code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d: %v", code, resp)
+		}
+	})
+
+	t.Run("team admin cannot grant what the team lacks", func(t *testing.T) {
+		code, resp := s.do("POST", keys, aliceKey, map[string]any{
+			"email": "alice@example.com", "name": "reports", "permissions": []string{"usage"},
+		})
+		if code != http.StatusForbidden || !strings.Contains(resp["message"].(string), "usage") {
+			t.Errorf("Expected 403 naming the permission, got %d: %v", code, resp)
+		}
+	})
+
+	t.Run("global admin cannot grant admin to team keys", func(t *testing.T) {
+		code, resp := s.do("POST", keys, adminKey, map[string]any{
+			"email": "alice@example.com", "name": "escalate", "permissions": []string{"Admin"},
+		})
+		if code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d: %v", code, resp)
+		}
+	})
+
+	code, resp := s.do("POST", keys, aliceKey, map[string]any{"email": "alice@example.com", "name": "ci"})
+	if code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %v", code, resp)
+	}
+	teamKey := resp["api_key"].(string)
+	teamKeyID := int64(resp["key"].(map[string]any)["id"].(float64))
+
+	t.Run("limits cannot add admin", func(t *testing.T) {
+		path := keys + "/" + strconv.FormatInt(teamKeyID, 10)
+		code, resp := s.do("PATCH", path, aliceKey, map[string]any{"permissions": []string{"admin"}})
+		if code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d: %v", code, resp)
+		}
+	})
+
+	t.Run("team-admin-issued key is not a global admin", func(t *testing.T) {
+		if code, resp := s.do("GET", "/admin/keys", teamKey, nil); code != http.StatusForbidden {
+			t.Errorf("Expected 403 on /admin/keys, got %d: %v", code, resp)
+		}
+
+		// Even when the key itself lists admin, the team does not grant it
+		if err := s.db.SetKeyLimits(teamKeyID, auth.KeyLimits{Permissions: []string{"admin"}}); err != nil {
+			t.Fatalf("Failed to set limits: %v", err)
+		}
+		if code, resp := s.do("GET", "/admin/keys", teamKey, nil); code != http.StatusForbidden {
+			t.Errorf("Expected 403 on /admin/keys, got %d: %v", code, resp)
+		}
+	})
+
+	if code, resp := s.do("GET", "/admin/keys", adminKey, nil); code != http.StatusOK {
+		t.Errorf("Expected the global admin to list keys, got %d: %v", code, resp)
+	}
+}
+
+func TestTeamRoutesAuthorization(t *testing.T) {
+	s := newTeamTestServer(t)
+	keys := map[string]string{}
+	keys["global admin"], _ = s.createKey("root@example.com", "admin")
+	keys["team admin"], _ = s.createKey("alice@example.com")
+	keys["team member"], _ = s.createKey("bob@example.com")
+	keys["outsider"], _ = s.createKey("mallory@example.com")
+
+	teamID := s.createTeam(&auth.Team{Name: "ml-platform", Permissions: []string{"invoke"}}, map[string]string{
+		"alice@example.com": auth.TeamRoleAdmin,
+		"bob@example.com":   auth.TeamRoleMember,
+	})
+	_, bobKey, err := s.db.GenerateTeamAPIKey(mustParseID(t, teamID), "bob-notebook", "bob@example.com", "", nil, auth.KeyLimits{})
+	if err != nil {
+		t.Fatalf("Failed to create team key: %v", err)
+	}
+	keyPath := "/teams/" + teamID + "/keys/" + strconv.FormatInt(bobKey.ID, 10)
+	newKey := map[string]any{"email": "bob@example.com", "name": "bob-batch"}
+
+	// Expected status per caller: global admin, team admin, team member, outsider
+	tests := []struct {
+		method string
+		path   string
+		body   any
+		want   [4]int
+	}{
+		{"GET", "/teams/" + teamID, nil, [4]int{200, 200, 200, 403}},
+		{"GET", "/teams/" + teamID + "/members", nil, [4]int{200, 200, 200, 403}},
+		{"GET", "/teams/" + teamID + "/keys", nil, [4]int{200, 200, 403, 403}},
+		{"POST", "/teams/" + teamID + "/keys", newKey, [4]int{201, 201, 403, 403}},
+		{"PATCH", keyPath, map[string]any{"rate_limit_rpm": 30}, [4]int{200, 200, 403, 403}},
+		{"GET", "/teams/999", nil, [4]int{404, 404, 404, 404}},
+		{"GET", "/admin/teams", nil, [4]int{200, 403, 403, 403}},
+		{"POST", "/admin/teams/" + teamID + "/members", map[string]any{"email": "carol@example.com"}, [4]int{200, 403, 403, 403}},
+	}
+
+	for _, tt := range tests {
+		for i, caller := range []string{"global admin", "team admin", "team member", "outsider"} {
+			t.Run(caller+" "+tt.method+" "+tt.path, func(t *testing.T) {
+				if code, resp := s.do(tt.method, tt.path, keys[caller], tt.body); code != tt.want[i] {
+					t.Errorf("Expected %d, got %d: %v", tt.want[i], code, resp)
+				}
+			})
+		}
+	}
+
+	t.Run("team admin revokes a team key", func(t *testing.T) {
+		if code, resp := s.do("DELETE", keyPath, keys["team member"], nil); code != http.StatusForbidden {
+			t.Errorf("Expected 403 for a member, got %d: %v", code, resp)
+		}
+		if code, resp := s.do("DELETE", keyPath, keys["team admin"], nil); code != http.StatusOK {
+			t.Errorf("Expected 200, got %d: %v", code, resp)
+		}
+		if _, err := s.db.GetAPIKeyByID(bobKey.ID); err == nil {
+			t.Error("Expected the key to be revoked")
+		}
+	})
+
+	t.Run("keys of other teams are not found", func(t *testing.T) {
+		otherID := s.createTeam(&auth.Team{Name: "search"}, map[string]string{"alice@example.com": auth.TeamRoleAdmin})
+		path := "/teams/" + otherID + "/keys/" + strconv.FormatInt(bobKey.ID, 10)
+		if code, resp := s.do("PATCH", path, keys["team admin"], map[string]any{}); code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d: %v", code, resp)
+		}
+	})
+}
+
+func TestTeamPolicyAdminAccess(t *testing.T) {
+	s := newTeamTestServer(t)
+
+	t.Run("team granting admin", func(t *testing.T) {
+		teamID := s.createTeam(&auth.Team{Name: "platform-admins", Permissions: []string{"admin"}}, map[string]string{
+			"ops@example.com": auth.TeamRoleMember,
+		})
+		apiKey, _, err := s.db.GenerateTeamAPIKey(mustParseID(t, teamID), "ops", "ops@example.com", "", nil, auth.KeyLimits{})
+		if err != nil {
+			t.Fatalf("Failed to create team key: %v", err)
+		}
+		if code, resp := s.do("GET", "/admin/keys", apiKey, nil); code != http.StatusOK {
+			t.Errorf("Expected the inherited admin permission to reach /admin, got %d: %v", code, resp)
+		}
+	})
+
+	t.Run("admin key joining a team without admin", func(t *testing.T) {
+		teamID := s.createTeam(&auth.Team{Name: "ml-platform", Permissions: []string{"invoke"}}, map[string]string{
+			"root@example.com": auth.TeamRoleMember,
+		})
+		apiKey, keyID := s.createKey("root@example.com", "admin")
+		if code, resp := s.do("GET", "/admin/keys", apiKey, nil); code != http.StatusOK {
+			t.Fatalf("Expected 200 before joining the team, got %d: %v", code, resp)
+		}
+
+		if err := s.db.AssignKeyToTeam(keyID, mustParseID(t, teamID)); err != nil {
+			t.Fatalf("Failed to assign key: %v", err)
+		}
+		if code, resp := s.do("GET", "/admin/keys", apiKey, nil); code != http.StatusForbidden {
+			t.Errorf("Expected the team to narrow away admin, got %d: %v", code, resp)
+		}
+	})
+}
+
+func TestTeamPolicyLimits(t *testing.T) {
+	s := newTeamTestServer(t)
+
+	// teamKey creates a team with the given settings and a key in it
+	teamKey := func(team *auth.Team, limits auth.KeyLimits) (string, int64, int64) {
+		t.Helper()
+		email := team.Name + "@example.com"
+		teamID := mustParseID(t, s.createTeam(team, map[string]string{email: auth.TeamRoleMember}))
+		apiKey, key, err := s.db.GenerateTeamAPIKey(teamID, team.Name, email, "", nil, limits)
+		if err != nil {
+			t.Fatalf("Failed to create team key: %v", err)
+		}
+		return apiKey, key.ID, teamID
+	}
+
+	t.Run("model outside the allowlist", func(t *testing.T) {
+		apiKey, _, _ := teamKey(&auth.Team{Name: "haiku-only", AllowedModels: []string{"claude-3-haiku"}}, auth.KeyLimits{})
+		code, resp := s.do("POST", "/v1/chat/completions", apiKey, map[string]any{
+			"model":    "claude-3-opus",
+			"messages": []map[string]string{{"role": "user", "content": "hi"}},
+		})
+		errBody, _ := resp["error"].(map[string]any)
+		if code != http.StatusForbidden || errBody["code"] != "model_not_allowed" {
+			t.Errorf("Expected 403 model_not_allowed, got %d: %v", code, resp)
+		}
+	})
+
+	t.Run("key rate limit", func(t *testing.T) {
+		apiKey, _, _ := teamKey(&auth.Team{Name: "key-rate"}, auth.KeyLimits{RateLimitRPM: 2})
+		expectStatuses(t, s, apiKey, http.StatusOK, http.StatusOK)
+		expectLimited(t, s, apiKey, "rate_limit_exceeded", "key")
+	})
+
+	t.Run("team rate limit is shared", func(t *testing.T) {
+		first, _, teamID := teamKey(&auth.Team{Name: "team-rate", RateLimitRPM: 3}, auth.KeyLimits{})
+		if err := s.db.AddTeamMember(teamID, "second@example.com", auth.TeamRoleMember); err != nil {
+			t.Fatalf("Failed to add member: %v", err)
+		}
+		second, _, err := s.db.GenerateTeamAPIKey(teamID, "second", "second@example.com", "", nil, auth.KeyLimits{})
+		if err != nil {
+			t.Fatalf("Failed to create team key: %v", err)
+		}
+
+		expectStatuses(t, s, first, http.StatusOK, http.StatusOK)
+		expectStatuses(t, s, second, http.StatusOK)
+		expectLimited(t, s, second, "rate_limit_exceeded", "team")
+	})
+
+	t.Run("key budget", func(t *testing.T) {
+		apiKey, keyID, teamID := teamKey(&auth.Team{Name: "key-budget"}, auth.KeyLimits{MonthlyBudgetUSD: 2})
+		expectStatuses(t, s, apiKey, http.StatusOK, http.StatusOK)
+		if spent, _ := s.db.KeySpend(keyID, time.Now()); spent != 2 {
+			t.Errorf("Expected $2 recorded for the key, got %v", spent)
+		}
+		if spent, _ := s.db.TeamSpend(teamID, time.Now()); spent != 2 {
+			t.Errorf("Expected $2 recorded for the team, got %v", spent)
+		}
+		expectLimited(t, s, apiKey, "budget_exceeded", "key")
+	})
+
+	t.Run("team budget", func(t *testing.T) {
+		apiKey, _, teamID := teamKey(&auth.Team{Name: "team-budget", MonthlyBudgetUSD: 5}, auth.KeyLimits{})
+
+		// Another key of the team spent the shared budget
+		_, other, err := s.db.GenerateTeamAPIKey(teamID, "other", "team-budget@example.com", "", nil, auth.KeyLimits{})
+		if err != nil {
+			t.Fatalf("Failed to create team key: %v", err)
+		}
+		if err := s.db.RecordSpend(other.ID, teamID, 5, time.Now()); err != nil {
+			t.Fatalf("Failed to record spend: %v", err)
+		}
+		expectLimited(t, s, apiKey, "budget_exceeded", "team")
+	})
+}
+
+// expectStatuses sends one /v1/echo request per expected status
+func expectStatuses(t *testing.T, s *teamTestServer, apiKey string, want ...int) {
+	t.Helper()
+
+	for i, status := range want {
+		if code, resp := s.do("POST", "/v1/echo", apiKey, nil); code != status {
+			t.Fatalf("Request %d: expected %d, got %d: %v", i+1, status, code, resp)
+		}
+	}
+}
+
+// expectLimited checks the next /v1/echo request is rejected with 429
+func expectLimited(t *testing.T, s *teamTestServer, apiKey, code, scope string) {
+	t.Helper()
+
+	status, resp := s.do("POST", "/v1/echo", apiKey, nil)
+	if status != http.StatusTooManyRequests || resp["code"] != code || resp["scope"] != scope {
+		t.Errorf("Expected 429 %s for the %s, got %d: %v", code, scope, status, resp)
+	}
+}
+
+func mustParseID(t *testing.T, id string) int64 {
+	t.Helper()
+
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		t.Fatalf("Invalid ID %q: %v", id, err)
+	}
+	return n
+}
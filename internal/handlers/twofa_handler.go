@@ -0,0 +1,257 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/png"
+	"net/http"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// TOTPIssuer is shown as the account issuer in authenticator apps
+const TOTPIssuer = "Bedrock Proxy"
+
+// qrCodeSize is the width and height of enrollment QR codes in pixels
+const qrCodeSize = 256
+
+// TwoFACodeRequest carries a TOTP (or backup) code
+type TwoFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// EnrollResponse represents a pending 2FA enrollment
+type EnrollResponse struct {
+	OTPAuthURI string `json:"otpauth_uri"`
+	Secret     string `json:"secret"`
+	QRCodePNG  string `json:"qr_code_png"` // base64-encoded PNG
+	Issuer     string `json:"issuer"`
+	Account    string `json:"account"`
+	Message    string `json:"message"`
+}
+
+// Enroll2FA starts self-enrollment and returns the otpauth URI and QR code.
+// With ?format=png the QR code image is returned directly.
+func (h *AuthHandler) Enroll2FA(c *gin.Context) {
+	apiKeyID := c.GetInt64("api_key_id")
+
+	account := c.GetString("user_email")
+	if account == "" {
+		account = c.GetString("user")
+	}
+
+	key, err := h.totpManager.BeginEnrollment(apiKeyID, account, TOTPIssuer)
+	if errors.Is(err, auth.ErrTOTPAlreadyEnabled) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "2FA already enabled",
+			"message": "Disable 2FA with DELETE /auth/2fa before enrolling again",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to start 2FA enrollment",
+		})
+		return
+	}
+
+	img, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to render QR code",
+		})
+		return
+	}
+	var qr bytes.Buffer
+	if err := png.Encode(&qr, img); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to render QR code",
+		})
+		return
+	}
+
+	h.apiKeyDB.LogAPIKeyUsage(
+		apiKeyID,
+		"2fa_enroll_started",
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+		c.Request.URL.Path,
+		200,
+		`{}`,
+	)
+
+	// Never let intermediaries cache the secret
+	c.Header("Cache-Control", "no-store")
+
+	if c.Query("format") == "png" {
+		c.Data(http.StatusOK, "image/png", qr.Bytes())
+		return
+	}
+
+	c.JSON(http.StatusOK, EnrollResponse{
+		OTPAuthURI: key.URL(),
+		Secret:     key.Secret(),
+		QRCodePNG:  base64.StdEncoding.EncodeToString(qr.Bytes()),
+		Issuer:     TOTPIssuer,
+		Account:    account,
+		Message:    "Scan the QR code, then POST the first code to /auth/2fa/confirm",
+	})
+}
+
+// Confirm2FA enables 2FA once the first valid code is presented
+func (h *AuthHandler) Confirm2FA(c *gin.Context) {
+	var req TwoFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "Provide code",
+		})
+		return
+	}
+
+	apiKeyID := c.GetInt64("api_key_id")
+
+	backupCodes, err := h.totpManager.ConfirmEnrollment(apiKeyID, req.Code)
+	if err != nil {
+		h.respond2FAError(c, apiKeyID, "2fa_confirm_failed", err)
+		return
+	}
+
+	h.apiKeyDB.LogAPIKeyUsage(
+		apiKeyID,
+		"2fa_enabled",
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+		c.Request.URL.Path,
+		200,
+		`{}`,
+	)
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "2FA enabled. Store these backup codes securely; they are shown only once.",
+		"backup_codes": backupCodes,
+	})
+}
+
+// RegenerateBackupCodes replaces all backup codes of the caller
+func (h *AuthHandler) RegenerateBackupCodes(c *gin.Context) {
+	apiKeyID := c.GetInt64("api_key_id")
+
+	enabled, err := h.totpManager.IsTOTPEnabled(apiKeyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check 2FA status",
+		})
+		return
+	}
+	if !enabled {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "2FA is not enabled",
+		})
+		return
+	}
+
+	backupCodes, err := h.totpManager.RegenerateBackupCodes(apiKeyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to regenerate backup codes",
+		})
+		return
+	}
+
+	h.apiKeyDB.LogAPIKeyUsage(
+		apiKeyID,
+		"2fa_backup_codes_regenerated",
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+		c.Request.URL.Path,
+		200,
+		fmt.Sprintf(`{"count":%d}`, len(backupCodes)),
+	)
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Previous backup codes are no longer valid",
+		"backup_codes": backupCodes,
+	})
+}
+
+// Disable2FA turns off 2FA for the caller after checking a current code
+func (h *AuthHandler) Disable2FA(c *gin.Context) {
+	var req TwoFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "Provide a current code",
+		})
+		return
+	}
+
+	apiKeyID := c.GetInt64("api_key_id")
+
+	valid, err := h.totpManager.ValidateTOTP(apiKeyID, req.Code)
+	if err != nil || !valid {
+		h.respond2FAError(c, apiKeyID, "2fa_disable_failed", err)
+		return
+	}
+
+	if err := h.totpManager.DisableTOTP(apiKeyID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to disable 2FA",
+		})
+		return
+	}
+
+	h.apiKeyDB.LogAPIKeyUsage(
+		apiKeyID,
+		"2fa_disabled",
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+		c.Request.URL.Path,
+		200,
+		`{}`,
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "2FA disabled",
+	})
+}
+
+// respond2FAError maps TOTP manager errors to HTTP responses and audits them
+func (h *AuthHandler) respond2FAError(c *gin.Context, apiKeyID int64, action string, err error) {
+	status := http.StatusUnauthorized
+	message := "Invalid TOTP code"
+
+	switch {
+	case errors.Is(err, auth.ErrTOTPLocked):
+		status = http.StatusTooManyRequests
+		message = "Too many failed 2FA attempts, try again later"
+	case errors.Is(err, auth.ErrTOTPNoEnrollment):
+		status = http.StatusConflict
+		message = "No pending 2FA enrollment; call /auth/2fa/enroll first"
+	case errors.Is(err, auth.ErrTOTPAlreadyEnabled):
+		status = http.StatusConflict
+		message = "2FA already enabled"
+	}
+
+	h.apiKeyDB.LogAPIKeyUsage(
+		apiKeyID,
+		action,
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+		c.Request.URL.Path,
+		status,
+		`{}`,
+	)
+
+	c.JSON(status, gin.H{
+		"error": message,
+	})
+}
@@ -0,0 +1,82 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// UsageHandler reports an API key's own usage
+type UsageHandler struct {
+	apiKeyDB *auth.APIKeyDB
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(apiKeyDB *auth.APIKeyDB) *UsageHandler {
+	return &UsageHandler{apiKeyDB: apiKeyDB}
+}
+
+// GetUsage handles GET /v1/usage: the caller's daily usage, filtered by
+// from, to, provider, model and tag and grouped by day, provider, model
+// and/or tag
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	keyID := c.GetInt64("api_key_id")
+	if keyID == 0 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": "Usage is only available to database API keys",
+		})
+		return
+	}
+
+	query, err := auth.ParseUsageQuery(c.Request.URL.Query(), time.Now())
+	if err == nil {
+		err = checkOwnUsageQuery(query)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+	query.APIKeyID = keyID
+	query.TeamID = 0
+
+	respondUsage(c, h.apiKeyDB, query)
+}
+
+// checkOwnUsageQuery rejects groups and filters that only make sense
+// across keys
+func checkOwnUsageQuery(q auth.UsageQuery) error {
+	for _, group := range q.GroupBy {
+		if group == auth.UsageGroupKey || group == auth.UsageGroupTeam {
+			return errors.New("group_by key and team are not available for your own usage")
+		}
+	}
+	return nil
+}
+
+// respondUsage writes the totals of a usage query as JSON
+func respondUsage(c *gin.Context, db *auth.APIKeyDB, query auth.UsageQuery) {
+	rows, err := db.QueryUsage(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to query usage",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":     query.From.Format(time.DateOnly),
+		"to":       query.To.Format(time.DateOnly),
+		"group_by": query.GroupBy,
+		"usage":    rows,
+	})
+}
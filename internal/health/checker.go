@@ -2,18 +2,27 @@ package health
 
 import (
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// DefaultWindow is how far back error rates look
+const DefaultWindow = 5 * time.Minute
+
+// minErrorSamples is how many outcomes the window must hold before its
+// error rate can mark the service unhealthy
+const minErrorSamples = 10
+
 // Checker provides health and readiness checking functionality
 type Checker struct {
-	healthy     int32
-	ready       int32
-	errors      int64
-	successes   int64
+	healthy int32
+	ready   int32
+
+	mu          sync.Mutex
+	window      *window
 	lastError   time.Time
 	lastSuccess time.Time
 	startTime   time.Time
@@ -24,6 +33,7 @@ func NewChecker() *Checker {
 	checker := &Checker{
 		healthy:     1,
 		ready:       1,
+		window:      newWindow(DefaultWindow),
 		startTime:   time.Now(),
 		lastSuccess: time.Now(),
 	}
@@ -42,20 +52,27 @@ func (c *Checker) IsReady() bool {
 
 // RecordError records a service error
 func (c *Checker) RecordError() {
-	atomic.AddInt64(&c.errors, 1)
-	c.lastError = time.Now()
-
-	// Mark as unhealthy if error rate is too high
-	errorRate := c.getErrorRate()
-	if errorRate > 0.5 { // More than 50% errors
+	c.mu.Lock()
+	now := time.Now()
+	c.window.add(now, true)
+	c.lastError = now
+	successes, errors := c.window.counts(now)
+	errorRate := c.window.errorRate(now)
+	c.mu.Unlock()
+
+	// Mark as unhealthy if the recent error rate is too high
+	if successes+errors >= minErrorSamples && errorRate > 0.5 { // More than 50% errors
 		atomic.StoreInt32(&c.healthy, 0)
 	}
 }
 
 // RecordSuccess records a successful operation
 func (c *Checker) RecordSuccess() {
-	atomic.AddInt64(&c.successes, 1)
-	c.lastSuccess = time.Now()
+	c.mu.Lock()
+	now := time.Now()
+	c.window.add(now, false)
+	c.lastSuccess = now
+	c.mu.Unlock()
 
 	// Mark as healthy if we have recent success
 	atomic.StoreInt32(&c.healthy, 1)
@@ -70,27 +87,20 @@ func (c *Checker) SetReady(ready bool) {
 	}
 }
 
-// getErrorRate calculates the current error rate
-func (c *Checker) getErrorRate() float64 {
-	errors := atomic.LoadInt64(&c.errors)
-	successes := atomic.LoadInt64(&c.successes)
-	total := errors + successes
-
-	if total == 0 {
-		return 0.0
-	}
-
-	return float64(errors) / float64(total)
-}
-
-// GetStats returns health statistics
+// GetStats returns health statistics; counts cover the last DefaultWindow
 func (c *Checker) GetStats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	successes, errors := c.window.counts(now)
 	return map[string]interface{}{
 		"healthy":      c.IsHealthy(),
 		"ready":        c.IsReady(),
-		"errors":       atomic.LoadInt64(&c.errors),
-		"successes":    atomic.LoadInt64(&c.successes),
-		"error_rate":   c.getErrorRate(),
+		"errors":       errors,
+		"successes":    successes,
+		"error_rate":   c.window.errorRate(now),
+		"window":       DefaultWindow.String(),
 		"uptime":       time.Since(c.startTime).String(),
 		"last_error":   c.lastError.Format(time.RFC3339),
 		"last_success": c.lastSuccess.Format(time.RFC3339),
@@ -45,6 +45,23 @@ func TestRecordError(t *testing.T) {
 	}
 }
 
+func TestRecordErrorNeedsSamples(t *testing.T) {
+	checker := NewChecker()
+
+	// A few errors on a quiet service are not enough to judge it
+	for i := 0; i < minErrorSamples-1; i++ {
+		checker.RecordError()
+	}
+	if !checker.IsHealthy() {
+		t.Errorf("Checker should stay healthy with fewer than %d samples", minErrorSamples)
+	}
+
+	checker.RecordError()
+	if checker.IsHealthy() {
+		t.Error("Checker should be unhealthy once enough samples are errors")
+	}
+}
+
 func TestRecordSuccess(t *testing.T) {
 	checker := NewChecker()
 
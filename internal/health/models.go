@@ -0,0 +1,217 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/pkg/metrics"
+)
+
+// ModelCheckerConfig controls synthetic model checks
+type ModelCheckerConfig struct {
+	// Interval between checks of each model (default 5m)
+	Interval time.Duration
+
+	// Timeout of a single check (default 30s)
+	Timeout time.Duration
+
+	// FailureThreshold is how many checks in a row must fail before a
+	// model is unavailable (default 2)
+	FailureThreshold int
+
+	// Concurrency bounds how many checks run at once (default 4)
+	Concurrency int
+}
+
+// ModelStatus is the health of one model on one provider
+type ModelStatus struct {
+	Model       string     `json:"model"`
+	Provider    string     `json:"provider"`
+	Available   bool       `json:"available"`
+	LatencyMS   int64      `json:"latency_ms"` // of the last check
+	Failures    int        `json:"consecutive_failures"`
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// ModelChecker periodically sends a synthetic request to each model on
+// each provider it is mapped to, so models that stop answering can be
+// taken out of routing and model listings
+type ModelChecker struct {
+	cfg ModelCheckerConfig
+
+	mu     sync.Mutex
+	models map[modelKey]*modelState
+}
+
+type modelKey struct {
+	model    string
+	provider string
+}
+
+type modelState struct {
+	check       ProbeFunc
+	checked     bool
+	failures    int
+	latency     time.Duration
+	lastChecked time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// NewModelChecker creates a model checker; register models with Add, then
+// Start it
+func NewModelChecker(cfg ModelCheckerConfig) *ModelChecker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 2
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	return &ModelChecker{cfg: cfg, models: make(map[modelKey]*modelState)}
+}
+
+// Add registers a model on a provider to check
+func (m *ModelChecker) Add(model, provider string, check ProbeFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models[modelKey{model, provider}] = &modelState{check: check}
+}
+
+// Start checks every model now and then every Interval until ctx is done
+func (m *ModelChecker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			m.CheckAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// CheckAll checks every model, at most Concurrency at a time, and waits
+// for the results
+func (m *ModelChecker) CheckAll(ctx context.Context) {
+	m.mu.Lock()
+	checks := make(map[modelKey]ProbeFunc, len(m.models))
+	for key, state := range m.models {
+		checks[key] = state.check
+	}
+	m.mu.Unlock()
+
+	sem := make(chan struct{}, m.cfg.Concurrency)
+	var wg sync.WaitGroup
+	for key, check := range checks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key modelKey, check ProbeFunc) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			checkCtx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(checkCtx)
+			m.recordCheck(key, time.Since(start), err)
+		}(key, check)
+	}
+	wg.Wait()
+}
+
+// recordCheck stores the outcome of a check
+func (m *ModelChecker) recordCheck(key modelKey, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.models[key]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	state.checked = true
+	state.latency = latency
+	state.lastChecked = now
+	if err != nil {
+		state.failures++
+		state.lastError = err.Error()
+		if len(state.lastError) > maxErrorLength {
+			state.lastError = state.lastError[:maxErrorLength] + "..."
+		}
+		state.lastErrorAt = now
+	} else {
+		state.failures = 0
+	}
+	metrics.RecordModelCheck(key.provider, key.model, m.available(state), latency)
+}
+
+func (m *ModelChecker) available(state *modelState) bool {
+	return state.failures < m.cfg.FailureThreshold
+}
+
+// Available reports whether a model answers on a provider. Models that are
+// not checked, or not checked yet, are available. It is safe to call on a
+// nil checker.
+func (m *ModelChecker) Available(model, provider string) bool {
+	if m == nil {
+		return true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.models[modelKey{model, provider}]
+	return !ok || m.available(state)
+}
+
+// Statuses returns the health of every checked model, sorted by model and
+// provider
+func (m *ModelChecker) Statuses() []ModelStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]ModelStatus, 0, len(m.models))
+	for key, state := range m.models {
+		status := ModelStatus{
+			Model:     key.model,
+			Provider:  key.provider,
+			Available: m.available(state),
+			LatencyMS: state.latency.Milliseconds(),
+			Failures:  state.failures,
+			LastError: state.lastError,
+		}
+		if state.checked {
+			checked := state.lastChecked
+			status.LastChecked = &checked
+		}
+		if !state.lastErrorAt.IsZero() {
+			errorAt := state.lastErrorAt
+			status.LastErrorAt = &errorAt
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Model != statuses[j].Model {
+			return statuses[i].Model < statuses[j].Model
+		}
+		return statuses[i].Provider < statuses[j].Provider
+	})
+	return statuses
+}
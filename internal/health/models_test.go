@@ -0,0 +1,91 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestModelCheckerAvailability(t *testing.T) {
+	m := NewModelChecker(ModelCheckerConfig{FailureThreshold: 2})
+
+	var gptErr error
+	m.Add("gpt-4", "openai", func(ctx context.Context) error { return gptErr })
+	m.Add("gpt-4", "azure", func(ctx context.Context) error { return nil })
+
+	if !m.Available("gpt-4", "openai") {
+		t.Error("Expected a model to be available before its first check")
+	}
+	if !m.Available("claude-3", "anthropic") {
+		t.Error("Expected an unchecked model to be available")
+	}
+
+	// One failure stays under the threshold
+	gptErr = errors.New("model overloaded")
+	m.CheckAll(context.Background())
+	if !m.Available("gpt-4", "openai") {
+		t.Error("Expected a single failed check to keep the model available")
+	}
+	m.CheckAll(context.Background())
+	if m.Available("gpt-4", "openai") {
+		t.Error("Expected the model to be unavailable after consecutive failures")
+	}
+	if !m.Available("gpt-4", "azure") {
+		t.Error("Expected the model to stay available on the healthy provider")
+	}
+
+	statuses := m.Statuses()
+	if len(statuses) != 2 || statuses[0].Provider != "azure" || statuses[1].Provider != "openai" {
+		t.Fatalf("Expected statuses sorted by model and provider, got %+v", statuses)
+	}
+	if got := statuses[1]; got.Available || got.Failures != 2 || got.LastError != "model overloaded" || got.LastChecked == nil {
+		t.Errorf("Expected openai status to show the failures, got %+v", got)
+	}
+
+	// A successful check restores the model
+	gptErr = nil
+	m.CheckAll(context.Background())
+	if !m.Available("gpt-4", "openai") || m.Statuses()[1].Failures != 0 {
+		t.Errorf("Expected the model to recover, got %+v", m.Statuses()[1])
+	}
+
+	var nilChecker *ModelChecker
+	if !nilChecker.Available("gpt-4", "openai") {
+		t.Error("Expected a nil checker to report models available")
+	}
+}
+
+func TestModelCheckerConcurrency(t *testing.T) {
+	m := NewModelChecker(ModelCheckerConfig{Concurrency: 2, Timeout: time.Second})
+
+	var running, peak int32
+	for _, model := range []string{"a", "b", "c", "d", "e"} {
+		m.Add(model, "openai", func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		})
+	}
+
+	m.CheckAll(context.Background())
+	if peak > 2 {
+		t.Errorf("Expected at most 2 checks at once, got %d", peak)
+	}
+	for _, status := range m.Statuses() {
+		if status.LastChecked == nil {
+			t.Errorf("Expected %s to be checked", status.Model)
+		}
+	}
+}
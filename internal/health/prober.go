@@ -0,0 +1,306 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Provider statuses
+const (
+	StatusHealthy     = "healthy"
+	StatusUnhealthy   = "unhealthy"
+	StatusUnknown     = "unknown"     // not probed and no traffic yet
+	StatusUnavailable = "unavailable" // required but not configured
+)
+
+// ProbeFunc checks one provider, e.g. providers.Provider.HealthCheck
+type ProbeFunc func(ctx context.Context) error
+
+// ProberConfig controls provider probing
+type ProberConfig struct {
+	// Interval between probes of each provider (default 30s)
+	Interval time.Duration
+
+	// Timeout of a single probe (default 10s)
+	Timeout time.Duration
+
+	// Window is how far back error rates look (default DefaultWindow)
+	Window time.Duration
+
+	// ErrorThreshold is the error rate above which a provider is
+	// unhealthy (default 0.5)
+	ErrorThreshold float64
+
+	// MinRequests is how many probes and requests the window must hold
+	// before ErrorThreshold applies (default 10), so a single failure on
+	// a quiet provider does not mark it unhealthy
+	MinRequests int
+
+	// FailureThreshold is how many probes in a row must fail before a
+	// provider is unhealthy (default 2)
+	FailureThreshold int
+
+	// Required lists the providers that must be healthy for readiness
+	Required []string
+
+	// OnChange, if set, is called when a provider becomes unhealthy or
+	// recovers; it must not block
+	OnChange func(status ProviderStatus)
+}
+
+// ProviderStatus is the health of one provider
+type ProviderStatus struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Required    bool       `json:"required"`
+	LatencyMS   int64      `json:"latency_ms"` // of the last probe
+	ErrorRate   float64    `json:"error_rate"`
+	Requests    int64      `json:"requests"` // probes and traffic within the window
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Prober probes providers in the background and tracks their error rates
+// from probes and live traffic over a sliding window
+type Prober struct {
+	cfg ProberConfig
+
+	mu     sync.Mutex
+	states map[string]*providerState
+}
+
+type providerState struct {
+	probe          ProbeFunc
+	window         *window
+	probed         bool
+	probeSucceeded bool // set once any probe passed
+	probeFailures  int  // latest probes that failed in a row
+	latency        time.Duration
+	lastChecked    time.Time
+	lastError      string
+	lastErrorAt    time.Time
+}
+
+// NewProber creates a prober; register providers with Add, then Start it
+func NewProber(cfg ProberConfig) *Prober {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.ErrorThreshold <= 0 {
+		cfg.ErrorThreshold = 0.5
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 10
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 2
+	}
+	return &Prober{cfg: cfg, states: make(map[string]*providerState)}
+}
+
+// Add registers a provider to probe
+func (p *Prober) Add(name string, probe ProbeFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states[name] = &providerState{probe: probe, window: newWindow(p.cfg.Window)}
+}
+
+// Start probes every provider now and then every Interval until ctx is done
+func (p *Prober) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			p.ProbeAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ProbeAll probes every provider concurrently and waits for the results
+func (p *Prober) ProbeAll(ctx context.Context) {
+	p.mu.Lock()
+	probes := make(map[string]ProbeFunc, len(p.states))
+	for name, state := range p.states {
+		probes[name] = state.probe
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for name, probe := range probes {
+		wg.Add(1)
+		go func(name string, probe ProbeFunc) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := probe(probeCtx)
+			p.recordProbe(name, time.Since(start), err)
+		}(name, probe)
+	}
+	wg.Wait()
+}
+
+// recordProbe stores the outcome of a probe
+func (p *Prober) recordProbe(name string, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state, ok := p.states[name]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	before := p.status(name, state, false, now).Status
+	state.probed = true
+	if err != nil {
+		state.probeFailures++
+	} else {
+		state.probeFailures = 0
+		state.probeSucceeded = true
+	}
+	state.latency = latency
+	state.lastChecked = now
+	state.record(now, err)
+	p.notifyChange(name, state, before, now)
+}
+
+// Record adds the outcome of a live request to a provider's error rate.
+// It is a no-op on a nil prober or an unregistered provider.
+func (p *Prober) Record(name string, err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if state, ok := p.states[name]; ok {
+		now := time.Now()
+		before := p.status(name, state, false, now).Status
+		state.record(now, err)
+		p.notifyChange(name, state, before, now)
+	}
+}
+
+// notifyChange calls OnChange when a provider's status moved from before to
+// or from unhealthy; reaching healthy from unknown is not a change
+func (p *Prober) notifyChange(name string, state *providerState, before string, now time.Time) {
+	if p.cfg.OnChange == nil {
+		return
+	}
+	status := p.status(name, state, p.required(name), now)
+	if status.Status == before || (before != StatusUnhealthy && status.Status != StatusUnhealthy) {
+		return
+	}
+	p.cfg.OnChange(status)
+}
+
+func (p *Prober) required(name string) bool {
+	for _, required := range p.cfg.Required {
+		if required == name {
+			return true
+		}
+	}
+	return false
+}
+
+// maxErrorLength bounds the last error kept per provider, which may hold
+// a provider's whole error page
+const maxErrorLength = 256
+
+func (s *providerState) record(now time.Time, err error) {
+	s.window.add(now, err != nil)
+	if err != nil {
+		s.lastError = err.Error()
+		if len(s.lastError) > maxErrorLength {
+			s.lastError = s.lastError[:maxErrorLength] + "..."
+		}
+		s.lastErrorAt = now
+	}
+}
+
+// Statuses returns the health of every registered or required provider,
+// sorted by name
+func (p *Prober) Statuses() []ProviderStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	required := make(map[string]bool, len(p.cfg.Required))
+	for _, name := range p.cfg.Required {
+		required[name] = true
+	}
+
+	statuses := make([]ProviderStatus, 0, len(p.states))
+	for name, state := range p.states {
+		statuses = append(statuses, p.status(name, state, required[name], now))
+	}
+	for _, name := range p.cfg.Required {
+		if _, ok := p.states[name]; !ok {
+			statuses = append(statuses, ProviderStatus{Name: name, Status: StatusUnavailable, Required: true})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (p *Prober) status(name string, state *providerState, required bool, now time.Time) ProviderStatus {
+	successes, errors := state.window.counts(now)
+	status := ProviderStatus{
+		Name:      name,
+		Required:  required,
+		LatencyMS: state.latency.Milliseconds(),
+		ErrorRate: state.window.errorRate(now),
+		Requests:  successes + errors,
+		LastError: state.lastError,
+	}
+	if state.probed {
+		checked := state.lastChecked
+		status.LastChecked = &checked
+	}
+	if !state.lastErrorAt.IsZero() {
+		errorAt := state.lastErrorAt
+		status.LastErrorAt = &errorAt
+	}
+
+	switch {
+	case state.probeFailures >= p.cfg.FailureThreshold,
+		status.Requests >= int64(p.cfg.MinRequests) && status.ErrorRate > p.cfg.ErrorThreshold:
+		status.Status = StatusUnhealthy
+	case !state.probeSucceeded && successes == 0:
+		// Nothing has succeeded yet and there are too few failures to tell
+		status.Status = StatusUnknown
+	default:
+		status.Status = StatusHealthy
+	}
+	return status
+}
+
+// Ready reports whether every required provider is healthy, along with the
+// statuses it was decided from
+func (p *Prober) Ready() (bool, []ProviderStatus) {
+	statuses := p.Statuses()
+	for _, status := range statuses {
+		if status.Required && status.Status != StatusHealthy {
+			return false, statuses
+		}
+	}
+	return true, statuses
+}
@@ -0,0 +1,204 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWindowAgesOut(t *testing.T) {
+	w := newWindow(time.Minute)
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 10; i++ {
+		w.add(start, true)
+	}
+	w.add(start.Add(30*time.Second), false)
+	if rate := w.errorRate(start.Add(30 * time.Second)); rate < 0.9 {
+		t.Errorf("Expected a high error rate within the window, got %.2f", rate)
+	}
+
+	// The burst ages out; only the later success remains
+	later := start.Add(75 * time.Second)
+	if successes, errors := w.counts(later); successes != 1 || errors != 0 {
+		t.Errorf("Expected only the recent success, got %d successes and %d errors", successes, errors)
+	}
+	if rate := w.errorRate(start.Add(5 * time.Minute)); rate != 0 {
+		t.Errorf("Expected an empty window, got %.2f", rate)
+	}
+}
+
+func TestProberStatuses(t *testing.T) {
+	p := NewProber(ProberConfig{Required: []string{"bedrock", "oracle"}, MinRequests: 4, FailureThreshold: 1})
+
+	var bedrockErr error
+	p.Add("bedrock", func(ctx context.Context) error { return bedrockErr })
+	p.Add("openai", func(ctx context.Context) error { return errors.New("connection refused") })
+	p.Add("azure", func(ctx context.Context) error { return nil })
+
+	statuses := p.Statuses()
+	if len(statuses) != 4 || statuses[0].Name != "azure" || statuses[0].Status != StatusUnknown {
+		t.Fatalf("Expected unknown providers before probing, got %+v", statuses)
+	}
+	if statuses[3].Name != "oracle" || statuses[3].Status != StatusUnavailable {
+		t.Errorf("Expected unconfigured required provider to be unavailable, got %+v", statuses[3])
+	}
+
+	p.ProbeAll(context.Background())
+	byName := func() map[string]ProviderStatus {
+		m := make(map[string]ProviderStatus)
+		for _, s := range p.Statuses() {
+			m[s.Name] = s
+		}
+		return m
+	}
+	got := byName()
+	if got["bedrock"].Status != StatusHealthy || got["bedrock"].LastChecked == nil {
+		t.Errorf("Expected bedrock healthy after probing, got %+v", got["bedrock"])
+	}
+	if got["openai"].Status != StatusUnhealthy || got["openai"].LastError != "connection refused" {
+		t.Errorf("Expected openai unhealthy with its error, got %+v", got["openai"])
+	}
+	if ready, _ := p.Ready(); ready {
+		t.Error("Expected not ready while a required provider is unavailable")
+	}
+
+	// Live traffic errors push the error rate over the threshold
+	for i := 0; i < 3; i++ {
+		p.Record("bedrock", errors.New("throttled"))
+	}
+	if got := byName()["bedrock"]; got.Status != StatusUnhealthy || got.Requests != 4 {
+		t.Errorf("Expected bedrock unhealthy from traffic errors, got %+v", got)
+	}
+	for i := 0; i < 4; i++ {
+		p.Record("bedrock", nil)
+	}
+	if got := byName()["bedrock"]; got.Status != StatusHealthy {
+		t.Errorf("Expected bedrock healthy again, got %+v", got)
+	}
+
+	// A failed probe marks the provider unhealthy regardless of traffic
+	bedrockErr = errors.New("timeout")
+	p.ProbeAll(context.Background())
+	if got := byName()["bedrock"]; got.Status != StatusUnhealthy {
+		t.Errorf("Expected bedrock unhealthy after a failed probe, got %+v", got)
+	}
+
+	var nilProber *Prober
+	nilProber.Record("bedrock", nil)
+}
+
+func TestProberThresholds(t *testing.T) {
+	p := NewProber(ProberConfig{Required: []string{"bedrock"}})
+
+	var probeErr error
+	p.Add("bedrock", func(ctx context.Context) error { return probeErr })
+	status := func() string { return p.Statuses()[0].Status }
+
+	t.Run("first probe failing leaves the provider unknown", func(t *testing.T) {
+		probeErr = errors.New("connection refused")
+		p.ProbeAll(context.Background())
+		if got := status(); got != StatusUnknown {
+			t.Errorf("Expected unknown after one failed probe, got %s", got)
+		}
+		if ready, _ := p.Ready(); ready {
+			t.Error("Expected not ready while the provider is unknown")
+		}
+	})
+
+	t.Run("failures in a row mark the provider unhealthy", func(t *testing.T) {
+		p.ProbeAll(context.Background())
+		if got := status(); got != StatusUnhealthy {
+			t.Errorf("Expected unhealthy after two failed probes, got %s", got)
+		}
+	})
+
+	t.Run("a success resets the count", func(t *testing.T) {
+		probeErr = nil
+		p.ProbeAll(context.Background())
+		probeErr = errors.New("timeout")
+		p.ProbeAll(context.Background())
+		if got := status(); got != StatusHealthy {
+			t.Errorf("Expected one failure after a success to stay healthy, got %s", got)
+		}
+	})
+
+	t.Run("error rate needs enough samples", func(t *testing.T) {
+		probeErr = nil
+		p.ProbeAll(context.Background())
+
+		// The window holds 5 probes, 3 of them failed
+		p.Record("bedrock", errors.New("throttled"))
+		p.Record("bedrock", errors.New("throttled"))
+		if got := status(); got != StatusHealthy {
+			t.Errorf("Expected healthy with 7 samples, got %s", got)
+		}
+		for i := 0; i < 3; i++ {
+			p.Record("bedrock", errors.New("throttled"))
+		}
+		if got := p.Statuses()[0]; got.Status != StatusUnhealthy || got.Requests != 10 {
+			t.Errorf("Expected unhealthy at 10 samples, got %+v", got)
+		}
+	})
+}
+
+func TestProberReady(t *testing.T) {
+	p := NewProber(ProberConfig{Required: []string{"bedrock"}})
+	p.Add("bedrock", func(ctx context.Context) error { return nil })
+	p.Add("openai", func(ctx context.Context) error { return errors.New("down") })
+
+	if ready, _ := p.Ready(); ready {
+		t.Error("Expected not ready before the first probe")
+	}
+	p.ProbeAll(context.Background())
+	if ready, statuses := p.Ready(); !ready {
+		t.Errorf("Expected ready when only an optional provider is down, got %+v", statuses)
+	}
+}
+
+func TestProberTimeout(t *testing.T) {
+	p := NewProber(ProberConfig{Timeout: 10 * time.Millisecond, FailureThreshold: 1})
+	p.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	p.ProbeAll(context.Background())
+	if got := p.Statuses()[0]; got.Status != StatusUnhealthy || got.LastError == "" {
+		t.Errorf("Expected a timed-out probe to fail, got %+v", got)
+	}
+}
+
+func TestProberOnChange(t *testing.T) {
+	var changes []ProviderStatus
+	p := NewProber(ProberConfig{
+		Required: []string{"bedrock"},
+		OnChange: func(status ProviderStatus) { changes = append(changes, status) },
+	})
+
+	var probeErr error
+	p.Add("bedrock", func(ctx context.Context) error { return probeErr })
+
+	// Unknown to healthy is not a change
+	p.ProbeAll(context.Background())
+	if len(changes) != 0 {
+		t.Fatalf("Expected no change on the first healthy probe, got %+v", changes)
+	}
+
+	probeErr = errors.New("connection refused")
+	p.ProbeAll(context.Background())
+	p.ProbeAll(context.Background())
+	if len(changes) != 1 || changes[0].Status != StatusUnhealthy || !changes[0].Required {
+		t.Fatalf("Expected one unhealthy change, got %+v", changes)
+	}
+
+	probeErr = nil
+	p.ProbeAll(context.Background())
+	if len(changes) != 2 || changes[1].Status != StatusHealthy {
+		t.Errorf("Expected a recovery change, got %+v", changes)
+	}
+}
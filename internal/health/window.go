@@ -0,0 +1,66 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import "time"
+
+// windowBuckets is the resolution of a sliding window
+const windowBuckets = 10
+
+// window counts outcomes over a sliding time window in fixed buckets, so
+// old results age out instead of accumulating forever. It is not safe for
+// concurrent use.
+type window struct {
+	bucketSize time.Duration
+	buckets    [windowBuckets]windowBucket
+}
+
+type windowBucket struct {
+	start     int64 // bucket start in units of bucketSize
+	successes int64
+	errors    int64
+}
+
+func newWindow(size time.Duration) *window {
+	bucketSize := size / windowBuckets
+	if bucketSize <= 0 {
+		bucketSize = time.Millisecond
+	}
+	return &window{bucketSize: bucketSize}
+}
+
+// add records one outcome at now
+func (w *window) add(now time.Time, failed bool) {
+	slot := now.UnixNano() / int64(w.bucketSize)
+	b := &w.buckets[slot%windowBuckets]
+	if b.start != slot {
+		*b = windowBucket{start: slot}
+	}
+	if failed {
+		b.errors++
+	} else {
+		b.successes++
+	}
+}
+
+// counts returns the successes and errors recorded within the window
+func (w *window) counts(now time.Time) (successes, errors int64) {
+	slot := now.UnixNano() / int64(w.bucketSize)
+	for _, b := range w.buckets {
+		if slot-b.start < windowBuckets {
+			successes += b.successes
+			errors += b.errors
+		}
+	}
+	return successes, errors
+}
+
+// errorRate returns the share of errors within the window, 0 when empty
+func (w *window) errorRate(now time.Time) float64 {
+	successes, errors := w.counts(now)
+	if successes+errors == 0 {
+		return 0
+	}
+	return float64(errors) / float64(successes+errors)
+}
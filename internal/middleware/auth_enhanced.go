@@ -4,6 +4,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -11,8 +12,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// EnhancedAPIKeyAuth validates API keys from database with optional 2FA
-func EnhancedAPIKeyAuth(apiKeyDB *auth.APIKeyDB, totpManager *auth.TOTPManager, require2FA bool) gin.HandlerFunc {
+// EnhancedAPIKeyAuth validates API keys from database with optional 2FA.
+// Failures count against the client IP and key prefix in guard (nil disables).
+func EnhancedAPIKeyAuth(
+	apiKeyDB *auth.APIKeyDB,
+	totpManager *auth.TOTPManager,
+	require2FA bool,
+	guard *auth.BruteForceGuard,
+) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Extract API key from header
 		apiKey := c.GetHeader("X-API-Key")
@@ -32,6 +39,12 @@ func EnhancedAPIKeyAuth(apiKeyDB *auth.APIKeyDB, totpManager *auth.TOTPManager,
 			return
 		}
 
+		// Reject locked-out clients before doing any expensive work
+		ipSubject, keySubject := auth.IPSubject(c.ClientIP()), auth.KeySubject(c.ClientIP(), apiKey)
+		if rejectIfLocked(guard, c, ipSubject, keySubject) {
+			return
+		}
+
 		// Validate API key against database
 		keyInfo, err := apiKeyDB.ValidateAPIKey(apiKey)
 		if err != nil {
@@ -45,6 +58,7 @@ func EnhancedAPIKeyAuth(apiKeyDB *auth.APIKeyDB, totpManager *auth.TOTPManager,
 				401,
 				`{"error":"invalid_api_key"}`,
 			)
+			recordAuthFailure(guard, apiKeyDB, c, 0, ipSubject, keySubject)
 
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid API key",
@@ -70,6 +84,15 @@ func EnhancedAPIKeyAuth(apiKeyDB *auth.APIKeyDB, totpManager *auth.TOTPManager,
 
 			// Validate TOTP code
 			valid, err := totpManager.ValidateTOTP(keyInfo.ID, totpCode)
+			if errors.Is(err, auth.ErrTOTPLocked) {
+				logAudit(apiKeyDB, c, keyInfo.ID, "2fa_locked", http.StatusTooManyRequests, nil)
+
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"error": "Too many failed 2FA attempts, try again later",
+				})
+				c.Abort()
+				return
+			}
 			if err != nil || !valid {
 				// Log failed 2FA attempt
 				apiKeyDB.LogAPIKeyUsage(
@@ -81,6 +104,7 @@ func EnhancedAPIKeyAuth(apiKeyDB *auth.APIKeyDB, totpManager *auth.TOTPManager,
 					401,
 					`{"error":"invalid_totp"}`,
 				)
+				recordAuthFailure(guard, apiKeyDB, c, keyInfo.ID, ipSubject, keySubject)
 
 				c.JSON(http.StatusUnauthorized, gin.H{
 					"error": "Invalid TOTP code",
@@ -90,10 +114,13 @@ func EnhancedAPIKeyAuth(apiKeyDB *auth.APIKeyDB, totpManager *auth.TOTPManager,
 			}
 		}
 
+		guard.RecordSuccess(keySubject)
+
 		// Set user context
 		c.Set("user", keyInfo.Name)
 		c.Set("user_email", keyInfo.Email)
 		c.Set("api_key_id", keyInfo.ID)
+		c.Set("permissions", keyInfo.PermissionList())
 		c.Set("auth_method", "api_key_db")
 		c.Set("2fa_enabled", twoFAEnabled)
 
@@ -186,3 +186,24 @@ func RateLimitByUser(requestsPerMinute int) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequirePermission allows only callers whose authenticated identity holds permission
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions, _ := c.Get("permissions")
+		if list, ok := permissions.([]string); ok {
+			for _, p := range list {
+				if p == permission {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": "Requires permission: " + permission,
+		})
+		c.Abort()
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// rejectIfLocked aborts with 429 and Retry-After when any subject is locked out
+func rejectIfLocked(guard *auth.BruteForceGuard, c *gin.Context, subjects ...string) bool {
+	wait, locked := guard.Check(subjects...)
+	if !locked {
+		return false
+	}
+
+	RespondLocked(c, wait)
+	return true
+}
+
+// recordAuthFailure counts a failed attempt and audits any lockout it triggers
+func recordAuthFailure(guard *auth.BruteForceGuard, apiKeyDB *auth.APIKeyDB, c *gin.Context, keyID int64, subjects ...string) {
+	if wait := guard.RecordFailure(subjects...); wait > 0 {
+		logAudit(apiKeyDB, c, keyID, "auth_lockout", http.StatusTooManyRequests, map[string]any{
+			"subjects":    subjects,
+			"retry_after": retryAfterSeconds(wait),
+		})
+	}
+}
+
+// RespondLocked writes a 429 lockout response with a Retry-After header
+func RespondLocked(c *gin.Context, wait time.Duration) {
+	seconds := retryAfterSeconds(wait)
+
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many failed authentication attempts",
+		"retry_after": seconds,
+	})
+	c.Abort()
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
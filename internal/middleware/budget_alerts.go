@@ -0,0 +1,99 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/notify"
+)
+
+// BudgetAlerts sends a budget.threshold notification the first time a key's
+// or team's monthly spend crosses each of the notifier's budget thresholds.
+// Sent alerts are remembered in memory for the month, so a restart may
+// repeat the latest one. A nil BudgetAlerts sends nothing.
+type BudgetAlerts struct {
+	notifier *notify.Notifier
+
+	mu     sync.Mutex
+	period string
+	sent   map[string]bool
+}
+
+// NewBudgetAlerts creates budget alerts sent through notifier, or nil when
+// notifier is nil
+func NewBudgetAlerts(notifier *notify.Notifier) *BudgetAlerts {
+	if notifier == nil {
+		return nil
+	}
+	return &BudgetAlerts{notifier: notifier, sent: make(map[string]bool)}
+}
+
+// checkSpend looks up the key's and team's spend after a request and sends
+// alerts for any thresholds it crossed
+func (a *BudgetAlerts) checkSpend(apiKeyDB *auth.APIKeyDB, policy *auth.KeyPolicy, keyID int64, now time.Time) {
+	if a == nil {
+		return
+	}
+	if policy.MonthlyBudgetUSD > 0 {
+		if spent, err := apiKeyDB.KeySpend(keyID, now); err == nil {
+			a.observe("key", keyID, fmt.Sprintf("Key %d", keyID), spent, policy.MonthlyBudgetUSD, now)
+		} else {
+			log.Printf("Failed to check budget alerts for key %d: %v", keyID, err)
+		}
+	}
+	if policy.TeamID != 0 && policy.TeamMonthlyBudgetUSD > 0 {
+		if spent, err := apiKeyDB.TeamSpend(policy.TeamID, now); err == nil {
+			a.observe("team", policy.TeamID, fmt.Sprintf("Team %q", policy.TeamName), spent, policy.TeamMonthlyBudgetUSD, now)
+		} else {
+			log.Printf("Failed to check budget alerts for team %d: %v", policy.TeamID, err)
+		}
+	}
+}
+
+// observe sends an alert for the highest threshold that spent has newly
+// reached this month; lower thresholds crossed at the same time are marked
+// sent without their own alert
+func (a *BudgetAlerts) observe(scope string, id int64, label string, spent, budget float64, now time.Time) {
+	period := now.UTC().Format("2006-01")
+
+	a.mu.Lock()
+	if period != a.period {
+		a.period = period
+		a.sent = make(map[string]bool)
+	}
+	var crossed float64
+	for _, threshold := range a.notifier.BudgetThresholds() {
+		key := fmt.Sprintf("%s:%d:%g", scope, id, threshold)
+		if spent >= threshold*budget && !a.sent[key] {
+			a.sent[key] = true
+			if threshold > crossed {
+				crossed = threshold
+			}
+		}
+	}
+	a.mu.Unlock()
+
+	if crossed == 0 {
+		return
+	}
+	severity := notify.SeverityWarning
+	if crossed >= 1 {
+		severity = notify.SeverityCritical
+	}
+	a.notifier.Notify(notify.EventBudgetThreshold, severity,
+		fmt.Sprintf("%s has spent $%.2f of its $%.2f monthly budget (%.0f%% threshold)", label, spent, budget, crossed*100),
+		map[string]any{
+			"scope":       scope,
+			scope + "_id": id,
+			"period":      period,
+			"spent_usd":   spent,
+			"budget_usd":  budget,
+			"threshold":   crossed,
+		})
+}
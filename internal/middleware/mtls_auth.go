@@ -0,0 +1,161 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// MTLSAuth authenticates callers by their verified TLS client certificate.
+// When the mapped identity requires it, an API key from apiKeyDB issued to
+// that identity must also be presented as a second factor; failed keys count
+// towards lockouts in guard.
+func MTLSAuth(mapper *auth.CertMapper, apiKeyDB *auth.APIKeyDB, guard *auth.BruteForceGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil || len(c.Request.TLS.PeerCertificates) == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Client certificate required",
+				"message": "Connect over TLS and present a client certificate",
+			})
+			c.Abort()
+			return
+		}
+
+		identity, err := mapper.Identify(c.Request.TLS.PeerCertificates)
+		if err != nil {
+			fingerprint := auth.CertFingerprint(c.Request.TLS.PeerCertificates[0])
+			logAudit(apiKeyDB, c, 0, "mtls_auth_failed", http.StatusUnauthorized, map[string]any{
+				"error":            err.Error(),
+				"cert_fingerprint": fingerprint,
+			})
+
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Client certificate not authorized",
+			})
+			c.Abort()
+			return
+		}
+
+		// Optional second factor: an API key issued to this identity
+		var keyID int64
+		if identity.RequireAPIKey {
+			apiKey := c.GetHeader("X-API-Key")
+			if apiKey == "" {
+				authHeader := c.GetHeader("Authorization")
+				if strings.HasPrefix(authHeader, "Bearer ") {
+					apiKey = strings.TrimPrefix(authHeader, "Bearer ")
+				}
+			}
+
+			if apiKey == "" || apiKeyDB == nil {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error":   "API key required",
+					"message": "This client certificate requires an API key via X-API-Key header",
+				})
+				c.Abort()
+				return
+			}
+
+			ipSubject, keySubject := auth.IPSubject(c.ClientIP()), auth.KeySubject(c.ClientIP(), apiKey)
+			if rejectIfLocked(guard, c, ipSubject, keySubject) {
+				return
+			}
+
+			keyInfo, err := apiKeyDB.ValidateAPIKey(apiKey)
+			if err != nil {
+				logAudit(apiKeyDB, c, 0, "mtls_api_key_failed", http.StatusUnauthorized, map[string]any{
+					"identity":         identity.Name,
+					"cert_fingerprint": identity.Fingerprint,
+				})
+				recordAuthFailure(guard, apiKeyDB, c, 0, ipSubject, keySubject)
+
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Invalid API key",
+				})
+				c.Abort()
+				return
+			}
+
+			// A valid key of another owner must not lend its identity,
+			// team policy and budget to this certificate
+			if !identity.OwnsKey(keyInfo) {
+				logAudit(apiKeyDB, c, keyInfo.ID, "mtls_api_key_mismatch", http.StatusUnauthorized, map[string]any{
+					"identity":         identity.Name,
+					"cert_fingerprint": identity.Fingerprint,
+				})
+				recordAuthFailure(guard, apiKeyDB, c, keyInfo.ID, ipSubject, keySubject)
+
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "API key was not issued to this client certificate",
+				})
+				c.Abort()
+				return
+			}
+			guard.RecordSuccess(keySubject)
+			keyID = keyInfo.ID
+			c.Set("api_key_id", keyInfo.ID)
+		}
+
+		// Set user context
+		c.Set("user", identity.Name)
+		c.Set("user_email", identity.Email)
+		c.Set("permissions", identity.Permissions)
+		c.Set("cert_fingerprint", identity.Fingerprint)
+		c.Set("cert_subject", identity.Subject)
+		if identity.SPIFFEID != "" {
+			c.Set("spiffe_id", identity.SPIFFEID)
+		}
+		if identity.RequireAPIKey {
+			c.Set("auth_method", "mtls_api_key")
+		} else {
+			c.Set("auth_method", "mtls")
+		}
+
+		logAudit(apiKeyDB, c, keyID, "mtls_auth_success", http.StatusOK, map[string]any{
+			"identity":         identity.Name,
+			"subject":          identity.Subject,
+			"spiffe_id":        identity.SPIFFEID,
+			"cert_fingerprint": identity.Fingerprint,
+			"api_key_used":     identity.RequireAPIKey,
+		})
+
+		c.Next()
+	}
+}
+
+// logAudit writes an audit entry with JSON metadata; without a database the
+// entry goes to the process log instead.
+func logAudit(apiKeyDB *auth.APIKeyDB, c *gin.Context, keyID int64, action string, statusCode int, metadata map[string]any) {
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	meta, err := json.Marshal(metadata)
+	if err != nil {
+		meta = []byte("{}")
+	}
+
+	if apiKeyDB == nil {
+		log.Printf("audit: action=%s key_id=%d ip=%s path=%s status=%d metadata=%s",
+			action, keyID, c.ClientIP(), c.Request.URL.Path, statusCode, meta)
+		return
+	}
+
+	if err := apiKeyDB.LogAPIKeyUsage(
+		keyID,
+		action,
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+		c.Request.URL.Path,
+		statusCode,
+		string(meta),
+	); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+}
@@ -4,6 +4,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -11,8 +12,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// SessionTokenAuth validates session tokens (no TOTP needed after initial auth)
-func SessionTokenAuth(sessionManager *auth.SessionManager, apiKeyDB *auth.APIKeyDB) gin.HandlerFunc {
+// SessionTokenAuth validates session tokens (no TOTP needed after initial auth).
+// Invalid tokens count against the client IP in guard (nil disables).
+func SessionTokenAuth(sessionManager *auth.SessionManager, apiKeyDB *auth.APIKeyDB, guard *auth.BruteForceGuard) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Extract session token from header or Authorization Bearer
 		sessionToken := c.GetHeader("X-Session-Token")
@@ -33,19 +35,17 @@ func SessionTokenAuth(sessionManager *auth.SessionManager, apiKeyDB *auth.APIKey
 			return
 		}
 
+		ipSubject := auth.IPSubject(c.ClientIP())
+		if rejectIfLocked(guard, c, ipSubject) {
+			return
+		}
+
 		// Validate session token
-		session, apiKeyID, err := sessionManager.ValidateSessionToken(sessionToken)
+		session, apiKeyID, err := sessionManager.ValidateSessionToken(sessionToken, c.ClientIP(), c.GetHeader("User-Agent"))
 		if err != nil {
 			// Log failed attempt
-			apiKeyDB.LogAPIKeyUsage(
-				0,
-				"session_auth_failed",
-				c.ClientIP(),
-				c.GetHeader("User-Agent"),
-				c.Request.URL.Path,
-				401,
-				`{"error":"invalid_session_token"}`,
-			)
+			logSessionFailure(apiKeyDB, c, session, apiKeyID, err)
+			recordAuthFailure(guard, apiKeyDB, c, apiKeyID, ipSubject)
 
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid or expired session token",
@@ -69,18 +69,13 @@ func SessionTokenAuth(sessionManager *auth.SessionManager, apiKeyDB *auth.APIKey
 		c.Set("user_email", keyInfo.Email)
 		c.Set("api_key_id", apiKeyID)
 		c.Set("session_id", session.ID)
+		c.Set("permissions", keyInfo.PermissionList())
 		c.Set("auth_method", "session_token")
 
 		// Log successful authentication
-		apiKeyDB.LogAPIKeyUsage(
-			apiKeyID,
-			"session_auth_success",
-			c.ClientIP(),
-			c.GetHeader("User-Agent"),
-			c.Request.URL.Path,
-			200,
-			`{"session_id":` + intToString(int(session.ID)) + `}`,
-		)
+		logAudit(apiKeyDB, c, apiKeyID, "session_auth_success", http.StatusOK, map[string]any{
+			"session_id": session.ID,
+		})
 
 		c.Next()
 	}
@@ -92,8 +87,15 @@ func HybridAuth(
 	apiKeyDB *auth.APIKeyDB,
 	totpManager *auth.TOTPManager,
 	require2FA bool,
+	guard *auth.BruteForceGuard,
 ) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ipSubject := auth.IPSubject(c.ClientIP())
+		keySubject := auth.KeySubject(c.ClientIP(), c.GetHeader("X-API-Key"))
+		if rejectIfLocked(guard, c, ipSubject, keySubject) {
+			return
+		}
+
 		// Try session token first
 		sessionToken := c.GetHeader("X-Session-Token")
 		if sessionToken == "" {
@@ -105,19 +107,38 @@ func HybridAuth(
 
 		// If has session token, validate it
 		if sessionToken != "" {
-			session, apiKeyID, err := sessionManager.ValidateSessionToken(sessionToken)
+			session, apiKeyID, err := sessionManager.ValidateSessionToken(sessionToken, c.ClientIP(), c.GetHeader("User-Agent"))
+			if errors.Is(err, auth.ErrSessionReused) {
+				// Never fall back to API key auth for a stolen token
+				logSessionFailure(apiKeyDB, c, session, apiKeyID, err)
+				recordAuthFailure(guard, apiKeyDB, c, apiKeyID, ipSubject)
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Invalid or expired session token",
+				})
+				c.Abort()
+				return
+			}
 			if err == nil {
 				// Valid session token - authenticated!
-				keyInfo, _ := apiKeyDB.GetAPIKeyByID(apiKeyID)
+				keyInfo, err := apiKeyDB.GetAPIKeyByID(apiKeyID)
+				if err != nil {
+					c.JSON(http.StatusUnauthorized, gin.H{
+						"error": "Session token valid but API key not found",
+					})
+					c.Abort()
+					return
+				}
 				c.Set("user", keyInfo.Name)
 				c.Set("user_email", keyInfo.Email)
 				c.Set("api_key_id", apiKeyID)
 				c.Set("session_id", session.ID)
+				c.Set("permissions", keyInfo.PermissionList())
 				c.Set("auth_method", "session_token")
 				c.Next()
 				return
 			}
 			// Session token invalid, fall through to API key + TOTP
+			recordAuthFailure(guard, apiKeyDB, c, apiKeyID, ipSubject)
 		}
 
 		// No valid session token, require API key + TOTP
@@ -135,6 +156,7 @@ func HybridAuth(
 		// Validate API key
 		keyInfo, err := apiKeyDB.ValidateAPIKey(apiKey)
 		if err != nil {
+			recordAuthFailure(guard, apiKeyDB, c, 0, ipSubject, keySubject)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid API key",
 			})
@@ -157,7 +179,17 @@ func HybridAuth(
 			}
 
 			valid, err := totpManager.ValidateTOTP(keyInfo.ID, totpCode)
+			if errors.Is(err, auth.ErrTOTPLocked) {
+				logAudit(apiKeyDB, c, keyInfo.ID, "2fa_locked", http.StatusTooManyRequests, nil)
+
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"error": "Too many failed 2FA attempts, try again later",
+				})
+				c.Abort()
+				return
+			}
 			if err != nil || !valid {
+				recordAuthFailure(guard, apiKeyDB, c, keyInfo.ID, ipSubject, keySubject)
 				c.JSON(http.StatusUnauthorized, gin.H{
 					"error": "Invalid TOTP code",
 				})
@@ -166,12 +198,37 @@ func HybridAuth(
 			}
 		}
 
+		guard.RecordSuccess(keySubject)
+
 		// Authenticated with API key + TOTP
 		c.Set("user", keyInfo.Name)
 		c.Set("user_email", keyInfo.Email)
 		c.Set("api_key_id", keyInfo.ID)
+		c.Set("permissions", keyInfo.PermissionList())
 		c.Set("auth_method", "api_key_totp")
 
 		c.Next()
 	}
 }
+
+// logSessionFailure records a failed session validation, flagging token reuse
+func logSessionFailure(apiKeyDB *auth.APIKeyDB, c *gin.Context, session *auth.SessionToken, apiKeyID int64, err error) {
+	if errors.Is(err, auth.ErrSessionReused) && session != nil {
+		logAudit(apiKeyDB, c, apiKeyID, "session_reuse_detected", http.StatusUnauthorized, map[string]any{
+			"session_id": session.ID,
+			"family_id":  session.FamilyID,
+		})
+		return
+	}
+
+	reason := "invalid_session_token"
+	switch {
+	case errors.Is(err, auth.ErrSessionBindingMismatch):
+		reason = "session_binding_mismatch"
+	case errors.Is(err, auth.ErrSessionIdle):
+		reason = "session_idle_timeout"
+	}
+	logAudit(apiKeyDB, c, 0, "session_auth_failed", http.StatusUnauthorized, map[string]any{
+		"error": reason,
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// SigV4Auth verifies AWS SigV4 signed requests from stock AWS SDK clients.
+// Requests without a SigV4 Authorization header are passed to fallback; with
+// a nil fallback they are rejected. Failed signatures count towards lockouts
+// in guard.
+func SigV4Auth(verifier *auth.SigV4Verifier, apiKeyDB *auth.APIKeyDB, guard *auth.BruteForceGuard, fallback gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.IsSigV4Request(c.Request) {
+			if fallback != nil {
+				fallback(c)
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Missing SigV4 signature",
+				"message": "Sign requests with AWS Signature Version 4 using proxy-issued credentials",
+			})
+			c.Abort()
+			return
+		}
+
+		// Reject locked-out clients before doing any expensive work
+		ipSubject := auth.IPSubject(c.ClientIP())
+		keySubject := auth.AccessKeySubject(c.ClientIP(), auth.SigV4AccessKeyID(c.Request))
+		if rejectIfLocked(guard, c, ipSubject, keySubject) {
+			return
+		}
+
+		// Read the body for payload hash verification and restore it for the handler
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to read request body",
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		keyInfo, cred, err := verifier.Verify(c.Request, body)
+		if err != nil {
+			logAudit(apiKeyDB, c, 0, "sigv4_auth_failed", http.StatusForbidden, map[string]any{
+				"error": err.Error(),
+			})
+			recordAuthFailure(guard, apiKeyDB, c, 0, ipSubject, keySubject)
+
+			// Match the AWS error shape so SDK clients surface a sensible message
+			c.Header("X-Amzn-ErrorType", "InvalidSignatureException")
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "The request signature we calculated does not match the signature you provided",
+			})
+			c.Abort()
+			return
+		}
+
+		guard.RecordSuccess(keySubject)
+
+		// Set user context
+		c.Set("user", keyInfo.Name)
+		c.Set("user_email", keyInfo.Email)
+		c.Set("api_key_id", keyInfo.ID)
+		c.Set("access_key_id", cred.AccessKeyID)
+		c.Set("permissions", keyInfo.PermissionList())
+		c.Set("auth_method", "sigv4")
+
+		logAudit(apiKeyDB, c, keyInfo.ID, "sigv4_auth_success", http.StatusOK, map[string]any{
+			"access_key_id": cred.AccessKeyID,
+		})
+
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tags"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTags resolves a request's cost-center tags from the X-Proxy-Tags
+// header and the API key's default tags, and sets them as "request_tags".
+// Header tags override defaults. Header tags outside the allowlist reject
+// the request; default tags outside it are ignored. It must run after
+// TeamPolicy, which loads the key's defaults.
+func RequestTags(allow tags.Allowlist) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requested, err := tags.Parse(c.GetHeader(tags.Header))
+		if err == nil {
+			err = allow.Check(requested)
+		}
+
+		var defaults map[string]string
+		if policy, ok := c.Get("key_policy"); ok {
+			if p, ok := policy.(*auth.KeyPolicy); ok {
+				defaults = allow.Filter(p.DefaultTags)
+			}
+		}
+		merged := tags.Merge(defaults, requested)
+		if err == nil {
+			err = tags.Validate(merged)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid tags",
+				"code":    "invalid_tags",
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		// Tags reach providers only through the translated request
+		c.Request.Header.Del(tags.Header)
+		if len(merged) > 0 {
+			c.Set("request_tags", merged)
+		}
+		c.Next()
+	}
+}
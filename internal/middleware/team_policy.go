@@ -0,0 +1,175 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestLimiter counts requests per subject in fixed one-minute windows
+type RequestLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*requestWindow
+	now     func() time.Time
+}
+
+type requestWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRequestLimiter creates an in-memory per-minute request limiter
+func NewRequestLimiter() *RequestLimiter {
+	return &RequestLimiter{
+		windows: make(map[string]*requestWindow),
+		now:     time.Now,
+	}
+}
+
+// Allow counts a request for subject and reports whether it is within
+// limit per minute; otherwise it returns how long until the window resets
+func (l *RequestLimiter) Allow(subject string, limit int) (time.Duration, bool) {
+	if limit <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	window, ok := l.windows[subject]
+	if !ok || now.Sub(window.start) >= time.Minute {
+		// Drop stale windows so idle subjects don't accumulate
+		for key, w := range l.windows {
+			if now.Sub(w.start) >= time.Minute {
+				delete(l.windows, key)
+			}
+		}
+		window = &requestWindow{start: now}
+		l.windows[subject] = window
+	}
+
+	if window.count >= limit {
+		return window.start.Add(time.Minute).Sub(now), false
+	}
+
+	window.count++
+	return 0, true
+}
+
+// TeamPolicy applies team-inherited permissions, rate limits and budgets to
+// requests authenticated with a database API key. It must run after auth.
+// Handlers report the cost of a request by setting "request_cost_usd".
+// Spend crossing a budget threshold is reported through alerts, if set.
+func TeamPolicy(apiKeyDB *auth.APIKeyDB, limiter *RequestLimiter, alerts *BudgetAlerts) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, exists := c.Get("api_key_id")
+		keyID, _ := id.(int64)
+		if !exists || keyID == 0 || apiKeyDB == nil {
+			c.Next()
+			return
+		}
+
+		policy, err := apiKeyDB.EffectivePolicy(keyID)
+		if err != nil {
+			log.Printf("Failed to load key policy for key %d: %v", keyID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to load key policy",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("key_policy", policy)
+		if policy.TeamID != 0 {
+			c.Set("team_id", policy.TeamID)
+		}
+		if policy.Permissions != nil {
+			c.Set("permissions", policy.Permissions)
+		}
+
+		// Rate limits: per key, then shared across the team
+		if wait, ok := limiter.Allow(fmt.Sprintf("key:%d", keyID), policy.RateLimitRPM); !ok {
+			respondRateLimited(c, apiKeyDB, keyID, "key", wait)
+			return
+		}
+		if policy.TeamID != 0 {
+			if wait, ok := limiter.Allow(fmt.Sprintf("team:%d", policy.TeamID), policy.TeamRateLimitRPM); !ok {
+				respondRateLimited(c, apiKeyDB, keyID, "team", wait)
+				return
+			}
+		}
+
+		// Monthly budgets: per key, then shared across the team
+		now := time.Now()
+		if policy.MonthlyBudgetUSD > 0 {
+			spent, err := apiKeyDB.KeySpend(keyID, now)
+			if err == nil && spent >= policy.MonthlyBudgetUSD {
+				respondBudgetExceeded(c, apiKeyDB, keyID, "key", spent, policy.MonthlyBudgetUSD)
+				return
+			}
+		}
+		if policy.TeamMonthlyBudgetUSD > 0 {
+			spent, err := apiKeyDB.TeamSpend(policy.TeamID, now)
+			if err == nil && spent >= policy.TeamMonthlyBudgetUSD {
+				respondBudgetExceeded(c, apiKeyDB, keyID, "team", spent, policy.TeamMonthlyBudgetUSD)
+				return
+			}
+		}
+
+		c.Next()
+
+		if cost := c.GetFloat64("request_cost_usd"); cost > 0 {
+			if err := apiKeyDB.RecordSpend(keyID, policy.TeamID, cost, now); err != nil {
+				log.Printf("Failed to record spend for key %d: %v", keyID, err)
+			} else {
+				alerts.checkSpend(apiKeyDB, policy, keyID, now)
+			}
+		}
+	}
+}
+
+// respondRateLimited writes a 429 rate limit response for a key or team limit
+func respondRateLimited(c *gin.Context, apiKeyDB *auth.APIKeyDB, keyID int64, scope string, wait time.Duration) {
+	seconds := retryAfterSeconds(wait)
+
+	logAudit(apiKeyDB, c, keyID, "rate_limited", http.StatusTooManyRequests, map[string]any{
+		"scope": scope,
+	})
+
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Rate limit exceeded",
+		"code":        "rate_limit_exceeded",
+		"scope":       scope,
+		"retry_after": seconds,
+	})
+	c.Abort()
+}
+
+// respondBudgetExceeded writes a 429 response once a monthly budget is spent
+func respondBudgetExceeded(c *gin.Context, apiKeyDB *auth.APIKeyDB, keyID int64, scope string, spent, budget float64) {
+	logAudit(apiKeyDB, c, keyID, "budget_exceeded", http.StatusTooManyRequests, map[string]any{
+		"scope":      scope,
+		"spent_usd":  spent,
+		"budget_usd": budget,
+	})
+
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":      "Monthly budget exceeded",
+		"code":       "budget_exceeded",
+		"scope":      scope,
+		"spent_usd":  spent,
+		"budget_usd": budget,
+	})
+	c.Abort()
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Default limits applied when the configuration leaves them unset
+const (
+	DefaultBufferSize     = 1000
+	DefaultWorkers        = 4
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = time.Minute
+	DefaultTimeout        = 10 * time.Second
+)
+
+// DefaultBudgetThresholds are the fractions of a monthly budget that
+// trigger budget.threshold events when none are configured
+var DefaultBudgetThresholds = []float64{0.8, 1.0}
+
+// Payload formats
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+)
+
+// Config configures webhook notifications
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+
+	// BufferSize is how many deliveries may wait for a worker; further
+	// deliveries are dropped rather than slowing requests down
+	BufferSize int `yaml:"buffer_size"`
+	Workers    int `yaml:"workers"`
+
+	// MaxAttempts bounds delivery attempts per destination, with the wait
+	// between attempts doubling from InitialBackoff up to MaxBackoff
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Timeout        time.Duration `yaml:"timeout"` // per attempt
+
+	// DeadLetter is a JSONL file receiving deliveries that exhausted their
+	// attempts; without it they are only logged
+	DeadLetter string `yaml:"dead_letter"`
+
+	// BudgetThresholds are the fractions of a monthly budget whose crossing
+	// sends a budget.threshold event, e.g. [0.8, 1.0]
+	BudgetThresholds []float64 `yaml:"budget_thresholds"`
+
+	Destinations []Destination `yaml:"destinations"`
+}
+
+// Destination is a webhook endpoint and the events it receives
+type Destination struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+
+	// Secret signs each payload with HMAC-SHA256 in the X-Proxy-Signature
+	// header; empty sends unsigned payloads
+	Secret string `yaml:"secret"`
+
+	// Format is json (the event itself) or slack ({"text": ...})
+	Format string `yaml:"format"`
+
+	// Events lists the event types sent here; "*" or none sends all
+	Events []string `yaml:"events"`
+
+	// Templates render Slack text per event type, with "*" as the fallback;
+	// they default to DefaultSlackTemplate
+	Templates map[string]string `yaml:"templates"`
+
+	templates map[string]*template.Template
+}
+
+// LoadConfig loads notification settings from a YAML file, expanding
+// ${VAR} references so secrets can come from the environment
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse notification config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate fills in defaults and, when notifications are enabled, checks
+// the destinations and compiles their templates
+func (c *Config) Validate() error {
+	if c.BufferSize < 0 || c.Workers < 0 || c.MaxAttempts < 0 {
+		return fmt.Errorf("notification limits must not be negative")
+	}
+	if c.BufferSize == 0 {
+		c.BufferSize = DefaultBufferSize
+	}
+	if c.Workers == 0 {
+		c.Workers = DefaultWorkers
+	}
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = DefaultMaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = DefaultInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultMaxBackoff
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if len(c.BudgetThresholds) == 0 {
+		c.BudgetThresholds = DefaultBudgetThresholds
+	}
+	for _, threshold := range c.BudgetThresholds {
+		if threshold <= 0 {
+			return fmt.Errorf("budget threshold %v must be positive", threshold)
+		}
+	}
+
+	// Destinations of a disabled config may reference unset variables
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Destinations) == 0 {
+		return fmt.Errorf("at least one notification destination is required")
+	}
+	names := make(map[string]bool, len(c.Destinations))
+	for i := range c.Destinations {
+		d := &c.Destinations[i]
+		if d.Name == "" {
+			return fmt.Errorf("destination %d: name is required", i)
+		}
+		if names[d.Name] {
+			return fmt.Errorf("duplicate destination %q", d.Name)
+		}
+		names[d.Name] = true
+		if err := d.compile(); err != nil {
+			return fmt.Errorf("destination %q: %w", d.Name, err)
+		}
+	}
+	return nil
+}
+
+// compile checks a destination and parses its templates
+func (d *Destination) compile() error {
+	u, err := url.Parse(d.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", d.URL)
+	}
+
+	switch d.Format {
+	case "":
+		d.Format = FormatJSON
+	case FormatJSON, FormatSlack:
+	default:
+		return fmt.Errorf("unknown format %q (expected json or slack)", d.Format)
+	}
+
+	for _, event := range d.Events {
+		if event != "*" && !knownEvent(event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+
+	d.templates = make(map[string]*template.Template, len(d.Templates))
+	for event, text := range d.Templates {
+		if event != "*" && !knownEvent(event) {
+			return fmt.Errorf("template for unknown event %q", event)
+		}
+		tmpl, err := parseTemplate(event, text)
+		if err != nil {
+			return fmt.Errorf("template for %q: %w", event, err)
+		}
+		d.templates[event] = tmpl
+	}
+	return nil
+}
+
+// wants reports whether the destination receives events of a type
+func (d *Destination) wants(eventType string) bool {
+	if len(d.Events) == 0 {
+		return true
+	}
+	for _, event := range d.Events {
+		if event == "*" || event == eventType {
+			return true
+		}
+	}
+	return false
+}
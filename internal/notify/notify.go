@@ -0,0 +1,322 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package notify sends operational events (budget thresholds, provider
+// outages, authentication lockouts) to webhooks in the background, signing,
+// retrying and dead-lettering deliveries
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/pkg/metrics"
+	"github.com/google/uuid"
+)
+
+// Event types
+const (
+	EventBudgetThreshold   = "budget.threshold"   // spend crossed a fraction of a monthly budget
+	EventProviderUnhealthy = "provider.unhealthy" // a provider started failing
+	EventProviderRecovered = "provider.recovered" // an unhealthy provider is healthy again
+	EventAuthLockout       = "auth.lockout"       // repeated failed logins locked out a client or key
+)
+
+// Severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var eventTypes = []string{EventBudgetThreshold, EventProviderUnhealthy, EventProviderRecovered, EventAuthLockout}
+
+func knownEvent(eventType string) bool {
+	for _, known := range eventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON body of a webhook
+type Event struct {
+	ID       string         `json:"id"`
+	Type     string         `json:"type"`
+	Time     time.Time      `json:"time"`
+	Severity string         `json:"severity"`
+	Summary  string         `json:"summary"`
+	Data     map[string]any `json:"data,omitempty"`
+}
+
+// Headers sent with every delivery besides SignatureHeader
+const (
+	EventHeader    = "X-Proxy-Event"
+	DeliveryHeader = "X-Proxy-Delivery" // the event ID, stable across retries
+)
+
+// Notifier delivers events to the configured destinations in the
+// background. A nil Notifier is valid and sends nothing.
+type Notifier struct {
+	cfg        Config
+	client     *http.Client
+	deliveries chan *delivery
+	done       chan struct{}
+
+	// ctx is cancelled when Close gives up waiting, abandoning retries
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+
+	deadMu sync.Mutex
+}
+
+type delivery struct {
+	dest  *Destination
+	event *Event
+}
+
+// deadLetter is one line of the dead-letter file
+type deadLetter struct {
+	Time        time.Time `json:"time"`
+	Destination string    `json:"destination"`
+	Attempts    int       `json:"attempts"`
+	Error       string    `json:"error"`
+	Event       *Event    `json:"event"`
+}
+
+// New starts a notifier with cfg.Workers delivery workers
+func New(cfg *Config) (*Notifier, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		cfg:        *cfg,
+		client:     &http.Client{Timeout: cfg.Timeout},
+		deliveries: make(chan *delivery, cfg.BufferSize),
+		done:       make(chan struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.run()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(n.done)
+	}()
+	return n, nil
+}
+
+// BudgetThresholds returns the budget fractions that trigger
+// budget.threshold events
+func (n *Notifier) BudgetThresholds() []float64 {
+	if n == nil {
+		return nil
+	}
+	return n.cfg.BudgetThresholds
+}
+
+// Notify queues an event for every destination subscribed to its type. It
+// never blocks: when the buffer is full the delivery is dropped and counted.
+func (n *Notifier) Notify(eventType, severity, summary string, data map[string]any) {
+	if n == nil {
+		return
+	}
+
+	event := &Event{
+		ID:       uuid.NewString(),
+		Type:     eventType,
+		Time:     time.Now().UTC(),
+		Severity: severity,
+		Summary:  summary,
+		Data:     data,
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+
+	for i := range n.cfg.Destinations {
+		dest := &n.cfg.Destinations[i]
+		if !dest.wants(eventType) {
+			continue
+		}
+		select {
+		case n.deliveries <- &delivery{dest: dest, event: event}:
+		default:
+			log.Printf("Notification queue full, dropping %s event for %s", eventType, dest.Name)
+			metrics.RecordWebhookDelivery(dest.Name, "dropped")
+		}
+	}
+}
+
+// Close stops accepting events and waits for queued deliveries, including
+// their retries, until ctx is done; deliveries still pending then are
+// dead-lettered
+func (n *Notifier) Close(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.deliveries)
+	}
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+		return nil
+	case <-ctx.Done():
+		n.cancel()
+		<-n.done
+		return ctx.Err()
+	}
+}
+
+func (n *Notifier) run() {
+	for d := range n.deliveries {
+		n.deliver(d)
+	}
+}
+
+// deliver sends one event to one destination, retrying failures with
+// exponential backoff and dead-lettering it once attempts run out
+func (n *Notifier) deliver(d *delivery) {
+	body, err := d.payload()
+	if err != nil {
+		n.deadLetter(d, 0, err)
+		return
+	}
+
+	backoff := n.cfg.InitialBackoff
+	attempt := 0
+	for {
+		if n.ctx.Err() != nil {
+			if err == nil {
+				err = fmt.Errorf("notifier closed before delivery")
+			}
+			n.deadLetter(d, attempt, err)
+			return
+		}
+		attempt++
+
+		var retryable bool
+		retryable, err = n.send(d, body)
+		if err == nil {
+			metrics.RecordWebhookDelivery(d.dest.Name, "delivered")
+			return
+		}
+		if !retryable || attempt >= n.cfg.MaxAttempts {
+			n.deadLetter(d, attempt, err)
+			return
+		}
+
+		metrics.RecordWebhookDelivery(d.dest.Name, "retried")
+		select {
+		case <-time.After(backoff):
+		case <-n.ctx.Done():
+		}
+		backoff *= 2
+		if backoff > n.cfg.MaxBackoff {
+			backoff = n.cfg.MaxBackoff
+		}
+	}
+}
+
+// payload encodes an event in the destination's format
+func (d *delivery) payload() ([]byte, error) {
+	if d.dest.Format == FormatSlack {
+		return d.dest.slackPayload(d.event)
+	}
+	body, err := json.Marshal(d.event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+	return body, nil
+}
+
+// send makes one delivery attempt and reports whether a failure is worth
+// retrying: network errors, 429 and 5xx are, other statuses are not
+func (n *Notifier) send(d *delivery, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, d.dest.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bedrock-proxy-notifier")
+	req.Header.Set(EventHeader, d.event.Type)
+	req.Header.Set(DeliveryHeader, d.event.ID)
+	if d.dest.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.dest.Secret, time.Now(), body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+}
+
+// deadLetter records a delivery that will not be retried
+func (n *Notifier) deadLetter(d *delivery, attempts int, err error) {
+	log.Printf("Giving up on %s notification %s to %s after %d attempts: %v", d.event.Type, d.event.ID, d.dest.Name, attempts, err)
+	metrics.RecordWebhookDelivery(d.dest.Name, "dead_lettered")
+	if n.cfg.DeadLetter == "" {
+		return
+	}
+
+	line, encodeErr := json.Marshal(deadLetter{
+		Time:        time.Now().UTC(),
+		Destination: d.dest.Name,
+		Attempts:    attempts,
+		Error:       err.Error(),
+		Event:       d.event,
+	})
+	if encodeErr != nil {
+		log.Printf("Failed to encode dead letter: %v", encodeErr)
+		return
+	}
+
+	n.deadMu.Lock()
+	defer n.deadMu.Unlock()
+	f, openErr := os.OpenFile(n.cfg.DeadLetter, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if openErr != nil {
+		log.Printf("Failed to open dead-letter file: %v", openErr)
+		return
+	}
+	defer f.Close()
+	if _, writeErr := f.Write(append(line, '\n')); writeErr != nil {
+		log.Printf("Failed to write dead letter: %v", writeErr)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is a local webhook endpoint answering with queued statuses (200
+// once they run out) and recording what it received
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() ([]*http.Request, [][]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests, r.bodies
+}
+
+func newTestNotifier(t *testing.T, cfg Config) *Notifier {
+	t.Helper()
+	cfg.Enabled = true
+	if cfg.InitialBackoff == 0 {
+		cfg.InitialBackoff = time.Millisecond
+	}
+	n, err := New(&cfg)
+	if err != nil {
+		t.Fatalf("Failed to create notifier: %v", err)
+	}
+	return n
+}
+
+func closeNotifier(t *testing.T, n *Notifier) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Close(ctx); err != nil {
+		t.Fatalf("Failed to close notifier: %v", err)
+	}
+}
+
+func TestSignedDelivery(t *testing.T) {
+	ops := newReceiver(t)
+	finance := newReceiver(t)
+	n := newTestNotifier(t, Config{Destinations: []Destination{
+		{Name: "ops", URL: ops.URL, Secret: "s3cret", Events: []string{EventProviderUnhealthy, EventAuthLockout}},
+		{Name: "finance", URL: finance.URL, Events: []string{EventBudgetThreshold}},
+	}})
+
+	n.Notify(EventProviderUnhealthy, SeverityCritical, "Provider bedrock is unhealthy", map[string]any{"provider": "bedrock"})
+	closeNotifier(t, n)
+
+	requests, bodies := ops.received()
+	if len(requests) != 1 {
+		t.Fatalf("Expected one delivery to ops, got %d", len(requests))
+	}
+	if got, _ := finance.received(); len(got) != 0 {
+		t.Errorf("Expected no delivery to an unsubscribed destination, got %d", len(got))
+	}
+
+	req, body := requests[0], bodies[0]
+	if err := Verify("s3cret", req.Header.Get(SignatureHeader), body, time.Now(), time.Minute); err != nil {
+		t.Errorf("Expected a valid signature: %v", err)
+	}
+	if err := Verify("wrong", req.Header.Get(SignatureHeader), body, time.Now(), time.Minute); err == nil {
+		t.Error("Expected a signature check with the wrong secret to fail")
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if event.Type != EventProviderUnhealthy || event.Severity != SeverityCritical || event.Data["provider"] != "bedrock" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if req.Header.Get(EventHeader) != EventProviderUnhealthy || req.Header.Get(DeliveryHeader) != event.ID {
+		t.Errorf("Unexpected headers: %v", req.Header)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"auth.lockout"}`)
+	sentAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	header := Sign("key", sentAt, body)
+
+	if err := Verify("key", header, body, sentAt.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Errorf("Expected signature to verify: %v", err)
+	}
+	if err := Verify("key", header, []byte(`{"type":"budget.threshold"}`), sentAt, 0); err == nil {
+		t.Error("Expected a tampered body to fail")
+	}
+	if err := Verify("key", header, body, sentAt.Add(10*time.Minute), 5*time.Minute); err == nil {
+		t.Error("Expected a stale signature to fail")
+	}
+	if err := Verify("key", "v1=abc", body, sentAt, 0); err == nil {
+		t.Error("Expected a header without timestamp to fail")
+	}
+}
+
+func TestRetryAndDeadLetter(t *testing.T) {
+	flaky := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	down := newReceiver(t, 500, 500, 500, 500)
+	rejecting := newReceiver(t, http.StatusBadRequest)
+	deadLetters := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	n := newTestNotifier(t, Config{
+		MaxAttempts: 3,
+		DeadLetter:  deadLetters,
+		Destinations: []Destination{
+			{Name: "flaky", URL: flaky.URL},
+			{Name: "down", URL: down.URL},
+			{Name: "rejecting", URL: rejecting.URL},
+		},
+	})
+	n.Notify(EventAuthLockout, SeverityWarning, "ip:203.0.113.7 locked out", nil)
+	closeNotifier(t, n)
+
+	requests, bodies := flaky.received()
+	if len(requests) != 3 {
+		t.Errorf("Expected flaky destination to succeed on the third attempt, got %d attempts", len(requests))
+	}
+	if len(bodies) == 3 && string(bodies[0]) != string(bodies[2]) {
+		t.Error("Expected retries to resend the same payload")
+	}
+	if got, _ := down.received(); len(got) != 3 {
+		t.Errorf("Expected MaxAttempts attempts to a failing destination, got %d", len(got))
+	}
+	if got, _ := rejecting.received(); len(got) != 1 {
+		t.Errorf("Expected no retry after a 4xx, got %d attempts", len(got))
+	}
+
+	f, err := os.Open(deadLetters)
+	if err != nil {
+		t.Fatalf("Failed to open dead letters: %v", err)
+	}
+	defer f.Close()
+	attempts := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line deadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Failed to decode dead letter: %v", err)
+		}
+		if line.Event == nil || line.Event.Type != EventAuthLockout {
+			t.Errorf("Expected the event in the dead letter, got %+v", line)
+		}
+		attempts[line.Destination] = line.Attempts
+	}
+	if len(attempts) != 2 || attempts["down"] != 3 || attempts["rejecting"] != 1 {
+		t.Errorf("Expected dead letters for down and rejecting, got %v", attempts)
+	}
+}
+
+func TestCloseAbandonsRetries(t *testing.T) {
+	down := newReceiver(t, 500, 500, 500)
+	deadLetters := filepath.Join(t.TempDir(), "dead.jsonl")
+	n := newTestNotifier(t, Config{
+		MaxAttempts:    3,
+		InitialBackoff: time.Hour,
+		DeadLetter:     deadLetters,
+		Destinations:   []Destination{{Name: "down", URL: down.URL}},
+	})
+	n.Notify(EventProviderRecovered, SeverityInfo, "Provider openai recovered", nil)
+
+	// Wait for the first attempt, then give up on the hour-long backoff
+	for i := 0; i < 100; i++ {
+		if got, _ := down.received(); len(got) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := n.Close(ctx); err == nil {
+		t.Error("Expected Close to report abandoned deliveries")
+	}
+
+	data, err := os.ReadFile(deadLetters)
+	if err != nil || !strings.Contains(string(data), `"destination":"down"`) {
+		t.Errorf("Expected the abandoned delivery to be dead-lettered, got %q (%v)", data, err)
+	}
+
+	// Closed notifiers drop events; nil notifiers are no-ops
+	n.Notify(EventProviderRecovered, SeverityInfo, "ignored", nil)
+	var nilNotifier *Notifier
+	nilNotifier.Notify(EventAuthLockout, SeverityWarning, "ignored", nil)
+}
+
+func TestSlackTemplates(t *testing.T) {
+	slack := newReceiver(t)
+	n := newTestNotifier(t, Config{Destinations: []Destination{{
+		Name:   "slack",
+		URL:    slack.URL,
+		Format: FormatSlack,
+		Templates: map[string]string{
+			EventBudgetThreshold: "{{upper .Severity}}: {{.Summary}} ({{.Data.spent_usd}} USD)",
+		},
+	}}})
+
+	n.Notify(EventBudgetThreshold, SeverityWarning, "Team \"ml\" at 80%", map[string]any{"spent_usd": 80})
+	n.Notify(EventAuthLockout, SeverityWarning, "key:ab12 locked out", map[string]any{"failures": 5, "subject": "key:ab12"})
+	closeNotifier(t, n)
+
+	_, bodies := slack.received()
+	texts := map[string]bool{}
+	for _, body := range bodies {
+		var payload map[string]string
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatalf("Failed to decode slack payload: %v", err)
+		}
+		texts[payload["text"]] = true
+	}
+	for _, want := range []string{
+		`WARNING: Team "ml" at 80% (80 USD)`,
+		":warning: *key:ab12 locked out*\n• failures: 5\n• subject: key:ab12",
+	} {
+		if !texts[want] {
+			t.Errorf("Expected slack text %q, got %v", want, texts)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for name, dest := range map[string]Destination{
+		"bad url":        {Name: "a", URL: "ftp://example.com"},
+		"unknown format": {Name: "a", URL: "https://example.com", Format: "xml"},
+		"unknown event":  {Name: "a", URL: "https://example.com", Events: []string{"budget.exceeded"}},
+		"bad template":   {Name: "a", URL: "https://example.com", Templates: map[string]string{"*": "{{.Summary"}},
+		"no name":        {URL: "https://example.com"},
+	} {
+		cfg := Config{Enabled: true, Destinations: []Destination{dest}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	dup := Config{Enabled: true, Destinations: []Destination{
+		{Name: "a", URL: "https://example.com"},
+		{Name: "a", URL: "https://example.org"},
+	}}
+	if err := dup.Validate(); err == nil {
+		t.Error("Expected duplicate destination names to be rejected")
+	}
+
+	cfg := Config{Enabled: true, Destinations: []Destination{{Name: "a", URL: "https://example.com"}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.MaxAttempts != DefaultMaxAttempts || cfg.Destinations[0].Format != FormatJSON || len(cfg.BudgetThresholds) != 2 {
+		t.Errorf("Expected defaults to be filled in, got %+v", cfg)
+	}
+
+	disabled, err := LoadConfig("../../configs/notifications.yaml")
+	if err != nil || disabled.Enabled {
+		t.Errorf("Expected the sample config to load disabled, got %v", err)
+	}
+}
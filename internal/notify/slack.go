@@ -0,0 +1,55 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultSlackTemplate renders an event as a Slack message: the summary
+// followed by its data fields
+const DefaultSlackTemplate = `{{emoji .Severity}} *{{.Summary}}*{{range $key, $value := .Data}}
+• {{$key}}: {{$value}}{{end}}`
+
+var templateFuncs = template.FuncMap{
+	"emoji": func(severity string) string {
+		switch severity {
+		case SeverityCritical:
+			return ":rotating_light:"
+		case SeverityWarning:
+			return ":warning:"
+		default:
+			return ":information_source:"
+		}
+	},
+	"upper": strings.ToUpper,
+}
+
+var defaultSlackTemplate = template.Must(parseTemplate("default", DefaultSlackTemplate))
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+// slackPayload renders an event as a Slack incoming-webhook message using
+// the destination's template for the event type, its "*" template or the
+// default
+func (d *Destination) slackPayload(event *Event) ([]byte, error) {
+	tmpl, ok := d.templates[event.Type]
+	if !ok {
+		tmpl, ok = d.templates["*"]
+	}
+	if !ok {
+		tmpl = defaultSlackTemplate
+	}
+
+	var text strings.Builder
+	if err := tmpl.Execute(&text, event); err != nil {
+		return nil, fmt.Errorf("failed to render slack template: %w", err)
+	}
+	return json.Marshal(map[string]string{"text": text.String()})
+}
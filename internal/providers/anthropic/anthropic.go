@@ -39,6 +39,12 @@ type AnthropicRequest struct {
 	Tools       []AnthropicTool     `json:"tools,omitempty"`
 	ToolChoice  interface{}         `json:"tool_choice,omitempty"`
 	Stream      bool                `json:"stream,omitempty"`
+	Metadata    *AnthropicMetadata  `json:"metadata,omitempty"`
+}
+
+// AnthropicMetadata describes the request; user_id is an opaque caller ID
+type AnthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
 }
 
 type AnthropicMessage struct {
@@ -271,7 +277,122 @@ func (p *AnthropicProvider) InvokeStreaming(ctx context.Context, request *provid
 		}
 	}
 
-	return resp.Body, nil
+	return translator.NewStreamPipe(resp.Body, func(emit func(*translator.ChatCompletionStreamResponse) error) error {
+		return translateAnthropicStream(resp.Body, emit)
+	}), nil
+}
+
+// anthropicStreamEvent covers the payloads of Messages API stream events
+type anthropicStreamEvent struct {
+	Type         string                 `json:"type"`
+	Index        int                    `json:"index"`
+	Message      *AnthropicResponse     `json:"message,omitempty"`
+	ContentBlock *AnthropicContentBlock `json:"content_block,omitempty"`
+	Delta        *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta,omitempty"`
+	Usage *AnthropicUsage `json:"usage,omitempty"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// translateAnthropicStream converts Messages API stream events to OpenAI chunks
+func translateAnthropicStream(body io.Reader, emit func(*translator.ChatCompletionStreamResponse) error) error {
+	events := translator.NewSSEReader(body)
+	var usage translator.Usage
+	// toolIndex maps content block indexes to OpenAI tool call indexes
+	toolIndex := make(map[int]int)
+
+	for {
+		sse, err := events.Next()
+		if err == io.EOF {
+			return emit(translator.NewUsageChunk(usage))
+		}
+		if err != nil {
+			return err
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(sse.Data), &event); err != nil {
+			return fmt.Errorf("invalid stream event: %w", err)
+		}
+
+		var chunk *translator.ChatCompletionStreamResponse
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				usage.PromptTokens = event.Message.Usage.InputTokens
+			}
+			chunk = translator.NewStreamChunk(translator.ChatMessageDelta{Role: "assistant"}, "")
+
+		case "content_block_start":
+			if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+				index := len(toolIndex)
+				toolIndex[event.Index] = index
+				chunk = translator.NewStreamChunk(translator.ChatMessageDelta{ToolCalls: []translator.ToolCall{{
+					Index:    &index,
+					ID:       event.ContentBlock.ID,
+					Type:     "function",
+					Function: translator.FunctionCall{Name: event.ContentBlock.Name},
+				}}}, "")
+			}
+
+		case "content_block_delta":
+			if event.Delta == nil {
+				break
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				chunk = translator.NewStreamChunk(translator.ChatMessageDelta{Content: event.Delta.Text}, "")
+			case "input_json_delta":
+				index := toolIndex[event.Index]
+				chunk = translator.NewStreamChunk(translator.ChatMessageDelta{ToolCalls: []translator.ToolCall{{
+					Index:    &index,
+					Function: translator.FunctionCall{Arguments: event.Delta.PartialJSON},
+				}}}, "")
+			}
+
+		case "message_delta":
+			if event.Usage != nil {
+				usage.CompletionTokens = event.Usage.OutputTokens
+			}
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				chunk = translator.NewStreamChunk(translator.ChatMessageDelta{}, mapStopReason(event.Delta.StopReason))
+			}
+
+		case "message_stop":
+			return emit(translator.NewUsageChunk(usage))
+
+		case "error":
+			if event.Error != nil {
+				return fmt.Errorf("%s: %s", event.Error.Type, event.Error.Message)
+			}
+			return fmt.Errorf("stream error: %s", sse.Data)
+		}
+
+		if chunk != nil {
+			if err := emit(chunk); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// mapStopReason maps an Anthropic stop reason to an OpenAI finish reason
+func mapStopReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return "stop"
+	}
 }
 
 // ListModels lists available Anthropic models
@@ -309,6 +430,9 @@ func translateOpenAIToAnthropic(req *translator.ChatCompletionRequest) *Anthropi
 	if req.Temperature > 0 {
 		anthropicReq.Temperature = &req.Temperature
 	}
+	if req.User != "" {
+		anthropicReq.Metadata = &AnthropicMetadata{UserID: req.User}
+	}
 
 	// Convert messages
 	for _, msg := range req.Messages {
@@ -385,13 +509,7 @@ func translateAnthropicToOpenAI(resp *AnthropicResponse, model string) *translat
 		}
 	}
 
-	// Map stop reason
-	finishReason := "stop"
-	if resp.StopReason == "max_tokens" {
-		finishReason = "length"
-	} else if resp.StopReason == "tool_use" {
-		finishReason = "tool_calls"
-	}
+	finishReason := mapStopReason(resp.StopReason)
 
 	message := translator.ChatMessage{
 		Role:    "assistant",
@@ -0,0 +1,122 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tracing"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/pkg/metrics"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Instrument wraps p so each Invoke and InvokeStreaming call is recorded
+// as a client span and counted by upstream status. A streaming span ends
+// when the stream is closed.
+func Instrument(p Provider) Provider {
+	return &instrumentedProvider{Provider: p}
+}
+
+type instrumentedProvider struct {
+	Provider
+}
+
+// Type returns the wrapped provider's type
+func (t *instrumentedProvider) Type() string {
+	return TypeOf(t.Provider)
+}
+
+// start begins a span named "{provider}.{method}"
+func (t *instrumentedProvider) start(ctx context.Context, method string, request *ProviderRequest) (context.Context, trace.Span) {
+	attrs := tracing.ProviderAttributes(t.Name(), t.Type())
+	if request.Method != "" {
+		attrs = append(attrs, semconv.HTTPRequestMethodKey.String(request.Method))
+	}
+	if request.Path != "" {
+		attrs = append(attrs, semconv.URLPath(request.Path))
+	}
+	return tracing.Tracer().Start(ctx, t.Name()+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// Invoke implements Provider
+func (t *instrumentedProvider) Invoke(ctx context.Context, request *ProviderRequest) (*ProviderResponse, error) {
+	ctx, span := t.start(ctx, "Invoke", request)
+	defer span.End()
+
+	resp, err := t.Provider.Invoke(ctx, request)
+	if err != nil {
+		t.recordError(span, err)
+		return nil, err
+	}
+	metrics.RecordUpstreamStatus(t.Name(), resp.StatusCode)
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.Metadata.ModelUsed != "" {
+		span.SetAttributes(semconv.GenAIResponseModel(resp.Metadata.ModelUsed))
+	}
+	return resp, nil
+}
+
+// InvokeStreaming implements Provider
+func (t *instrumentedProvider) InvokeStreaming(ctx context.Context, request *ProviderRequest) (io.ReadCloser, error) {
+	ctx, span := t.start(ctx, "InvokeStreaming", request)
+
+	body, err := t.Provider.InvokeStreaming(ctx, request)
+	if err != nil {
+		t.recordError(span, err)
+		span.End()
+		return nil, err
+	}
+	metrics.RecordUpstreamStatus(t.Name(), http.StatusOK)
+	return &tracedStream{ReadCloser: body, span: span}, nil
+}
+
+// recordError records a failed call, classified by its provider error code
+func (t *instrumentedProvider) recordError(span trace.Span, err error) {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		metrics.RecordUpstreamStatus(t.Name(), providerErr.StatusCode)
+		if providerErr.StatusCode != 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(providerErr.StatusCode))
+		}
+		tracing.RecordError(span, err, providerErr.Code)
+		return
+	}
+	metrics.RecordUpstreamStatus(t.Name(), 0)
+	tracing.RecordError(span, err, "")
+}
+
+// tracedStream ends its span when the stream is closed, marking the first
+// byte received and any read error
+type tracedStream struct {
+	io.ReadCloser
+	span     trace.Span
+	started  bool
+	closeOne sync.Once
+}
+
+func (s *tracedStream) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	if n > 0 && !s.started {
+		s.started = true
+		s.span.AddEvent("first_byte")
+	}
+	if err != nil && err != io.EOF {
+		tracing.RecordError(s.span, err, "")
+	}
+	return n, err
+}
+
+func (s *tracedStream) Close() error {
+	err := s.ReadCloser.Close()
+	s.closeOne.Do(func() { s.span.End() })
+	return err
+}
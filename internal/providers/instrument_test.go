@@ -0,0 +1,93 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// failingProvider returns err from every call, or a response with status
+type failingProvider struct {
+	fakeProvider
+	status int
+	err    error
+}
+
+func (f *failingProvider) Invoke(ctx context.Context, request *ProviderRequest) (*ProviderResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ProviderResponse{StatusCode: f.status}, nil
+}
+
+func (f *failingProvider) InvokeStreaming(ctx context.Context, request *ProviderRequest) (io.ReadCloser, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return io.NopCloser(strings.NewReader("data: [DONE]\n\n")), nil
+}
+
+func TestInstrumentUpstreamStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   map[string]float64 // responses by status code after Invoke and InvokeStreaming
+	}{
+		{
+			name:   "response status; a started stream counts as 200",
+			status: 201,
+			want:   map[string]float64{"201": 1, "200": 1},
+		},
+		{
+			name: "provider error status",
+			err:  &ProviderError{Provider: "upstream", StatusCode: 429, Message: "throttled"},
+			want: map[string]float64{"429": 2},
+		},
+		{
+			name: "wrapped provider error",
+			err:  fmt.Errorf("invoke: %w", &ProviderError{StatusCode: 503}),
+			want: map[string]float64{"503": 2},
+		},
+		{
+			name: "provider error without a response",
+			err:  &ProviderError{Code: "timeout"},
+			want: map[string]float64{"error": 2},
+		},
+		{
+			name: "transport error",
+			err:  errors.New("connection refused"),
+			want: map[string]float64{"error": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics.UpstreamResponses.Reset()
+			p := Instrument(&failingProvider{fakeProvider: fakeProvider{providerType: "upstream"}, status: tt.status, err: tt.err})
+
+			p.Invoke(context.Background(), &ProviderRequest{})
+			if stream, err := p.InvokeStreaming(context.Background(), &ProviderRequest{}); err == nil {
+				stream.Close()
+			}
+
+			for code, want := range tt.want {
+				if got := testutil.ToFloat64(metrics.UpstreamResponses.WithLabelValues("upstream", code)); got != want {
+					t.Errorf("Expected %v responses with status %s, got %v", want, code, got)
+				}
+			}
+			if got := testutil.CollectAndCount(metrics.UpstreamResponses); got != len(tt.want) {
+				t.Errorf("Expected %d status series, got %d", len(tt.want), got)
+			}
+		})
+	}
+}
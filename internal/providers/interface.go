@@ -5,7 +5,9 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"io"
+	"net/http"
 	"time"
 )
 
@@ -183,6 +185,18 @@ func (e *ProviderError) Unwrap() error {
 	return e.Err
 }
 
+// ProviderFault returns err when it reflects on the provider's health
+// rather than on the request, and nil otherwise: 4xx responses other than
+// 429 are the request's fault
+func ProviderFault(err error) error {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) && providerErr.StatusCode >= 400 && providerErr.StatusCode < 500 &&
+		providerErr.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	return err
+}
+
 // Common capability constants
 const (
 	CapabilityChat            = "chat"
@@ -0,0 +1,107 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Settings configures one provider instance. Which fields a provider reads
+// depends on its type; credentials are usually ${VAR} references expanded
+// when the config is loaded.
+type Settings struct {
+	Region        string `yaml:"region,omitempty"`
+	Location      string `yaml:"location,omitempty"`
+	ProjectID     string `yaml:"project_id,omitempty"`
+	Endpoint      string `yaml:"endpoint,omitempty"`
+	BaseURL       string `yaml:"base_url,omitempty"`
+	APIVersion    string `yaml:"api_version,omitempty"`
+	APIKey        string `yaml:"api_key,omitempty"`
+	AuthToken     string `yaml:"auth_token,omitempty"`
+	AccessToken   string `yaml:"access_token,omitempty"`
+	CompartmentID string `yaml:"compartment_id,omitempty"`
+}
+
+// Factory creates a provider of one type from its settings
+type Factory func(settings Settings) (Provider, error)
+
+// Registry builds provider instances with the factory registered for their type
+type Registry struct {
+	factories map[string]Factory
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// Register adds the factory for a provider type, replacing any earlier one
+func (r *Registry) Register(providerType string, factory Factory) {
+	r.factories[providerType] = factory
+}
+
+// Has reports whether a factory is registered for the type
+func (r *Registry) Has(providerType string) bool {
+	_, ok := r.factories[providerType]
+	return ok
+}
+
+// Types returns the registered provider types, sorted
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.factories))
+	for providerType := range r.factories {
+		types = append(types, providerType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Build creates the instance called name from the factory for its type
+func (r *Registry) Build(name, providerType string, settings Settings) (Provider, error) {
+	factory, ok := r.factories[providerType]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider type %q", providerType)
+	}
+	provider, err := factory(settings)
+	if err != nil {
+		return nil, err
+	}
+	return Named(name, provider), nil
+}
+
+// Named gives p an instance name, e.g. "bedrock-eu-west-1" for a bedrock
+// provider; TypeOf still returns its type
+func Named(name string, p Provider) Provider {
+	if name == p.Name() {
+		return p
+	}
+	return &namedProvider{Provider: p, name: name, providerType: TypeOf(p)}
+}
+
+type namedProvider struct {
+	Provider
+	name         string
+	providerType string
+}
+
+// Name implements Provider
+func (n *namedProvider) Name() string {
+	return n.name
+}
+
+// Type returns the provider type
+func (n *namedProvider) Type() string {
+	return n.providerType
+}
+
+// TypeOf returns the type of a provider, which decides its request and
+// response formats: bedrock, azure, openai, anthropic, vertex, ibm or oracle.
+// It is the provider's name unless it is a named instance.
+func TypeOf(p Provider) string {
+	if typed, ok := p.(interface{ Type() string }); ok {
+		return typed.Type()
+	}
+	return p.Name()
+}
@@ -0,0 +1,92 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// fakeProvider is a Provider of a given type that remembers its settings
+type fakeProvider struct {
+	providerType string
+	settings     Settings
+}
+
+func (f *fakeProvider) Name() string                          { return f.providerType }
+func (f *fakeProvider) HealthCheck(ctx context.Context) error { return nil }
+func (f *fakeProvider) Invoke(ctx context.Context, request *ProviderRequest) (*ProviderResponse, error) {
+	return &ProviderResponse{StatusCode: 200}, nil
+}
+func (f *fakeProvider) InvokeStreaming(ctx context.Context, request *ProviderRequest) (io.ReadCloser, error) {
+	return nil, errors.New("not supported")
+}
+func (f *fakeProvider) ListModels(ctx context.Context) ([]Model, error) { return nil, nil }
+func (f *fakeProvider) GetModelInfo(ctx context.Context, modelID string) (*Model, error) {
+	return nil, errors.New("not found")
+}
+
+func fakeFactory(providerType string) Factory {
+	return func(s Settings) (Provider, error) {
+		if s.Region == "" {
+			return nil, errors.New("region is required")
+		}
+		return &fakeProvider{providerType: providerType, settings: s}, nil
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register("openai", fakeFactory("openai"))
+	r.Register("bedrock", fakeFactory("bedrock"))
+
+	if !r.Has("bedrock") || r.Has("oracle") {
+		t.Error("Expected only registered types")
+	}
+	if got := r.Types(); !reflect.DeepEqual(got, []string{"bedrock", "openai"}) {
+		t.Errorf("Expected sorted types, got %v", got)
+	}
+
+	t.Run("instance named after its type", func(t *testing.T) {
+		p, err := r.Build("bedrock", "bedrock", Settings{Region: "us-east-1"})
+		if err != nil {
+			t.Fatalf("Failed to build: %v", err)
+		}
+		if _, wrapped := p.(*namedProvider); wrapped {
+			t.Error("Expected no wrapper when the name matches the type")
+		}
+		if p.Name() != "bedrock" || TypeOf(p) != "bedrock" {
+			t.Errorf("Unexpected name %q or type %q", p.Name(), TypeOf(p))
+		}
+	})
+
+	t.Run("named instance keeps its type", func(t *testing.T) {
+		p, err := r.Build("bedrock-eu-west-1", "bedrock", Settings{Region: "eu-west-1"})
+		if err != nil {
+			t.Fatalf("Failed to build: %v", err)
+		}
+		if p.Name() != "bedrock-eu-west-1" || TypeOf(p) != "bedrock" {
+			t.Errorf("Unexpected name %q or type %q", p.Name(), TypeOf(p))
+		}
+		inner := p.(*namedProvider).Provider.(*fakeProvider)
+		if inner.settings.Region != "eu-west-1" {
+			t.Errorf("Expected settings passed to the factory, got %+v", inner.settings)
+		}
+	})
+
+	t.Run("factory error", func(t *testing.T) {
+		if _, err := r.Build("openai", "openai", Settings{}); err == nil {
+			t.Error("Expected the factory error")
+		}
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		if _, err := r.Build("oracle", "oracle", Settings{Region: "x"}); err == nil {
+			t.Error("Expected an error for an unregistered type")
+		}
+	})
+}
@@ -266,7 +266,7 @@ func (p *VertexProvider) InvokeStreaming(ctx context.Context, request *providers
 	}
 
 	modelID := openaiReq.Model
-	url := fmt.Sprintf("%s/publishers/google/models/%s:streamGenerateContent", p.baseURL, modelID)
+	url := fmt.Sprintf("%s/publishers/google/models/%s:streamGenerateContent?alt=sse", p.baseURL, modelID)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
@@ -301,7 +301,87 @@ func (p *VertexProvider) InvokeStreaming(ctx context.Context, request *providers
 		}
 	}
 
-	return resp.Body, nil
+	return translator.NewStreamPipe(resp.Body, func(emit func(*translator.ChatCompletionStreamResponse) error) error {
+		return translateVertexStream(resp.Body, emit)
+	}), nil
+}
+
+// translateVertexStream converts streamGenerateContent events to OpenAI chunks
+func translateVertexStream(body io.Reader, emit func(*translator.ChatCompletionStreamResponse) error) error {
+	events := translator.NewSSEReader(body)
+	var usage *VertexUsageMetadata
+	toolCalls := 0
+	started := false
+
+	for {
+		sse, err := events.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var resp VertexResponse
+		if err := json.Unmarshal([]byte(sse.Data), &resp); err != nil {
+			return fmt.Errorf("invalid stream event: %w", err)
+		}
+		if resp.UsageMetadata != nil {
+			usage = resp.UsageMetadata
+		}
+		if len(resp.Candidates) == 0 {
+			continue
+		}
+
+		var chunks []*translator.ChatCompletionStreamResponse
+		if !started {
+			chunks = append(chunks, translator.NewStreamChunk(translator.ChatMessageDelta{Role: "assistant"}, ""))
+			started = true
+		}
+
+		candidate := resp.Candidates[0]
+		for _, part := range candidate.Content.Parts {
+			if part.Text != "" {
+				chunks = append(chunks, translator.NewStreamChunk(translator.ChatMessageDelta{Content: part.Text}, ""))
+			}
+			if part.FunctionCall != nil {
+				// Gemini streams each function call whole
+				index := toolCalls
+				toolCalls++
+				argsJSON, _ := json.Marshal(part.FunctionCall.Args)
+				chunks = append(chunks, translator.NewStreamChunk(translator.ChatMessageDelta{ToolCalls: []translator.ToolCall{{
+					Index: &index,
+					ID:    fmt.Sprintf("call_%d", index),
+					Type:  "function",
+					Function: translator.FunctionCall{
+						Name:      part.FunctionCall.Name,
+						Arguments: string(argsJSON),
+					},
+				}}}, ""))
+			}
+		}
+		if candidate.FinishReason != "" {
+			finishReason := mapFinishReason(candidate.FinishReason)
+			if toolCalls > 0 {
+				finishReason = "tool_calls"
+			}
+			chunks = append(chunks, translator.NewStreamChunk(translator.ChatMessageDelta{}, finishReason))
+		}
+
+		for _, chunk := range chunks {
+			if err := emit(chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	if usage == nil {
+		return nil
+	}
+	return emit(translator.NewUsageChunk(translator.Usage{
+		PromptTokens:     usage.PromptTokenCount,
+		CompletionTokens: usage.CandidatesTokenCount,
+	}))
 }
 
 // ListModels lists available Vertex AI models
@@ -426,15 +506,7 @@ func translateVertexToOpenAI(resp *VertexResponse, model string) *translator.Cha
 			}
 		}
 
-		// Map finish reason
-		switch candidate.FinishReason {
-		case "STOP":
-			finishReason = "stop"
-		case "MAX_TOKENS":
-			finishReason = "length"
-		case "SAFETY":
-			finishReason = "content_filter"
-		}
+		finishReason = mapFinishReason(candidate.FinishReason)
 
 		if len(toolCalls) > 0 {
 			finishReason = "tool_calls"
@@ -474,6 +546,18 @@ func translateVertexToOpenAI(resp *VertexResponse, model string) *translator.Cha
 	}
 }
 
+// mapFinishReason maps a Gemini finish reason to an OpenAI finish reason
+func mapFinishReason(reason string) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
 // extractTextContent extracts text from content interface
 func extractTextContent(content interface{}) string {
 	switch c := content.(type) {
@@ -7,21 +7,25 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the router configuration loaded from YAML
 type Config struct {
-	ModelMappings map[string]ModelMapping `yaml:"model_mappings"`
-	Routing       RoutingConfig           `yaml:"routing"`
+	ModelMappings map[string]ModelMapping   `yaml:"model_mappings"`
+	Routing       RoutingConfig             `yaml:"routing"`
 	Providers     map[string]ProviderConfig `yaml:"providers"`
-	Features      FeatureFlags            `yaml:"features"`
+	Features      FeatureFlags              `yaml:"features"`
+	HealthChecks  HealthCheckConfig         `yaml:"health_checks"`
 }
 
-// ModelMapping defines how a model name maps to different providers
+// ModelMapping defines how a model name maps to different providers, keyed
+// by provider instance name
 type ModelMapping struct {
 	DefaultProvider string                       `yaml:"default_provider"`
 	Providers       map[string]ProviderModelInfo `yaml:"providers"`
@@ -39,9 +43,9 @@ type ProviderModelInfo struct {
 
 // RoutingConfig defines routing rules and fallback behavior
 type RoutingConfig struct {
-	Patterns       []RoutingPattern `yaml:"patterns"`
-	Fallback       FallbackConfig   `yaml:"fallback"`
-	LoadBalancing  LoadBalancingConfig `yaml:"load_balancing"`
+	Patterns      []RoutingPattern    `yaml:"patterns"`
+	Fallback      FallbackConfig      `yaml:"fallback"`
+	LoadBalancing LoadBalancingConfig `yaml:"load_balancing"`
 }
 
 // RoutingPattern defines a regex pattern for routing
@@ -65,18 +69,54 @@ type LoadBalancingConfig struct {
 	Strategy string `yaml:"strategy"` // round_robin, least_latency, random, cost_optimized
 }
 
-// ProviderConfig contains provider-specific configuration
+// ProviderConfig declares a provider instance. Its key in the providers
+// section is the instance name that model mappings, routing patterns and
+// fallbacks refer to, e.g. "bedrock-eu-west-1".
 type ProviderConfig struct {
-	Enabled     bool          `yaml:"enabled"`
-	Region      string        `yaml:"region,omitempty"`
-	Location    string        `yaml:"location,omitempty"`
-	ProjectID   string        `yaml:"project_id,omitempty"`
-	Endpoint    string        `yaml:"endpoint,omitempty"`
-	BaseURL     string        `yaml:"base_url,omitempty"`
-	APIVersion  string        `yaml:"api_version,omitempty"`
-	Timeout     time.Duration `yaml:"timeout"`
-	MaxRetries  int           `yaml:"max_retries"`
-	RetryDelay  time.Duration `yaml:"retry_delay,omitempty"`
+	// Type selects the provider factory (bedrock, azure, openai, anthropic,
+	// vertex, ibm or oracle) and defaults to the instance name
+	Type    string `yaml:"type,omitempty"`
+	Enabled bool   `yaml:"enabled"`
+
+	// Region, endpoint, credentials and other settings for the factory
+	providers.Settings `yaml:",inline"`
+
+	Timeout    time.Duration `yaml:"timeout"`
+	MaxRetries int           `yaml:"max_retries"`
+	RetryDelay time.Duration `yaml:"retry_delay,omitempty"`
+
+	// Required providers must be healthy for the gateway to report ready
+	Required bool `yaml:"required,omitempty"`
+}
+
+// HealthCheckConfig controls background provider probing. Zero values
+// take the defaults of health.ProberConfig.
+type HealthCheckConfig struct {
+	Interval       time.Duration `yaml:"interval"`
+	Timeout        time.Duration `yaml:"timeout"`
+	Window         time.Duration `yaml:"window"`
+	ErrorThreshold float64       `yaml:"error_threshold"`
+
+	// MinRequests is how many probes and requests the window must hold
+	// before error_threshold applies
+	MinRequests int `yaml:"min_requests"`
+	// FailureThreshold is how many probes in a row must fail before a
+	// provider is unhealthy
+	FailureThreshold int `yaml:"failure_threshold"`
+
+	// Models configures synthetic checks of each mapped model
+	Models ModelCheckConfig `yaml:"models"`
+}
+
+// ModelCheckConfig controls synthetic model checks, which send a one-token
+// completion to each model in model_mappings on each of its providers.
+// Zero values take the defaults of health.ModelCheckerConfig.
+type ModelCheckConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Interval         time.Duration `yaml:"interval"`
+	Timeout          time.Duration `yaml:"timeout"`
+	FailureThreshold int           `yaml:"failure_threshold"`
+	Concurrency      int           `yaml:"concurrency"`
 }
 
 // FeatureFlags contains feature flag settings
@@ -168,6 +208,14 @@ func (c *Config) GetProviderConfig(providerName string) (*ProviderConfig, bool)
 	return &config, exists
 }
 
+// ProviderType returns the type of a provider instance
+func (c *Config) ProviderType(providerName string) string {
+	if config, exists := c.Providers[providerName]; exists && config.Type != "" {
+		return config.Type
+	}
+	return providerName
+}
+
 // IsProviderEnabled checks if a provider is enabled
 func (c *Config) IsProviderEnabled(providerName string) bool {
 	config, exists := c.Providers[providerName]
@@ -196,6 +244,18 @@ func (c *Config) ListEnabledProviders() []string {
 	return enabled
 }
 
+// ListRequiredProviders returns the enabled providers marked required
+func (c *Config) ListRequiredProviders() []string {
+	var required []string
+	for name, config := range c.Providers {
+		if config.Enabled && config.Required {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+	return required
+}
+
 // ListModelsForProvider returns all models that can use a specific provider
 func (c *Config) ListModelsForProvider(providerName string) []string {
 	var models []string
@@ -231,6 +291,16 @@ func (c *Config) ValidateConfig() error {
 		}
 	}
 
+	// Check that mapped providers are declared instances
+	for modelName, mapping := range c.ModelMappings {
+		for providerName := range mapping.Providers {
+			if _, exists := c.Providers[providerName]; !exists {
+				errors = append(errors, fmt.Sprintf("model %q provider %q not found in provider configs",
+					modelName, providerName))
+			}
+		}
+	}
+
 	// Check fallback providers exist
 	if c.Routing.Fallback.Enabled {
 		for _, providerName := range c.Routing.Fallback.Providers {
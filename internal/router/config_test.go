@@ -0,0 +1,60 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProviderType(t *testing.T) {
+	t.Setenv("TEST_AZURE_SWEDEN_KEY", "secret")
+	path := filepath.Join(t.TempDir(), "model-mapping.yaml")
+	err := os.WriteFile(path, []byte(`
+providers:
+  bedrock:
+    enabled: true
+    region: us-east-1
+  bedrock-eu-west-1:
+    type: bedrock
+    enabled: true
+    region: eu-west-1
+  azure-sweden:
+    type: azure
+    enabled: false
+    endpoint: https://sweden.openai.azure.com
+    api_key: ${TEST_AZURE_SWEDEN_KEY}
+`), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	tests := map[string]string{
+		"bedrock":           "bedrock", // type defaults to the instance name
+		"bedrock-eu-west-1": "bedrock",
+		"azure-sweden":      "azure",
+		"undeclared":        "undeclared",
+	}
+	for name, want := range tests {
+		if got := cfg.ProviderType(name); got != want {
+			t.Errorf("ProviderType(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	if got := cfg.Providers["bedrock-eu-west-1"].Region; got != "eu-west-1" {
+		t.Errorf("Expected inline settings, got region %q", got)
+	}
+	if got := cfg.Providers["azure-sweden"].APIKey; got != "secret" {
+		t.Errorf("Expected expanded credentials, got %q", got)
+	}
+	if cfg.IsProviderEnabled("azure-sweden") || !cfg.IsProviderEnabled("bedrock-eu-west-1") {
+		t.Error("Unexpected enabled providers")
+	}
+}
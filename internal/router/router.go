@@ -9,12 +9,24 @@ import (
 	"log"
 
 	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tracing"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/pkg/metrics"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Router handles routing requests to appropriate providers
 type Router struct {
 	config    *Config
 	providers map[string]providers.Provider
+	models    ModelAvailability
+}
+
+// ModelAvailability reports whether a model currently answers on a
+// provider, e.g. health.ModelChecker
+type ModelAvailability interface {
+	Available(model, provider string) bool
 }
 
 // NewRouter creates a new router with the given configuration
@@ -30,8 +42,33 @@ func NewRouter(config *Config, providerRegistry map[string]providers.Provider) (
 	}, nil
 }
 
+// SetModelHealth makes routing skip providers on which a model is
+// unavailable and model listings report it as such
+func (r *Router) SetModelHealth(m ModelAvailability) {
+	r.models = m
+}
+
 // RouteRequest determines which provider should handle a request
 func (r *Router) RouteRequest(ctx context.Context, modelName string, preferredProvider string) (providers.Provider, *ProviderModelInfo, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "Router.RouteRequest",
+		trace.WithAttributes(semconv.GenAIRequestModel(modelName)))
+	defer span.End()
+	if preferredProvider != "" {
+		span.SetAttributes(attribute.String("proxy.route.preferred_provider", preferredProvider))
+	}
+
+	provider, modelInfo, err := r.route(ctx, modelName, preferredProvider)
+	if err != nil {
+		tracing.RecordError(span, err, "no_route")
+		return nil, nil, err
+	}
+	span.SetAttributes(tracing.ProviderAttributes(provider.Name(), providers.TypeOf(provider))...)
+	span.SetAttributes(attribute.String("proxy.route.provider_model", modelInfo.Model))
+	return provider, modelInfo, nil
+}
+
+// route picks the preferred, default or a fallback provider for a model
+func (r *Router) route(ctx context.Context, modelName string, preferredProvider string) (providers.Provider, *ProviderModelInfo, error) {
 	// If preferred provider is specified and valid, use it
 	if preferredProvider != "" {
 		if provider, modelInfo, err := r.getProviderForModel(modelName, preferredProvider); err == nil {
@@ -81,9 +118,40 @@ func (r *Router) getProviderForModel(modelName, providerName string) (providers.
 		return nil, nil, fmt.Errorf("model %q not available on provider %q: %w", modelName, providerName, err)
 	}
 
+	if r.models != nil && !r.models.Available(modelName, providerName) {
+		return nil, nil, fmt.Errorf("model %q is failing health checks on provider %q", modelName, providerName)
+	}
+
 	return provider, modelInfo, nil
 }
 
+// available reports whether requests for a model can currently be routed,
+// to its default provider or a fallback
+func (r *Router) available(modelName string) bool {
+	defaultProvider := r.config.GetDefaultProvider(modelName)
+	if _, _, err := r.getProviderForModel(modelName, defaultProvider); err == nil {
+		return true
+	}
+	if !r.config.Features.AutoFallback || !r.config.Routing.Fallback.Enabled {
+		return false
+	}
+
+	attempts := 0
+	for _, providerName := range r.config.GetFallbackProviders() {
+		if providerName == defaultProvider {
+			continue
+		}
+		if attempts >= r.config.Routing.Fallback.MaxAttempts {
+			break
+		}
+		attempts++
+		if _, _, err := r.getProviderForModel(modelName, providerName); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // tryFallbackProviders attempts to find an alternative provider
 func (r *Router) tryFallbackProviders(ctx context.Context, modelName, excludeProvider string) (providers.Provider, *ProviderModelInfo, error) {
 	fallbackProviders := r.config.GetFallbackProviders()
@@ -106,6 +174,12 @@ func (r *Router) tryFallbackProviders(ctx context.Context, modelName, excludePro
 		provider, modelInfo, err := r.getProviderForModel(modelName, providerName)
 		if err == nil {
 			log.Printf("Successfully failed over to provider %q for model %q", providerName, modelName)
+			metrics.RecordFallback(modelName, excludeProvider, providerName)
+			trace.SpanFromContext(ctx).AddEvent("fallback", trace.WithAttributes(
+				attribute.String("proxy.route.failed_provider", excludeProvider),
+				attribute.String("proxy.route.fallback_provider", providerName),
+				attribute.Int("proxy.route.attempts", attempts),
+			))
 			return provider, modelInfo, nil
 		}
 
@@ -129,7 +203,8 @@ func (r *Router) GetProvider(providerName string) (providers.Provider, error) {
 	return provider, nil
 }
 
-// ListModels lists all available models across all enabled providers
+// ListModels lists the models of all enabled providers, with Available set
+// by whether each can currently be routed
 func (r *Router) ListModels(ctx context.Context) ([]providers.Model, error) {
 	var allModels []providers.Model
 
@@ -154,12 +229,14 @@ func (r *Router) ListModels(ctx context.Context) ([]providers.Model, error) {
 				ID:       modelName,
 				Provider: mapping.DefaultProvider,
 				Name:     modelName,
-				Available: true,
+				Available: r.available(modelName),
 			})
 			continue
 		}
 
-		allModels = append(allModels, *modelInfo)
+		model := *modelInfo
+		model.Available = r.available(modelName)
+		allModels = append(allModels, model)
 	}
 
 	return allModels, nil
@@ -180,7 +257,13 @@ func (r *Router) GetModelInfo(ctx context.Context, modelName string) (*providers
 	}
 
 	// Get model info
-	return provider.GetModelInfo(ctx, modelName)
+	modelInfo, err := provider.GetModelInfo(ctx, modelName)
+	if err != nil {
+		return nil, err
+	}
+	model := *modelInfo
+	model.Available = r.available(modelName)
+	return &model, nil
 }
 
 // HealthCheck performs health checks on all enabled providers
@@ -0,0 +1,112 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the SQL flavour spoken by a backend
+type Dialect string
+
+// Supported dialects
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// Rebind rewrites ? placeholders into the dialect's bind syntax. Question
+// marks inside quoted literals are left alone.
+func (d Dialect) Rebind(query string) string {
+	if d != DialectPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	inQuote := false
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\'':
+			inQuote = !inQuote
+			b.WriteByte(ch)
+		case ch == '?' && !inQuote:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	return b.String()
+}
+
+var (
+	autoIncrementPattern = regexp.MustCompile(`\bINTEGER PRIMARY KEY AUTOINCREMENT\b`)
+	integerPattern       = regexp.MustCompile(`\bINTEGER\b`)
+	realPattern          = regexp.MustCompile(`\bREAL\b`)
+	timestampPattern     = regexp.MustCompile(`\bTIMESTAMP\b`)
+)
+
+// DDL translates schema statements written for SQLite into the dialect.
+// Integers widen to 64 bits, REAL becomes double precision and timestamps
+// keep their time zone. Only upper-case type names are rewritten so columns
+// named like types (e.g. timestamp) are left alone.
+func (d Dialect) DDL(ddl string) string {
+	if d != DialectPostgres {
+		return ddl
+	}
+
+	ddl = autoIncrementPattern.ReplaceAllString(ddl, "BIGSERIAL PRIMARY KEY")
+	ddl = integerPattern.ReplaceAllString(ddl, "BIGINT")
+	ddl = realPattern.ReplaceAllString(ddl, "DOUBLE PRECISION")
+	ddl = timestampPattern.ReplaceAllString(ddl, "TIMESTAMPTZ")
+	return ddl
+}
+
+// ExecSchema runs schema statements after translating them to the store's dialect
+func ExecSchema(s Store, ddl string) error {
+	_, err := s.Exec(s.Dialect().DDL(ddl))
+	return err
+}
+
+// Columns returns the column names of a table (empty if it does not exist)
+func Columns(q Querier, dialect Dialect, table string) (map[string]bool, error) {
+	var rows *sql.Rows
+	var err error
+
+	switch dialect {
+	case DialectPostgres:
+		rows, err = q.Query(`
+			SELECT column_name
+			FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = ?
+		`, table)
+	default:
+		rows, err = q.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to inspect table %s: %w", table, err)
+		}
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
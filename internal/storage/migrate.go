@@ -0,0 +1,280 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationLockID is the PostgreSQL advisory lock held while migrating
+const migrationLockID = 4_217_530_981
+
+// Migration is one numbered up-migration
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+var (
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.sql$`)
+	addColumnPattern     = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\w+)\s+ADD\s+COLUMN\s+(\w+)\b`)
+)
+
+// LoadMigrations reads NNNN_name.sql files from dir, ordered by version
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, entry.Name())
+		}
+		seen[version] = entry.Name()
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: match[2], SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator applies migrations to a store and records them in schema_migrations
+type Migrator struct {
+	store      Store
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the given migrations
+func NewMigrator(store Store, migrations []Migration) *Migrator {
+	return &Migrator{store: store, migrations: migrations}
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(m.store, m.store.Dialect())
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		s := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if at, ok := applied[migration.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &at
+		}
+		status = append(status, s)
+	}
+
+	return status, nil
+}
+
+// Pending returns migrations that have not been applied yet
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := appliedMigrations(m.store, m.store.Dialect())
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies all pending migrations in one transaction while holding the
+// migration lock, so concurrent replicas starting together migrate once.
+// It returns the migrations that were applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	dialect := m.store.Dialect()
+
+	tx, err := m.store.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockMigrations(tx, dialect); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(dialect.DDL(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)
+	`)); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedMigrations(tx, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		if err := applyMigration(tx, dialect, migration); err != nil {
+			return nil, fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+
+		if _, err := tx.Exec(
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Name, time.Now().UTC(),
+		); err != nil {
+			return nil, fmt.Errorf("failed to record migration %04d: %w", migration.Version, err)
+		}
+		done = append(done, migration)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit migrations: %w", err)
+	}
+
+	return done, nil
+}
+
+// lockMigrations serializes migration runs across processes for the
+// lifetime of the transaction
+func lockMigrations(tx Tx, dialect Dialect) error {
+	switch dialect {
+	case DialectPostgres:
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+	default:
+		// The first write takes SQLite's database write lock; other
+		// processes wait on the busy timeout until we commit
+		if _, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+				id INTEGER PRIMARY KEY,
+				locked_at TIMESTAMP
+			)
+		`); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)
+			ON CONFLICT(id) DO UPDATE SET locked_at = excluded.locked_at
+		`, time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs each statement of a migration. Adding a column that
+// already exists is skipped so databases upgraded in place by older
+// releases converge on the same schema.
+func applyMigration(q Querier, dialect Dialect, migration Migration) error {
+	for _, stmt := range splitStatements(migration.SQL) {
+		if match := addColumnPattern.FindStringSubmatch(stmt); match != nil {
+			columns, err := Columns(q, dialect, match[1])
+			if err != nil {
+				return err
+			}
+			if columns[match[2]] {
+				continue
+			}
+		}
+
+		if _, err := q.Exec(dialect.DDL(stmt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appliedMigrations returns applied versions and when they were applied
+func appliedMigrations(q Querier, dialect Dialect) (map[int]time.Time, error) {
+	columns, err := Columns(q, dialect, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]time.Time)
+	if len(columns) == 0 {
+		return applied, nil
+	}
+
+	rows, err := q.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var at sql.NullTime
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[version] = at.Time
+	}
+
+	return applied, rows.Err()
+}
+
+// splitStatements splits a migration into statements on semicolons and
+// drops comment-only lines. Migrations must not use semicolons in literals.
+func splitStatements(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	var statements []string
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			statements = append(statements, stmt)
+		}
+	}
+	return statements
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package storage
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_email.sql": {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+		"migrations/0001_users.sql":     {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT);")},
+		"migrations/README.md":          {Data: []byte("ignored")},
+	}
+
+	migrations, err := LoadMigrations(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("Expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "users" || migrations[1].Version != 2 {
+		t.Errorf("Migrations not ordered by version: %+v", migrations)
+	}
+
+	fsys["migrations/0002_duplicate.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	if _, err := LoadMigrations(fsys, "migrations"); err == nil {
+		t.Error("Expected error for duplicate version")
+	}
+}
+
+func TestMigratorUp(t *testing.T) {
+	store, err := NewMemory()
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	migrations := []Migration{
+		{Version: 1, Name: "users", SQL: `
+			-- users table
+			CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT);
+			CREATE INDEX IF NOT EXISTS idx_users_name ON users(name);
+		`},
+		{Version: 2, Name: "add_email", SQL: "ALTER TABLE users ADD COLUMN email TEXT;"},
+	}
+
+	// A database upgraded in place before migrations existed
+	if err := ExecSchema(store, "CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, email TEXT)"); err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+
+	migrator := NewMigrator(store, migrations)
+
+	pending, err := migrator.Pending()
+	if err != nil {
+		t.Fatalf("Failed to list pending: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending migrations, got %d", len(pending))
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("Expected 2 applied migrations, got %d", len(applied))
+	}
+
+	// Running again is a no-op
+	applied, err = migrator.Up()
+	if err != nil {
+		t.Fatalf("Failed to re-run migrations: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Expected no migrations on second run, got %d", len(applied))
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	for _, s := range status {
+		if !s.Applied || s.AppliedAt == nil {
+			t.Errorf("Migration %d not recorded as applied", s.Version)
+		}
+	}
+
+	// A new migration shows up as pending and is applied on its own
+	migrations = append(migrations, Migration{Version: 3, Name: "add_team", SQL: "ALTER TABLE users ADD COLUMN team_id INTEGER;"})
+	migrator = NewMigrator(store, migrations)
+	applied, err = migrator.Up()
+	if err != nil {
+		t.Fatalf("Failed to apply new migration: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 3 {
+		t.Errorf("Expected only migration 3, got %+v", applied)
+	}
+}
+
+func TestMigratorRollsBackFailedMigration(t *testing.T) {
+	store, err := NewMemory()
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	migrator := NewMigrator(store, []Migration{
+		{Version: 1, Name: "ok", SQL: "CREATE TABLE a (id INTEGER);"},
+		{Version: 2, Name: "broken", SQL: "CREATE TABLE b (id INTEGER); NOT VALID SQL;"},
+	})
+
+	if _, err := migrator.Up(); err == nil {
+		t.Fatal("Expected migration failure")
+	}
+
+	columns, err := Columns(store, store.Dialect(), "a")
+	if err != nil {
+		t.Fatalf("Failed to list columns: %v", err)
+	}
+	if len(columns) != 0 {
+		t.Error("Expected failed run to roll back earlier migrations")
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package storage provides the SQL database shared by API keys, sessions,
+// TOTP secrets and the audit log. Queries are written once with ?
+// placeholders and SQLite-flavoured DDL; each backend adapts them to its
+// dialect, so replicas can share one PostgreSQL database.
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Backend names accepted by Open
+const (
+	BackendMemory   = "memory"
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+)
+
+// Config selects and tunes a storage backend
+type Config struct {
+	// Backend is memory, sqlite or postgres (default: sqlite)
+	Backend string
+
+	// DSN is the database file for sqlite or the connection string for
+	// postgres (e.g. postgres://proxy@db:5432/proxy?sslmode=verify-full)
+	DSN string
+
+	// Connection pool settings (postgres only; 0 keeps the driver default)
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// Querier runs SQL written with ? placeholders
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Tx is a transaction on a Store
+type Tx interface {
+	Querier
+	Commit() error
+	Rollback() error
+}
+
+// Store is a database backend for keys, sessions, TOTP and audit records
+type Store interface {
+	Querier
+
+	// Begin starts a transaction
+	Begin() (Tx, error)
+
+	// Dialect returns the SQL dialect of the backend
+	Dialect() Dialect
+
+	// Ping checks the database is reachable
+	Ping() error
+
+	// Close releases the database
+	Close() error
+}
+
+// Open opens the backend selected by cfg
+func Open(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case BackendMemory:
+		return NewMemory()
+
+	case BackendSQLite, "":
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("sqlite storage requires a database path")
+		}
+		return openSQL("sqlite3", cfg.DSN, DialectSQLite, cfg)
+
+	case BackendPostgres:
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("postgres storage requires a connection string")
+		}
+		return openSQL("postgres", cfg.DSN, DialectPostgres, cfg)
+
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+}
+
+// memoryDBs numbers in-memory databases so each NewMemory is isolated
+var memoryDBs atomic.Int64
+
+// NewMemory opens a private in-memory database, intended for tests and
+// single-process development. Its contents are lost on Close.
+func NewMemory() (Store, error) {
+	name := fmt.Sprintf("file:memdb%d?mode=memory&cache=private", memoryDBs.Add(1))
+
+	db, err := sql.Open("sqlite3", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// The database lives only as long as its single connection
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+
+	return &sqlStore{db: db, dialect: DialectSQLite}, nil
+}
+
+// openSQL opens a database/sql backed store
+func openSQL(driver, dsn string, dialect Dialect, cfg Config) (Store, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	if dialect == DialectPostgres {
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+	}
+
+	return &sqlStore{db: db, dialect: dialect}, nil
+}
+
+// sqlStore adapts a *sql.DB to the Store interface
+type sqlStore struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func (s *sqlStore) Exec(query string, args ...any) (sql.Result, error) {
+	return s.db.Exec(s.dialect.Rebind(query), args...)
+}
+
+func (s *sqlStore) Query(query string, args ...any) (*sql.Rows, error) {
+	return s.db.Query(s.dialect.Rebind(query), args...)
+}
+
+func (s *sqlStore) QueryRow(query string, args ...any) *sql.Row {
+	return s.db.QueryRow(s.dialect.Rebind(query), args...)
+}
+
+func (s *sqlStore) Begin() (Tx, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{tx: tx, dialect: s.dialect}, nil
+}
+
+func (s *sqlStore) Dialect() Dialect {
+	return s.dialect
+}
+
+func (s *sqlStore) Ping() error {
+	return s.db.Ping()
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// sqlTx adapts a *sql.Tx to the Tx interface
+type sqlTx struct {
+	tx      *sql.Tx
+	dialect Dialect
+}
+
+func (t *sqlTx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.tx.Exec(t.dialect.Rebind(query), args...)
+}
+
+func (t *sqlTx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.tx.Query(t.dialect.Rebind(query), args...)
+}
+
+func (t *sqlTx) QueryRow(query string, args ...any) *sql.Row {
+	return t.tx.QueryRow(t.dialect.Rebind(query), args...)
+}
+
+func (t *sqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,121 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	query := "SELECT id FROM t WHERE a = ? AND b = '?' AND c IN (?, ?)"
+
+	if got := DialectSQLite.Rebind(query); got != query {
+		t.Errorf("SQLite query should be unchanged, got %q", got)
+	}
+
+	want := "SELECT id FROM t WHERE a = $1 AND b = '?' AND c IN ($2, $3)"
+	if got := DialectPostgres.Rebind(query); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestDDL(t *testing.T) {
+	ddl := `CREATE TABLE t (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		n INTEGER,
+		amount REAL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		timestamp TIMESTAMP
+	)`
+
+	got := DialectPostgres.DDL(ddl)
+	for _, want := range []string{
+		"id BIGSERIAL PRIMARY KEY",
+		"n BIGINT",
+		"amount DOUBLE PRECISION",
+		"created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP",
+		"timestamp TIMESTAMPTZ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in translated DDL:\n%s", want, got)
+		}
+	}
+
+	if DialectSQLite.DDL(ddl) != ddl {
+		t.Error("SQLite DDL should be unchanged")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, cfg := range []Config{
+		{Backend: BackendMemory},
+		{Backend: BackendSQLite, DSN: filepath.Join(t.TempDir(), "store.db")},
+	} {
+		t.Run(cfg.Backend, func(t *testing.T) {
+			store, err := Open(cfg)
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			defer store.Close()
+
+			if err := ExecSchema(store, "CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)"); err != nil {
+				t.Fatalf("Failed to create table: %v", err)
+			}
+
+			tx, err := store.Begin()
+			if err != nil {
+				t.Fatalf("Failed to begin: %v", err)
+			}
+			var id int64
+			if err := tx.QueryRow("INSERT INTO items (name) VALUES (?) RETURNING id", "a").Scan(&id); err != nil {
+				t.Fatalf("Failed to insert: %v", err)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("Failed to commit: %v", err)
+			}
+
+			columns, err := Columns(store, store.Dialect(), "items")
+			if err != nil {
+				t.Fatalf("Failed to list columns: %v", err)
+			}
+			if !columns["id"] || !columns["name"] {
+				t.Errorf("Unexpected columns: %v", columns)
+			}
+		})
+	}
+
+	if _, err := Open(Config{Backend: "mysql"}); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+	if _, err := Open(Config{Backend: BackendPostgres}); err == nil {
+		t.Error("Expected error for postgres without DSN")
+	}
+}
+
+func TestMemoryStoresAreIsolated(t *testing.T) {
+	a, err := NewMemory()
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer a.Close()
+	b, err := NewMemory()
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer b.Close()
+
+	if err := ExecSchema(a, "CREATE TABLE only_in_a (id INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	columns, err := Columns(b, b.Dialect(), "only_in_a")
+	if err != nil {
+		t.Fatalf("Failed to list columns: %v", err)
+	}
+	if len(columns) != 0 {
+		t.Error("Table leaked between in-memory stores")
+	}
+}
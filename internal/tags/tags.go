@@ -0,0 +1,158 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package tags parses and validates the cost-center tags attached to
+// requests for chargeback
+package tags
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Header carries a request's tags as comma-separated key=value pairs,
+// e.g. "project=foo,env=dev"
+const Header = "X-Proxy-Tags"
+
+// MaxTags is the most tags a request may carry; Bedrock request metadata
+// and OpenAI metadata both accept at most 16 pairs
+const MaxTags = 16
+
+var (
+	keyPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+	valuePattern = regexp.MustCompile(`^[A-Za-z0-9_.:/@+-]{1,128}$`)
+)
+
+// Parse reads tags from an X-Proxy-Tags header value
+func Parse(header string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("tag %q is not key=value", pair)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if _, dup := tags[key]; dup {
+			return nil, fmt.Errorf("duplicate tag %q", key)
+		}
+		tags[key] = value
+	}
+	return tags, Validate(tags)
+}
+
+// Validate checks tag syntax: lowercase keys of up to 64 characters and
+// values of up to 128 letters, digits and _.:/@+-
+func Validate(tags map[string]string) error {
+	if len(tags) > MaxTags {
+		return fmt.Errorf("too many tags (%d, at most %d)", len(tags), MaxTags)
+	}
+	for _, key := range sortedKeys(tags) {
+		if !keyPattern.MatchString(key) {
+			return fmt.Errorf("invalid tag key %q", key)
+		}
+		if !valuePattern.MatchString(tags[key]) {
+			return fmt.Errorf("invalid value for tag %q", key)
+		}
+	}
+	return nil
+}
+
+// Allowlist maps the permitted tag keys to their permitted values; a key
+// with no values accepts any value
+type Allowlist map[string][]string
+
+// ParseAllowlist reads an allowlist such as "project,env=dev|staging|prod"
+func ParseAllowlist(spec string) (Allowlist, error) {
+	allow := make(Allowlist)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, values, _ := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !keyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid tag key %q", key)
+		}
+		allow[key] = nil
+		for _, value := range strings.Split(values, "|") {
+			if value = strings.TrimSpace(value); value != "" {
+				allow[key] = append(allow[key], value)
+			}
+		}
+	}
+	if len(allow) == 0 {
+		return nil, fmt.Errorf("no tag keys allowed")
+	}
+	return allow, nil
+}
+
+// Check reports the first tag the allowlist does not permit
+func (a Allowlist) Check(tags map[string]string) error {
+	for _, key := range sortedKeys(tags) {
+		values, ok := a[key]
+		if !ok {
+			return fmt.Errorf("tag %q is not allowed", key)
+		}
+		if values != nil && !contains(values, tags[key]) {
+			return fmt.Errorf("value %q is not allowed for tag %q", tags[key], key)
+		}
+	}
+	return nil
+}
+
+// Filter returns the tags the allowlist permits
+func (a Allowlist) Filter(tags map[string]string) map[string]string {
+	allowed := make(map[string]string, len(tags))
+	for key, value := range tags {
+		if a.Check(map[string]string{key: value}) == nil {
+			allowed[key] = value
+		}
+	}
+	return allowed
+}
+
+// Merge returns defaults overridden by tags
+func Merge(defaults, tags map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(tags))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range tags {
+		merged[key] = value
+	}
+	return merged
+}
+
+// String formats tags in header form with keys sorted
+func String(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for _, key := range sortedKeys(tags) {
+		pairs = append(pairs, key+"="+tags[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
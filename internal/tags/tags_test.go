@@ -0,0 +1,81 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tags
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse(" project=search , env=prod,")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if want := map[string]string{"project": "search", "env": "prod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got, err := Parse(""); err != nil || len(got) != 0 {
+		t.Errorf("Expected no tags from an empty header, got %v, %v", got, err)
+	}
+
+	for _, bad := range []string{
+		"project",
+		"project=a,project=b",
+		"Project=a",
+		"project=",
+		"project=a b",
+		"project=" + strings.Repeat("x", 129),
+		manyTags(MaxTags + 1),
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func manyTags(n int) string {
+	pairs := make([]string, n)
+	for i := range pairs {
+		pairs[i] = "k" + strings.Repeat("x", i) + "=v"
+	}
+	return strings.Join(pairs, ",")
+}
+
+func TestAllowlist(t *testing.T) {
+	allow, err := ParseAllowlist("project, env=dev|prod")
+	if err != nil {
+		t.Fatalf("ParseAllowlist failed: %v", err)
+	}
+
+	if err := allow.Check(map[string]string{"project": "anything", "env": "prod"}); err != nil {
+		t.Errorf("Expected tags to be allowed: %v", err)
+	}
+	if err := allow.Check(map[string]string{"env": "staging"}); err == nil {
+		t.Error("Expected a value outside the allowlist to be rejected")
+	}
+	if err := allow.Check(map[string]string{"team": "x"}); err == nil {
+		t.Error("Expected a key outside the allowlist to be rejected")
+	}
+
+	got := allow.Filter(map[string]string{"project": "a", "env": "staging", "team": "x"})
+	if want := map[string]string{"project": "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if _, err := ParseAllowlist(" , "); err == nil {
+		t.Error("Expected an empty allowlist to be rejected")
+	}
+	if _, err := ParseAllowlist("Bad Key"); err == nil {
+		t.Error("Expected an invalid key to be rejected")
+	}
+}
+
+func TestMergeAndString(t *testing.T) {
+	merged := Merge(map[string]string{"project": "default", "env": "dev"}, map[string]string{"project": "search"})
+	if got := String(merged); got != "env=dev,project=search" {
+		t.Errorf("Unexpected tags %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Proxy-specific span attributes
+const (
+	// ProviderKey records the proxy's own name for a provider, which
+	// gen_ai.provider.name does not always preserve
+	ProviderKey = attribute.Key("proxy.provider")
+
+	// RequestIDKey is the X-Request-ID assigned to the inbound request
+	RequestIDKey = attribute.Key("proxy.request_id")
+)
+
+// genAIProviders maps proxy provider types to gen_ai.provider.name values
+var genAIProviders = map[string]attribute.KeyValue{
+	"bedrock":   semconv.GenAIProviderNameAWSBedrock,
+	"openai":    semconv.GenAIProviderNameOpenAI,
+	"azure":     semconv.GenAIProviderNameAzureAIOpenAI,
+	"anthropic": semconv.GenAIProviderNameAnthropic,
+	"vertex":    semconv.GenAIProviderNameGCPVertexAI,
+	"ibm":       semconv.GenAIProviderNameIBMWatsonxAI,
+}
+
+// ProviderAttributes identifies a provider by its GenAI name, taken from
+// its type, and its proxy instance name
+func ProviderAttributes(provider, providerType string) []attribute.KeyValue {
+	genAI, ok := genAIProviders[providerType]
+	if !ok {
+		genAI = semconv.GenAIProviderNameKey.String(providerType)
+	}
+	return []attribute.KeyValue{genAI, ProviderKey.String(provider)}
+}
+
+// ChatRequest holds the request parameters recorded on a chat span
+type ChatRequest struct {
+	Model       string
+	MaxTokens   int
+	Temperature float64
+	TopP        float64
+}
+
+// StartChat starts the span for a chat completion, named "chat {model}"
+func StartChat(ctx context.Context, req ChatRequest) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		semconv.GenAIOperationNameChat,
+		semconv.GenAIRequestModel(req.Model),
+	}
+	if req.MaxTokens > 0 {
+		attrs = append(attrs, semconv.GenAIRequestMaxTokens(req.MaxTokens))
+	}
+	if req.Temperature > 0 {
+		attrs = append(attrs, semconv.GenAIRequestTemperature(req.Temperature))
+	}
+	if req.TopP > 0 {
+		attrs = append(attrs, semconv.GenAIRequestTopP(req.TopP))
+	}
+	return Tracer().Start(ctx, "chat "+req.Model, trace.WithAttributes(attrs...))
+}
+
+// ChatResponse holds the response details recorded on a chat span
+type ChatResponse struct {
+	ID            string
+	Model         string
+	InputTokens   int
+	OutputTokens  int
+	FinishReasons []string
+}
+
+// SetChatResponse records a chat completion's outcome on span
+func SetChatResponse(span trace.Span, resp ChatResponse) {
+	attrs := []attribute.KeyValue{
+		semconv.GenAIUsageInputTokens(resp.InputTokens),
+		semconv.GenAIUsageOutputTokens(resp.OutputTokens),
+	}
+	if resp.ID != "" {
+		attrs = append(attrs, semconv.GenAIResponseID(resp.ID))
+	}
+	if resp.Model != "" {
+		attrs = append(attrs, semconv.GenAIResponseModel(resp.Model))
+	}
+	if len(resp.FinishReasons) > 0 {
+		attrs = append(attrs, semconv.GenAIResponseFinishReasons(resp.FinishReasons...))
+	}
+	span.SetAttributes(attrs...)
+}
@@ -0,0 +1,91 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package tracing sets up OpenTelemetry tracing and records spans using the
+// GenAI semantic conventions
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the proxy's tracer
+const instrumentationName = "github.com/bedrock-proxy/bedrock-iam-proxy"
+
+// Config configures the tracer provider
+type Config struct {
+	ServiceName string
+	Exporter    sdktrace.SpanExporter
+
+	// Synchronous exports each span as it ends instead of in batches. It
+	// is meant for tests using an in-memory exporter.
+	Synchronous bool
+}
+
+// Setup installs a global tracer provider exporting to cfg.Exporter and the
+// W3C trace context propagator. Sampling follows the standard
+// OTEL_TRACES_SAMPLER variables. The returned function flushes pending
+// spans and stops the provider.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if cfg.Synchronous {
+		opts = append(opts, sdktrace.WithSyncer(cfg.Exporter))
+	} else {
+		opts = append(opts, sdktrace.WithBatcher(cfg.Exporter))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	return provider.Shutdown, nil
+}
+
+// NewOTLPExporter returns an OTLP/HTTP exporter configured by the standard
+// OTEL_EXPORTER_OTLP_* environment variables
+func NewOTLPExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	return exporter, nil
+}
+
+// Tracer returns the proxy's tracer. Until Setup is called it records nothing.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// RecordError marks span as failed with err, classified as errorType
+func RecordError(span trace.Span, err error, errorType string) {
+	if err == nil {
+		return
+	}
+	if errorType == "" {
+		errorType = fmt.Sprintf("%T", err)
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(semconv.ErrorTypeKey.String(errorType))
+}
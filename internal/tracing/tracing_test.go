@@ -0,0 +1,193 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package tracing_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/providers"
+	"github.com/bedrock-proxy/bedrock-iam-proxy/internal/tracing"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "test",
+		Exporter:    exporter,
+		Synchronous: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to set up tracing: %v", err)
+	}
+	t.Cleanup(func() { shutdown(context.Background()) })
+	return exporter
+}
+
+func findSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("Span %q not recorded", name)
+	return tracetest.SpanStub{}
+}
+
+func attr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	exporter := newExporter(t)
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(tracing.Middleware())
+	r.POST("/v1/chat/completions", func(c *gin.Context) {
+		_, span := tracing.StartChat(c.Request.Context(), tracing.ChatRequest{Model: "gpt-4", MaxTokens: 100})
+		tracing.SetChatResponse(span, tracing.ChatResponse{
+			ID:            "chatcmpl-1",
+			InputTokens:   12,
+			OutputTokens:  34,
+			FinishReasons: []string{"stop"},
+		})
+		span.End()
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	server := findSpan(t, exporter, "POST /v1/chat/completions")
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected inbound trace ID, got %s", got)
+	}
+	if got := server.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("Expected inbound parent span, got %s", got)
+	}
+	if server.SpanKind != trace.SpanKindServer {
+		t.Errorf("Expected server span, got %v", server.SpanKind)
+	}
+	if got := attr(server, "http.response.status_code").AsInt64(); got != 200 {
+		t.Errorf("Expected status 200, got %d", got)
+	}
+
+	chat := findSpan(t, exporter, "chat gpt-4")
+	if chat.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("Expected chat span to be a child of the server span")
+	}
+	if got := attr(chat, "gen_ai.operation.name").AsString(); got != "chat" {
+		t.Errorf("Unexpected operation %q", got)
+	}
+	if got := attr(chat, "gen_ai.usage.output_tokens").AsInt64(); got != 34 {
+		t.Errorf("Unexpected output tokens %d", got)
+	}
+	if got := attr(chat, "gen_ai.response.finish_reasons").AsStringSlice(); len(got) != 1 || got[0] != "stop" {
+		t.Errorf("Unexpected finish reasons %v", got)
+	}
+}
+
+func TestProviderAttributes(t *testing.T) {
+	tests := map[string]string{
+		"bedrock": "aws.bedrock",
+		"azure":   "azure.ai.openai",
+		"vertex":  "gcp.vertex_ai",
+		"oracle":  "oracle",
+	}
+	for provider, want := range tests {
+		attrs := tracing.ProviderAttributes(provider, provider)
+		if got := attrs[0].Value.AsString(); got != want {
+			t.Errorf("%s: got %q, want %q", provider, got, want)
+		}
+	}
+
+	// Named instances keep the GenAI name of their type
+	attrs := tracing.ProviderAttributes("bedrock-eu-west-1", "bedrock")
+	if attrs[0].Value.AsString() != "aws.bedrock" || attrs[1].Value.AsString() != "bedrock-eu-west-1" {
+		t.Errorf("Unexpected attributes for a named instance: %v", attrs)
+	}
+}
+
+// fakeProvider returns canned responses
+type fakeProvider struct {
+	err    error
+	stream string
+}
+
+func (f *fakeProvider) Name() string                          { return "anthropic" }
+func (f *fakeProvider) HealthCheck(ctx context.Context) error { return nil }
+func (f *fakeProvider) Invoke(ctx context.Context, request *providers.ProviderRequest) (*providers.ProviderResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &providers.ProviderResponse{StatusCode: http.StatusOK}, nil
+}
+func (f *fakeProvider) InvokeStreaming(ctx context.Context, request *providers.ProviderRequest) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(f.stream)), nil
+}
+func (f *fakeProvider) ListModels(ctx context.Context) ([]providers.Model, error) { return nil, nil }
+func (f *fakeProvider) GetModelInfo(ctx context.Context, modelID string) (*providers.Model, error) {
+	return nil, nil
+}
+
+func TestProviderSpans(t *testing.T) {
+	exporter := newExporter(t)
+	request := &providers.ProviderRequest{Method: http.MethodPost, Path: "/messages"}
+
+	p := providers.Instrument(&fakeProvider{err: &providers.ProviderError{
+		Provider:   "anthropic",
+		StatusCode: http.StatusTooManyRequests,
+		Code:       providers.ErrCodeRateLimitExceeded,
+		Message:    "slow down",
+	}})
+	if _, err := p.Invoke(context.Background(), request); err == nil {
+		t.Fatal("Expected provider error")
+	}
+	span := findSpan(t, exporter, "anthropic.Invoke")
+	if span.SpanKind != trace.SpanKindClient || span.Status.Code != codes.Error {
+		t.Errorf("Expected failed client span, got kind %v status %v", span.SpanKind, span.Status)
+	}
+	if got := attr(span, "error.type").AsString(); got != providers.ErrCodeRateLimitExceeded {
+		t.Errorf("Unexpected error type %q", got)
+	}
+	if got := attr(span, "http.response.status_code").AsInt64(); got != http.StatusTooManyRequests {
+		t.Errorf("Unexpected status %d", got)
+	}
+
+	exporter.Reset()
+	p = providers.Instrument(&fakeProvider{stream: "data: [DONE]\n\n"})
+	body, err := p.InvokeStreaming(context.Background(), request)
+	if err != nil {
+		t.Fatalf("InvokeStreaming failed: %v", err)
+	}
+	io.ReadAll(body)
+	if len(exporter.GetSpans()) != 0 {
+		t.Error("Expected streaming span to stay open until the body is closed")
+	}
+	body.Close()
+	body.Close()
+	span = findSpan(t, exporter, "anthropic.InvokeStreaming")
+	if len(exporter.GetSpans()) != 1 || len(span.Events) != 1 || span.Events[0].Name != "first_byte" {
+		t.Errorf("Expected one span with a first_byte event, got %+v", exporter.GetSpans())
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package transcript
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Default limits applied when the configuration leaves them unset
+const (
+	DefaultMaxFileSizeMB = 100
+	DefaultMaxFiles      = 10
+	DefaultBufferSize    = 1000
+)
+
+// Config configures transcript logging
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Dir holds the active transcripts.jsonl and its rotated files
+	Dir           string          `yaml:"dir"`
+	MaxFileSizeMB int             `yaml:"max_file_size_mb"`
+	MaxFiles      int             `yaml:"max_files"` // rotated files kept, 0 for the default
+	BufferSize    int             `yaml:"buffer_size"`
+	Sampling      SamplingConfig  `yaml:"sampling"`
+	Redact        []RedactionRule `yaml:"redact"`
+}
+
+// SamplingConfig sets the fraction of requests recorded. A key rate takes
+// precedence over a model rate, which takes precedence over the default.
+type SamplingConfig struct {
+	Default *float64           `yaml:"default"` // unset records every request
+	Keys    map[int64]float64  `yaml:"keys"`
+	Models  map[string]float64 `yaml:"models"`
+}
+
+// Redaction actions
+const (
+	ActionRemove = "remove"
+	ActionHash   = "hash"
+	ActionMask   = "mask"
+)
+
+// RedactionRule rewrites record fields before they are written.
+//
+// Field is a dot-separated path into the JSON record where "*" matches any
+// key or array element, e.g. "request.messages.*.content". With a Pattern,
+// only matching substrings of string values are rewritten, and an empty
+// Field applies the pattern to every string in the record.
+type RedactionRule struct {
+	Field       string `yaml:"field"`
+	Action      string `yaml:"action"` // remove, hash or mask
+	Pattern     string `yaml:"pattern"`
+	Replacement string `yaml:"replacement"` // mask only, defaults to [REDACTED]
+
+	path    []string
+	pattern *regexp.Regexp
+}
+
+// LoadConfig loads transcript settings from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse transcript config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks the configuration, compiles redaction rules and fills in
+// defaults
+func (c *Config) Validate() error {
+	if c.Enabled && c.Dir == "" {
+		return fmt.Errorf("transcript dir is required")
+	}
+	if c.MaxFileSizeMB < 0 || c.MaxFiles < 0 || c.BufferSize < 0 {
+		return fmt.Errorf("transcript limits must not be negative")
+	}
+	if c.MaxFileSizeMB == 0 {
+		c.MaxFileSizeMB = DefaultMaxFileSizeMB
+	}
+	if c.MaxFiles == 0 {
+		c.MaxFiles = DefaultMaxFiles
+	}
+	if c.BufferSize == 0 {
+		c.BufferSize = DefaultBufferSize
+	}
+
+	rates := []float64{}
+	if c.Sampling.Default != nil {
+		rates = append(rates, *c.Sampling.Default)
+	}
+	for _, rate := range c.Sampling.Keys {
+		rates = append(rates, rate)
+	}
+	for _, rate := range c.Sampling.Models {
+		rates = append(rates, rate)
+	}
+	for _, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("sampling rate %v must be between 0 and 1", rate)
+		}
+	}
+
+	for i := range c.Redact {
+		if err := c.Redact[i].compile(); err != nil {
+			return fmt.Errorf("redaction rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *RedactionRule) compile() error {
+	switch r.Action {
+	case ActionRemove, ActionHash, ActionMask:
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	if r.Field == "" && r.Pattern == "" {
+		return fmt.Errorf("field or pattern is required")
+	}
+	if r.Pattern != "" {
+		if r.Action == ActionRemove {
+			return fmt.Errorf("action remove does not take a pattern")
+		}
+		compiled, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		r.pattern = compiled
+	}
+	if r.Replacement == "" {
+		r.Replacement = "[REDACTED]"
+	}
+	r.path = splitPath(r.Field)
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package transcript
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// splitPath splits a dot-separated field path; an empty path matches the
+// whole record
+func splitPath(field string) []string {
+	if field == "" {
+		return nil
+	}
+	return strings.Split(field, ".")
+}
+
+// redact applies the rules in order to a decoded JSON record
+func redact(record map[string]any, rules []RedactionRule) {
+	for i := range rules {
+		rule := &rules[i]
+		if len(rule.path) == 0 {
+			for key, value := range record {
+				record[key] = rule.rewrite(value)
+			}
+			continue
+		}
+		applyAt(record, rule.path, rule)
+	}
+}
+
+// applyAt walks path below node and applies rule to the values it reaches
+func applyAt(node any, path []string, rule *RedactionRule) {
+	last := len(path) == 1
+
+	switch n := node.(type) {
+	case map[string]any:
+		for key, value := range n {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			if !last {
+				applyAt(value, path[1:], rule)
+			} else if rule.Action == ActionRemove {
+				delete(n, key)
+			} else {
+				n[key] = rule.rewrite(value)
+			}
+		}
+	case []any:
+		if path[0] != "*" {
+			return
+		}
+		for i, value := range n {
+			if !last {
+				applyAt(value, path[1:], rule)
+			} else if rule.Action == ActionRemove {
+				n[i] = nil
+			} else {
+				n[i] = rule.rewrite(value)
+			}
+		}
+	}
+}
+
+// rewrite returns the redacted form of value. Pattern rules rewrite
+// matches within every string below value; others replace it outright.
+func (r *RedactionRule) rewrite(value any) any {
+	if r.pattern == nil {
+		if value == nil {
+			return nil
+		}
+		if r.Action == ActionHash {
+			return hashValue(value)
+		}
+		return r.Replacement
+	}
+
+	switch v := value.(type) {
+	case string:
+		return r.pattern.ReplaceAllStringFunc(v, func(match string) string {
+			if r.Action == ActionHash {
+				return hashValue(match)
+			}
+			return r.Replacement
+		})
+	case map[string]any:
+		for key, child := range v {
+			v[key] = r.rewrite(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = r.rewrite(child)
+		}
+	}
+	return value
+}
+
+// hashValue returns a stable digest so equal values can still be correlated
+func hashValue(value any) string {
+	s, ok := value.(string)
+	if !ok {
+		data, _ := json.Marshal(value)
+		s = string(data)
+	}
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
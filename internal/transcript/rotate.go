@@ -0,0 +1,100 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package transcript
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ActiveFile is the name of the transcript file currently written to;
+// rotated files are renamed to transcripts-<UTC timestamp>.jsonl
+const ActiveFile = "transcripts.jsonl"
+
+const rotatedLayout = "20060102T150405.000000000Z"
+
+// rotatingWriter appends lines to the active file and rotates it once it
+// would exceed maxSize, keeping at most maxFiles rotated files
+type rotatingWriter struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	size int64
+}
+
+func newRotatingWriter(dir string, maxSize int64, maxFiles int) (*rotatingWriter, error) {
+	w := &rotatingWriter{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) open() error {
+	file, err := os.OpenFile(filepath.Join(w.dir, ActiveFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open transcript file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat transcript file: %w", err)
+	}
+	w.file, w.size = file, info.Size()
+	return nil
+}
+
+// Write appends one whole line, rotating first if it would not fit
+func (w *rotatingWriter) Write(line []byte) (int, error) {
+	if w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close transcript file: %w", err)
+	}
+
+	rotated := filepath.Join(w.dir, "transcripts-"+time.Now().UTC().Format(rotatedLayout)+".jsonl")
+	if err := os.Rename(filepath.Join(w.dir, ActiveFile), rotated); err != nil {
+		return fmt.Errorf("failed to rotate transcript file: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	return w.prune()
+}
+
+// prune removes the oldest rotated files beyond maxFiles
+func (w *rotatingWriter) prune() error {
+	files, err := filepath.Glob(filepath.Join(w.dir, "transcripts-*.jsonl"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for len(files) > w.maxFiles {
+		if err := os.Remove(files[0]); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove old transcript file: %w", err)
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+func (w *rotatingWriter) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,188 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package transcript records sampled request/response transcripts to
+// rotating JSONL files without blocking the request path
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bedrock-proxy/bedrock-iam-proxy/pkg/metrics"
+)
+
+// Record is one transcript line
+type Record struct {
+	Timestamp          time.Time `json:"timestamp"`
+	RequestID          string    `json:"request_id"`
+	APIKeyID           int64     `json:"api_key_id,omitempty"`
+	Model              string    `json:"model"`
+	Provider           string    `json:"provider"`
+	ProviderModel      string    `json:"provider_model,omitempty"`
+	Stream             bool      `json:"stream"`
+	StatusCode         int       `json:"status_code"`
+	LatencyMs          int64     `json:"latency_ms"`
+	TimeToFirstTokenMs int64     `json:"time_to_first_token_ms,omitempty"`
+	PromptTokens       int       `json:"prompt_tokens"`
+	CompletionTokens   int       `json:"completion_tokens"`
+	CostUSD            float64   `json:"cost_usd"`
+	Request            any       `json:"request"`
+	Response           any       `json:"response,omitempty"`
+	// Completion is the generated text, reassembled for streamed responses
+	Completion string `json:"completion,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Logger writes sampled records in the background. A nil Logger is
+// valid and records nothing.
+type Logger struct {
+	cfg     Config
+	records chan *Record
+	done    chan struct{}
+	out     *rotatingWriter
+	dropped atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New starts a logger writing to cfg.Dir
+func New(cfg *Config) (*Logger, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create transcript directory: %w", err)
+	}
+	out, err := newRotatingWriter(cfg.Dir, int64(cfg.MaxFileSizeMB)<<20, cfg.MaxFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Logger{
+		cfg:     *cfg,
+		records: make(chan *Record, cfg.BufferSize),
+		done:    make(chan struct{}),
+		out:     out,
+	}
+	go l.run()
+	return l, nil
+}
+
+// Sample reports whether a request by keyID for model should be recorded
+func (l *Logger) Sample(keyID int64, model string) bool {
+	if l == nil {
+		return false
+	}
+
+	rate := 1.0
+	if l.cfg.Sampling.Default != nil {
+		rate = *l.cfg.Sampling.Default
+	}
+	if r, ok := l.cfg.Sampling.Models[model]; ok {
+		rate = r
+	}
+	if r, ok := l.cfg.Sampling.Keys[keyID]; ok {
+		rate = r
+	}
+
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	default:
+		return rand.Float64() < rate
+	}
+}
+
+// Log queues a record. It never blocks: when the buffer is full the record
+// is dropped and counted. The record must not be modified afterwards.
+func (l *Logger) Log(record *Record) {
+	if l == nil {
+		return
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return
+	}
+
+	select {
+	case l.records <- record:
+	default:
+		l.dropped.Add(1)
+		metrics.TranscriptRecords.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Dropped returns the number of records discarded because the buffer was full
+func (l *Logger) Dropped() int64 {
+	return l.dropped.Load()
+}
+
+// Close writes the queued records and closes the current file
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.records)
+	}
+	l.mu.Unlock()
+
+	<-l.done
+	return l.out.Close()
+}
+
+func (l *Logger) run() {
+	defer close(l.done)
+
+	for record := range l.records {
+		line, err := l.encode(record)
+		if err == nil {
+			_, err = l.out.Write(line)
+		}
+		if err != nil {
+			log.Printf("Failed to write transcript for %s: %v", record.RequestID, err)
+			metrics.TranscriptRecords.WithLabelValues("failed").Inc()
+			continue
+		}
+		metrics.TranscriptRecords.WithLabelValues("written").Inc()
+	}
+}
+
+// encode returns the redacted JSON line for a record
+func (l *Logger) encode(record *Record) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transcript: %w", err)
+	}
+
+	if len(l.cfg.Redact) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var fields map[string]any
+		if err := decoder.Decode(&fields); err != nil {
+			return nil, fmt.Errorf("failed to decode transcript: %w", err)
+		}
+		redact(fields, l.cfg.Redact)
+		if data, err = json.Marshal(fields); err != nil {
+			return nil, fmt.Errorf("failed to encode transcript: %w", err)
+		}
+	}
+
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rate(r float64) *float64 { return &r }
+
+func TestSample(t *testing.T) {
+	l := &Logger{cfg: Config{Sampling: SamplingConfig{
+		Default: rate(0),
+		Keys:    map[int64]float64{7: 1, 8: 0},
+		Models:  map[string]float64{"gpt-4o": 1},
+	}}}
+
+	tests := []struct {
+		keyID int64
+		model string
+		want  bool
+	}{
+		{1, "claude-3-5-sonnet", false}, // default
+		{1, "gpt-4o", true},             // model rate
+		{7, "claude-3-5-sonnet", true},  // key rate
+		{8, "gpt-4o", false},            // key rate wins over model rate
+	}
+	for _, tt := range tests {
+		if got := l.Sample(tt.keyID, tt.model); got != tt.want {
+			t.Errorf("Sample(%d, %q) = %v, want %v", tt.keyID, tt.model, got, tt.want)
+		}
+	}
+
+	// Without a default every request is recorded; a nil logger records nothing
+	if !(&Logger{}).Sample(1, "any") {
+		t.Error("Expected requests to be sampled by default")
+	}
+	var nilLogger *Logger
+	if nilLogger.Sample(1, "any") {
+		t.Error("Expected a nil logger not to sample")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"missing dir", Config{Enabled: true}},
+		{"rate out of range", Config{Sampling: SamplingConfig{Models: map[string]float64{"m": 1.5}}}},
+		{"unknown action", Config{Redact: []RedactionRule{{Field: "request", Action: "drop"}}}},
+		{"no field or pattern", Config{Redact: []RedactionRule{{Action: ActionMask}}}},
+		{"remove with pattern", Config{Redact: []RedactionRule{{Pattern: "x", Action: ActionRemove}}}},
+		{"bad pattern", Config{Redact: []RedactionRule{{Pattern: "(", Action: ActionMask}}}},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", tt.name)
+		}
+	}
+
+	cfg := Config{Enabled: true, Dir: "/tmp"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Failed to validate: %v", err)
+	}
+	if cfg.MaxFileSizeMB != DefaultMaxFileSizeMB || cfg.MaxFiles != DefaultMaxFiles || cfg.BufferSize != DefaultBufferSize {
+		t.Errorf("Expected defaults, got %+v", cfg)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cfg := Config{Redact: []RedactionRule{
+		{Field: "request.messages.*.content", Action: ActionHash},
+		{Field: "request.user", Action: ActionRemove},
+		{Field: "completion", Action: ActionMask},
+		{Pattern: `[\w.]+@[\w.]+`, Action: ActionMask, Replacement: "<EMAIL>"},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Failed to validate: %v", err)
+	}
+	l := &Logger{cfg: cfg}
+
+	line, err := l.encode(&Record{
+		RequestID: "chatcmpl-1",
+		Request: map[string]any{
+			"user": "alice",
+			"messages": []any{
+				map[string]any{"role": "user", "content": "secret"},
+				map[string]any{"role": "user", "content": "secret"},
+			},
+		},
+		Completion: "the answer",
+		Error:      "rejected for bob@example.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	var got struct {
+		Request struct {
+			User     *string `json:"user"`
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		} `json:"request"`
+		Completion string `json:"completion"`
+		Error      string `json:"error"`
+	}
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatalf("Failed to decode %s: %v", line, err)
+	}
+
+	if got.Request.User != nil {
+		t.Errorf("Expected user to be removed, got %q", *got.Request.User)
+	}
+	first, second := got.Request.Messages[0].Content, got.Request.Messages[1].Content
+	if !strings.HasPrefix(first, "sha256:") || first != second {
+		t.Errorf("Expected equal hashes, got %q and %q", first, second)
+	}
+	if got.Request.Messages[0].Role != "user" {
+		t.Errorf("Expected role to be kept, got %q", got.Request.Messages[0].Role)
+	}
+	if got.Completion != "[REDACTED]" {
+		t.Errorf("Expected masked completion, got %q", got.Completion)
+	}
+	if got.Error != "rejected for <EMAIL>" {
+		t.Errorf("Expected masked email, got %q", got.Error)
+	}
+}
+
+func TestLoggerRotation(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(&Config{Enabled: true, Dir: dir, MaxFileSizeMB: 1, MaxFiles: 2})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	// Rotate after every couple of records
+	l.out.maxSize = 600
+
+	padding := strings.Repeat("x", 200)
+	for i := 0; i < 10; i++ {
+		l.Log(&Record{Timestamp: time.Now(), RequestID: "chatcmpl-1", Completion: padding})
+		// Rotated names have nanosecond resolution; keep them distinct
+		time.Sleep(time.Millisecond)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	l.Log(&Record{}) // ignored after Close
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "transcripts-*.jsonl"))
+	if len(rotated) != 2 {
+		t.Errorf("Expected 2 rotated files, got %d", len(rotated))
+	}
+
+	info, err := os.Stat(filepath.Join(dir, ActiveFile))
+	if err != nil {
+		t.Fatalf("Failed to stat active file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	file, _ := os.Open(filepath.Join(dir, ActiveFile))
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Completion != padding {
+			t.Errorf("Unexpected line %q: %v", scanner.Text(), err)
+		}
+	}
+}
+
+func TestLogNeverBlocks(t *testing.T) {
+	// No writer drains the queue
+	l := &Logger{records: make(chan *Record, 2)}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			l.Log(&Record{})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Log blocked on a full buffer")
+	}
+	if l.Dropped() != 3 {
+		t.Errorf("Expected 3 dropped records, got %d", l.Dropped())
+	}
+}
@@ -21,6 +21,7 @@ type ConverseRequest struct {
 	InferenceConfig  *InferenceConfig          `json:"inferenceConfig,omitempty"`
 	ToolConfig       *ToolConfig               `json:"toolConfig,omitempty"`
 	AdditionalModelRequestFields map[string]interface{} `json:"additionalModelRequestFields,omitempty"`
+	RequestMetadata  map[string]string         `json:"requestMetadata,omitempty"`
 }
 
 // ConverseMessage represents a message in Converse API
@@ -213,6 +214,7 @@ func TranslateOpenAIToConverseAPI(openaiReq *ChatCompletionRequest) (*providers.
 		System:          systemBlocks,
 		InferenceConfig: inferenceConfig,
 		ToolConfig:      toolConfig,
+		RequestMetadata: openaiReq.Metadata,
 	}
 
 	// Marshal to JSON
@@ -223,8 +225,10 @@ func TranslateOpenAIToConverseAPI(openaiReq *ChatCompletionRequest) (*providers.
 
 	// Build provider request
 	path := fmt.Sprintf("/model/%s/converse", bedrockModelID)
+	accept := "application/json"
 	if openaiReq.Stream {
 		path = fmt.Sprintf("/model/%s/converse-stream", bedrockModelID)
+		accept = "application/vnd.amazon.eventstream"
 	}
 
 	providerReq := &providers.ProviderRequest{
@@ -232,7 +236,7 @@ func TranslateOpenAIToConverseAPI(openaiReq *ChatCompletionRequest) (*providers.
 		Path:   path,
 		Headers: map[string]string{
 			"Content-Type": "application/json",
-			"Accept":       "application/json",
+			"Accept":       accept,
 		},
 		Body: body,
 	}
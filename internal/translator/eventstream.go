@@ -0,0 +1,232 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package translator
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Bedrock streams use the AWS event stream encoding: each message is a
+// prelude (total length, headers length, CRC), typed headers, a JSON
+// payload and a trailing CRC of the whole message.
+
+const (
+	eventStreamPreludeLen = 12
+	eventStreamMaxMessage = 16 << 20
+)
+
+// EventStreamMessage is one decoded event stream message
+type EventStreamMessage struct {
+	Headers map[string]string
+	Payload []byte
+}
+
+// EventStreamReader reads AWS event stream messages
+type EventStreamReader struct {
+	r io.Reader
+}
+
+// NewEventStreamReader reads event stream messages from r
+func NewEventStreamReader(r io.Reader) *EventStreamReader {
+	return &EventStreamReader{r: r}
+}
+
+// Next returns the next message, or io.EOF at the end of the stream
+func (r *EventStreamReader) Next() (*EventStreamMessage, error) {
+	prelude := make([]byte, eventStreamPreludeLen)
+	if _, err := io.ReadFull(r.r, prelude); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated event stream prelude")
+		}
+		return nil, err
+	}
+
+	// Lengths are checked in 64 bits so a huge headers length cannot wrap
+	totalLen := uint64(binary.BigEndian.Uint32(prelude[0:4]))
+	headersLen := uint64(binary.BigEndian.Uint32(prelude[4:8]))
+	if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, fmt.Errorf("event stream prelude checksum mismatch")
+	}
+	if totalLen > eventStreamMaxMessage || totalLen < eventStreamPreludeLen+headersLen+4 {
+		return nil, fmt.Errorf("invalid event stream message length %d (headers %d)", totalLen, headersLen)
+	}
+
+	message := make([]byte, totalLen)
+	copy(message, prelude)
+	if _, err := io.ReadFull(r.r, message[eventStreamPreludeLen:]); err != nil {
+		return nil, fmt.Errorf("truncated event stream message: %w", err)
+	}
+	crcOffset := totalLen - 4
+	if crc32.ChecksumIEEE(message[:crcOffset]) != binary.BigEndian.Uint32(message[crcOffset:]) {
+		return nil, fmt.Errorf("event stream message checksum mismatch")
+	}
+
+	headersEnd := eventStreamPreludeLen + headersLen
+	headers, err := decodeEventStreamHeaders(message[eventStreamPreludeLen:headersEnd])
+	if err != nil {
+		return nil, err
+	}
+	return &EventStreamMessage{Headers: headers, Payload: message[headersEnd:crcOffset]}, nil
+}
+
+// decodeEventStreamHeaders keeps string headers and skips other value types
+func decodeEventStreamHeaders(b []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	errTruncated := errors.New("truncated event stream header")
+
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		if len(b) < 1+nameLen+1 {
+			return nil, errTruncated
+		}
+		name := string(b[1 : 1+nameLen])
+		valueType := b[1+nameLen]
+		b = b[2+nameLen:]
+
+		var size int
+		switch valueType {
+		case 0, 1: // bool true, bool false
+			size = 0
+		case 2: // byte
+			size = 1
+		case 3: // int16
+			size = 2
+		case 4: // int32
+			size = 4
+		case 5, 8: // int64, timestamp
+			size = 8
+		case 9: // uuid
+			size = 16
+		case 6, 7: // bytes, string
+			if len(b) < 2 {
+				return nil, errTruncated
+			}
+			n := int(binary.BigEndian.Uint16(b))
+			if len(b) < 2+n {
+				return nil, errTruncated
+			}
+			if valueType == 7 {
+				headers[name] = string(b[2 : 2+n])
+			}
+			b = b[2+n:]
+			continue
+		default:
+			return nil, fmt.Errorf("unknown event stream header type %d", valueType)
+		}
+		if len(b) < size {
+			return nil, errTruncated
+		}
+		b = b[size:]
+	}
+
+	return headers, nil
+}
+
+// converseStreamDecoder translates ConverseStream events into chunks
+type converseStreamDecoder struct {
+	messages *EventStreamReader
+	// toolIndex maps content block indexes to OpenAI tool call indexes
+	toolIndex map[int]int
+}
+
+// NewConverseStreamDecoder decodes a Bedrock ConverseStream response
+func NewConverseStreamDecoder(r io.Reader) StreamDecoder {
+	return &converseStreamDecoder{
+		messages:  NewEventStreamReader(r),
+		toolIndex: make(map[int]int),
+	}
+}
+
+// converseStreamEvent covers the payloads of all ConverseStream event types
+type converseStreamEvent struct {
+	Role              string `json:"role"`
+	ContentBlockIndex int    `json:"contentBlockIndex"`
+	Start             *struct {
+		ToolUse *struct {
+			ToolUseID string `json:"toolUseId"`
+			Name      string `json:"name"`
+		} `json:"toolUse"`
+	} `json:"start"`
+	Delta *struct {
+		Text    string `json:"text"`
+		ToolUse *struct {
+			Input string `json:"input"`
+		} `json:"toolUse"`
+	} `json:"delta"`
+	StopReason string         `json:"stopReason"`
+	Usage      *ConverseUsage `json:"usage"`
+	Message    string         `json:"message"`
+}
+
+func (d *converseStreamDecoder) Next() (*ChatCompletionStreamResponse, error) {
+	for {
+		msg, err := d.messages.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch msg.Headers[":message-type"] {
+		case "exception":
+			var event converseStreamEvent
+			json.Unmarshal(msg.Payload, &event)
+			return nil, fmt.Errorf("%s: %s", msg.Headers[":exception-type"], event.Message)
+		case "error":
+			return nil, fmt.Errorf("%s: %s", msg.Headers[":error-code"], msg.Headers[":error-message"])
+		}
+
+		var event converseStreamEvent
+		if err := json.Unmarshal(msg.Payload, &event); err != nil {
+			return nil, fmt.Errorf("invalid %s event: %w", msg.Headers[":event-type"], err)
+		}
+
+		switch msg.Headers[":event-type"] {
+		case "messageStart":
+			return NewStreamChunk(ChatMessageDelta{Role: event.Role}, ""), nil
+
+		case "contentBlockStart":
+			if event.Start == nil || event.Start.ToolUse == nil {
+				continue
+			}
+			index := len(d.toolIndex)
+			d.toolIndex[event.ContentBlockIndex] = index
+			return NewStreamChunk(ChatMessageDelta{ToolCalls: []ToolCall{{
+				Index:    &index,
+				ID:       event.Start.ToolUse.ToolUseID,
+				Type:     "function",
+				Function: FunctionCall{Name: event.Start.ToolUse.Name},
+			}}}, ""), nil
+
+		case "contentBlockDelta":
+			if event.Delta == nil {
+				continue
+			}
+			if event.Delta.ToolUse != nil {
+				index := d.toolIndex[event.ContentBlockIndex]
+				return NewStreamChunk(ChatMessageDelta{ToolCalls: []ToolCall{{
+					Index:    &index,
+					Function: FunctionCall{Arguments: event.Delta.ToolUse.Input},
+				}}}, ""), nil
+			}
+			if event.Delta.Text != "" {
+				return NewStreamChunk(ChatMessageDelta{Content: event.Delta.Text}, ""), nil
+			}
+
+		case "messageStop":
+			return NewStreamChunk(ChatMessageDelta{}, mapConverseStopReason(event.StopReason)), nil
+
+		case "metadata":
+			if event.Usage != nil {
+				return NewUsageChunk(Usage{
+					PromptTokens:     event.Usage.InputTokens,
+					CompletionTokens: event.Usage.OutputTokens,
+				}), nil
+			}
+		}
+	}
+}
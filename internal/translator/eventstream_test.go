@@ -0,0 +1,224 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package translator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// encodeEventStreamMessage builds an AWS event stream frame with string
+// headers, as Bedrock sends them
+func encodeEventStreamMessage(headers map[string]string, payload string) []byte {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var h bytes.Buffer
+	for _, name := range names {
+		h.WriteByte(byte(len(name)))
+		h.WriteString(name)
+		h.WriteByte(7) // string
+		binary.Write(&h, binary.BigEndian, uint16(len(headers[name])))
+		h.WriteString(headers[name])
+	}
+
+	totalLen := eventStreamPreludeLen + h.Len() + len(payload) + 4
+	msg := make([]byte, 0, totalLen)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(totalLen))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(h.Len()))
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+	msg = append(msg, h.Bytes()...)
+	msg = append(msg, payload...)
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
+
+func converseEvent(eventType, payload string) []byte {
+	return encodeEventStreamMessage(map[string]string{
+		":message-type": "event",
+		":event-type":   eventType,
+		":content-type": "application/json",
+	}, payload)
+}
+
+func TestEventStreamReader(t *testing.T) {
+	valid := converseEvent("messageStart", `{"role":"assistant"}`)
+
+	tests := []struct {
+		name    string
+		stream  func() []byte
+		wantErr string
+	}{
+		{
+			name:   "valid message",
+			stream: func() []byte { return valid },
+		},
+		{
+			name: "message checksum mismatch",
+			stream: func() []byte {
+				b := bytes.Clone(valid)
+				b[len(b)-6] ^= 0xff // payload byte
+				return b
+			},
+			wantErr: "message checksum mismatch",
+		},
+		{
+			name: "prelude checksum mismatch",
+			stream: func() []byte {
+				b := bytes.Clone(valid)
+				b[9] ^= 0xff
+				return b
+			},
+			wantErr: "prelude checksum mismatch",
+		},
+		{
+			name:    "truncated prelude",
+			stream:  func() []byte { return valid[:7] },
+			wantErr: "truncated event stream prelude",
+		},
+		{
+			name:    "truncated message",
+			stream:  func() []byte { return valid[:len(valid)-3] },
+			wantErr: "truncated event stream message",
+		},
+		{
+			name: "headers length wraps past the total length",
+			stream: func() []byte {
+				prelude := binary.BigEndian.AppendUint32(nil, 16)
+				prelude = binary.BigEndian.AppendUint32(prelude, 0xfffffff8)
+				prelude = binary.BigEndian.AppendUint32(prelude, crc32.ChecksumIEEE(prelude))
+				return binary.BigEndian.AppendUint32(prelude, crc32.ChecksumIEEE(prelude))
+			},
+			wantErr: "invalid event stream message length",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := NewEventStreamReader(bytes.NewReader(tt.stream())).Next()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to read message: %v", err)
+			}
+			if msg.Headers[":event-type"] != "messageStart" || string(msg.Payload) != `{"role":"assistant"}` {
+				t.Errorf("Unexpected message: %v %s", msg.Headers, msg.Payload)
+			}
+		})
+	}
+
+	t.Run("end of stream", func(t *testing.T) {
+		if _, err := NewEventStreamReader(bytes.NewReader(nil)).Next(); err != io.EOF {
+			t.Errorf("Expected io.EOF, got %v", err)
+		}
+	})
+}
+
+func TestConverseStreamDecoder(t *testing.T) {
+	var stream bytes.Buffer
+	for _, frame := range [][]byte{
+		converseEvent("messageStart", `{"role":"assistant"}`),
+		converseEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Checking "}}`),
+		converseEvent("contentBlockStart", `{"contentBlockIndex":1,"start":{"toolUse":{"toolUseId":"tooluse_1","name":"get_weather"}}}`),
+		converseEvent("contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"{\"city\":"}}}`),
+		converseEvent("contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"\"Paris\"}"}}}`),
+		converseEvent("contentBlockStop", `{"contentBlockIndex":1}`),
+		converseEvent("messageStop", `{"stopReason":"tool_use"}`),
+		converseEvent("metadata", `{"usage":{"inputTokens":12,"outputTokens":7,"totalTokens":19},"metrics":{"latencyMs":300}}`),
+	} {
+		stream.Write(frame)
+	}
+
+	// Deliver one byte at a time so every frame is split across reads
+	decoder := NewConverseStreamDecoder(iotest.OneByteReader(&stream))
+	var acc StreamAccumulator
+	for {
+		chunk, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to decode stream: %v", err)
+		}
+		acc.Add(chunk)
+	}
+
+	if acc.Chunks() != 7 {
+		t.Errorf("Expected 7 chunks, got %d", acc.Chunks())
+	}
+	resp := acc.Response()
+	if len(resp.Choices) != 1 {
+		t.Fatalf("Expected one choice, got %+v", resp.Choices)
+	}
+	choice := resp.Choices[0]
+	if choice.Message.Role != "assistant" || choice.Message.Content != "Checking " || choice.FinishReason != "tool_calls" {
+		t.Errorf("Unexpected choice: %+v", choice)
+	}
+	if len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("Expected one tool call, got %+v", choice.Message.ToolCalls)
+	}
+	call := choice.Message.ToolCalls[0]
+	if call.ID != "tooluse_1" || call.Function.Name != "get_weather" || call.Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("Expected the split tool call to be reassembled, got %+v", call)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 7 || resp.Usage.TotalTokens != 19 {
+		t.Errorf("Unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestConverseStreamDecoderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   []byte
+		wantErr string
+	}{
+		{
+			name: "exception",
+			frame: encodeEventStreamMessage(map[string]string{
+				":message-type":   "exception",
+				":exception-type": "throttlingException",
+			}, `{"message":"Too many requests"}`),
+			wantErr: "throttlingException: Too many requests",
+		},
+		{
+			name: "error",
+			frame: encodeEventStreamMessage(map[string]string{
+				":message-type":  "error",
+				":error-code":    "InternalFailure",
+				":error-message": "upstream failed",
+			}, ""),
+			wantErr: "InternalFailure: upstream failed",
+		},
+		{
+			name:    "invalid payload",
+			frame:   converseEvent("contentBlockDelta", `{"delta":`),
+			wantErr: "invalid contentBlockDelta event",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := converseEvent("messageStart", `{"role":"assistant"}`)
+			decoder := NewConverseStreamDecoder(bytes.NewReader(append(first, tt.frame...)))
+			if _, err := decoder.Next(); err != nil {
+				t.Fatalf("Failed to decode the first event: %v", err)
+			}
+			if _, err := decoder.Next(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
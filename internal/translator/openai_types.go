@@ -14,11 +14,13 @@ type ChatCompletionRequest struct {
 	TopP             float64                `json:"top_p,omitempty"`
 	N                int                    `json:"n,omitempty"`
 	Stream           bool                   `json:"stream,omitempty"`
+	StreamOptions    *StreamOptions         `json:"stream_options,omitempty"`
 	Stop             []string               `json:"stop,omitempty"`
 	PresencePenalty  float64                `json:"presence_penalty,omitempty"`
 	FrequencyPenalty float64                `json:"frequency_penalty,omitempty"`
 	LogitBias        map[string]int         `json:"logit_bias,omitempty"`
 	User             string                 `json:"user,omitempty"`
+	Metadata         map[string]string      `json:"metadata,omitempty"`
 	Functions        []Function             `json:"functions,omitempty"`
 	FunctionCall     interface{}            `json:"function_call,omitempty"`
 	Tools            []Tool                 `json:"tools,omitempty"`
@@ -70,11 +72,17 @@ type FunctionCall struct {
 
 // ToolCall represents a tool call
 type ToolCall struct {
-	ID       string       `json:"id"`
-	Type     string       `json:"type"` // function
+	Index    *int         `json:"index,omitempty"` // position within a streamed delta
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"` // function
 	Function FunctionCall `json:"function"`
 }
 
+// StreamOptions controls streamed responses
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage,omitempty"`
+}
+
 // ResponseFormat specifies the format of the response
 type ResponseFormat struct {
 	Type string `json:"type"` // text or json_object
@@ -127,6 +135,7 @@ type ChatCompletionStreamResponse struct {
 	Model             string                      `json:"model"`
 	SystemFingerprint string                      `json:"system_fingerprint,omitempty"`
 	Choices           []ChatCompletionStreamChoice `json:"choices"`
+	Usage             *Usage                      `json:"usage,omitempty"` // final chunk when usage is requested
 }
 
 // ChatCompletionStreamChoice represents a choice in a streaming response
@@ -0,0 +1,332 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package translator
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxSSELineSize bounds one server-sent event line (a single chunk)
+const maxSSELineSize = 1 << 20
+
+// SSEEvent is one server-sent event
+type SSEEvent struct {
+	Event string
+	Data  string
+}
+
+// SSEReader reads server-sent events
+type SSEReader struct {
+	scanner *bufio.Scanner
+}
+
+// NewSSEReader reads server-sent events from r
+func NewSSEReader(r io.Reader) *SSEReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+	return &SSEReader{scanner: scanner}
+}
+
+// Next returns the next event with data, or io.EOF at the end of the stream
+func (r *SSEReader) Next() (*SSEEvent, error) {
+	var event SSEEvent
+	var data []string
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				event.Data = strings.Join(data, "\n")
+				return &event, nil
+			}
+			event = SSEEvent{}
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// A final event without a trailing blank line
+	if len(data) > 0 {
+		event.Data = strings.Join(data, "\n")
+		return &event, nil
+	}
+	return nil, io.EOF
+}
+
+// StreamDecoder yields OpenAI chat completion chunks from a provider stream
+type StreamDecoder interface {
+	// Next returns the next chunk, or io.EOF at the end of the stream
+	Next() (*ChatCompletionStreamResponse, error)
+}
+
+// openAIStreamDecoder reads OpenAI-format server-sent events
+type openAIStreamDecoder struct {
+	events *SSEReader
+}
+
+// NewOpenAIStreamDecoder decodes an OpenAI-compatible SSE stream
+func NewOpenAIStreamDecoder(r io.Reader) StreamDecoder {
+	return &openAIStreamDecoder{events: NewSSEReader(r)}
+}
+
+func (d *openAIStreamDecoder) Next() (*ChatCompletionStreamResponse, error) {
+	for {
+		event, err := d.events.Next()
+		if err != nil {
+			return nil, err
+		}
+		if event.Data == "[DONE]" {
+			return nil, io.EOF
+		}
+
+		var chunk struct {
+			ChatCompletionStreamResponse
+			Error *ErrorDetail `json:"error,omitempty"`
+		}
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			return nil, fmt.Errorf("invalid stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, errors.New(chunk.Error.Message)
+		}
+		return &chunk.ChatCompletionStreamResponse, nil
+	}
+}
+
+// NewStreamChunk returns a chunk with a single choice. An empty
+// finishReason leaves the choice unfinished.
+func NewStreamChunk(delta ChatMessageDelta, finishReason string) *ChatCompletionStreamResponse {
+	choice := ChatCompletionStreamChoice{Delta: delta}
+	if finishReason != "" {
+		choice.FinishReason = &finishReason
+	}
+	return &ChatCompletionStreamResponse{
+		Object:  "chat.completion.chunk",
+		Choices: []ChatCompletionStreamChoice{choice},
+	}
+}
+
+// NewUsageChunk returns the final chunk reporting token usage
+func NewUsageChunk(usage Usage) *ChatCompletionStreamResponse {
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return &ChatCompletionStreamResponse{
+		Object:  "chat.completion.chunk",
+		Choices: []ChatCompletionStreamChoice{},
+		Usage:   &usage,
+	}
+}
+
+// WriteStreamChunk writes a chunk as a server-sent event
+func WriteStreamChunk(w io.Writer, chunk *ChatCompletionStreamResponse) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("failed to encode stream chunk: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+// WriteStreamError reports an error that occurred mid-stream
+func WriteStreamError(w io.Writer, detail ErrorDetail) error {
+	data, err := json.Marshal(ErrorResponse{Error: detail})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+// WriteStreamDone terminates an OpenAI-format stream
+func WriteStreamDone(w io.Writer) error {
+	_, err := io.WriteString(w, "data: [DONE]\n\n")
+	return err
+}
+
+// NewStreamPipe translates a native provider stream into OpenAI-format
+// server-sent events. translate reads body and calls emit for each chunk;
+// it runs in its own goroutine until it returns or the pipe is closed.
+func NewStreamPipe(body io.ReadCloser, translate func(emit func(*ChatCompletionStreamResponse) error) error) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		defer body.Close()
+		err := translate(func(chunk *ChatCompletionStreamResponse) error {
+			return WriteStreamChunk(pw, chunk)
+		})
+		if err == nil {
+			err = WriteStreamDone(pw)
+		} else {
+			// Surface the error in-band so the decoder reports it
+			WriteStreamError(pw, ErrorDetail{Message: err.Error(), Type: "api_error", Code: "stream_error"})
+			err = nil
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return &streamPipe{PipeReader: pr, body: body}
+}
+
+// streamPipe closes the upstream body with the pipe so translation stops
+type streamPipe struct {
+	*io.PipeReader
+	body      io.Closer
+	closeOnce sync.Once
+}
+
+func (p *streamPipe) Close() error {
+	p.closeOnce.Do(func() {
+		p.PipeReader.Close()
+		p.body.Close()
+	})
+	return nil
+}
+
+// StreamAccumulator reassembles streamed chunks into a complete response
+type StreamAccumulator struct {
+	id       string
+	model    string
+	created  int64
+	choices  map[int]*accumulatedChoice
+	usage    *Usage
+	chunks   int
+	hasChunk bool
+}
+
+type accumulatedChoice struct {
+	role         string
+	content      strings.Builder
+	toolCalls    map[int]*accumulatedToolCall
+	finishReason string
+}
+
+type accumulatedToolCall struct {
+	id        string
+	name      string
+	arguments strings.Builder
+}
+
+// Add merges a chunk into the response
+func (a *StreamAccumulator) Add(chunk *ChatCompletionStreamResponse) {
+	a.chunks++
+	if !a.hasChunk {
+		a.id, a.model, a.created = chunk.ID, chunk.Model, chunk.Created
+		a.hasChunk = true
+	}
+	if chunk.Usage != nil {
+		usage := *chunk.Usage
+		a.usage = &usage
+	}
+
+	for _, c := range chunk.Choices {
+		if a.choices == nil {
+			a.choices = make(map[int]*accumulatedChoice)
+		}
+		choice, ok := a.choices[c.Index]
+		if !ok {
+			choice = &accumulatedChoice{toolCalls: make(map[int]*accumulatedToolCall)}
+			a.choices[c.Index] = choice
+		}
+
+		if c.Delta.Role != "" {
+			choice.role = c.Delta.Role
+		}
+		choice.content.WriteString(c.Delta.Content)
+		for i, tc := range c.Delta.ToolCalls {
+			index := i
+			if tc.Index != nil {
+				index = *tc.Index
+			}
+			call, ok := choice.toolCalls[index]
+			if !ok {
+				call = &accumulatedToolCall{}
+				choice.toolCalls[index] = call
+			}
+			if tc.ID != "" {
+				call.id = tc.ID
+			}
+			if tc.Function.Name != "" {
+				call.name = tc.Function.Name
+			}
+			call.arguments.WriteString(tc.Function.Arguments)
+		}
+		if c.FinishReason != nil {
+			choice.finishReason = *c.FinishReason
+		}
+	}
+}
+
+// Chunks returns the number of chunks added
+func (a *StreamAccumulator) Chunks() int {
+	return a.chunks
+}
+
+// Response returns the reassembled response
+func (a *StreamAccumulator) Response() *ChatCompletionResponse {
+	resp := &ChatCompletionResponse{
+		ID:      a.id,
+		Object:  "chat.completion",
+		Created: a.created,
+		Model:   a.model,
+		Choices: []ChatCompletionChoice{},
+		Usage:   a.usage,
+	}
+
+	indexes := make([]int, 0, len(a.choices))
+	for index := range a.choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		choice := a.choices[index]
+		role := choice.role
+		if role == "" {
+			role = "assistant"
+		}
+		message := ChatMessage{Role: role, Content: choice.content.String()}
+
+		callIndexes := make([]int, 0, len(choice.toolCalls))
+		for i := range choice.toolCalls {
+			callIndexes = append(callIndexes, i)
+		}
+		sort.Ints(callIndexes)
+		for _, i := range callIndexes {
+			call := choice.toolCalls[i]
+			message.ToolCalls = append(message.ToolCalls, ToolCall{
+				ID:   call.id,
+				Type: "function",
+				Function: FunctionCall{
+					Name:      call.name,
+					Arguments: call.arguments.String(),
+				},
+			})
+		}
+
+		resp.Choices = append(resp.Choices, ChatCompletionChoice{
+			Index:        index,
+			Message:      message,
+			FinishReason: choice.finishReason,
+		})
+	}
+
+	return resp
+}
@@ -0,0 +1,266 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package translator
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSSEReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []SSEEvent
+	}{
+		{
+			name:   "single event",
+			stream: "data: {\"a\":1}\n\n",
+			want:   []SSEEvent{{Data: `{"a":1}`}},
+		},
+		{
+			name:   "named multi-line event",
+			stream: "event: message_start\ndata: line one\ndata: line two\n\n",
+			want:   []SSEEvent{{Event: "message_start", Data: "line one\nline two"}},
+		},
+		{
+			name:   "comments and empty events are skipped",
+			stream: ": keep-alive\n\nevent: ping\n\ndata: x\n\n",
+			want:   []SSEEvent{{Data: "x"}},
+		},
+		{
+			name:   "final event without trailing blank line",
+			stream: "data: first\n\ndata: last",
+			want:   []SSEEvent{{Data: "first"}, {Data: "last"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewSSEReader(iotest.OneByteReader(strings.NewReader(tt.stream)))
+			var got []SSEEvent
+			for {
+				event, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Failed to read event: %v", err)
+				}
+				got = append(got, *event)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   string
+		contents []string
+		wantErr  string
+	}{
+		{
+			name: "chunks until done",
+			stream: "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+				"data: [DONE]\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ignored\"}}]}\n\n",
+			contents: []string{"Hel", "lo"},
+		},
+		{
+			name: "error chunk",
+			stream: "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"error\":{\"message\":\"content filtered\",\"type\":\"invalid_request_error\"}}\n\n",
+			contents: []string{"Hel"},
+			wantErr:  "content filtered",
+		},
+		{
+			name:    "invalid chunk",
+			stream:  "data: {\"choices\":\n\n",
+			wantErr: "invalid stream chunk",
+		},
+		{
+			name: "truncated stream ends without done",
+			stream: "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"}}]}",
+			contents: []string{"Hel", "lo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := NewOpenAIStreamDecoder(strings.NewReader(tt.stream))
+			var contents []string
+			var err error
+			for {
+				var chunk *ChatCompletionStreamResponse
+				chunk, err = decoder.Next()
+				if err != nil {
+					break
+				}
+				contents = append(contents, chunk.Choices[0].Delta.Content)
+			}
+
+			if !reflect.DeepEqual(contents, tt.contents) {
+				t.Errorf("Expected contents %q, got %q", tt.contents, contents)
+			}
+			if tt.wantErr == "" {
+				if err != io.EOF {
+					t.Errorf("Expected io.EOF, got %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestStreamPipe(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr string
+	}{
+		{name: "translated chunks end with done"},
+		{name: "translation error is reported in-band", err: errors.New("upstream reset"), wantErr: "upstream reset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &closeRecorder{Reader: strings.NewReader("")}
+			pipe := NewStreamPipe(body, func(emit func(*ChatCompletionStreamResponse) error) error {
+				if err := emit(NewStreamChunk(ChatMessageDelta{Role: "assistant", Content: "Hi"}, "")); err != nil {
+					return err
+				}
+				if tt.err != nil {
+					return tt.err
+				}
+				if err := emit(NewStreamChunk(ChatMessageDelta{}, "stop")); err != nil {
+					return err
+				}
+				return emit(NewUsageChunk(Usage{PromptTokens: 3, CompletionTokens: 1}))
+			})
+			defer pipe.Close()
+
+			decoder := NewOpenAIStreamDecoder(pipe)
+			var acc StreamAccumulator
+			var err error
+			for {
+				var chunk *ChatCompletionStreamResponse
+				chunk, err = decoder.Next()
+				if err != nil {
+					break
+				}
+				acc.Add(chunk)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error %q, got %v", tt.wantErr, err)
+				}
+				if acc.Chunks() != 1 {
+					t.Errorf("Expected the chunk before the error, got %d", acc.Chunks())
+				}
+				return
+			}
+			if err != io.EOF {
+				t.Fatalf("Expected io.EOF, got %v", err)
+			}
+			resp := acc.Response()
+			if resp.Choices[0].Message.Content != "Hi" || resp.Choices[0].FinishReason != "stop" {
+				t.Errorf("Unexpected choice: %+v", resp.Choices[0])
+			}
+			if resp.Usage == nil || resp.Usage.TotalTokens != 4 {
+				t.Errorf("Expected usage totalling 4 tokens, got %+v", resp.Usage)
+			}
+		})
+	}
+
+	t.Run("closing the pipe closes the body", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader("")}
+		pipe := NewStreamPipe(body, func(emit func(*ChatCompletionStreamResponse) error) error {
+			for {
+				if err := emit(NewStreamChunk(ChatMessageDelta{Content: "x"}, "")); err != nil {
+					return err
+				}
+			}
+		})
+		pipe.Close()
+		if !body.closed.Load() {
+			t.Error("Expected the upstream body to be closed")
+		}
+	})
+}
+
+func TestStreamAccumulator(t *testing.T) {
+	index := func(i int) *int { return &i }
+
+	var acc StreamAccumulator
+	for _, chunk := range []*ChatCompletionStreamResponse{
+		{ID: "req-1", Model: "gpt-4", Created: 100, Choices: []ChatCompletionStreamChoice{
+			{Index: 1, Delta: ChatMessageDelta{Content: "second"}},
+			{Index: 0, Delta: ChatMessageDelta{Role: "assistant", Content: "fir"}},
+		}},
+		{ID: "ignored", Choices: []ChatCompletionStreamChoice{{Index: 0, Delta: ChatMessageDelta{
+			Content: "st",
+			ToolCalls: []ToolCall{
+				{Index: index(1), ID: "call_b", Function: FunctionCall{Name: "b", Arguments: "{}"}},
+				{Index: index(0), ID: "call_a", Function: FunctionCall{Name: "a", Arguments: `{"x":`}},
+			},
+		}}}},
+		{Choices: []ChatCompletionStreamChoice{{Index: 0, Delta: ChatMessageDelta{
+			ToolCalls: []ToolCall{{Index: index(0), Function: FunctionCall{Arguments: "1}"}}},
+		}}}},
+		NewStreamChunk(ChatMessageDelta{}, "tool_calls"),
+	} {
+		acc.Add(chunk)
+	}
+
+	resp := acc.Response()
+	if resp.ID != "req-1" || resp.Model != "gpt-4" || resp.Created != 100 {
+		t.Errorf("Expected identity from the first chunk, got %q %q %d", resp.ID, resp.Model, resp.Created)
+	}
+	if len(resp.Choices) != 2 {
+		t.Fatalf("Expected two choices, got %+v", resp.Choices)
+	}
+
+	first, second := resp.Choices[0], resp.Choices[1]
+	if first.Message.Content != "first" || first.FinishReason != "tool_calls" {
+		t.Errorf("Unexpected first choice: %+v", first)
+	}
+	if second.Index != 1 || second.Message.Role != "assistant" || second.Message.Content != "second" {
+		t.Errorf("Unexpected second choice: %+v", second)
+	}
+
+	calls := first.Message.ToolCalls
+	if len(calls) != 2 {
+		t.Fatalf("Expected two tool calls, got %+v", calls)
+	}
+	if calls[0].ID != "call_a" || calls[0].Function.Arguments != `{"x":1}` || calls[1].ID != "call_b" {
+		t.Errorf("Expected tool calls ordered by index with arguments joined, got %+v", calls)
+	}
+	if resp.Usage != nil {
+		t.Errorf("Expected no usage, got %+v", resp.Usage)
+	}
+}
+
+// closeRecorder is an upstream body that records being closed
+type closeRecorder struct {
+	io.Reader
+	closed atomic.Bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed.Store(true)
+	return nil
+}
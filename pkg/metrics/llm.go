@@ -0,0 +1,180 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Request outcomes
+const (
+	OutcomeSuccess     = "success"
+	OutcomeError       = "error"        // the provider returned an error
+	OutcomeStreamError = "stream_error" // the stream failed after it started
+	OutcomeBlocked     = "blocked"      // rejected by the proxy after routing
+	OutcomeCanceled    = "canceled"     // the client went away
+)
+
+// Latency histograms leave out the tenant label to bound their cardinality
+var (
+	// LLMRequests counts chat completions by provider, model, tenant and outcome
+	LLMRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_proxy_requests_total",
+			Help: "Total number of chat completion requests by provider, model, tenant and outcome",
+		},
+		[]string{"provider", "model", "tenant", "outcome"},
+	)
+
+	// LLMRequestDuration tracks end-to-end chat completion latency
+	LLMRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_proxy_request_duration_seconds",
+			Help:    "Duration of chat completion requests in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 12), // 50ms to ~100s
+		},
+		[]string{"provider", "model", "outcome"},
+	)
+
+	// TimeToFirstToken tracks how long streams take to produce content
+	TimeToFirstToken = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_proxy_time_to_first_token_seconds",
+			Help:    "Time from request to the first streamed content in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.025, 2, 12), // 25ms to ~50s
+		},
+		[]string{"provider", "model"},
+	)
+
+	// InterTokenLatency tracks the gap between streamed content chunks
+	InterTokenLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_proxy_inter_token_latency_seconds",
+			Help:    "Time between successive streamed content chunks in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 13), // 1ms to ~4s
+		},
+		[]string{"provider", "model"},
+	)
+
+	// LLMTokens counts tokens by provider, model and tenant
+	LLMTokens = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_proxy_tokens_total",
+			Help: "Total number of tokens by provider, model, tenant and type",
+		},
+		[]string{"provider", "model", "tenant", "type"}, // type: input/output
+	)
+
+	// LLMCost accumulates the estimated cost of completions
+	LLMCost = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_proxy_cost_usd_total",
+			Help: "Total estimated cost of chat completions in USD",
+		},
+		[]string{"provider", "model", "tenant"},
+	)
+
+	// ProviderFallbacks counts requests routed away from a model's default provider
+	ProviderFallbacks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_proxy_provider_fallbacks_total",
+			Help: "Total number of requests routed to a fallback provider",
+		},
+		[]string{"model", "from", "to"},
+	)
+
+	// UpstreamResponses counts provider responses by status code
+	UpstreamResponses = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_proxy_upstream_responses_total",
+			Help: "Total number of provider responses by status code",
+		},
+		[]string{"provider", "status_code"}, // status_code is "error" when no response arrived
+	)
+
+	// ModelCheckAvailable tracks synthetic model check results
+	ModelCheckAvailable = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ai_proxy_model_check_available",
+			Help: "Whether a model answers synthetic checks on a provider (1 = available, 0 = unavailable)",
+		},
+		[]string{"provider", "model"},
+	)
+
+	// ModelCheckDuration tracks synthetic model check latency
+	ModelCheckDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ai_proxy_model_check_duration_seconds",
+			Help:    "Synthetic model check latency in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 10), // 50ms to ~25s
+		},
+		[]string{"provider", "model"},
+	)
+)
+
+// Labels identify the provider, model and tenant of a request
+type Labels struct {
+	Provider string
+	Model    string
+	Tenant   string // team name or API key ID; empty when not tracked
+}
+
+// RecordLLMRequest records a finished chat completion
+func RecordLLMRequest(l Labels, outcome string, duration time.Duration) {
+	LLMRequests.WithLabelValues(l.Provider, l.Model, l.Tenant, outcome).Inc()
+	LLMRequestDuration.WithLabelValues(l.Provider, l.Model, outcome).Observe(duration.Seconds())
+}
+
+// RecordTimeToFirstToken records when a stream produced its first content
+func RecordTimeToFirstToken(l Labels, d time.Duration) {
+	TimeToFirstToken.WithLabelValues(l.Provider, l.Model).Observe(d.Seconds())
+}
+
+// RecordInterTokenLatency records the gap before a streamed content chunk
+func RecordInterTokenLatency(l Labels, d time.Duration) {
+	InterTokenLatency.WithLabelValues(l.Provider, l.Model).Observe(d.Seconds())
+}
+
+// RecordTokens records a completion's token usage
+func RecordTokens(l Labels, input, output int) {
+	LLMTokens.WithLabelValues(l.Provider, l.Model, l.Tenant, "input").Add(float64(input))
+	LLMTokens.WithLabelValues(l.Provider, l.Model, l.Tenant, "output").Add(float64(output))
+}
+
+// RecordCost records a completion's estimated cost
+func RecordCost(l Labels, usd float64) {
+	if usd > 0 {
+		LLMCost.WithLabelValues(l.Provider, l.Model, l.Tenant).Add(usd)
+	}
+}
+
+// RecordFallback records a request for model routed from one provider to another
+func RecordFallback(model, from, to string) {
+	ProviderFallbacks.WithLabelValues(model, from, to).Inc()
+}
+
+// RecordUpstreamStatus records a provider response; status 0 means the
+// call failed before a response arrived
+func RecordUpstreamStatus(provider string, status int) {
+	code := "error"
+	if status != 0 {
+		code = strconv.Itoa(status)
+	}
+	UpstreamResponses.WithLabelValues(provider, code).Inc()
+}
+
+// RecordModelCheck records a synthetic model check and whether the model is
+// available after it
+func RecordModelCheck(provider, model string, available bool, d time.Duration) {
+	var value float64
+	if available {
+		value = 1
+	}
+	ModelCheckAvailable.WithLabelValues(provider, model).Set(value)
+	ModelCheckDuration.WithLabelValues(provider, model).Observe(d.Seconds())
+}
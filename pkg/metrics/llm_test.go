@@ -0,0 +1,145 @@
+// Copyright 2025 Bedrock Proxy Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// histogramSamples returns the sample count and sum of one histogram series
+func histogramSamples(t *testing.T, observer prometheus.Observer) (uint64, float64) {
+	t.Helper()
+
+	var m dto.Metric
+	if err := observer.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("Failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+func TestRecordLLMRequest(t *testing.T) {
+	LLMRequests.Reset()
+	LLMRequestDuration.Reset()
+
+	labels := Labels{Provider: "bedrock", Model: "claude-3-sonnet", Tenant: "ml-platform"}
+	RecordLLMRequest(labels, OutcomeSuccess, 2*time.Second)
+	RecordLLMRequest(labels, OutcomeSuccess, time.Second)
+	RecordLLMRequest(labels, OutcomeStreamError, 500*time.Millisecond)
+	RecordLLMRequest(Labels{Provider: "bedrock", Model: "claude-3-sonnet", Tenant: "42"}, OutcomeSuccess, time.Second)
+
+	if got := testutil.ToFloat64(LLMRequests.WithLabelValues("bedrock", "claude-3-sonnet", "ml-platform", OutcomeSuccess)); got != 2 {
+		t.Errorf("Expected 2 successful requests, got %v", got)
+	}
+	if got := testutil.ToFloat64(LLMRequests.WithLabelValues("bedrock", "claude-3-sonnet", "ml-platform", OutcomeStreamError)); got != 1 {
+		t.Errorf("Expected 1 stream error, got %v", got)
+	}
+	if got := testutil.CollectAndCount(LLMRequests); got != 3 {
+		t.Errorf("Expected a series per tenant and outcome, got %d", got)
+	}
+
+	// The latency histogram has no tenant label, so both tenants share a series
+	if got := testutil.CollectAndCount(LLMRequestDuration); got != 2 {
+		t.Errorf("Expected a duration series per outcome, got %d", got)
+	}
+	count, sum := histogramSamples(t, LLMRequestDuration.WithLabelValues("bedrock", "claude-3-sonnet", OutcomeSuccess))
+	if count != 3 || sum != 4 {
+		t.Errorf("Expected 3 samples summing to 4s, got %d and %v", count, sum)
+	}
+}
+
+func TestRecordStreamLatency(t *testing.T) {
+	TimeToFirstToken.Reset()
+	InterTokenLatency.Reset()
+
+	labels := Labels{Provider: "openai", Model: "gpt-4", Tenant: "7"}
+	RecordTimeToFirstToken(labels, 250*time.Millisecond)
+	RecordInterTokenLatency(labels, 20*time.Millisecond)
+	RecordInterTokenLatency(labels, 30*time.Millisecond)
+
+	count, sum := histogramSamples(t, TimeToFirstToken.WithLabelValues("openai", "gpt-4"))
+	if count != 1 || sum != 0.25 {
+		t.Errorf("Expected one TTFT sample of 0.25s, got %d and %v", count, sum)
+	}
+	count, sum = histogramSamples(t, InterTokenLatency.WithLabelValues("openai", "gpt-4"))
+	if count != 2 || sum < 0.0499 || sum > 0.0501 {
+		t.Errorf("Expected two inter-token samples summing to 0.05s, got %d and %v", count, sum)
+	}
+}
+
+func TestRecordTokensAndCost(t *testing.T) {
+	LLMTokens.Reset()
+	LLMCost.Reset()
+
+	labels := Labels{Provider: "anthropic", Model: "claude-3-haiku"}
+	RecordTokens(labels, 100, 20)
+	RecordTokens(labels, 50, 0)
+	RecordCost(labels, 0.25)
+	RecordCost(labels, 0)
+
+	if got := testutil.ToFloat64(LLMTokens.WithLabelValues("anthropic", "claude-3-haiku", "", "input")); got != 150 {
+		t.Errorf("Expected 150 input tokens, got %v", got)
+	}
+	if got := testutil.ToFloat64(LLMTokens.WithLabelValues("anthropic", "claude-3-haiku", "", "output")); got != 20 {
+		t.Errorf("Expected 20 output tokens, got %v", got)
+	}
+	if got := testutil.ToFloat64(LLMCost.WithLabelValues("anthropic", "claude-3-haiku", "")); got != 0.25 {
+		t.Errorf("Expected a cost of 0.25, got %v", got)
+	}
+
+	// A zero cost does not create a series
+	RecordCost(Labels{Provider: "ibm", Model: "granite"}, 0)
+	if got := testutil.CollectAndCount(LLMCost); got != 1 {
+		t.Errorf("Expected only the priced series, got %d", got)
+	}
+}
+
+func TestRecordFallback(t *testing.T) {
+	ProviderFallbacks.Reset()
+
+	RecordFallback("claude-3-sonnet", "bedrock", "anthropic")
+
+	if got := testutil.ToFloat64(ProviderFallbacks.WithLabelValues("claude-3-sonnet", "bedrock", "anthropic")); got != 1 {
+		t.Errorf("Expected 1 fallback, got %v", got)
+	}
+}
+
+func TestRecordUpstreamStatus(t *testing.T) {
+	UpstreamResponses.Reset()
+
+	RecordUpstreamStatus("azure", 200)
+	RecordUpstreamStatus("azure", 200)
+	RecordUpstreamStatus("azure", 429)
+	RecordUpstreamStatus("azure", 0)
+
+	for code, want := range map[string]float64{"200": 2, "429": 1, "error": 1} {
+		if got := testutil.ToFloat64(UpstreamResponses.WithLabelValues("azure", code)); got != want {
+			t.Errorf("Expected %v responses with status %s, got %v", want, code, got)
+		}
+	}
+	if got := testutil.CollectAndCount(UpstreamResponses); got != 3 {
+		t.Errorf("Expected no series for status 0, got %d series", got)
+	}
+}
+
+func TestRecordModelCheck(t *testing.T) {
+	ModelCheckAvailable.Reset()
+	ModelCheckDuration.Reset()
+
+	RecordModelCheck("vertex", "gemini-pro", true, time.Second)
+	if got := testutil.ToFloat64(ModelCheckAvailable.WithLabelValues("vertex", "gemini-pro")); got != 1 {
+		t.Errorf("Expected the model available, got %v", got)
+	}
+	RecordModelCheck("vertex", "gemini-pro", false, 2*time.Second)
+	if got := testutil.ToFloat64(ModelCheckAvailable.WithLabelValues("vertex", "gemini-pro")); got != 0 {
+		t.Errorf("Expected the model unavailable, got %v", got)
+	}
+	if count, _ := histogramSamples(t, ModelCheckDuration.WithLabelValues("vertex", "gemini-pro")); count != 2 {
+		t.Errorf("Expected 2 check durations, got %d", count)
+	}
+}
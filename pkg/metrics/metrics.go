@@ -96,6 +96,33 @@ var (
 		},
 		[]string{"check_type"}, // health, readiness
 	)
+
+	// TranscriptRecords tracks transcript records by outcome
+	TranscriptRecords = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bedrock_proxy_transcript_records_total",
+			Help: "Total number of transcript records written, dropped or failed",
+		},
+		[]string{"result"}, // written, dropped, failed
+	)
+
+	// DLPDetections tracks PII values detected in prompts
+	DLPDetections = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bedrock_proxy_dlp_detections_total",
+			Help: "Total number of PII values detected in prompts",
+		},
+		[]string{"detector", "action"}, // action: redact, block
+	)
+
+	// WebhookDeliveries tracks webhook notification deliveries by outcome
+	WebhookDeliveries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bedrock_proxy_webhook_deliveries_total",
+			Help: "Total number of webhook notification deliveries by outcome",
+		},
+		[]string{"destination", "result"}, // delivered, retried, dead_lettered, dropped
+	)
 )
 
 // Init initializes metrics (can be used for custom setup if needed)
@@ -110,6 +137,8 @@ func RecordModelInvocation(modelID, status string) {
 }
 
 // RecordTokensProcessed records tokens processed by a model
+//
+// Deprecated: use RecordTokens, which labels tokens by provider and tenant
 func RecordTokensProcessed(modelID, tokenType string, count int) {
 	BedrockTokensProcessed.WithLabelValues(modelID, tokenType).Add(float64(count))
 }
@@ -119,6 +148,11 @@ func RecordCredentialRetrieval(method, status string) {
 	AWSCredentialRetrievals.WithLabelValues(method, status).Inc()
 }
 
+// RecordWebhookDelivery records the outcome of a webhook delivery attempt
+func RecordWebhookDelivery(destination, result string) {
+	WebhookDeliveries.WithLabelValues(destination, result).Inc()
+}
+
 // SetHealthStatus sets health check status
 func SetHealthStatus(checkType string, healthy bool) {
 	var value float64